@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,10 +14,532 @@ type CrawlerConfig struct {
 	MaxDepth        int      `yaml:"max_depth"`
 	DelayMs         int64    `yaml:"delay_ms"`
 	MaxConcurrency  int      `yaml:"max_concurrency"`
-	UserAgents      []string `yaml:"user_agents"`
 	AdLinkPatterns  []string `yaml:"ad_link_patterns"`
 	ContentTags     []string `yaml:"content_tags"`
 	ExcludedDomains []string `yaml:"excluded_domains"`
+	SeedFile        string   `yaml:"seed_file"`
+	FailedURLsFile  string   `yaml:"failed_urls_file"`
+	GlobalRateLimit float64  `yaml:"global_rate_limit"` // requests per second across all workers, robots.txt and sitemaps included, 0 = unlimited
+	GlobalRateBurst int      `yaml:"global_rate_burst"`
+	ExtractTables   bool     `yaml:"extract_tables"`
+	MaxHosts        int      `yaml:"max_hosts"`       // 0 = unlimited
+	RedirectPolicy  string   `yaml:"redirect_policy"` // follow_and_store (default), follow_only or skip
+	MaxRedirects    int      `yaml:"max_redirects"`   // redirects followed per fetch with follow_and_store, default 5
+	Mode            string   `yaml:"mode"`            // crawl (default), fetch_list or verify_links
+	LinkSource      string   `yaml:"link_source"`     // html (default), sitemap or both
+	TrailingSlash   string   `yaml:"trailing_slash"`  // keep (default) or strip
+	ContentFormat   string   `yaml:"content_format"`  // plaintext (default) or markdown
+	UseSitemaps     bool     `yaml:"use_sitemaps"`
+	ContentCutoff   string   `yaml:"content_cutoff"` // e.g. "7d", "48h" or "2024-01-01"; empty disables
+
+	// SeedRequests are seeds fetched with a method other than GET and
+	// optionally a body, e.g. list pages filtered by a POSTed form. Links
+	// found on them are fetched with GET. Each URL is still fetched once per
+	// crawl, so requests with the same URL but different bodies are skipped
+	// after the first.
+	SeedRequests []SeedRequest `yaml:"seed_requests"`
+
+	// LinkReportFile is where verify_links mode writes its CSV report of
+	// broken and redirected links, default link_report.csv. With
+	// VerifyExternalLinks, links to other hosts are checked too, with a HEAD
+	// request, but not followed.
+	LinkReportFile      string `yaml:"link_report_file"`
+	VerifyExternalLinks bool   `yaml:"verify_external_links"`
+
+	// TreatWWWAsSame tracks www.example.com and example.com as one host for
+	// visited URLs, same-host link scoping and the robots.txt cache.
+	TreatWWWAsSame bool `yaml:"treat_www_as_same"`
+
+	// CaseInsensitivePaths lists hosts whose servers ignore path case, so
+	// /Page and /page are tracked as one visited URL. Listing a case-sensitive
+	// host merges distinct pages and skips all but the first one found.
+	CaseInsensitivePaths []string `yaml:"case_insensitive_paths"`
+
+	// UserAgents are picked at random for each fetch, in proportion to their
+	// weights. Entries are either a plain string (weight 1) or an
+	// {agent, weight} mapping.
+	UserAgents []UserAgent `yaml:"user_agents"`
+
+	// FingerprintProfiles replace UserAgents with coherent bundles of a user
+	// agent and the headers a browser sending it would send, picked together
+	// by weight for each fetch. Entries are either the name of a built-in
+	// profile or a {name, user_agent, headers, weight} mapping.
+	FingerprintProfiles []FingerprintProfile `yaml:"fingerprint_profiles"`
+
+	FrontierPolicy  string `yaml:"frontier_policy"`    // priority (default) or host_round_robin
+	MaxPagesPerHost int    `yaml:"max_pages_per_host"` // 0 = unlimited
+
+	// RobotsOverrides replaces robots.txt for explicitly listed hosts (host or
+	// host:port). Only use it for sites you have permission to crawl.
+	RobotsOverrides map[string]RobotsOverride `yaml:"robots_overrides"`
+
+	// RobotsFailurePolicy decides what happens to a host whose robots.txt
+	// can't be fetched (network error, 429 or 5xx response): allow (default)
+	// crawls it as if robots.txt allowed everything, deny skips its pages,
+	// and retry retries the fetch RobotsMaxRetries times with a backoff
+	// starting at RobotsRetryBackoffMs and doubling each time, then skips
+	// its pages. Allowed and denied hosts are retried after a minute. A
+	// missing robots.txt (other 4xx responses) always allows everything.
+	RobotsFailurePolicy  string `yaml:"robots_failure_policy"`
+	RobotsMaxRetries     int    `yaml:"robots_max_retries"`
+	RobotsRetryBackoffMs int    `yaml:"robots_retry_backoff_ms"`
+
+	// FetchSecurityTxt records each host's /.well-known/security.txt contacts
+	// in the crawl summary; HonorCrawlDelayHeaders slows down hosts that send
+	// an X-Crawl-Delay or Crawl-Delay response header.
+	FetchSecurityTxt       bool `yaml:"fetch_security_txt"`
+	HonorCrawlDelayHeaders bool `yaml:"honor_crawl_delay_headers"`
+
+	// MaxGoroutines, MaxHeapMB and MaxOpenConns pause new fetches while the
+	// process exceeds them, resuming once usage falls back below 90% of every
+	// limit. 0 disables a limit; usage is sampled every GovernorIntervalMs.
+	MaxGoroutines      int `yaml:"max_goroutines"`
+	MaxHeapMB          int `yaml:"max_heap_mb"`
+	MaxOpenConns       int `yaml:"max_open_conns"`
+	GovernorIntervalMs int `yaml:"governor_interval_ms"`
+
+	// CrawlWindows restricts fetching to daily time windows, e.g. off-peak
+	// hours. Outside them workers finish the pages they are fetching and
+	// wait for the next window, keeping the frontier. Times are in
+	// ScheduleTimezone, an IANA name such as "Asia/Seoul", or local time.
+	CrawlWindows     []CrawlWindow `yaml:"crawl_windows"`
+	ScheduleTimezone string        `yaml:"schedule_timezone"`
+
+	// StateFile, if set, is rewritten atomically with a JSON snapshot of the
+	// crawl's progress every StateIntervalSec and once more, with the final
+	// summary, when the crawl ends.
+	StateFile        string `yaml:"state_file"`
+	StateIntervalSec int    `yaml:"state_interval_sec"`
+
+	// FrontierFile, if set, is rewritten atomically with the pending tasks
+	// and visited URLs every FrontierSnapshotIntervalSec and when the crawl
+	// ends. A crawl started with pending tasks in it resumes from them
+	// instead of the seeds.
+	FrontierFile                string `yaml:"frontier_file"`
+	FrontierSnapshotIntervalSec int    `yaml:"frontier_snapshot_interval_sec"`
+
+	// CheckpointFile, if set, is rewritten atomically with the crawl's full
+	// state (the frontier and visited URLs as in FrontierFile, redirects,
+	// per-host page counts and stats) every CheckpointIntervalSec, as soon
+	// as the crawl is cancelled, e.g. by SIGTERM, and when it ends. A crawl
+	// started with pending tasks in it resumes exactly where it stopped,
+	// unless it was saved with different seeds, scope or URL normalization
+	// settings, in which case a new crawl starts.
+	CheckpointFile        string `yaml:"checkpoint_file"`
+	CheckpointIntervalSec int    `yaml:"checkpoint_interval_sec"`
+
+	// WebhookURL, if set, receives a POST of the final crawl state as JSON,
+	// with status finished, cancelled or failed, when the crawl ends.
+	// WebhookHeaders are added to the request, e.g. for Authorization.
+	// Failed deliveries are retried WebhookMaxRetries times with a backoff
+	// starting at WebhookRetryBackoffMs and doubling each time.
+	WebhookURL            string            `yaml:"webhook_url"`
+	WebhookHeaders        map[string]string `yaml:"webhook_headers"`
+	WebhookMaxRetries     int               `yaml:"webhook_max_retries"`
+	WebhookRetryBackoffMs int               `yaml:"webhook_retry_backoff_ms"`
+
+	ProgressIntervalSec int    `yaml:"progress_interval_sec"` // 0 disables progress logging
+	RobotsUserAgent     string `yaml:"robots_user_agent"`     // agent used for all robots.txt decisions
+
+	DNSServers     []string `yaml:"dns_servers"` // host:port of custom resolvers; empty uses the system resolver
+	DNSCacheSize   int      `yaml:"dns_cache_size"`
+	DNSCacheTTLSec int      `yaml:"dns_cache_ttl_sec"`
+
+	// LocalAddresses are local source IPs that connections are bound to in
+	// turn (round_robin, the default) or at random (random), per
+	// LocalAddressSelection, to spread traffic over a multi-homed host's
+	// egress IPs. Connections are reused, so each one keeps its address.
+	// A failed dial is retried from the other addresses.
+	LocalAddresses        []string `yaml:"local_addresses"`
+	LocalAddressSelection string   `yaml:"local_address_selection"`
+
+	// HTTPProtocol is "auto" (default: HTTP/2 when offered over TLS),
+	// "http1" or "http2". ForceHTTP1Hosts always use HTTP/1.1.
+	HTTPProtocol    string   `yaml:"http_protocol"`
+	ForceHTTP1Hosts []string `yaml:"force_http1_hosts"`
+
+	// MaxConnsPerHost caps the TCP connections, dialing, in use or idle,
+	// open to one host at once (default 8, negative is unlimited), shared by
+	// page, robots.txt and sitemap fetches. Nothing else limits how many of
+	// the max_concurrency workers fetch from the same host at a time, so
+	// with more workers than this on one host the rest wait in the
+	// transport for a free connection, a wait that counts towards the
+	// request timeout; delay_ms and host_budget only pace when requests
+	// start. Over HTTP/2 one connection carries many requests.
+	MaxConnsPerHost int `yaml:"max_conns_per_host"`
+
+	// Meta refresh redirects (<meta http-equiv="refresh">) with a delay of at
+	// most MetaRefreshMaxDelaySec are followed like HTTP redirects.
+	FollowMetaRefresh      bool `yaml:"follow_meta_refresh"`
+	MetaRefreshMaxDelaySec int  `yaml:"meta_refresh_max_delay_sec"`
+	MetaRefreshSkipStore   bool `yaml:"meta_refresh_skip_store"` // don't store the refreshing page itself
+
+	// ComputeQualityScore stores a 0-1 quality_score per page; see crawler.QualityScore.
+	ComputeQualityScore bool           `yaml:"compute_quality_score"`
+	QualityWeights      QualityWeights `yaml:"quality_weights"`
+
+	// UseCookies shares one cookie jar across all fetches; CookieJarFile, if
+	// set, persists it between runs.
+	UseCookies    bool   `yaml:"use_cookies"`
+	CookieJarFile string `yaml:"cookie_jar_file"`
+
+	// Crawler trap detection; see crawler.trapDetector. Zero values disable
+	// the individual heuristics.
+	TrapDetection         bool    `yaml:"trap_detection"`
+	TrapMaxURLsPerPattern int     `yaml:"trap_max_urls_per_pattern"`
+	TrapMaxQueryParams    int     `yaml:"trap_max_query_params"`
+	TrapMinFetches        int     `yaml:"trap_min_fetches"`
+	TrapMinUsefulRatio    float64 `yaml:"trap_min_useful_ratio"`
+
+	// LinksFromMainContent follows only links inside the main content blocks
+	// instead of the whole page, skipping navigation and footer links.
+	LinksFromMainContent bool `yaml:"links_from_main_content"`
+
+	// Accept lists media types added in front of the default HTML Accept
+	// header on every fetch.
+	Accept []string `yaml:"accept"`
+
+	// ExtractionRules override the generic title, content and date
+	// extraction for matching hosts, keyed by host or "*.domain" pattern.
+	ExtractionRules map[string]ExtractionRule `yaml:"extraction_rules"`
+
+	// ParseFeeds stores each item of an RSS/Atom/JSON feed as its own
+	// document and queues the item links for crawling.
+	ParseFeeds bool `yaml:"parse_feeds"`
+
+	// ContentTypes maps response media types, exact or "type/*", to the
+	// extractor that handles them: html, feed, json, pdf, or none to skip a
+	// type registered by default. HTML is always registered, the feed types
+	// with ParseFeeds and application/json when an extraction rule has a
+	// json mapping. Responses of other types are skipped.
+	ContentTypes map[string]string `yaml:"content_types"`
+
+	// DedupeIdenticalBodies skips pages whose raw response body is
+	// byte-identical to one already crawled under another URL.
+	DedupeIdenticalBodies bool `yaml:"dedupe_identical_bodies"`
+
+	// ExtractHreflang stores <link rel="alternate" hreflang> links as
+	// hreflang_json; FollowHreflang also queues them for crawling.
+	ExtractHreflang bool `yaml:"extract_hreflang"`
+	FollowHreflang  bool `yaml:"follow_hreflang"`
+
+	// StoreOutboundAnchors stores up to MaxOutboundAnchors of a page's
+	// in-scope links with their anchor text as outbound_anchors_json.
+	StoreOutboundAnchors bool `yaml:"store_outbound_anchors"`
+	MaxOutboundAnchors   int  `yaml:"max_outbound_anchors"`
+
+	// StoreMetaTags stores all of a page's <meta> name or property to
+	// content pairs as meta_json, capped at milvus max_length_meta; it is an
+	// extended metadata field.
+	StoreMetaTags bool `yaml:"store_meta_tags"`
+
+	// ExtractBreadcrumbs stores a page's breadcrumb trail, from schema.org
+	// BreadcrumbList markup or a breadcrumb-named element, and its primary
+	// navigation menu as breadcrumbs_json; it is an extended metadata field.
+	ExtractBreadcrumbs bool `yaml:"extract_breadcrumbs"`
+
+	// HTMLStorage is always (default) or on_failure, which stores html_source
+	// only for pages with less than HTMLMinContentChars of main content.
+	HTMLStorage         string `yaml:"html_storage"`
+	HTMLMinContentChars int    `yaml:"html_min_content_chars"`
+
+	// HTMLTruncation is how HTML longer than milvus.max_length_html is
+	// stored: smart (default) drops scripts, styles and most of <head>, then
+	// the end of the body, keeping it well-formed; naive cuts it at the
+	// limit; skip doesn't store it. Which was applied is recorded in the
+	// html_truncation extended metadata field. HTML offloaded to a blob
+	// store is never truncated.
+	HTMLTruncation string `yaml:"html_truncation"`
+
+	// SanitizeFallback retries extraction on a sanitized, re-parsed copy of
+	// pages whose main content came out empty, recovering content from
+	// malformed markup such as an unclosed <title> or <textarea>.
+	SanitizeFallback bool `yaml:"sanitize_fallback"`
+
+	// StoreIfMatches stores only pages whose main content matches one of
+	// these regexes; SkipIfMatches skips pages matching any of its own.
+	// Links on skipped pages are still followed.
+	StoreIfMatches []string `yaml:"store_if_matches"`
+	SkipIfMatches  []string `yaml:"skip_if_matches"`
+
+	// AllowedLanguages stores only pages in these languages, e.g. ["ko",
+	// "en"]; "en" also allows "en-US". A page's language is its lang
+	// attribute, else its Content-Language header, else detected from its
+	// main content. UnknownLanguagePolicy is store (default) or skip for
+	// pages whose language can't be told. Links on skipped pages are still
+	// followed.
+	AllowedLanguages      []string `yaml:"allowed_languages"`
+	UnknownLanguagePolicy string   `yaml:"unknown_language_policy"`
+
+	// GatedPolicy enables detection of paywall and consent interstitials:
+	// skip doesn't store them, mark stores them with gated set. A page is
+	// gated when it matches one of GatedSelectors or GatedPatterns (regexes
+	// on the page text), or those of its extraction rule, or when its main
+	// content is shorter than GatedMaxContentChars (default 500, negative
+	// disables this check) and it matches a built-in paywall or consent
+	// marker. Links on gated pages are still followed.
+	GatedPolicy          string   `yaml:"gated_policy"`
+	GatedSelectors       []string `yaml:"gated_selectors"`
+	GatedPatterns        []string `yaml:"gated_patterns"`
+	GatedMaxContentChars int      `yaml:"gated_max_content_chars"`
+
+	// MaxDocumentBytes truncates response bodies, and MaxDOMNodes and
+	// MaxDOMDepth prune parsed pages, so a huge or deeply nested page can't
+	// stall a worker in extraction; the part that was kept is still
+	// extracted and stored. Negative values disable a limit.
+	MaxDocumentBytes int `yaml:"max_document_bytes"`
+	MaxDOMNodes      int `yaml:"max_dom_nodes"`
+	MaxDOMDepth      int `yaml:"max_dom_depth"`
+
+	// MaxTotalBytes stops the crawl once the response bodies it has fetched
+	// add up to more than this many bytes: no new pages are started, pages
+	// already being fetched finish and are stored, and the pages still
+	// queued are kept in the frontier and checkpoint files. 0 = unlimited.
+	MaxTotalBytes int64 `yaml:"max_total_bytes"`
+
+	// MinContentLength and MaxContentLength skip pages whose Content-Length
+	// header is outside the range before extraction; 0 disables a bound.
+	// Responses without the header are processed normally.
+	MinContentLength int64 `yaml:"min_content_length"`
+	MaxContentLength int64 `yaml:"max_content_length"`
+
+	// TextNormalization lists the normalization steps applied in order to
+	// main content and titles before hashing and storage: nfc, nfkc,
+	// ligatures, quotes, control_chars and whitespace. Unset means nfc and
+	// whitespace; an empty list disables normalization.
+	TextNormalization []string `yaml:"text_normalization"`
+
+	// HostBudget caps the requests to each host within a sliding window of
+	// HostBudgetWindowSec (default 3600); a host that uses it up is paused
+	// until the window frees up. HostBudgets overrides it per exact host or
+	// "*.domain" wildcard. 0 is unlimited.
+	HostBudget          int            `yaml:"host_budget"`
+	HostBudgetWindowSec int            `yaml:"host_budget_window_sec"`
+	HostBudgets         map[string]int `yaml:"host_budgets"`
+
+	// DetectChanges looks up the stored version of each page before storing
+	// it; if its content is unchanged only the stored crawled_at is updated,
+	// skipping embedding and storage. Needs a Milvus storer, and milvus
+	// extended_metadata to compare content_fingerprint rather than hash_id,
+	// which also changes with extraction_version.
+	DetectChanges bool `yaml:"detect_changes"`
+
+	// EmbedDocuments embeds each page while crawling. Otherwise documents
+	// are stored without vectors for the re-embedding job to fill in.
+	EmbedDocuments bool `yaml:"embed_documents"`
+
+	// EmbedConcurrency embeds and stores documents on this many workers of
+	// their own, fed through a queue of EmbedQueueSize documents (default
+	// 10 per worker), so slow embedding doesn't hold up the max_concurrency
+	// fetch workers. 0 embeds on the fetch workers. Each worker embeds the
+	// documents waiting in the queue together, up to EmbedBatchSize
+	// (default 16) per embedder call.
+	EmbedConcurrency int `yaml:"embed_concurrency"`
+	EmbedQueueSize   int `yaml:"embed_queue_size"`
+	EmbedBatchSize   int `yaml:"embed_batch_size"`
+
+	// Deterministic makes runs over the same fixtures reproducible: one
+	// worker, a seeded random source and links queued in sorted order.
+	Deterministic     bool  `yaml:"deterministic"`
+	DeterministicSeed int64 `yaml:"deterministic_seed"`
+
+	// StoreInboundLinks stores how many crawled pages link to each document;
+	// FinalizeInboundLinks raises the stored counts of the linked URLs to
+	// their final counts once the crawl ends. Counts are never lowered, so a
+	// partial recrawl keeps those of earlier crawls.
+	StoreInboundLinks    bool `yaml:"store_inbound_links"`
+	FinalizeInboundLinks bool `yaml:"finalize_inbound_links"`
+
+	// ExtractionVersion salts content hashes and is stored with each document;
+	// bump it after changing extraction logic to force pages to be re-stored.
+	ExtractionVersion string `yaml:"extraction_version"`
+
+	// StoreResponseHeaders stores each page's response headers, with the
+	// values of RedactedHeaders masked, as response_headers. The field is
+	// only part of the collection with it enabled.
+	StoreResponseHeaders bool     `yaml:"store_response_headers"`
+	RedactedHeaders      []string `yaml:"redacted_headers"`
+
+	// Focused crawling: links are prioritized by similarity to FocusTopic.
+	FocusTopic       string  `yaml:"focus_topic"`
+	FocusMinScore    float64 `yaml:"focus_min_score"`
+	FocusScoreSource string  `yaml:"focus_score_source"` // "anchor" (default) or "parent"
+
+	// NearDuplicateMode controls SimHash near-duplicate handling: "" (off), "skip" or "mark".
+	NearDuplicateMode        string `yaml:"near_duplicate_mode"`
+	NearDuplicateMaxDistance int    `yaml:"near_duplicate_max_distance"`
+
+	// TitleSources is the order the stored title is taken from: title,
+	// og_title, h1 and url_slug (the last path segment of the canonical or
+	// page URL), all of them by default. The first non-empty title that
+	// isn't generic, such as "Home", is used; an extraction rule's
+	// title_selector takes precedence.
+	TitleSources []string `yaml:"title_sources"`
+
+	// TitleDedup marks pages whose normalized title (lowercased, without the
+	// site name) shares at least TitleDedupMinSimilarity of its words with
+	// an earlier page's, on any host, as duplicate_of that page. They are
+	// still stored, unlike content near-duplicates in skip mode. Titles with
+	// fewer than TitleDedupMinWords words are not compared.
+	TitleDedup              bool    `yaml:"title_dedup"`
+	TitleDedupMinSimilarity float64 `yaml:"title_dedup_min_similarity"`
+	TitleDedupMinWords      int     `yaml:"title_dedup_min_words"`
+
+	// SemanticDedupMode searches the collection for a stored document whose
+	// content vector has at least SemanticDedupThreshold cosine similarity
+	// (default 0.95) to each new one before storing it: "" (off), "skip"
+	// doesn't store the new document, "mark" stores it as duplicate_of the
+	// stored one. It catches reworded duplicates that hashing misses, but
+	// needs embed_documents and costs a vector search per store; with
+	// embed_concurrency the documents of an embedding batch share one
+	// search, and are also compared with each other.
+	SemanticDedupMode      string  `yaml:"semantic_dedup_mode"`
+	SemanticDedupThreshold float64 `yaml:"semantic_dedup_threshold"`
+
+	// BoilerplateDetection removes blocks shared by most sampled pages of a
+	// host from main content extraction.
+	BoilerplateDetection   bool    `yaml:"boilerplate_detection"`
+	BoilerplateSamplePages int     `yaml:"boilerplate_sample_pages"`
+	BoilerplateMinRatio    float64 `yaml:"boilerplate_min_ratio"`
+}
+
+// SeedRequest is a seed_requests entry. Method defaults to GET and a Body is
+// only allowed for POST, PUT and PATCH. ContentType is the Content-Type of the
+// body, application/x-www-form-urlencoded by default.
+type SeedRequest struct {
+	URL         string `yaml:"url"`
+	Method      string `yaml:"method"`
+	Body        string `yaml:"body"`
+	ContentType string `yaml:"content_type"`
+}
+
+// CrawlWindow is a crawl_windows entry: fetching is allowed from Start until
+// End ("HH:MM") on Days ("mon" to "sun", every day if empty). A window whose
+// End is before its Start runs past midnight into the next day, and one whose
+// End equals its Start lasts all day.
+type CrawlWindow struct {
+	Days  []string `yaml:"days"`
+	Start string   `yaml:"start"`
+	End   string   `yaml:"end"`
+}
+
+// UserAgent is a user_agents entry. Weight defaults to 1 and 0 disables the agent.
+type UserAgent struct {
+	Agent  string  `yaml:"agent"`
+	Weight float64 `yaml:"weight"`
+}
+
+// UnmarshalYAML accepts either a plain user-agent string or an
+// {agent, weight} mapping.
+func (ua *UserAgent) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*ua = UserAgent{Agent: value.Value, Weight: 1}
+		return nil
+	}
+	type plain UserAgent
+	entry := plain{Weight: 1}
+	if err := value.Decode(&entry); err != nil {
+		return err
+	}
+	*ua = UserAgent(entry)
+	return nil
+}
+
+// FingerprintProfile is a fingerprint_profiles entry. Without a UserAgent it
+// refers to the built-in profile called Name. Weight defaults to 1 and 0
+// disables the profile.
+type FingerprintProfile struct {
+	Name      string            `yaml:"name"`
+	UserAgent string            `yaml:"user_agent"`
+	Headers   map[string]string `yaml:"headers"`
+	Weight    float64           `yaml:"weight"`
+}
+
+// UnmarshalYAML accepts either a built-in profile name or a mapping.
+func (fp *FingerprintProfile) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*fp = FingerprintProfile{Name: value.Value, Weight: 1}
+		return nil
+	}
+	type plain FingerprintProfile
+	entry := plain{Weight: 1}
+	if err := value.Decode(&entry); err != nil {
+		return err
+	}
+	*fp = FingerprintProfile(entry)
+	return nil
+}
+
+// RobotsOverride is the robots.txt policy for one host: either ignore its
+// robots.txt entirely or use an inline robots body instead.
+type RobotsOverride struct {
+	IgnoreRobots bool   `yaml:"ignore_robots"`
+	Robots       string `yaml:"robots"`
+}
+
+// ExtractionRule is a set of CSS selectors for one domain pattern. Empty
+// selectors, and selectors that match nothing on a page, fall back to the
+// generic extraction.
+type ExtractionRule struct {
+	TitleSelector   string `yaml:"title_selector"`
+	ContentSelector string `yaml:"content_selector"`
+	DateSelector    string `yaml:"date_selector"`
+
+	// Accept lists media types added in front of the default Accept header
+	// for this domain, e.g. "application/json".
+	Accept []string `yaml:"accept"`
+
+	// DropQueryParams lists query parameters removed from this domain's
+	// links before they are queued, so URLs differing only in them are
+	// crawled once. A trailing "*" matches a prefix ("utm_*"); "*" alone
+	// drops the whole query.
+	DropQueryParams []string `yaml:"drop_query_params"`
+
+	// Headers are added to every request for this domain, e.g. an
+	// Authorization header for an API.
+	Headers map[string]string `yaml:"headers"`
+
+	// GatedSelectors and GatedPatterns mark this domain's paywall or consent
+	// interstitials, in addition to the global gated_selectors and
+	// gated_patterns.
+	GatedSelectors []string `yaml:"gated_selectors"`
+	GatedPatterns  []string `yaml:"gated_patterns"`
+
+	// JSON maps the fields of JSON responses from this domain to documents.
+	// JSON responses from domains without a mapping are not stored.
+	JSON *JSONMapping `yaml:"json"`
+}
+
+// JSONMapping maps a JSON response to documents. Paths are dot-separated
+// object keys and array indexes, e.g. "data.items" or "authors.0.name".
+type JSONMapping struct {
+	// Items is the path of the documents: an array is stored as one document
+	// per element, anything else as a single document. Empty is the whole
+	// response.
+	Items string `yaml:"items"`
+
+	// Field paths, relative to each item. Title or Content is required; URL
+	// defaults to the response URL and ID, which keys the document, to URL.
+	ID          string `yaml:"id"`
+	URL         string `yaml:"url"`
+	Title       string `yaml:"title"`
+	Content     string `yaml:"content"`
+	Description string `yaml:"description"`
+	Published   string `yaml:"published"` // Unix seconds or milliseconds, or a date string
+	Language    string `yaml:"language"`
+
+	// Links are paths, relative to the whole response, of URLs to crawl
+	// next such as a pagination link. Arrays queue every element.
+	Links []string `yaml:"links"`
+}
+
+// QualityWeights are the relative weights of the quality score signals.
+type QualityWeights struct {
+	TextRatio           float64 `yaml:"text_ratio"`
+	LinkDensity         float64 `yaml:"link_density"`
+	ArticleBody         float64 `yaml:"article_body"`
+	ContentLength       float64 `yaml:"content_length"`
+	TargetContentLength int     `yaml:"target_content_length"` // characters at which the length signal reaches 1
 }
 
 type MilvusConfig struct {
@@ -30,9 +555,71 @@ type MilvusConfig struct {
 	MaxLengthCanonicalURL int    `yaml:"max_length_canonical_url"`
 	MaxLengthLanguage     int    `yaml:"max_length_language"`
 	MaxLengthHeadings     int    `yaml:"max_length_headings"`
+	MaxLengthTables       int    `yaml:"max_length_tables"`
+	MaxLengthHeaders      int    `yaml:"max_length_headers"`
+	MaxLengthHreflang     int    `yaml:"max_length_hreflang"`
+	MaxLengthAnchors      int    `yaml:"max_length_outbound_anchors"`
+	MaxLengthMeta         int    `yaml:"max_length_meta"`
+	MaxLengthRedirects    int    `yaml:"max_length_redirect_chain"`
+	MaxLengthCrumbs       int    `yaml:"max_length_breadcrumbs"`
 	IndexType             string `yaml:"index_type"`
 	MetricType            string `yaml:"metric_type"`
 	Nlist                 int    `yaml:"nlist"`
+	WaitForIndex          bool   `yaml:"wait_for_index"`
+	IndexWaitTimeoutSec   int    `yaml:"index_wait_timeout_sec"`
+
+	OnMissingVector string `yaml:"on_missing_vector"` // zero (default), skip or error
+
+	// OnDimensionMismatch is fail (default) or new_collection. Before the
+	// crawl, the API embedder is probed for the dimension it produces; if
+	// that differs from EmbeddingDimension, e.g. because the model behind
+	// the API changed, fail stops with an error, and new_collection stores
+	// into <collection_name>_dim<dimension> instead, creating it if needed.
+	// A change during the crawl always stops it.
+	OnDimensionMismatch string `yaml:"on_dimension_mismatch"`
+
+	// ShardNum is the number of shards a new collection is created with; 0
+	// uses the Milvus default. It is fixed at creation time, so changing it
+	// needs a new collection_name.
+	ShardNum int `yaml:"shard_num"`
+
+	// FlushEveryRows and FlushEveryMB flush the collection, sealing its
+	// growing segment, once that many rows or megabytes have been inserted
+	// since the last flush, and when the crawl ends. With neither set, every
+	// inserted document is flushed, leaving many tiny segments that slow
+	// search down until Milvus compacts them. Higher thresholds give fewer,
+	// larger segments, but rows not yet flushed sit in growing segments,
+	// which are searched without the vector index.
+	FlushEveryRows int `yaml:"flush_every_rows"`
+	FlushEveryMB   int `yaml:"flush_every_mb"`
+
+	// TitleVector stores title_vector, an embedding of the title and headings
+	// searchable on its own or together with content_vector. It costs a
+	// second embedder call per document, made with the title_embedder.
+	// TitleEmbeddingDimension, the dimension of its vectors, defaults to
+	// EmbeddingDimension.
+	TitleVector             bool `yaml:"title_vector"`
+	TitleEmbeddingDimension int  `yaml:"title_embedding_dimension"`
+
+	// ExtendedMetadata stores body_hash, crawl_depth, content_fingerprint,
+	// meta_json, redirect_chain, gated, breadcrumbs_json, seed_url,
+	// referrer_url and html_truncation, which existing collections lack, so
+	// it is opt-in. With StoredFields, they must also be listed there.
+	ExtendedMetadata bool `yaml:"extended_metadata"`
+
+	// StoredFields selects the document fields stored in the collection; empty
+	// stores all. hash_id, content_vector, has_vector and needs_embedding are
+	// always stored, except in collections created before has_vector and
+	// needs_embedding existed. Fields of optional crawler features, such as
+	// tables_json for extract_tables, are only stored with the feature
+	// enabled, so new fields never break an existing collection unless a
+	// feature needing them is turned on.
+	StoredFields []string `yaml:"stored_fields"`
+
+	// Per-operation timeout and retries for transient Milvus errors.
+	OperationTimeoutSec int `yaml:"operation_timeout_sec"`
+	MaxRetries          int `yaml:"max_retries"`
+	RetryBackoffMs      int `yaml:"retry_backoff_ms"`
 }
 
 type LoggerConfig struct {
@@ -44,6 +631,74 @@ type EmbedderConfig struct {
 	APIEndpoint string `yaml:"api_endpoint,omitempty"`
 	APIKey      string `yaml:"api_key,omitempty"`
 	ModelName   string `yaml:"model_name,omitempty"`
+
+	// EmbedContentChars truncates main content to this many characters, at
+	// a sentence or word boundary, before it is embedded; the full content is
+	// still stored. 0 embeds the whole content. Long pages are then embedded
+	// by their beginning only, which lowers retrieval quality for content
+	// further down.
+	EmbedContentChars int `yaml:"embed_content_chars"`
+}
+
+// ElasticConfig configures the optional Elasticsearch/OpenSearch sink.
+type ElasticConfig struct {
+	Endpoint         string `yaml:"endpoint"` // e.g. "http://localhost:9200"; empty disables the sink
+	Index            string `yaml:"index"`
+	Username         string `yaml:"username,omitempty"`
+	Password         string `yaml:"password,omitempty"`
+	APIKey           string `yaml:"api_key,omitempty"`
+	BatchSize        int    `yaml:"batch_size"`
+	FlushIntervalSec int    `yaml:"flush_interval_sec"`
+	StoreVector      bool   `yaml:"store_vector"`
+	VectorFieldType  string `yaml:"vector_field_type"` // dense_vector (Elasticsearch, default) or knn_vector (OpenSearch)
+}
+
+// ReembedConfig configures the background job that re-embeds documents
+// flagged with needs_embedding.
+type ReembedConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	BatchSize   int    `yaml:"batch_size"`
+	Concurrency int    `yaml:"concurrency"`  // embedder calls in flight
+	IntervalSec int    `yaml:"interval_sec"` // pause between passes; 0 runs a single pass
+	MarkExpr    string `yaml:"mark_expr"`    // Milvus expression of documents to flag at startup, e.g. extraction_version != "2"
+}
+
+// ExportConfig controls the Parquet export run with --export-parquet.
+type ExportConfig struct {
+	Dir             string `yaml:"dir"`
+	Filter          string `yaml:"filter"` // Milvus expression selecting the documents; empty exports all
+	PageSize        int    `yaml:"page_size"`
+	MaxRowsPerFile  int    `yaml:"max_rows_per_file"`  // 0 = unlimited
+	MaxBytesPerFile int64  `yaml:"max_bytes_per_file"` // 0 = unlimited; checked after each page
+}
+
+// BlobConfig selects where large raw fields are kept outside Milvus.
+type BlobConfig struct {
+	Type      string `yaml:"type"`      // "" (disabled), "local" or "s3"
+	Threshold int    `yaml:"threshold"` // fields larger than this many bytes are offloaded
+	Dir       string `yaml:"dir"`       // local: base directory
+	Endpoint  string `yaml:"endpoint"`  // s3: host:port of the S3-compatible service
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region,omitempty"`
+	AccessKey string `yaml:"access_key,omitempty"`
+	SecretKey string `yaml:"secret_key,omitempty"`
+	UseSSL    bool   `yaml:"use_ssl"`
+}
+
+type HealthConfig struct {
+	Addr            string `yaml:"addr"`              // e.g. ":8081"; empty disables /healthz and /readyz
+	StallTimeoutSec int    `yaml:"stall_timeout_sec"` // /readyz fails if no page finished for this long
+}
+
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP collector host:port, e.g. "localhost:4318"; empty disables tracing
+	Insecure    bool    `yaml:"insecure"`     // use plain HTTP instead of HTTPS
+	ServiceName string  `yaml:"service_name"` // defaults to "crawlengine"
+	SampleRatio float64 `yaml:"sample_ratio"` // fraction of crawls traced, (0, 1]; defaults to 1
+}
+
+type DebugConfig struct {
+	PprofAddr string `yaml:"pprof_addr"` // e.g. "localhost:6060"; empty disables pprof
 }
 
 type Config struct {
@@ -51,6 +706,38 @@ type Config struct {
 	Milvus   MilvusConfig   `yaml:"milvus"`
 	Logger   LoggerConfig   `yaml:"logger"`
 	Embedder EmbedderConfig `yaml:"embedder"`
+	Elastic  ElasticConfig  `yaml:"elastic"`
+	Blob     BlobConfig     `yaml:"blob"`
+	Reembed  ReembedConfig  `yaml:"reembed"`
+	Export   ExportConfig   `yaml:"export"`
+	Health   HealthConfig   `yaml:"health"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Debug    DebugConfig    `yaml:"debug"`
+
+	// TitleEmbedder embeds milvus.title_vector, so titles can use another
+	// model than content. Without a type it is the same as Embedder.
+	TitleEmbedder EmbedderConfig `yaml:"title_embedder"`
+}
+
+// ConfigPathEnv names the environment variable that can point at the config file.
+const ConfigPathEnv = "CRAWLENGINE_CONFIG"
+
+// ResolvePath returns the first existing config file among, in order,
+// flagPath, $CRAWLENGINE_CONFIG, ./config.yaml and ./config/config.yaml.
+// Empty candidates are skipped. The error lists every path tried.
+func ResolvePath(flagPath string) (string, error) {
+	candidates := []string{flagPath, os.Getenv(ConfigPathEnv), "config.yaml", filepath.Join("config", "config.yaml")}
+	var tried []string
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+		tried = append(tried, path)
+	}
+	return "", fmt.Errorf("no config file found, tried: %s", strings.Join(tried, ", "))
 }
 
 // LoadConfig loads configuration from the given path.
@@ -70,9 +757,165 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Milvus.EmbeddingDimension == 0 {
 		cfg.Milvus.EmbeddingDimension = 768
 	}
+	if cfg.Milvus.TitleEmbeddingDimension == 0 {
+		cfg.Milvus.TitleEmbeddingDimension = cfg.Milvus.EmbeddingDimension
+	}
+	if cfg.Crawler.DNSCacheTTLSec == 0 {
+		cfg.Crawler.DNSCacheTTLSec = 300
+	}
+	if cfg.Milvus.IndexWaitTimeoutSec == 0 {
+		cfg.Milvus.IndexWaitTimeoutSec = 300
+	}
+	if cfg.Milvus.OperationTimeoutSec == 0 {
+		cfg.Milvus.OperationTimeoutSec = 30
+	}
+	if cfg.Milvus.MaxRetries == 0 {
+		cfg.Milvus.MaxRetries = 3
+	}
+	if cfg.Milvus.RetryBackoffMs <= 0 {
+		cfg.Milvus.RetryBackoffMs = 500
+	}
+	if cfg.Milvus.MaxLengthTables == 0 {
+		cfg.Milvus.MaxLengthTables = 65535
+	}
+	if cfg.Milvus.MaxLengthHreflang == 0 {
+		cfg.Milvus.MaxLengthHreflang = 8192
+	}
+	if cfg.Milvus.MaxLengthAnchors == 0 {
+		cfg.Milvus.MaxLengthAnchors = 32768
+	}
+	if cfg.Milvus.MaxLengthMeta == 0 {
+		cfg.Milvus.MaxLengthMeta = 16384
+	}
+	if cfg.Milvus.MaxLengthRedirects == 0 {
+		cfg.Milvus.MaxLengthRedirects = 8192
+	}
+	if cfg.Milvus.MaxLengthCrumbs == 0 {
+		cfg.Milvus.MaxLengthCrumbs = 16384
+	}
+	if cfg.Crawler.MaxRedirects <= 0 {
+		cfg.Crawler.MaxRedirects = 5
+	}
+	if cfg.Milvus.MaxLengthHeaders == 0 {
+		cfg.Milvus.MaxLengthHeaders = 8192
+	}
+	if cfg.Crawler.StoreResponseHeaders && cfg.Crawler.RedactedHeaders == nil {
+		cfg.Crawler.RedactedHeaders = []string{"Set-Cookie"}
+	}
+	if cfg.Crawler.NearDuplicateMode != "" && cfg.Crawler.NearDuplicateMaxDistance <= 0 {
+		cfg.Crawler.NearDuplicateMaxDistance = 3
+	}
+	if cfg.Crawler.TitleDedupMinSimilarity == 0 {
+		cfg.Crawler.TitleDedupMinSimilarity = 0.9
+	}
+	if cfg.Crawler.TitleDedupMinWords <= 0 {
+		cfg.Crawler.TitleDedupMinWords = 4
+	}
+	if cfg.Crawler.SemanticDedupThreshold <= 0 {
+		cfg.Crawler.SemanticDedupThreshold = 0.95
+	}
+	if cfg.Crawler.BoilerplateSamplePages <= 1 {
+		cfg.Crawler.BoilerplateSamplePages = 5
+	}
+	if cfg.Crawler.BoilerplateMinRatio <= 0 || cfg.Crawler.BoilerplateMinRatio > 1 {
+		cfg.Crawler.BoilerplateMinRatio = 0.8
+	}
+	if cfg.Crawler.HTMLMinContentChars <= 0 {
+		cfg.Crawler.HTMLMinContentChars = 200
+	}
+	if cfg.Crawler.MaxOutboundAnchors <= 0 {
+		cfg.Crawler.MaxOutboundAnchors = 100
+	}
+	if cfg.Crawler.StateIntervalSec <= 0 {
+		cfg.Crawler.StateIntervalSec = 30
+	}
+	if cfg.Crawler.FrontierSnapshotIntervalSec <= 0 {
+		cfg.Crawler.FrontierSnapshotIntervalSec = 60
+	}
+	if cfg.Crawler.CheckpointIntervalSec <= 0 {
+		cfg.Crawler.CheckpointIntervalSec = 60
+	}
+	if cfg.Crawler.RobotsMaxRetries == 0 {
+		cfg.Crawler.RobotsMaxRetries = 3
+	}
+	if cfg.Crawler.RobotsRetryBackoffMs <= 0 {
+		cfg.Crawler.RobotsRetryBackoffMs = 1000
+	}
+	if cfg.Crawler.WebhookMaxRetries == 0 {
+		cfg.Crawler.WebhookMaxRetries = 3
+	}
+	if cfg.Crawler.WebhookRetryBackoffMs <= 0 {
+		cfg.Crawler.WebhookRetryBackoffMs = 1000
+	}
+	if cfg.Crawler.MaxConnsPerHost == 0 {
+		cfg.Crawler.MaxConnsPerHost = 8
+	}
+	if cfg.Crawler.HostBudgetWindowSec <= 0 {
+		cfg.Crawler.HostBudgetWindowSec = 3600
+	}
+	if cfg.Crawler.TextNormalization == nil {
+		cfg.Crawler.TextNormalization = []string{"nfc", "whitespace"}
+	}
+	if cfg.Crawler.MaxDocumentBytes == 0 {
+		cfg.Crawler.MaxDocumentBytes = 10 << 20
+	}
+	if cfg.Crawler.MaxDOMNodes == 0 {
+		cfg.Crawler.MaxDOMNodes = 100000
+	}
+	if cfg.Crawler.MaxDOMDepth == 0 {
+		cfg.Crawler.MaxDOMDepth = 256
+	}
+	if cfg.Crawler.GatedMaxContentChars == 0 {
+		cfg.Crawler.GatedMaxContentChars = 500
+	}
+	if cfg.Crawler.GovernorIntervalMs <= 0 {
+		cfg.Crawler.GovernorIntervalMs = 1000
+	}
+	if cfg.Crawler.FollowMetaRefresh && cfg.Crawler.MetaRefreshMaxDelaySec <= 0 {
+		cfg.Crawler.MetaRefreshMaxDelaySec = 5
+	}
+	if cfg.Crawler.ComputeQualityScore {
+		w := &cfg.Crawler.QualityWeights
+		if w.TextRatio == 0 && w.LinkDensity == 0 && w.ArticleBody == 0 && w.ContentLength == 0 {
+			w.TextRatio, w.LinkDensity, w.ArticleBody, w.ContentLength = 0.3, 0.3, 0.2, 0.2
+		}
+		if w.TargetContentLength <= 0 {
+			w.TargetContentLength = 2000
+		}
+	}
+	if cfg.Elastic.Index == "" {
+		cfg.Elastic.Index = "web_documents"
+	}
+	if cfg.Elastic.BatchSize <= 0 {
+		cfg.Elastic.BatchSize = 100
+	}
+	if cfg.Reembed.BatchSize <= 0 {
+		cfg.Reembed.BatchSize = 64
+	}
+	if cfg.Reembed.Concurrency <= 0 {
+		cfg.Reembed.Concurrency = 4
+	}
+	if cfg.Export.Dir == "" {
+		cfg.Export.Dir = "export"
+	}
+	if cfg.Export.PageSize <= 0 {
+		cfg.Export.PageSize = 1000
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "crawlengine"
+	}
+	if cfg.Tracing.SampleRatio <= 0 || cfg.Tracing.SampleRatio > 1 {
+		cfg.Tracing.SampleRatio = 1
+	}
+	if cfg.Health.StallTimeoutSec <= 0 {
+		cfg.Health.StallTimeoutSec = 300
+	}
 	if cfg.Embedder.Type == "" {
 		cfg.Embedder.Type = "dummy"
 	}
+	if cfg.TitleEmbedder.Type == "" {
+		cfg.TitleEmbedder = cfg.Embedder
+	}
 
 	return cfg, nil
 }
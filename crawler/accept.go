@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultAccept is the Accept header sent on every fetch. Configured media
+// types are added in front of it, never replacing it, so HTML stays acceptable.
+const DefaultAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"
+
+// feedAccept is added for every fetch when parse_feeds is set, so servers
+// that negotiate on Accept serve feeds rather than an HTML rendering.
+var feedAccept = []string{"application/rss+xml", "application/atom+xml", "application/feed+json;q=0.9"}
+
+type acceptKey struct{}
+
+// withAccept returns ctx carrying the Accept header for fetches made with it.
+func withAccept(ctx context.Context, accept string) context.Context {
+	return context.WithValue(ctx, acceptKey{}, accept)
+}
+
+func acceptFromContext(ctx context.Context) string {
+	if accept, ok := ctx.Value(acceptKey{}).(string); ok && accept != "" {
+		return accept
+	}
+	return DefaultAccept
+}
+
+// acceptFor builds the Accept header for a fetch from host: media types from
+// the host's extraction rule, the global accept setting and, with
+// parse_feeds, the feed types, followed by DefaultAccept. Duplicates are
+// dropped, keeping the first occurrence.
+func (c *Crawler) acceptFor(host string) string {
+	var extra []string
+	if rule := c.rules.For(host); rule != nil {
+		extra = append(extra, rule.Accept...)
+	}
+	extra = append(extra, c.Config.Accept...)
+	if c.Config.ParseFeeds {
+		extra = append(extra, feedAccept...)
+	}
+	if len(extra) == 0 {
+		return DefaultAccept
+	}
+
+	seen := make(map[string]bool)
+	var types []string
+	for _, t := range append(extra, strings.Split(DefaultAccept, ",")...) {
+		t = strings.TrimSpace(t)
+		mediaType, _, _ := strings.Cut(t, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if t == "" || seen[mediaType] {
+			continue
+		}
+		seen[mediaType] = true
+		types = append(types, t)
+	}
+	return strings.Join(types, ",")
+}
+
+// fetchContext returns ctx carrying the Accept header, fingerprint and rule
+// headers for a page fetch from host.
+func (c *Crawler) fetchContext(ctx context.Context, host string, fp *fingerprint) context.Context {
+	return c.rules.withRuleHeaders(withFingerprint(withAccept(ctx, c.acceptFor(host)), fp), host)
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxAnchorTextRunes caps the length of a single stored anchor text.
+const maxAnchorTextRunes = 256
+
+// OutboundAnchor is an in-scope link of a page together with its anchor text.
+type OutboundAnchor struct {
+	URL  string `json:"url"`
+	Text string `json:"text"`
+}
+
+// ExtractOutboundAnchors serializes up to limit of the document's links as a
+// JSON array of {url, text} objects, in document order. Hrefs are resolved
+// against base and kept only if inScope accepts them. Anchor text has its
+// whitespace collapsed, falls back to the alt text of a linked image, and
+// links without any text are skipped, as are repeated (url, text) pairs.
+// Returns "" when no link qualifies.
+func ExtractOutboundAnchors(doc *goquery.Document, base *url.URL, limit int, inScope func(*url.URL) bool) (string, error) {
+	return extractOutboundAnchors(doc, base, defaultNormalizer, limit, inScope)
+}
+
+// extractOutboundAnchors is ExtractOutboundAnchors with link URLs in the form
+// normalizer gives them, so they match the URLs the crawl stores.
+func extractOutboundAnchors(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer, limit int, inScope func(*url.URL) bool) (string, error) {
+	var anchors []OutboundAnchor
+	seen := make(map[OutboundAnchor]bool)
+	doc.Find("a[href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return true
+		}
+		text := anchorText(s)
+		if text == "" {
+			return true
+		}
+		absURL, err := normalizer.Normalize(base, href)
+		if err != nil {
+			return true
+		}
+		linkURL, err := url.Parse(absURL)
+		if err != nil || !inScope(linkURL) {
+			return true
+		}
+		anchor := OutboundAnchor{URL: absURL, Text: text}
+		if !seen[anchor] {
+			seen[anchor] = true
+			anchors = append(anchors, anchor)
+		}
+		return len(anchors) < limit
+	})
+	if len(anchors) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(anchors)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize outbound anchors: %w", err)
+	}
+	return string(data), nil
+}
+
+// anchorText returns the whitespace-normalized text of a link, or the alt
+// text of its images when it has none.
+func anchorText(s *goquery.Selection) string {
+	text := strings.Join(strings.Fields(s.Text()), " ")
+	if text == "" {
+		text = strings.Join(strings.Fields(s.Find("img[alt]").First().AttrOr("alt", "")), " ")
+	}
+	if runes := []rune(text); len(runes) > maxAnchorTextRunes {
+		text = strings.TrimSpace(string(runes[:maxAnchorTextRunes]))
+	}
+	return text
+}
+
+// linkInScope reports whether linkURL, found on the page at baseURL, is a
+// link the crawl could follow: on the same host and neither excluded nor an
+// ad link. Unlike queueLink it does not admit new hosts or log.
+func (c *Crawler) linkInScope(linkURL, baseURL *url.URL) bool {
+	return c.normalizer.HostKey(linkURL.Hostname()) == c.normalizer.HostKey(baseURL.Hostname()) &&
+		!IsExcludedDomain(linkURL, c.Config.ExcludedDomains) &&
+		!IsAdLink(linkURL.String(), c.adPatterns)
+}
@@ -0,0 +1,90 @@
+package crawler_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractOutboundAnchors(t *testing.T) {
+	const page = `<html><body>
+<a href="/a">  First
+  link </a>
+<a href="/a">First link</a>
+<a href="/a">Other text</a>
+<a href="#top">Top</a>
+<a href="javascript:void(0)">Script</a>
+<a href="/empty"></a>
+<a href="/image"><img src="x.png" alt="An  image"></a>
+<a href="https://other.example/page">External</a>
+<a href="/b?x=1">Second</a>
+</body></html>`
+	base, _ := url.Parse("https://example.com/dir/page")
+	inScope := func(u *url.URL) bool { return u.Hostname() == "example.com" }
+
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{"all", 100, `[{"url":"https://example.com/a","text":"First link"},` +
+			`{"url":"https://example.com/a","text":"Other text"},` +
+			`{"url":"https://example.com/image","text":"An image"},` +
+			`{"url":"https://example.com/b?x=1","text":"Second"}]`},
+		{"capped", 2, `[{"url":"https://example.com/a","text":"First link"},` +
+			`{"url":"https://example.com/a","text":"Other text"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := crawler.ExtractOutboundAnchors(doc, base, tt.limit, inScope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractOutboundAnchors = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractOutboundAnchorsWithoutLinks(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><a href="https://other.example/">Away</a></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/")
+	got, err := crawler.ExtractOutboundAnchors(doc, base, 100, func(u *url.URL) bool { return u.Hostname() == "example.com" })
+	if err != nil || got != "" {
+		t.Errorf("ExtractOutboundAnchors = %q, %v, want no anchors", got, err)
+	}
+}
+
+func TestOutboundAnchorsUseStoredURLs(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/docs/">Docs</a></body></html>`),
+		"/docs":       crawltest.HTML(article("Docs") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  trailing_slash: strip\n  store_outbound_anchors: true\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	docs := storer.Documents()
+	if len(docs) != 2 || docs[1].URL != server.URL+"/docs" {
+		t.Fatalf("stored %v, want the seed and /docs", storer.URLs())
+	}
+	if want := `[{"url":"` + server.URL + `/docs","text":"Docs"}]`; docs[0].OutboundAnchorsJSON != want {
+		t.Errorf("outbound_anchors_json = %s, want %s", docs[0].OutboundAnchorsJSON, want)
+	}
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"crawlengine/config"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// benchPage is an article page with navigation, 100 paragraphs and 100 links,
+// about the size of a long blog post.
+var benchPage = func() string {
+	var b strings.Builder
+	b.WriteString(`<html><head><title>Benchmark page</title></head><body><nav>`)
+	for i := range 20 {
+		fmt.Fprintf(&b, `<a href="/section/%d/">Section %d</a> `, i, i)
+	}
+	b.WriteString(`</nav><article><h1>Benchmark page</h1>`)
+	for i := range 100 {
+		fmt.Fprintf(&b, `<p>Paragraph %d   has some    text with <a href="../posts/%d?ref=bench#top">a link</a>
+and <em>emphasis</em>, spread over    two lines.</p>`, i, i)
+	}
+	b.WriteString(`</article><footer>`)
+	for i := range 10 {
+		fmt.Fprintf(&b, `<a href="https://other.example/%d">Elsewhere</a> `, i)
+	}
+	b.WriteString(`</footer></body></html>`)
+	return b.String()
+}()
+
+var benchBase, _ = url.Parse("https://example.com/blog/2024/post/")
+
+func benchDocument(b *testing.B) *goquery.Document {
+	b.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(benchPage))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return doc
+}
+
+// discardLogs silences the per-link log lines for the rest of the benchmark.
+func discardLogs(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func BenchmarkExtractMainContent(b *testing.B) {
+	doc := benchDocument(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		ExtractMainContent(doc, []string{"article", "main"})
+	}
+}
+
+func BenchmarkExtractMainContentMarkdown(b *testing.B) {
+	doc := benchDocument(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		ExtractMainContentMarkdown(doc, []string{"article", "main"}, benchBase)
+	}
+}
+
+func BenchmarkNormalizeURL(b *testing.B) {
+	refs := []string{"../posts/1?ref=bench#top", "/section/2/", "https://Other.example:443/%7ea", "next"}
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, ref := range refs {
+			if _, err := NormalizeURL(benchBase, ref); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkGenerateContentHash(b *testing.B) {
+	content := ExtractMainContent(benchDocument(b), []string{"article"})
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	for b.Loop() {
+		GenerateContentHash(content, "v2")
+	}
+}
+
+// BenchmarkExtractAndQueueLinks measures the link loop of a page whose links
+// are all new: resolving, scope checks and queueing.
+func BenchmarkExtractAndQueueLinks(b *testing.B) {
+	discardLogs(b)
+	cfg := &config.CrawlerConfig{MaxDepth: 2, AdLinkPatterns: []string{`/ads?/`, `doubleclick\.net`}}
+	c := NewCrawler(cfg, nil, nil)
+	doc := benchDocument(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		b.StopTimer()
+		c.visited = make(map[string]bool)
+		c.frontier = newFrontier(0, "", nil)
+		b.StartTimer()
+		c.extractAndQueueLinks(ctx, doc, benchBase, benchBase.String(), 1, 0)
+	}
+	if n := c.frontier.Len(); n != 120 {
+		b.Fatalf("queued %d links, want 120", n)
+	}
+}
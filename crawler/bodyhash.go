@@ -0,0 +1,37 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// BodyHash returns the SHA256 of a raw response body. Unlike
+// GenerateContentHash it covers the exact bytes served, so it is independent
+// of extraction and flags byte-identical pages served under different URLs.
+func BodyHash(body string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+}
+
+// bodyIndex remembers the first URL each response body hash was seen under
+// during the crawl.
+type bodyIndex struct {
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+func newBodyIndex() *bodyIndex {
+	return &bodyIndex{seen: make(map[string]string)}
+}
+
+// FindOrAdd returns the URL hash was first seen under and true, or records
+// pageURL for it and returns false.
+func (b *bodyIndex) FindOrAdd(hash, pageURL string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if first, ok := b.seen[hash]; ok {
+		return first, true
+	}
+	b.seen[hash] = pageURL
+	return "", false
+}
@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// boilerplateDetector learns site-wide boilerplate (headers, footers,
+// sidebars) per host by comparing pages. Each element is keyed by its DOM
+// path (tag, id and classes of it and its ancestors) and its normalized text.
+// The keys of the first sampleSize pages of a host are counted; a key found
+// on at least minRatio of them is boilerplate, and matching elements are
+// removed before main content extraction on later pages of the host.
+//
+// Pages fetched while a host's sample is still being collected, and all pages
+// of hosts with fewer than sampleSize pages, use single-page extraction.
+type boilerplateDetector struct {
+	sampleSize int
+	minRatio   float64
+
+	mu    sync.Mutex
+	hosts map[string]*hostBoilerplate
+}
+
+type hostBoilerplate struct {
+	sampled int
+	counts  map[uint64]int      // key -> pages containing it, while sampling
+	shared  map[uint64]struct{} // learned boilerplate keys, once sampled
+}
+
+func newBoilerplateDetector(sampleSize int, minRatio float64) *boilerplateDetector {
+	return &boilerplateDetector{
+		sampleSize: sampleSize,
+		minRatio:   minRatio,
+		hosts:      make(map[string]*hostBoilerplate),
+	}
+}
+
+// Strip returns a copy of doc with the host's learned boilerplate removed,
+// or doc itself if the host's sample is not complete yet, in which case the
+// page is added to the sample. doc is never modified, so links in removed
+// regions are still followed.
+func (b *boilerplateDetector) Strip(host string, doc *goquery.Document) *goquery.Document {
+	b.mu.Lock()
+	hb, ok := b.hosts[host]
+	if !ok {
+		hb = &hostBoilerplate{counts: make(map[uint64]int)}
+		b.hosts[host] = hb
+	}
+	shared := hb.shared
+	b.mu.Unlock()
+
+	if shared == nil {
+		b.sample(host, hb, doc)
+		return doc
+	}
+	if len(shared) == 0 {
+		return doc
+	}
+
+	stripped := goquery.NewDocumentFromNode(cloneNode(doc.Get(0)))
+	stripped.Url = doc.Url
+	removed := 0
+	walkBlocks(stripped.Find("body"), func(s *goquery.Selection, key uint64) bool {
+		if _, ok := shared[key]; ok {
+			s.Remove()
+			removed++
+			return false
+		}
+		return true
+	})
+	if removed == 0 {
+		return doc
+	}
+	return stripped
+}
+
+func (b *boilerplateDetector) sample(host string, hb *hostBoilerplate, doc *goquery.Document) {
+	keys := make(map[uint64]struct{})
+	walkBlocks(doc.Find("body"), func(s *goquery.Selection, key uint64) bool {
+		keys[key] = struct{}{}
+		return true
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if hb.shared != nil {
+		return
+	}
+	for key := range keys {
+		hb.counts[key]++
+	}
+	hb.sampled++
+	if hb.sampled < b.sampleSize {
+		return
+	}
+
+	minPages := int(float64(hb.sampled)*b.minRatio + 0.999999)
+	if minPages < 2 {
+		minPages = 2
+	}
+	hb.shared = make(map[uint64]struct{})
+	for key, n := range hb.counts {
+		if n >= minPages {
+			hb.shared[key] = struct{}{}
+		}
+	}
+	hb.counts = nil
+	log.Printf("Learned %d boilerplate blocks for %s from %d pages", len(hb.shared), host, hb.sampled)
+}
+
+// walkBlocks visits the elements below sel that carry text, top-down, with
+// their boilerplate key. Children are visited only if fn returns true.
+func walkBlocks(sel *goquery.Selection, fn func(s *goquery.Selection, key uint64) bool) {
+	var walk func(s *goquery.Selection, path string)
+	walk = func(s *goquery.Selection, path string) {
+		s.Children().Each(func(i int, child *goquery.Selection) {
+			switch goquery.NodeName(child) {
+			case "script", "style", "noscript", "template":
+				return
+			}
+			text := strings.Join(strings.Fields(child.Text()), " ")
+			if text == "" {
+				return
+			}
+			childPath := path + ">" + elementSignature(child)
+			h := fnv.New64a()
+			h.Write([]byte(childPath))
+			h.Write([]byte{0})
+			h.Write([]byte(text))
+			if fn(child, h.Sum64()) {
+				walk(child, childPath)
+			}
+		})
+	}
+	walk(sel, "body")
+}
+
+// elementSignature describes an element by tag, id and classes.
+func elementSignature(s *goquery.Selection) string {
+	sig := goquery.NodeName(s)
+	if id, ok := s.Attr("id"); ok && id != "" {
+		sig += "#" + id
+	}
+	if class, ok := s.Attr("class"); ok {
+		for _, c := range strings.Fields(class) {
+			sig += "." + c
+		}
+	}
+	return sig
+}
+
+// cloneNode returns a deep copy of n.
+func cloneNode(n *html.Node) *html.Node {
+	clone := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute(nil), n.Attr...),
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		clone.AppendChild(cloneNode(c))
+	}
+	return clone
+}
@@ -0,0 +1,298 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Breadcrumb sources, in the order ExtractBreadcrumbs tries them.
+const (
+	BreadcrumbSourceJSONLD    = "json_ld"
+	BreadcrumbSourceMicrodata = "microdata"
+	BreadcrumbSourceHTML      = "html"
+)
+
+// HTML breadcrumb trails without schema.org markup.
+const breadcrumbSelector = `nav[aria-label*="breadcrumb" i], [class*="breadcrumb" i], [id*="breadcrumb" i]`
+
+// maxNavigationItems bounds the primary navigation stored per page, counting
+// nested items; mega menus can list hundreds of links.
+const maxNavigationItems = 100
+
+// maxNavigationDepth is how many levels of nested menus are kept.
+const maxNavigationDepth = 3
+
+// BreadcrumbItem is one entry of a breadcrumb trail or navigation menu. URL
+// is empty for entries without a link, such as the current page.
+type BreadcrumbItem struct {
+	Name     string           `json:"name"`
+	URL      string           `json:"url,omitempty"`
+	Children []BreadcrumbItem `json:"children,omitempty"` // submenu of a navigation entry
+}
+
+// Breadcrumbs is the serialized form of breadcrumbs_json.
+type Breadcrumbs struct {
+	Trail      []BreadcrumbItem `json:"breadcrumbs,omitempty"`
+	Source     string           `json:"source,omitempty"` // where Trail came from
+	Navigation []BreadcrumbItem `json:"navigation,omitempty"`
+}
+
+// ExtractBreadcrumbs serializes the document's breadcrumb trail and primary
+// navigation as JSON. The trail comes from a schema.org BreadcrumbList in
+// JSON-LD, else in microdata or RDFa, else from a breadcrumb-named element
+// such as <nav aria-label="breadcrumb"><ol>...</ol></nav>. The navigation is
+// the first <nav> or role="navigation" element that isn't the trail, with
+// nested lists as submenus. Relative URLs are resolved against base. Returns
+// "" when the document has neither.
+func ExtractBreadcrumbs(doc *goquery.Document, base *url.URL) (string, error) {
+	return extractBreadcrumbs(doc, base, defaultNormalizer)
+}
+
+// extractBreadcrumbs is ExtractBreadcrumbs with item URLs in the form
+// normalizer gives them, so they match the URLs the crawl stores.
+func extractBreadcrumbs(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer) (string, error) {
+	var crumbs Breadcrumbs
+	crumbs.Trail = jsonLDBreadcrumbs(doc, base, normalizer)
+	crumbs.Source = BreadcrumbSourceJSONLD
+	if len(crumbs.Trail) == 0 {
+		crumbs.Trail, crumbs.Source = microdataBreadcrumbs(doc, base, normalizer), BreadcrumbSourceMicrodata
+	}
+	if len(crumbs.Trail) == 0 {
+		crumbs.Trail, crumbs.Source = htmlBreadcrumbs(doc, base, normalizer), BreadcrumbSourceHTML
+	}
+	if len(crumbs.Trail) == 0 {
+		crumbs.Source = ""
+	}
+	crumbs.Navigation = primaryNavigation(doc, base, normalizer)
+	if len(crumbs.Trail) == 0 && len(crumbs.Navigation) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(crumbs)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize breadcrumbs: %w", err)
+	}
+	return string(data), nil
+}
+
+// jsonLDBreadcrumbs returns the first BreadcrumbList found in the document's
+// JSON-LD, anywhere in its @graph or nested in another object such as a
+// WebPage's breadcrumb.
+func jsonLDBreadcrumbs(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer) []BreadcrumbItem {
+	var trail []BreadcrumbItem
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data any
+		if json.Unmarshal([]byte(s.Text()), &data) != nil {
+			return true // invalid JSON-LD is common; ignore it
+		}
+		if list := findBreadcrumbList(data); list != nil {
+			trail = breadcrumbListItems(list, base, normalizer)
+		}
+		return len(trail) == 0
+	})
+	return trail
+}
+
+func findBreadcrumbList(data any) map[string]any {
+	switch v := data.(type) {
+	case map[string]any:
+		if hasJSONLDType(v["@type"], "BreadcrumbList") {
+			return v
+		}
+		for _, value := range v {
+			if list := findBreadcrumbList(value); list != nil {
+				return list
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if list := findBreadcrumbList(value); list != nil {
+				return list
+			}
+		}
+	}
+	return nil
+}
+
+// hasJSONLDType reports whether an @type value, a string or an array of
+// them, names typeName, with or without a schema.org prefix.
+func hasJSONLDType(value any, typeName string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == typeName || strings.HasSuffix(v, "schema.org/"+typeName)
+	case []any:
+		for _, t := range v {
+			if hasJSONLDType(t, typeName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// breadcrumbListItems returns the ListItems of a BreadcrumbList in position
+// order. An item's URL is its item, either a URL or an object with an @id or
+// url, and its name is its own name or else the item's.
+func breadcrumbListItems(list map[string]any, base *url.URL, normalizer *urlNormalizer) []BreadcrumbItem {
+	elements, _ := list["itemListElement"].([]any)
+	type positioned struct {
+		position float64
+		item     BreadcrumbItem
+	}
+	var items []positioned
+	for i, element := range elements {
+		obj, ok := element.(map[string]any)
+		if !ok {
+			continue
+		}
+		entry := positioned{position: float64(i + 1)}
+		switch p := obj["position"].(type) {
+		case float64:
+			entry.position = p
+		case string:
+			fmt.Sscan(p, &entry.position)
+		}
+		name, _ := obj["name"].(string)
+		var link string
+		switch item := obj["item"].(type) {
+		case string:
+			link = item
+		case map[string]any:
+			if id, ok := item["@id"].(string); ok {
+				link = id
+			} else if u, ok := item["url"].(string); ok {
+				link = u
+			}
+			if name == "" {
+				name, _ = item["name"].(string)
+			}
+		}
+		entry.item = breadcrumbItem(name, link, base, normalizer)
+		if entry.item.Name != "" {
+			items = append(items, entry)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].position < items[j].position })
+	trail := make([]BreadcrumbItem, len(items))
+	for i, entry := range items {
+		trail[i] = entry.item
+	}
+	return trail
+}
+
+// microdataBreadcrumbs reads a BreadcrumbList marked up with microdata
+// (itemtype and itemprop) or RDFa (typeof and property).
+func microdataBreadcrumbs(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer) []BreadcrumbItem {
+	var trail []BreadcrumbItem
+	lists := doc.Find(`[itemtype$="schema.org/BreadcrumbList"], [typeof~="BreadcrumbList"]`).First()
+	lists.Find(`[itemprop="itemListElement"], [property="itemListElement"]`).Each(func(i int, s *goquery.Selection) {
+		nameSel := s.Find(`[itemprop="name"], [property="name"]`).First()
+		name := nameSel.AttrOr("content", nameSel.Text())
+		itemSel := s.Find(`[itemprop="item"], [property="item"]`).First()
+		link := itemSel.AttrOr("href", itemSel.AttrOr("itemid", itemSel.AttrOr("resource", "")))
+		if item := breadcrumbItem(name, link, base, normalizer); item.Name != "" {
+			trail = append(trail, item)
+		}
+	})
+	return trail
+}
+
+// htmlBreadcrumbs reads the list items, else the links, of the first
+// breadcrumb-named element that has any.
+func htmlBreadcrumbs(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer) []BreadcrumbItem {
+	var trail []BreadcrumbItem
+	doc.Find(breadcrumbSelector).EachWithBreak(func(i int, container *goquery.Selection) bool {
+		if container.Is("li") {
+			container = container.Parent() // only the items are named, e.g. "breadcrumb-item"
+		}
+		entries := container.Find("li")
+		if entries.Length() == 0 {
+			entries = container.Find("a[href]")
+		}
+		entries.Each(func(i int, s *goquery.Selection) {
+			link := s
+			if !s.Is("a") {
+				link = s.Find("a[href]").First()
+			}
+			if item := breadcrumbItem(s.Text(), link.AttrOr("href", ""), base, normalizer); item.Name != "" {
+				trail = append(trail, item)
+			}
+		})
+		return len(trail) == 0
+	})
+	return trail
+}
+
+// primaryNavigation returns the entries of the first navigation element that
+// isn't a breadcrumb trail and has at least two links.
+func primaryNavigation(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer) []BreadcrumbItem {
+	var menu []BreadcrumbItem
+	doc.Find(`nav, [role="navigation"]`).EachWithBreak(func(i int, nav *goquery.Selection) bool {
+		if nav.Is(breadcrumbSelector) || nav.Find("a[href]").Length() < 2 {
+			return true
+		}
+		budget := maxNavigationItems
+		if list := nav.Find("ul, ol").First(); list.Length() > 0 {
+			menu = navigationItems(list, base, normalizer, 1, &budget)
+		} else {
+			nav.Find("a[href]").EachWithBreak(func(i int, a *goquery.Selection) bool {
+				if item := breadcrumbItem(a.Text(), a.AttrOr("href", ""), base, normalizer); item.Name != "" {
+					menu = append(menu, item)
+					budget--
+				}
+				return budget > 0
+			})
+		}
+		return len(menu) == 0
+	})
+	return menu
+}
+
+// navigationItems returns the entries of the list's items, with the nested
+// lists of each as its children, until budget items have been taken.
+func navigationItems(list *goquery.Selection, base *url.URL, normalizer *urlNormalizer, depth int, budget *int) []BreadcrumbItem {
+	var items []BreadcrumbItem
+	list.ChildrenFiltered("li").EachWithBreak(func(i int, li *goquery.Selection) bool {
+		submenu := li.ChildrenFiltered("ul, ol").First()
+		label := li.Clone()
+		label.ChildrenFiltered("ul, ol").Remove()
+		link := label.Find("a[href]").First()
+		name := link.Text()
+		if strings.TrimSpace(name) == "" {
+			name = label.Text()
+		}
+		item := breadcrumbItem(name, link.AttrOr("href", ""), base, normalizer)
+		if item.Name == "" {
+			return true
+		}
+		*budget--
+		if submenu.Length() > 0 && depth < maxNavigationDepth && *budget > 0 {
+			item.Children = navigationItems(submenu, base, normalizer, depth+1, budget)
+		}
+		items = append(items, item)
+		return *budget > 0
+	})
+	return items
+}
+
+// breadcrumbItem builds an entry from a name, with its whitespace collapsed,
+// and a link, resolved against base and normalized by normalizer. Names without
+// a letter or digit, such as "›" separators, are dropped, as are unparseable
+// and javascript: links.
+func breadcrumbItem(name, link string, base *url.URL, normalizer *urlNormalizer) BreadcrumbItem {
+	item := BreadcrumbItem{Name: strings.Join(strings.Fields(name), " ")}
+	if strings.IndexFunc(item.Name, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return BreadcrumbItem{}
+	}
+	if link = strings.TrimSpace(link); link != "" && !strings.HasPrefix(strings.ToLower(link), "javascript:") {
+		if absURL, err := normalizer.Normalize(base, link); err == nil {
+			item.URL = absURL
+		}
+	}
+	return item
+}
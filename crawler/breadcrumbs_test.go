@@ -0,0 +1,77 @@
+package crawler_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractBreadcrumbs(t *testing.T) {
+	const menu = `<nav><ul>
+<li><a href="/news">News</a><ul><li><a href="/news/world">World</a></li><li><a href="/news/local">Local</a></li></ul></li>
+<li><a href="/sports">Sports</a></li>
+</ul></nav>`
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"json-ld", `<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+			{"@type": "WebPage", "breadcrumb": {"@type": "BreadcrumbList", "itemListElement": [
+				{"@type": "ListItem", "position": 2, "name": "World", "item": "/news/world"},
+				{"@type": "ListItem", "position": 1, "name": "News", "item": {"@id": "/news"}}]}}]}</script>`,
+			`{"breadcrumbs":[{"name":"News","url":"https://example.com/news"},{"name":"World","url":"https://example.com/news/world"}],"source":"json_ld"}`},
+		{"microdata", `<ol itemscope itemtype="https://schema.org/BreadcrumbList">
+			<li itemprop="itemListElement" itemscope itemtype="https://schema.org/ListItem"><a itemprop="item" href="/news"><span itemprop="name">News</span></a></li>
+			<li itemprop="itemListElement" itemscope itemtype="https://schema.org/ListItem"><span itemprop="name">Story</span></li></ol>`,
+			`{"breadcrumbs":[{"name":"News","url":"https://example.com/news"},{"name":"Story"}],"source":"microdata"}`},
+		{"html and navigation", menu + `<nav aria-label="Breadcrumb"><ol><li><a href="/">Home</a></li><li>›</li><li><a href="javascript:void(0)">Story</a></li></ol></nav>`,
+			`{"breadcrumbs":[{"name":"Home","url":"https://example.com/"},{"name":"Story"}],"source":"html","navigation":[` +
+				`{"name":"News","url":"https://example.com/news","children":[{"name":"World","url":"https://example.com/news/world"},{"name":"Local","url":"https://example.com/news/local"}]},` +
+				`{"name":"Sports","url":"https://example.com/sports"}]}`},
+		{"none", `<p>No trail here.</p>`, ``},
+	}
+	base, _ := url.Parse("https://example.com/news/world/story")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>` + tt.body + `</body></html>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := crawler.ExtractBreadcrumbs(doc, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractBreadcrumbs =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreadcrumbsUseStoredURLs(t *testing.T) {
+	trail := `<nav aria-label="breadcrumb"><ol><li><a href="/">Home</a></li><li><a href="/news/">News</a></li></ol></nav>`
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/news/story": crawltest.HTML(strings.Replace(article("Story"), "<body>", "<body>"+trail, 1) + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  trailing_slash: strip\n  extract_breadcrumbs: true\n")
+	cfg.SeedURLs = []string{server.URL + "/news/story"}
+	_, storer := runCrawl(t, cfg)
+
+	docs := storer.Documents()
+	if len(docs) != 1 {
+		t.Fatalf("stored %v, want the seed", storer.URLs())
+	}
+	want := `{"breadcrumbs":[{"name":"Home","url":"` + server.URL + `/"},{"name":"News","url":"` + server.URL + `/news"}],"source":"html"}`
+	if docs[0].BreadcrumbsJSON != want {
+		t.Errorf("breadcrumbs_json = %s, want %s", docs[0].BreadcrumbsJSON, want)
+	}
+}
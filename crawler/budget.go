@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"crawlengine/config"
+)
+
+// hostBudgets caps the fetches per host within a sliding time window.
+// A nil *hostBudgets never blocks.
+type hostBudgets struct {
+	window    time.Duration
+	global    int
+	overrides map[string]int // exact hosts
+	suffixes  []string       // wildcard domains, longest first
+	wildcard  map[string]int
+
+	mu    sync.Mutex
+	hosts map[string]*hostBudget
+}
+
+type hostBudget struct {
+	fetches   []time.Time // start times of the fetches in the window, oldest first
+	exhausted bool
+}
+
+// newHostBudgets returns nil unless a global or per-host budget is set.
+// Overrides are exact hosts or "*.domain" wildcards, as in extraction_rules.
+func newHostBudgets(cfg *config.CrawlerConfig) *hostBudgets {
+	if cfg.HostBudget <= 0 && len(cfg.HostBudgets) == 0 {
+		return nil
+	}
+	b := &hostBudgets{
+		window:    time.Duration(cfg.HostBudgetWindowSec) * time.Second,
+		global:    cfg.HostBudget,
+		overrides: make(map[string]int),
+		wildcard:  make(map[string]int),
+		hosts:     make(map[string]*hostBudget),
+	}
+	for pattern, budget := range cfg.HostBudgets {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			b.wildcard[domain] = budget
+			b.suffixes = append(b.suffixes, domain)
+		} else {
+			b.overrides[pattern] = budget
+		}
+	}
+	sort.Slice(b.suffixes, func(i, j int) bool { return len(b.suffixes[i]) > len(b.suffixes[j]) })
+	log.Printf("Per-host request budget enabled: %d per %s, %d host overrides", cfg.HostBudget, b.window, len(cfg.HostBudgets))
+	return b
+}
+
+// budgetFor returns the budget of host; 0 means unlimited.
+func (b *hostBudgets) budgetFor(host string) int {
+	if budget, ok := b.overrides[host]; ok {
+		return budget
+	}
+	for _, domain := range b.suffixes {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return b.wildcard[domain]
+		}
+	}
+	return b.global
+}
+
+// Wait reserves a fetch from host, blocking while the host has used up its
+// budget for the current window. It returns early if ctx is cancelled.
+func (b *hostBudgets) Wait(ctx context.Context, host string) error {
+	if b == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	budget := b.budgetFor(host)
+	if budget <= 0 {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		hb := b.hosts[host]
+		if hb == nil {
+			hb = &hostBudget{}
+			b.hosts[host] = hb
+		}
+		now := time.Now()
+		expired := 0
+		for expired < len(hb.fetches) && now.Sub(hb.fetches[expired]) >= b.window {
+			expired++
+		}
+		hb.fetches = hb.fetches[expired:]
+		if len(hb.fetches) < budget {
+			hb.fetches = append(hb.fetches, now)
+			if hb.exhausted {
+				hb.exhausted = false
+				log.Printf("Request budget for %s reset, resuming fetches", host)
+			}
+			b.mu.Unlock()
+			return nil
+		}
+		resumeAt := hb.fetches[0].Add(b.window)
+		if !hb.exhausted {
+			hb.exhausted = true
+			log.Printf("Request budget for %s exhausted (%d requests per %s), pausing until %s", host, budget, b.window, resumeAt.Format(time.RFC3339))
+		}
+		b.mu.Unlock()
+
+		timer := time.NewTimer(resumeAt.Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+)
+
+func TestHostBudgetFor(t *testing.T) {
+	b := newHostBudgets(&config.CrawlerConfig{
+		HostBudget:          10,
+		HostBudgetWindowSec: 3600,
+		HostBudgets:         map[string]int{"Docs.Example.com": 5, "*.example.com": 2, "*.cdn.example.com": 0},
+	})
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"docs.example.com", 5},
+		{"www.example.com", 2},
+		{"example.com", 2},
+		{"img.cdn.example.com", 0},
+		{"example.org", 10},
+	}
+	for _, tt := range tests {
+		if got := b.budgetFor(tt.host); got != tt.want {
+			t.Errorf("budgetFor(%q) = %d, want %d", tt.host, got, tt.want)
+		}
+	}
+	if newHostBudgets(&config.CrawlerConfig{HostBudgetWindowSec: 3600}) != nil {
+		t.Error("newHostBudgets without budgets is not nil")
+	}
+}
+
+func TestHostBudgetWaitPausesExhaustedHost(t *testing.T) {
+	b := newHostBudgets(&config.CrawlerConfig{HostBudget: 2, HostBudgetWindowSec: 3600})
+	b.window = 100 * time.Millisecond
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 2 {
+		if err := b.Wait(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Wait(ctx, "other.example"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= b.window {
+		t.Fatalf("fetches within the budget waited %s", elapsed)
+	}
+	if err := b.Wait(ctx, "EXAMPLE.com"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < b.window {
+		t.Errorf("third fetch from example.com went ahead after %s, want a pause of the %s window", elapsed, b.window)
+	}
+
+	if err := b.Wait(ctx, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.Wait(cancelled, "example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait on an exhausted host with a cancelled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"crawlengine/storage"
+)
+
+// ChangeDetector is implemented by storers that can look up and rewrite the
+// stored version of a page, for detect_changes.
+type ChangeDetector interface {
+	DocumentByURL(ctx context.Context, url string) (*storage.WebDocument, error)
+	UpsertDocuments(ctx context.Context, docs []*storage.WebDocument) error
+}
+
+// ChangeHook is called with every page checked by detect_changes, reporting
+// whether its content changed since it was last stored; pages stored for
+// the first time count as changed. It is called from the crawl workers, so
+// it must be safe for concurrent use.
+type ChangeHook func(url string, changed bool)
+
+// SetChangeHook sets the hook notified of detect_changes decisions. It must
+// be called before Start.
+func (c *Crawler) SetChangeHook(hook ChangeHook) {
+	c.changeHook = hook
+}
+
+// ContentFingerprint returns the hash of content that change detection
+// compares, which unlike the document hash ID is not salted with the
+// extraction version.
+func ContentFingerprint(content string) string {
+	return GenerateContentHash(content, "")
+}
+
+// unchanged reports whether doc has the same content as the version of its
+// page stored last, in which case only that version's crawled_at is
+// updated, so it is neither re-embedded nor stored again. Lookup failures
+// count as changed.
+func (c *Crawler) unchanged(ctx context.Context, doc *storage.WebDocument) bool {
+	previous, err := c.changes.DocumentByURL(ctx, doc.URL)
+	if err != nil {
+		log.Printf("Warning: could not look up stored version of %s, storing it: %v", doc.URL, err)
+		previous = nil
+	}
+	same := previous != nil
+	if same && previous.ContentFingerprint != "" {
+		same = previous.ContentFingerprint == doc.ContentFingerprint
+	} else if same {
+		same = previous.HashID == doc.HashID // stored without a fingerprint
+	}
+
+	if same {
+		previous.CrawledAt = time.Now().UTC()
+		if err := c.changes.UpsertDocuments(ctx, []*storage.WebDocument{previous}); err != nil {
+			log.Printf("Warning: could not update crawled_at of unchanged %s, storing it: %v", doc.URL, err)
+			same = false
+		}
+	}
+	if same {
+		c.pagesUnchanged.Add(1)
+	}
+	if c.changeHook != nil {
+		c.changeHook(doc.URL, !same)
+	}
+	return same
+}
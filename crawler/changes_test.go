@@ -0,0 +1,90 @@
+package crawler_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+	"crawlengine/storage"
+)
+
+// versionedStorer looks up the documents it stored by URL, like Milvus
+// with detect_changes.
+type versionedStorer struct {
+	*crawltest.MockStorer
+
+	mu      sync.Mutex
+	upserts []string
+}
+
+func (s *versionedStorer) DocumentByURL(ctx context.Context, url string) (*storage.WebDocument, error) {
+	docs := s.Documents()
+	for i := len(docs) - 1; i >= 0; i-- {
+		if docs[i].URL == url {
+			doc := *docs[i]
+			return &doc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *versionedStorer) UpsertDocuments(ctx context.Context, docs []*storage.WebDocument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, doc := range docs {
+		s.upserts = append(s.upserts, doc.URL)
+	}
+	return nil
+}
+
+func TestDetectChangesSkipsUnchangedPages(t *testing.T) {
+	fixtures := map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	}
+	server := crawltest.NewFixtureServer(fixtures)
+	defer server.Close()
+	storer := &versionedStorer{MockStorer: crawltest.NewMockStorer()}
+
+	crawl := func() map[string]bool {
+		cfg := loadCrawlerConfig(t, "  max_depth: 1\n  detect_changes: true\n")
+		cfg.SeedURLs = []string{server.URL + "/"}
+		cfg.Deterministic = true
+		c := crawler.NewCrawler(cfg, storer, nil)
+		var mu sync.Mutex
+		changed := make(map[string]bool)
+		c.SetChangeHook(func(url string, ok bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			changed[url] = ok
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := c.Start(ctx); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		return changed
+	}
+
+	if changed := crawl(); !changed[server.URL+"/"] || !changed[server.URL+"/a"] {
+		t.Fatalf("first crawl reported %v, want both pages changed", changed)
+	}
+
+	fixtures["/a"] = crawltest.HTML(article("Page A, revised") + `</body></html>`)
+	changed := crawl()
+	if changed[server.URL+"/"] || !changed[server.URL+"/a"] {
+		t.Errorf("second crawl reported %v, want only /a changed", changed)
+	}
+	want := []string{server.URL + "/", server.URL + "/a", server.URL + "/a"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+	if want := []string{server.URL + "/"}; !slices.Equal(storer.upserts, want) {
+		t.Errorf("updated crawled_at of %v, want %v", storer.upserts, want)
+	}
+}
@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"crawlengine/config"
+)
+
+// checkpointVersion is bumped whenever crawlCheckpoint changes incompatibly;
+// checkpoints of another version are not resumed.
+const checkpointVersion = 1
+
+// crawlCheckpoint is the content of checkpoint_file: everything needed to
+// continue a crawl where it stopped. It holds the same frontier snapshot as
+// frontier_file plus the redirects, per-host counters and stats, and the
+// settings the crawl was started with so a changed config isn't resumed.
+type crawlCheckpoint struct {
+	Version int              `json:"version"`
+	Config  checkpointConfig `json:"config"`
+	frontierSnapshot
+	Redirects map[string]string `json:"redirects,omitempty"`
+	Hosts     hostCounts        `json:"hosts"`
+	Stats     checkpointStats   `json:"stats"`
+}
+
+// checkpointConfig is the part of the crawler config that decides which
+// URLs a crawl visits and how they are keyed; a checkpoint is only resumed
+// under the same values.
+type checkpointConfig struct {
+	SeedURLs             []string `json:"seed_urls"`
+	SeedFile             string   `json:"seed_file"`
+	Mode                 string   `json:"mode"`
+	LinkSource           string   `json:"link_source"`
+	MaxDepth             int      `json:"max_depth"`
+	MaxHosts             int      `json:"max_hosts"`
+	MaxPagesPerHost      int      `json:"max_pages_per_host"`
+	ExcludedDomains      []string `json:"excluded_domains"`
+	TreatWWWAsSame       bool     `json:"treat_www_as_same"`
+	TrailingSlash        string   `json:"trailing_slash"`
+	CaseInsensitivePaths []string `json:"case_insensitive_paths"`
+	ExtractionVersion    string   `json:"extraction_version"`
+
+	// SeedRequests are compared with their methods and bodies, so a crawl
+	// whose seed requests were edited starts over.
+	SeedRequests []config.SeedRequest `json:"seed_requests,omitempty"`
+}
+
+// checkpointStats are the crawl counters carried over into a resumed crawl.
+type checkpointStats struct {
+	PagesFetched   int64 `json:"pages_fetched"`
+	PagesStored    int64 `json:"pages_stored"`
+	Errors         int64 `json:"errors"`
+	FilteredByDate int64 `json:"filtered_by_date"`
+	PagesUnchanged int64 `json:"pages_unchanged"`
+	SemanticDups   int64 `json:"semantic_duplicates"`
+	Bytes          int64 `json:"bytes_downloaded"`
+}
+
+func (c *Crawler) checkpointConfig() checkpointConfig {
+	return checkpointConfig{
+		SeedURLs:             c.Config.SeedURLs,
+		SeedFile:             c.Config.SeedFile,
+		Mode:                 c.mode,
+		LinkSource:           c.linkSource,
+		MaxDepth:             c.Config.MaxDepth,
+		MaxHosts:             c.Config.MaxHosts,
+		MaxPagesPerHost:      c.Config.MaxPagesPerHost,
+		ExcludedDomains:      c.Config.ExcludedDomains,
+		TreatWWWAsSame:       c.Config.TreatWWWAsSame,
+		TrailingSlash:        c.Config.TrailingSlash,
+		CaseInsensitivePaths: c.Config.CaseInsensitivePaths,
+		ExtractionVersion:    c.Config.ExtractionVersion,
+		SeedRequests:         c.Config.SeedRequests,
+	}
+}
+
+// changedSettings returns the names of the settings that differ between cc
+// and other, in sorted order.
+func (cc checkpointConfig) changedSettings(other checkpointConfig) []string {
+	fields := func(cfg checkpointConfig) map[string]json.RawMessage {
+		data, _ := json.Marshal(cfg) // plain fields always encode
+		var m map[string]json.RawMessage
+		_ = json.Unmarshal(data, &m)
+		return m
+	}
+	mine, theirs := fields(cc), fields(other)
+	var changed []string
+	for name, value := range mine {
+		if !bytes.Equal(value, theirs[name]) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// snapshotCheckpoint captures the crawl's state for checkpoint_file.
+func (c *Crawler) snapshotCheckpoint() *crawlCheckpoint {
+	checkpoint := &crawlCheckpoint{
+		Version:          checkpointVersion,
+		Config:           c.checkpointConfig(),
+		frontierSnapshot: *c.snapshotFrontier(),
+		Hosts:            c.hosts.Counts(),
+		Stats: checkpointStats{
+			PagesFetched:   c.stats.pagesFetched.Load(),
+			PagesStored:    c.stats.pagesStored.Load(),
+			Errors:         c.stats.errors.Load(),
+			FilteredByDate: c.filteredByDate.Load(),
+			PagesUnchanged: c.pagesUnchanged.Load(),
+			SemanticDups:   c.semanticDups.Load(),
+			Bytes:          c.stats.bytes.Load(),
+		},
+	}
+	c.visitedLock.Lock()
+	if len(c.redirects) > 0 {
+		checkpoint.Redirects = make(map[string]string, len(c.redirects))
+		for from, to := range c.redirects {
+			checkpoint.Redirects[from] = to
+		}
+	}
+	c.visitedLock.Unlock()
+	return checkpoint
+}
+
+// runCheckpointWriter saves a checkpoint every interval until stop is
+// closed, and once as soon as ctx is cancelled, so a preempted process has
+// a recent checkpoint even if it is killed before the workers wind down.
+func (c *Crawler) runCheckpointWriter(ctx context.Context, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cancelled := ctx.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-cancelled:
+			cancelled = nil
+			c.saveCheckpoint()
+		case <-ticker.C:
+			c.saveCheckpoint()
+		}
+	}
+}
+
+// saveCheckpoint atomically replaces checkpoint_file with a checkpoint.
+func (c *Crawler) saveCheckpoint() {
+	if err := writeFileAtomic(c.Config.CheckpointFile, c.snapshotCheckpoint()); err != nil {
+		log.Printf("Error saving checkpoint: %v", err)
+	}
+}
+
+// resumeCheckpoint restores the crawl from checkpoint_file. It reports false,
+// to start a new crawl, if checkpoint_file is unset or missing, if the
+// previous crawl finished, or if the checkpoint was written by another
+// checkpoint version or under different crawl settings.
+func (c *Crawler) resumeCheckpoint() (bool, error) {
+	path := c.Config.CheckpointFile
+	if path == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+	var checkpoint crawlCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return false, fmt.Errorf("failed to parse checkpoint file %s (delete it to start a new crawl): %w", path, err)
+	}
+	if checkpoint.Version != checkpointVersion {
+		log.Printf("Warning: checkpoint file %s has version %d, expected %d; starting a new crawl", path, checkpoint.Version, checkpointVersion)
+		return false, nil
+	}
+	if changed := checkpoint.Config.changedSettings(c.checkpointConfig()); len(changed) > 0 {
+		log.Printf("Warning: checkpoint file %s was saved with different %s; starting a new crawl", path, strings.Join(changed, ", "))
+		return false, nil
+	}
+	if len(checkpoint.Tasks) == 0 {
+		log.Printf("Checkpoint file %s has no pending tasks; starting a new crawl from the seeds", path)
+		return false, nil
+	}
+
+	c.visitedLock.Lock()
+	for _, key := range checkpoint.Visited {
+		c.visited[key] = true
+	}
+	for from, to := range checkpoint.Redirects {
+		c.redirects[from] = to
+	}
+	c.visitedLock.Unlock()
+	c.hosts.Restore(checkpoint.Hosts)
+	for _, task := range checkpoint.Tasks {
+		c.frontier.Seed(task)
+		c.markVisited(task.URL)
+	}
+	c.stats.pagesFetched.Store(checkpoint.Stats.PagesFetched)
+	c.stats.pagesStored.Store(checkpoint.Stats.PagesStored)
+	c.stats.errors.Store(checkpoint.Stats.Errors)
+	c.filteredByDate.Store(checkpoint.Stats.FilteredByDate)
+	c.pagesUnchanged.Store(checkpoint.Stats.PagesUnchanged)
+	c.semanticDups.Store(checkpoint.Stats.SemanticDups)
+	c.stats.bytes.Store(checkpoint.Stats.Bytes)
+	log.Printf("Resuming crawl from checkpoint %s saved at %s: %d pending tasks, %d visited URLs, %d pages fetched",
+		path, checkpoint.SavedAt.Format(time.RFC3339), len(checkpoint.Tasks), len(checkpoint.Visited), checkpoint.Stats.PagesFetched)
+	return true, nil
+}
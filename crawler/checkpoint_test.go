@@ -0,0 +1,66 @@
+package crawler_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestCheckpointResumesInterruptedCrawl(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	dir := t.TempDir()
+	crawlerYAML := "  max_depth: 1\n  checkpoint_file: " + filepath.Join(dir, "checkpoint.json") +
+		"\n  state_file: " + filepath.Join(dir, "state.json") + "\n"
+
+	// The first run is cancelled while /a is being stored, after the seed
+	// has been crawled and its links queued.
+	cfg := loadCrawlerConfig(t, crawlerYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	if got, want := interruptedCrawl(t, cfg, 2), []string{server.URL + "/", server.URL + "/a"}; !slices.Equal(got, want) {
+		t.Fatalf("first run stored %v, want %v", got, want)
+	}
+
+	// The second run picks up the interrupted page and the queued links,
+	// without crawling the seed again, and carries the counters over.
+	cfg = loadCrawlerConfig(t, crawlerYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+	want := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("resumed run stored %v, want %v", got, want)
+	}
+	if state := readState(t, filepath.Join(dir, "state.json")); state.PagesFetched != 5 {
+		t.Errorf("pages fetched = %d, want the 2 of the first run and 3 of the second", state.PagesFetched)
+	}
+}
+
+func TestCheckpointIgnoredUnderChangedSettings(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	checkpointYAML := "  checkpoint_file: " + filepath.Join(t.TempDir(), "checkpoint.json") + "\n"
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n"+checkpointYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	interruptedCrawl(t, cfg, 1)
+
+	// A different max_depth starts a new crawl from the seeds.
+	cfg = loadCrawlerConfig(t, "  max_depth: 0\n"+checkpointYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+	if got, want := storer.URLs(), []string{server.URL + "/"}; !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+}
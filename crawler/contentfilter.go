@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// contentFilter decides from a page's main content whether to store it.
+// Pages it rejects are still crawled for links. A nil *contentFilter stores
+// everything.
+type contentFilter struct {
+	storeIf []*regexp.Regexp // if set, at least one must match
+	skipIf  []*regexp.Regexp // none may match
+}
+
+func newContentFilter(storeIfMatches, skipIfMatches []string) *contentFilter {
+	f := &contentFilter{
+		storeIf: compileContentPatterns("store_if_matches", storeIfMatches),
+		skipIf:  compileContentPatterns("skip_if_matches", skipIfMatches),
+	}
+	if len(f.storeIf) == 0 && len(f.skipIf) == 0 {
+		return nil
+	}
+	log.Printf("Content filter enabled: %d store_if_matches, %d skip_if_matches patterns", len(f.storeIf), len(f.skipIf))
+	return f
+}
+
+// compileContentPatterns compiles patterns, skipping invalid ones with a
+// warning.
+func compileContentPatterns(key string, patterns []string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Warning: Ignoring invalid %s pattern '%s': %v", key, pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// Allow reports whether content should be stored, and why.
+func (f *contentFilter) Allow(content string) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	for _, re := range f.skipIf {
+		if re.MatchString(content) {
+			return false, "matches skip_if_matches pattern '" + re.String() + "'"
+		}
+	}
+	if len(f.storeIf) == 0 {
+		return true, "matches no skip_if_matches pattern"
+	}
+	for _, re := range f.storeIf {
+		if re.MatchString(content) {
+			return true, "matches store_if_matches pattern '" + re.String() + "'"
+		}
+	}
+	return false, "matches no store_if_matches pattern"
+}
+
+// SetLogLevel sets the crawler's log level; "debug" enables per-page
+// decision logs. It must be called before Start.
+func (c *Crawler) SetLogLevel(level string) {
+	c.debugLogging = strings.EqualFold(level, "debug")
+}
+
+// debugf logs like log.Printf, but only at debug level.
+func (c *Crawler) debugf(format string, args ...any) {
+	if c.debugLogging {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,51 @@
+package crawler_test
+
+import (
+	"slices"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestContentFilterSkipsPagesButFollowsLinks(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Index") + `<a href="/keep">keep</a><a href="/drop">drop</a></body></html>`),
+		"/keep":       crawltest.HTML(article("Budget report") + `</body></html>`),
+		"/drop":       crawltest.HTML(article("Budget draft") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 1
+  store_if_matches: ["Budget"]
+  skip_if_matches: ["draft"]
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/keep"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+}
+
+func TestContentFilterAppliesToFeedItems(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/feed.xml":   {ContentType: "application/rss+xml", Body: rssFeed("budget", "draft-budget", "weather")},
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 0
+  parse_feeds: true
+  store_if_matches: ["budget"]
+  skip_if_matches: ["draft"]
+`)
+	cfg.SeedURLs = []string{server.URL + "/feed.xml"}
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/posts/budget"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+}
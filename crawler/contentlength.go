@@ -0,0 +1,28 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// contentLengthSkipReason returns why the Content-Length in header is below
+// min_content_length or above max_content_length, or "" if it is neither.
+// Responses without a valid Content-Length, such as chunked ones or those
+// the transport decompressed, are never skipped.
+func (c *Crawler) contentLengthSkipReason(header http.Header) string {
+	if c.Config.MinContentLength <= 0 && c.Config.MaxContentLength <= 0 {
+		return ""
+	}
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return ""
+	}
+	if c.Config.MinContentLength > 0 && length < c.Config.MinContentLength {
+		return fmt.Sprintf("Content-Length %d is below min_content_length %d", length, c.Config.MinContentLength)
+	}
+	if c.Config.MaxContentLength > 0 && length > c.Config.MaxContentLength {
+		return fmt.Sprintf("Content-Length %d is above max_content_length %d", length, c.Config.MaxContentLength)
+	}
+	return ""
+}
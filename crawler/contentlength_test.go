@@ -0,0 +1,33 @@
+package crawler_test
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestContentLengthBounds(t *testing.T) {
+	home := article("Home") + `<a href="/short">short</a><a href="/long">long</a><a href="/fits">fits</a></body></html>`
+	// Bodies under 2 KiB are sent with a Content-Length, larger ones chunked.
+	long := article("Long") + `<p>` + strings.Repeat("More words. ", 100) + `</p></body></html>`
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(home),
+		"/short":      crawltest.HTML(`<html><body><p>Too short.</p></body></html>`),
+		"/long":       crawltest.HTML(long),
+		"/fits":       crawltest.HTML(article("Fits") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  min_content_length: 100\n  max_content_length: "+strconv.Itoa(len(long)-1)+"\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/", server.URL + "/fits"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookieJar is an http.CookieJar shared by all fetches so cookies set by a
+// host (consent, session) are sent on later requests to it. Domain and path
+// scoping is left to net/http/cookiejar with the public suffix list.
+//
+// cookiejar.Jar can't enumerate its contents, so for persistence the jar
+// also keeps the latest version of every cookie it was given, keyed by the
+// URL host and the cookie's name, domain and path. Loading replays those
+// through SetCookies, which reapplies the jar's scoping rules.
+type cookieJar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	entries map[string]persistedCookie
+}
+
+type persistedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+func newCookieJar() (*cookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	return &cookieJar{jar: jar, entries: make(map[string]persistedCookie)}, nil
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	// Keep the request path so cookies without a Path attribute get the same
+	// default path when replayed.
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, cookie := range cookies {
+		scope := cookie.Path
+		if scope == "" {
+			scope = u.Path
+		}
+		key := u.Hostname() + "|" + cookie.Name + "|" + cookie.Domain + "|" + scope
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		stored := *cookie
+		if stored.MaxAge > 0 {
+			// MaxAge is relative to when the cookie was set; pin it to a time.
+			stored.Expires = now.Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		j.entries[key] = persistedCookie{URL: origin, Cookie: &stored}
+	}
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Load adds the cookies saved in path to the jar. A missing file is not an error.
+func (j *cookieJar) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cookie jar file %s: %w", path, err)
+	}
+
+	var entries []persistedCookie
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse cookie jar file %s: %w", path, err)
+	}
+	for _, entry := range entries {
+		u, err := url.Parse(entry.URL)
+		if err != nil || entry.Cookie == nil {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{entry.Cookie})
+	}
+	return nil
+}
+
+// Save writes the jar's unexpired cookies to path.
+func (j *cookieJar) Save(path string) error {
+	now := time.Now()
+	j.mu.Lock()
+	entries := make([]persistedCookie, 0, len(j.entries))
+	for _, entry := range j.entries {
+		if entry.Cookie.Expires.IsZero() || entry.Cookie.Expires.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+	j.mu.Unlock()
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize cookies: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cookie jar file %s: %w", path, err)
+	}
+	return nil
+}
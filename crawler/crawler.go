@@ -2,189 +2,1069 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"crawlengine/config"
+	"crawlengine/embedder"
 	"crawlengine/storage"
 
 	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/html"
 )
 
 type CrawlTask struct {
-	URL   string
-	Depth int
+	URL      string  `json:"url"`
+	Depth    int     `json:"depth"`
+	Priority float64 `json:"priority,omitempty"` // higher is dispatched first; 0 unless focused crawling is enabled
+
+	// Request is set for seed_requests entries; other tasks are fetched
+	// with a plain GET.
+	Request *TaskRequest `json:"request,omitempty"`
+
+	// CheckOnly marks out-of-scope links queued by verify_external_links,
+	// whose status is checked but whose links are never followed.
+	CheckOnly bool `json:"check_only,omitempty"`
+
+	// Seed is the seed URL whose links led to this task and Referrer the page
+	// that linked to it; both are empty for seeds.
+	Seed     string `json:"seed,omitempty"`
+	Referrer string `json:"referrer,omitempty"`
+
+	// Attempts counts the requests made for the task, reported with its
+	// failure in the failed URLs file.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// SeedURL returns the seed URL that initiated the task's crawl branch, the
+// task's own URL for seeds.
+func (t CrawlTask) SeedURL() string {
+	if t.Seed != "" {
+		return t.Seed
+	}
+	return t.URL
 }
 
 type Crawler struct {
 	Config      *config.CrawlerConfig
-	Storer      *storage.MilvusStorer
+	Storer      storage.Storer
 	httpClient  HTTPClient // Could be a more sophisticated client interface
+	fetcher     *Fetcher   // robots.txt, sitemaps and security.txt
 	visited     map[string]bool
 	visitedLock sync.Mutex
-	taskQueue   chan CrawlTask
+	frontier    *frontier
 	wg          sync.WaitGroup
+	embedQueue  chan embedJob // nil unless embed_concurrency is set
 	adPatterns  []*regexp.Regexp
+
+	nearDuplicates *simHashIndex
+	titles         *titleIndex // nil unless title_dedup is set
+	failures       *failureLog
+	hosts          *hostSet
+	redirects      map[string]string // original URL -> final URL, guarded by visitedLock
+	redirectPolicy string
+	mode           string
+	linkSource     string
+	htmlStorage    string
+	embedder       embedder.TextEmbedder
+	titleEmbedder  embedder.TextEmbedder // nil unless title vectors are stored
+	focus          *focusScorer
+	normalizer     *urlNormalizer
+	textNormalizer textNormalizer
+	contentCutoff  time.Time // zero when no date cutoff is configured
+	filteredByDate atomic.Int64
+	pagesUnchanged atomic.Int64 // pages detect_changes found unchanged and didn't store
+	semanticDups   atomic.Int64 // documents semantic_dedup_mode skipped or marked
+	stats          *crawlStats
+	robotsAgent    string
+	cookies        *cookieJar           // nil unless use_cookies is set
+	traps          *trapDetector        // nil unless trap_detection is set
+	inbound        *inboundLinks        // nil unless store_inbound_links is set
+	boilerplate    *boilerplateDetector // nil unless boilerplate_detection is set
+	budgets        *hostBudgets         // nil unless host_budget or host_budgets is set
+	hostPolicies   *hostPolicies
+	extractors     *contentExtractors
+	rng            *lockedRand       // nil unless deterministic is set
+	bodies         *bodyIndex        // nil unless dedupe_identical_bodies is set
+	rules          *extractionRules  // nil unless extraction_rules are configured
+	governor       *resourceGovernor // nil unless a resource limit is set
+	schedule       *crawlSchedule    // nil unless crawl_windows is set
+	contentFilter  *contentFilter    // nil unless store_if_matches or skip_if_matches is set
+	languages      *languageFilter   // nil unless allowed_languages is set
+	gated          *gatedDetector    // nil unless gated_policy is set
+	semantic       *semanticDedup    // nil unless semantic_dedup_mode is set and the storer can search
+	linkReport     *linkReport       // nil unless mode is verify_links
+	titleSources   []string
+	htmlTruncation string
+	debugLogging   bool
+	changes        ChangeDetector // nil unless detect_changes is set and the storer supports it
+	changeHook     ChangeHook
+	fingerprints   *fingerprintPicker // nil when no user agent or profile is configured
+	running        atomic.Bool
+	startedAt      time.Time
+	lastProgress   atomic.Int64 // UnixNano when a worker last finished a task
+	bytesExceeded  atomic.Bool  // set once max_total_bytes is exceeded
+	dimMismatch    atomic.Bool  // set once the embedder's dimension changed mid-crawl
+
+	embedContentChars int // main content is truncated to this many characters for embedding
+	maxMetaJSONBytes  int // meta_json is capped to this many bytes; 0 leaves it uncapped
+	maxHTMLBytes      int // html_source is capped to this many bytes as html_truncation says; 0 leaves it uncapped
 }
 
 // HTTPClient interface for fetching pages, allowing for mocks or advanced clients.
 type HTTPClient interface {
-	Get(url string, userAgent string) (*goquery.Document, string, error)
+	Get(ctx context.Context, url string, userAgent string) (*FetchResult, error)
+}
+
+// FetchResult is the outcome of fetching a page.
+type FetchResult struct {
+	Doc        *goquery.Document // nil for unfollowed redirects
+	HTML       string
+	StatusCode int
+	FinalURL   string // URL the content was served from, after any followed redirects
+	Location   string // redirect target of an unfollowed 3xx response
+	Header     http.Header
+
+	// RedirectChain lists the URLs whose redirects were followed to reach
+	// FinalURL, starting with the requested one; nil without redirects.
+	RedirectChain []string
+}
+
+type DefaultHTTPClient struct {
+	client       *http.Client
+	limiter      *rateLimiter // global_rate_limit; nil is unlimited
+	maxBodyBytes int64        // longer bodies are truncated; 0 reads them whole
+	extractors   *contentExtractors
 }
 
-type DefaultHTTPClient struct{}
+// NewDefaultHTTPClient creates a DefaultHTTPClient. When followRedirects is
+// false, 3xx responses are returned as a FetchResult with Location set.
+func NewDefaultHTTPClient(followRedirects bool, transport http.RoundTripper) *DefaultHTTPClient {
+	return &DefaultHTTPClient{client: NewHTTPClient(followRedirects, transport)}
+}
 
-// Get fetches a page and returns a goquery Document and the raw HTML string.
-func (c *DefaultHTTPClient) Get(targetURL string, userAgent string) (*goquery.Document, string, error) {
-	resp, err := FetchPage(targetURL, userAgent)
+// Get fetches a page and returns its parsed goquery Document and raw HTML.
+// Only responses registered to the html extractor are parsed; those of
+// types without an extractor are returned without reading their body.
+func (c *DefaultHTTPClient) Get(ctx context.Context, targetURL string, userAgent string) (*FetchResult, error) {
+	resp, err := fetchWithClient(ctx, c.client, c.limiter, targetURL, userAgent)
 	if err != nil {
-		return nil, "", err
+		return nil, newCrawlError(ErrCategoryNetwork, 0, err)
 	}
 	defer resp.Body.Close()
 
+	result := &FetchResult{StatusCode: resp.StatusCode, FinalURL: resp.Request.URL.String(), Header: resp.Header}
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		result.RedirectChain = append([]string{req.Response.Request.URL.String()}, result.RedirectChain...)
+	}
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		if location := resp.Header.Get("Location"); location != "" {
+			result.Location = location
+			return result, nil
+		}
+	}
+
 	if resp.StatusCode != 200 {
 		log.Printf("Non-200 status for %s: %d", targetURL, resp.StatusCode)
-		return nil, "", err // Or a custom error type
+		return nil, newCrawlError(ErrCategoryHTTPStatus, resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status))
+	}
+
+	// Bodies of types without an extractor aren't read; crawlPage skips them.
+	contentType := resp.Header.Get("Content-Type")
+	extractor := c.extractors.For(contentType)
+	if contentType != "" && extractor == "" {
+		return result, nil
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	body := io.Reader(resp.Body)
+	if c.maxBodyBytes > 0 {
+		body = io.LimitReader(resp.Body, c.maxBodyBytes+1)
+	}
+	bodyBytes, err := io.ReadAll(body)
 	if err != nil {
-		return nil, "", err
+		return nil, newCrawlError(ErrCategoryNetwork, resp.StatusCode, err)
+	}
+	if c.maxBodyBytes > 0 && int64(len(bodyBytes)) > c.maxBodyBytes {
+		log.Printf("Truncating %s to max_document_bytes (%d bytes)", targetURL, c.maxBodyBytes)
+		bodyBytes = bodyBytes[:c.maxBodyBytes]
+	}
+	if contentType == "" {
+		// Sniff the type and record it, so crawlPage picks the same extractor.
+		contentType = http.DetectContentType(bodyBytes)
+		resp.Header.Set("Content-Type", contentType)
+		if extractor = c.extractors.For(contentType); extractor == "" {
+			return result, nil
+		}
+	}
+	switch extractor {
+	case ExtractorPDF:
+		result.HTML = string(bodyBytes) // binary, not text in some charset
+		return result, nil
+	case ExtractorFeed, ExtractorJSON:
+		result.HTML = decodeBody(bodyBytes, contentType)
+		return result, nil // mapped to documents, not parsed as HTML
 	}
-	htmlString := string(bodyBytes)
+	result.HTML = decodeBody(bodyBytes, contentType)
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlString))
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(result.HTML))
 	if err != nil {
-		return nil, htmlString, err
+		return nil, newCrawlError(ErrCategoryParse, resp.StatusCode, err)
 	}
-	return doc, htmlString, nil
+	result.Doc = doc
+	return result, nil
 }
 
 // NewCrawler initializes a new Crawler.
-func NewCrawler(cfg *config.CrawlerConfig, storer *storage.MilvusStorer) *Crawler {
+// textEmbedder may be nil when no embedding-based feature is enabled.
+func NewCrawler(cfg *config.CrawlerConfig, storer storage.Storer, textEmbedder embedder.TextEmbedder) *Crawler {
+	var rng *lockedRand
+	if cfg.Deterministic {
+		if cfg.MaxConcurrency != 1 {
+			log.Printf("Deterministic mode: using 1 worker instead of max_concurrency %d", cfg.MaxConcurrency)
+			cfg.MaxConcurrency = 1
+		}
+		rng = newLockedRand(cfg.DeterministicSeed)
+		log.Printf("Deterministic mode enabled (seed %d)", cfg.DeterministicSeed)
+	}
+
 	compiledAdPatterns := make([]*regexp.Regexp, len(cfg.AdLinkPatterns))
 	for i, pattern := range cfg.AdLinkPatterns {
 		compiledAdPatterns[i] = regexp.MustCompile(pattern) // Compile patterns once
 	}
 
+	var nearDuplicates *simHashIndex
+	switch strings.ToLower(cfg.NearDuplicateMode) {
+	case "":
+	case "skip", "mark":
+		nearDuplicates = newSimHashIndex(cfg.NearDuplicateMaxDistance)
+		log.Printf("Near-duplicate detection enabled: mode=%s, max Hamming distance=%d", cfg.NearDuplicateMode, cfg.NearDuplicateMaxDistance)
+	default:
+		log.Printf("Warning: Unsupported near_duplicate_mode '%s', near-duplicate detection disabled.", cfg.NearDuplicateMode)
+	}
+
+	var titles *titleIndex
+	if cfg.TitleDedup {
+		titles = newTitleIndex(cfg.TitleDedupMinSimilarity, cfg.TitleDedupMinWords)
+		log.Printf("Title duplicate detection enabled: min similarity=%g, min words=%d", titles.minSimilarity, titles.minWords)
+	}
+
+	redirectPolicy := strings.ToLower(cfg.RedirectPolicy)
+	switch redirectPolicy {
+	case RedirectFollowAndStore, RedirectFollowOnly, RedirectSkip:
+	case "":
+		redirectPolicy = RedirectFollowAndStore
+	default:
+		log.Printf("Warning: Unsupported redirect_policy '%s', defaulting to %s.", cfg.RedirectPolicy, RedirectFollowAndStore)
+		redirectPolicy = RedirectFollowAndStore
+	}
+
+	mode := strings.ToLower(cfg.Mode)
+	switch mode {
+	case ModeCrawl:
+	case ModeFetchList:
+		log.Printf("Fetch list mode: only seed URLs are fetched, links are not followed")
+		if cfg.UseSitemaps {
+			log.Printf("Warning: use_sitemaps is ignored in %s mode.", ModeFetchList)
+		}
+	case ModeVerifyLinks:
+	case "":
+		mode = ModeCrawl
+	default:
+		log.Printf("Warning: Unsupported mode '%s', defaulting to %s.", cfg.Mode, ModeCrawl)
+		mode = ModeCrawl
+	}
+
+	linkSource := strings.ToLower(cfg.LinkSource)
+	switch linkSource {
+	case LinkSourceHTML, LinkSourceSitemap, LinkSourceBoth:
+	case "":
+		linkSource = LinkSourceHTML
+	default:
+		log.Printf("Warning: Unsupported link_source '%s', defaulting to %s.", cfg.LinkSource, LinkSourceHTML)
+		linkSource = LinkSourceHTML
+	}
+
+	htmlStorage := strings.ToLower(cfg.HTMLStorage)
+	switch htmlStorage {
+	case HTMLStorageAlways:
+	case HTMLStorageOnFailure:
+		log.Printf("Storing HTML only for pages with less than %d characters of main content", cfg.HTMLMinContentChars)
+	case "":
+		htmlStorage = HTMLStorageAlways
+	default:
+		log.Printf("Warning: Unsupported html_storage '%s', defaulting to %s.", cfg.HTMLStorage, HTMLStorageAlways)
+		htmlStorage = HTMLStorageAlways
+	}
+	htmlTruncation := strings.ToLower(cfg.HTMLTruncation)
+	switch htmlTruncation {
+	case HTMLTruncateSmart, HTMLTruncateNaive, HTMLTruncateSkip:
+	case "":
+		htmlTruncation = HTMLTruncateSmart
+	default:
+		log.Printf("Warning: Unsupported html_truncation '%s', defaulting to %s.", cfg.HTMLTruncation, HTMLTruncateSmart)
+		htmlTruncation = HTMLTruncateSmart
+	}
+
+	frontierPolicy := strings.ToLower(cfg.FrontierPolicy)
+	switch frontierPolicy {
+	case FrontierPriority, FrontierHostRoundRobin:
+	case "":
+		frontierPolicy = FrontierPriority
+	default:
+		log.Printf("Warning: Unsupported frontier_policy '%s', defaulting to %s.", cfg.FrontierPolicy, FrontierPriority)
+		frontierPolicy = FrontierPriority
+	}
+
+	robotsFailurePolicy := strings.ToLower(cfg.RobotsFailurePolicy)
+	switch robotsFailurePolicy {
+	case "":
+		robotsFailurePolicy = RobotsFailureAllow
+	case RobotsFailureAllow, RobotsFailureDeny, RobotsFailureRetry:
+	default:
+		log.Printf("Warning: Unsupported robots_failure_policy '%s', defaulting to %s.", cfg.RobotsFailurePolicy, RobotsFailureAllow)
+		robotsFailurePolicy = RobotsFailureAllow
+	}
+	log.Printf("robots.txt failure policy: %s", robotsFailurePolicy)
+
+	conns := &connCounter{}
+	transport := newTransport(cfg, conns)
+	// Pages, robots.txt, sitemaps and security.txt share the global rate
+	// limit.
+	fetcher := NewFetcher(NewHTTPClient(true, transport), cfg.GlobalRateLimit, cfg.GlobalRateBurst)
+	fetcher.SetRobotsCollapseWWW(cfg.TreatWWWAsSame)
+	fetcher.SetRobotsFailurePolicy(robotsFailurePolicy, cfg.RobotsMaxRetries, time.Duration(cfg.RobotsRetryBackoffMs)*time.Millisecond)
+	if err := fetcher.SetRobotsOverrides(cfg.RobotsOverrides); err != nil {
+		log.Printf("Warning: %v. No robots overrides applied.", err)
+	}
+	httpClient := &DefaultHTTPClient{client: newHTTPClient(redirectPolicy == RedirectFollowAndStore, cfg.MaxRedirects, transport), limiter: fetcher.limiter}
+	if cfg.MaxDocumentBytes > 0 {
+		httpClient.maxBodyBytes = int64(cfg.MaxDocumentBytes)
+	}
+	rules := newExtractionRules(cfg.ExtractionRules)
+	extractors := newContentExtractors(cfg, rules)
+	httpClient.extractors = extractors
+
+	var cookies *cookieJar
+	if cfg.UseCookies {
+		var err error
+		cookies, err = newCookieJar()
+		if err != nil {
+			log.Printf("Warning: %v. Cookies disabled.", err)
+		} else {
+			if cfg.CookieJarFile != "" {
+				if err := cookies.Load(cfg.CookieJarFile); err != nil {
+					log.Printf("Warning: %v. Starting with an empty cookie jar.", err)
+				}
+			}
+			fetcher.client.Jar = cookies
+			httpClient.client.Jar = cookies
+		}
+	}
+
+	var contentCutoff time.Time
+	if cfg.ContentCutoff != "" {
+		var err error
+		contentCutoff, err = ParseCutoff(cfg.ContentCutoff, time.Now())
+		if err != nil {
+			log.Printf("Warning: %v. Date filtering disabled.", err)
+		} else {
+			log.Printf("Only crawling content newer than %s", contentCutoff.Format(time.RFC3339))
+		}
+	}
+
+	var traps *trapDetector
+	if cfg.TrapDetection {
+		traps = newTrapDetector(cfg)
+	}
+
+	var inbound *inboundLinks
+	if cfg.StoreInboundLinks {
+		inbound = newInboundLinks()
+	}
+
+	var boilerplate *boilerplateDetector
+	if cfg.BoilerplateDetection {
+		boilerplate = newBoilerplateDetector(cfg.BoilerplateSamplePages, cfg.BoilerplateMinRatio)
+		log.Printf("Cross-page boilerplate detection enabled: %d sample pages per host, min ratio %.2f", cfg.BoilerplateSamplePages, cfg.BoilerplateMinRatio)
+	}
+
+	fingerprints := newFingerprintPicker(cfg.FingerprintProfiles)
+	if fingerprints == nil {
+		fingerprints = newUserAgentPicker(cfg.UserAgents)
+	} else if len(cfg.UserAgents) > 0 {
+		log.Printf("Warning: fingerprint_profiles is set, ignoring user_agents.")
+	}
+
+	var changes ChangeDetector
+	if cfg.DetectChanges {
+		if !storage.Find(storer, func(s storage.Storer) bool {
+			var ok bool
+			changes, ok = s.(ChangeDetector)
+			return ok
+		}) {
+			log.Printf("Warning: storer does not support looking up stored documents; detect_changes disabled.")
+		}
+	}
+
+	var bodies *bodyIndex
+	if cfg.DedupeIdenticalBodies {
+		bodies = newBodyIndex()
+	}
+
+	normalizer := newURLNormalizer(cfg.TrailingSlash, cfg.TreatWWWAsSame, cfg.CaseInsensitivePaths)
+
 	return &Crawler{
-		Config:     cfg,
-		Storer:     storer,
-		httpClient: &DefaultHTTPClient{},
-		visited:    make(map[string]bool),
-		taskQueue:  make(chan CrawlTask, cfg.MaxConcurrency*10), // Buffered channel
-		adPatterns: compiledAdPatterns,
+		Config:         cfg,
+		Storer:         storer,
+		httpClient:     httpClient,
+		visited:        make(map[string]bool),
+		redirects:      make(map[string]string),
+		redirectPolicy: redirectPolicy,
+		mode:           mode,
+		linkSource:     linkSource,
+		htmlStorage:    htmlStorage,
+		htmlTruncation: htmlTruncation,
+		frontier:       newFrontier(cfg.MaxConcurrency*10, frontierPolicy, normalizer.HostKey),
+		adPatterns:     compiledAdPatterns,
+		nearDuplicates: nearDuplicates,
+		titles:         titles,
+		hosts:          newHostSet(cfg.MaxHosts, cfg.MaxPagesPerHost),
+		embedder:       textEmbedder,
+		normalizer:     normalizer,
+		textNormalizer: newTextNormalizer(cfg.TextNormalization),
+		contentCutoff:  contentCutoff,
+		stats:          newCrawlStats(cfg.MaxConcurrency),
+		robotsAgent:    RobotsAgentToken(cfg.RobotsUserAgent),
+		cookies:        cookies,
+		traps:          traps,
+		inbound:        inbound,
+		boilerplate:    boilerplate,
+		budgets:        newHostBudgets(cfg),
+		fetcher:        fetcher,
+		hostPolicies:   newHostPolicies(fetcher, RobotsAgentToken(cfg.RobotsUserAgent)),
+		rng:            rng,
+		fingerprints:   fingerprints,
+		bodies:         bodies,
+		rules:          rules,
+		extractors:     extractors,
+		governor:       newResourceGovernor(cfg, conns),
+		titleSources:   parseTitleSources(cfg.TitleSources),
+		schedule:       newCrawlSchedule(cfg),
+		contentFilter:  newContentFilter(cfg.StoreIfMatches, cfg.SkipIfMatches),
+		languages:      newLanguageFilter(cfg.AllowedLanguages, cfg.UnknownLanguagePolicy),
+		gated:          newGatedDetector(cfg, rules),
+		semantic:       newSemanticDedup(cfg, storer),
+		linkReport:     newLinkReport(mode, cfg.LinkReportFile),
+		changes:        changes,
 	}
 }
 
-// Start begins the crawling process.
-func (c *Crawler) Start(ctx context.Context) {
+// Start runs the crawl until it runs out of work or ctx is cancelled. It
+// returns an error wrapping ErrNoSeeds without crawling if no seed URL is
+// valid and in scope.
+func (c *Crawler) Start(ctx context.Context) error {
 	log.Println("Crawler starting...")
+	c.startedAt = time.Now().UTC()
+
+	if c.Config.FocusTopic != "" {
+		if c.embedder == nil {
+			log.Printf("Warning: focus_topic is set but no embedder is available, falling back to breadth-first crawling.")
+		} else if focus, err := newFocusScorer(ctx, c.embedder, c.Config.FocusTopic, c.Config.FocusMinScore, c.Config.FocusScoreSource); err != nil {
+			log.Printf("Warning: %v. Falling back to breadth-first crawling.", err)
+		} else {
+			c.focus = focus
+			log.Printf("Focused crawl enabled for topic '%s' (min score %.2f, source %s)", c.Config.FocusTopic, focus.minScore, focus.source)
+		}
+	}
+
+	// A resumed crawl continues from its checkpoint or saved frontier
+	// instead of the seeds.
+	resumed, err := c.resumeCheckpoint()
+	if err != nil {
+		return c.startFailed(ctx, err)
+	}
+	if !resumed {
+		if resumed, err = c.resumeFrontier(); err != nil {
+			return c.startFailed(ctx, err)
+		}
+	}
+	var seeds []CrawlTask
+	if !resumed {
+		if seeds, err = c.seeds(); err != nil {
+			return c.startFailed(ctx, err)
+		}
+	}
+	// Opened after the seeds are read, since a retry run may use the
+	// previous run's failed URLs file as its seed file.
+	if c.Config.FailedURLsFile != "" {
+		if c.failures, err = openFailureLog(c.Config.FailedURLsFile, resumed); err != nil {
+			log.Printf("Warning: %v. Failed URLs will only be logged.", err)
+		}
+	}
+
+	// Wake idle workers when the crawl is cancelled.
+	stopWatcher := make(chan struct{})
+	defer close(stopWatcher)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.frontier.Close()
+		case <-stopWatcher:
+		}
+	}()
+
+	seedURLs := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		if c.hasVisited(seed.URL) {
+			log.Printf("Warning: Skipping duplicate seed URL %s.", seed.URL)
+			continue
+		}
+		parsedSeed, _ := url.Parse(seed.URL) // validated by validSeeds
+		if !c.admitHost(c.normalizer.HostKey(parsedSeed.Hostname()), seed.URL) {
+			continue
+		}
+		c.markVisited(seed.URL)
+		c.hosts.ReservePage(c.normalizer.HostKey(parsedSeed.Hostname())) // seeds are crawled even past the cap
+		c.frontier.Seed(seed)
+		seedURLs = append(seedURLs, seed.URL)
+	}
 
+	if c.usesSitemaps() {
+		c.seedFromSitemaps(ctx, seedURLs)
+	}
+
+	// Workers start after seeding so the frontier can't look finished before all seeds are queued.
+	c.lastProgress.Store(time.Now().UnixNano())
+	c.running.Store(true)
+	var embedDrained <-chan struct{}
+	if c.Config.EmbedDocuments && c.Config.EmbedConcurrency > 0 {
+		embedDrained = c.startEmbedWorkers()
+	}
 	for i := 0; i < c.Config.MaxConcurrency; i++ {
 		c.wg.Add(1)
 		go c.worker(ctx, i)
 	}
-
-	for _, seedURL := range c.Config.SeedURLs {
-		c.taskQueue <- CrawlTask{URL: seedURL, Depth: 0}
-		c.markVisited(seedURL)
+	stopReporter := make(chan struct{})
+	if c.Config.ProgressIntervalSec > 0 {
+		go c.runProgressReporter(time.Duration(c.Config.ProgressIntervalSec)*time.Second, stopReporter)
+	}
+	stateWriterDone := make(chan struct{})
+	if c.Config.StateFile != "" {
+		c.writeState(c.crawlState(StateRunning))
+		go func() {
+			defer close(stateWriterDone)
+			c.runStateWriter(time.Duration(c.Config.StateIntervalSec)*time.Second, stopReporter)
+		}()
+	} else {
+		close(stateWriterDone)
+	}
+	if c.governor != nil {
+		go c.governor.Run(time.Duration(c.Config.GovernorIntervalMs)*time.Millisecond, stopReporter)
+	}
+	frontierWriterDone := make(chan struct{})
+	if c.Config.FrontierFile != "" {
+		go func() {
+			defer close(frontierWriterDone)
+			c.runFrontierWriter(time.Duration(c.Config.FrontierSnapshotIntervalSec)*time.Second, stopReporter)
+		}()
+	} else {
+		close(frontierWriterDone)
+	}
+	checkpointWriterDone := make(chan struct{})
+	if c.Config.CheckpointFile != "" {
+		go func() {
+			defer close(checkpointWriterDone)
+			c.runCheckpointWriter(ctx, time.Duration(c.Config.CheckpointIntervalSec)*time.Second, stopReporter)
+		}()
+	} else {
+		close(checkpointWriterDone)
 	}
+
 	c.wg.Wait()
-	close(c.taskQueue)
+	if embedDrained != nil {
+		close(c.embedQueue)
+		<-embedDrained
+	}
+	c.running.Store(false)
+	close(stopReporter)
+	<-stateWriterDone // the final state write must not be overwritten by a periodic one
+	<-frontierWriterDone
+	<-checkpointWriterDone
+	if c.Config.FrontierFile != "" {
+		c.saveFrontier()
+	}
+	if c.Config.CheckpointFile != "" {
+		c.saveCheckpoint()
+	}
+	if c.changes != nil {
+		log.Printf("Change detection found %d pages unchanged", c.pagesUnchanged.Load())
+	}
+	if c.semantic != nil {
+		log.Printf("Semantic dedup found %d duplicates", c.semanticDups.Load())
+	}
+	if !c.contentCutoff.IsZero() {
+		log.Printf("Date cutoff filtered %d pages (sitemap lastmod or publication date before %s)", c.filteredByDate.Load(), c.contentCutoff.Format(time.RFC3339))
+	}
+	if err := c.linkReport.Write(); err != nil {
+		log.Printf("Error writing link report: %v", err)
+	}
+	if c.failures != nil {
+		if err := c.failures.Close(); err != nil {
+			log.Printf("Error closing failed URLs file: %v", err)
+		}
+	}
+	if c.cookies != nil && c.Config.CookieJarFile != "" {
+		if err := c.cookies.Save(c.Config.CookieJarFile); err != nil {
+			log.Printf("Error saving cookies: %v", err)
+		}
+	}
+	if c.inbound != nil && c.Config.FinalizeInboundLinks {
+		c.finalizeInboundLinks(context.WithoutCancel(ctx))
+	}
+	c.logSummary()
+	status := StateFinished
+	if ctx.Err() != nil {
+		status = StateCancelled
+	}
+	c.reportFinal(ctx, c.finalState(status))
 	log.Println("Crawler finished all tasks.")
+	return nil
+}
+
+// seeds returns the valid configured seeds, from seed_urls, seed_file and
+// seed_requests, as depth-0 tasks.
+func (c *Crawler) seeds() ([]CrawlTask, error) {
+	seedURLs := c.Config.SeedURLs
+	if c.Config.SeedFile != "" {
+		fileSeeds, err := ReadSeedFile(c.Config.SeedFile)
+		if err != nil {
+			log.Printf("Error reading seed file: %v", err)
+		} else {
+			log.Printf("Loaded %d seed URLs from %s", len(fileSeeds), c.Config.SeedFile)
+			seedURLs = append(append([]string{}, seedURLs...), fileSeeds...)
+		}
+	}
+	configured := len(seedURLs) + len(c.Config.SeedRequests)
+	var seeds []CrawlTask
+	for _, seedURL := range c.validSeeds(seedURLs) {
+		seeds = append(seeds, CrawlTask{URL: seedURL, Depth: 0})
+	}
+	seeds = append(seeds, c.seedRequestTasks(c.Config.SeedRequests)...)
+	if len(seeds) == 0 {
+		if configured == 0 {
+			return nil, fmt.Errorf("%w: set seed_urls, seed_file or seed_requests", ErrNoSeeds)
+		}
+		return nil, fmt.Errorf("%w: all %d configured seeds are invalid or excluded", ErrNoSeeds, configured)
+	}
+	return seeds, nil
+}
+
+// validSeeds canonicalizes seeds, dropping with a warning those that are not
+// absolute http(s) URLs or are in an excluded domain.
+func (c *Crawler) validSeeds(seeds []string) []string {
+	valid := make([]string, 0, len(seeds))
+	for _, rawSeed := range seeds {
+		seedURL := rawSeed
+		if canonical, err := c.normalizer.Canonicalize(rawSeed); err == nil {
+			seedURL = canonical
+		}
+		parsedSeed, err := url.Parse(seedURL)
+		if err != nil || (parsedSeed.Scheme != "http" && parsedSeed.Scheme != "https") || parsedSeed.Hostname() == "" {
+			log.Printf("Warning: Skipping invalid seed URL '%s'.", rawSeed)
+			continue
+		}
+		if IsExcludedDomain(parsedSeed, c.Config.ExcludedDomains) {
+			log.Printf("Warning: Skipping seed URL %s in an excluded domain.", seedURL)
+			continue
+		}
+		valid = append(valid, seedURL)
+	}
+	return valid
+}
+
+// recordFailure counts a failed URL and logs it to the failed URLs file, if
+// one is configured. attempts is the number of requests made for it.
+func (c *Crawler) recordFailure(targetURL string, attempts int, err error) {
+	c.stats.errors.Add(1)
+	if c.failures == nil {
+		return
+	}
+	c.failures.Record(targetURL, err, attempts)
 }
 
 func (c *Crawler) worker(ctx context.Context, id int) {
 	defer c.wg.Done()
 	log.Printf("Worker %d started", id)
 	for {
-		select {
-		case task, ok := <-c.taskQueue:
-			if !ok {
-				log.Printf("Worker %d: Task queue closed, exiting.", id)
-				return // Queue closed
-			}
-			if task.Depth > c.Config.MaxDepth {
-				log.Printf("Worker %d: Max depth %d reached for %s, skipping.", id, c.Config.MaxDepth, task.URL)
-				continue
+		task, ok := c.frontier.Pop()
+		if !ok {
+			if ctx.Err() != nil {
+				log.Printf("Worker %d: Context cancelled, exiting.", id)
+			} else {
+				log.Printf("Worker %d: No tasks left, exiting.", id)
 			}
-			c.crawlPage(ctx, task)
-			time.Sleep(time.Duration(c.Config.DelayMs) * time.Millisecond) // Respect delay
-		case <-ctx.Done():
-			log.Printf("Worker %d: Context cancelled, exiting.", id)
 			return
 		}
+		if task.Depth > c.Config.MaxDepth {
+			log.Printf("Worker %d: Max depth %d reached for %s, skipping.", id, c.Config.MaxDepth, task.URL)
+			c.frontier.Done(task)
+			continue
+		}
+		if err := c.schedule.Wait(ctx); err != nil {
+			c.frontier.Interrupt(task) // cancelled outside the crawl windows
+			continue
+		}
+		if err := c.governor.Wait(ctx); err != nil {
+			c.frontier.Interrupt(task) // cancelled while paused
+			continue
+		}
+		c.crawlPage(ctx, task)
+		if ctx.Err() != nil {
+			c.frontier.Interrupt(task)
+		} else {
+			c.frontier.Done(task)
+		}
+		c.stats.workerTasks[id].Add(1)
+		c.lastProgress.Store(time.Now().UnixNano())
+
+		// Respect delay
+		select {
+		case <-time.After(time.Duration(c.Config.DelayMs) * time.Millisecond):
+		case <-ctx.Done():
+		}
 	}
 }
 
+// SetHTTPClient replaces the client used to fetch pages, e.g. with a mock.
+// It must be called before Start.
+func (c *Crawler) SetHTTPClient(client HTTPClient) {
+	c.httpClient = client
+}
+
+// Ready reports whether the crawl is running and a worker has finished a task
+// within stallTimeout, or since the crawl window opened. A crawl paused
+// outside its crawl windows is ready.
+func (c *Crawler) Ready(stallTimeout time.Duration) error {
+	if !c.running.Load() {
+		return errors.New("crawler is not running")
+	}
+	if !c.schedule.Open(time.Now()) {
+		return nil // waiting for the next crawl window isn't a stall
+	}
+	last := time.Unix(0, c.lastProgress.Load())
+	if resumed := c.schedule.ResumedAt(); resumed.After(last) {
+		last = resumed
+	}
+	if idle := time.Since(last); idle > stallTimeout {
+		return fmt.Errorf("crawler has stalled: no task finished in %s", idle.Round(time.Second))
+	}
+	return nil
+}
+
+// VisitedURLs returns the sorted list of URLs marked visited so far.
+func (c *Crawler) VisitedURLs() []string {
+	c.visitedLock.Lock()
+	defer c.visitedLock.Unlock()
+	urls := make([]string, 0, len(c.visited))
+	for u := range c.visited {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
 func (c *Crawler) markVisited(url string) {
 	c.visitedLock.Lock()
 	defer c.visitedLock.Unlock()
-	c.visited[url] = true
+	c.visited[c.normalizer.VisitKey(url)] = true
+}
+
+func (c *Crawler) visitedCount() int {
+	c.visitedLock.Lock()
+	defer c.visitedLock.Unlock()
+	return len(c.visited)
 }
 
 func (c *Crawler) hasVisited(url string) bool {
 	c.visitedLock.Lock()
 	defer c.visitedLock.Unlock()
-	_, found := c.visited[url]
+	_, found := c.visited[c.normalizer.VisitKey(url)]
 	return found
 }
 
 func (c *Crawler) crawlPage(ctx context.Context, task CrawlTask) {
 	log.Printf("Crawling [Depth %d]: %s", task.Depth, task.URL)
+	ctx, span := tracer.Start(ctx, "crawler.crawlPage", trace.WithAttributes(
+		attribute.String("url", task.URL),
+		attribute.Int("depth", task.Depth),
+	))
+	defer span.End()
 
 	parsedURL, err := url.Parse(task.URL)
 	if err != nil {
 		log.Printf("Error parsing URL %s: %v", task.URL, err)
+		c.recordFailure(task.URL, task.Attempts, newCrawlError(ErrCategoryInvalidURL, 0, err))
 		return
 	}
 
-	currentUA := GetRandomUserAgent(c.Config.UserAgents)
-	if !IsAllowedByRobots(parsedURL, currentUA) {
-		log.Printf("Crawling disallowed by robots.txt for %s using agent %s", task.URL, currentUA)
+	// Robots decisions always use the same agent; the rotating user agent is only sent on page fetches.
+	if !IsAllowedByRobots(ctx, c.fetcher, parsedURL, c.robotsAgent) {
+		log.Printf("Crawling disallowed by robots.txt for %s using agent %s", task.URL, c.robotsAgent)
 		return
 	}
 
-	doc, htmlString, err := c.httpClient.Get(task.URL, currentUA)
+	if c.Config.FetchSecurityTxt {
+		c.hostPolicies.FetchSecurityTxt(parsedURL)
+	}
+
+	fp := c.fingerprint()
+
+	if err := c.budgets.Wait(ctx, c.normalizer.HostKey(parsedURL.Hostname())); err != nil {
+		log.Printf("Host budget wait aborted for %s: %v", task.URL, err)
+		return
+	}
+	if c.Config.HonorCrawlDelayHeaders {
+		if err := c.hostPolicies.Wait(ctx, parsedURL.Host); err != nil {
+			log.Printf("Crawl delay wait aborted for %s: %v", task.URL, err)
+			return
+		}
+	}
+
+	fetchCtx, fetchSpan := tracer.Start(ctx, "crawler.fetch")
+	request := task.Request
+	if c.headSuffices(task) {
+		request = headRequest
+	}
+	task.Attempts++
+	pageCtx := withRedirectCheck(c.fetchContext(fetchCtx, parsedURL.Hostname(), fp), func(target *url.URL) bool {
+		return c.allowedRedirectHop(ctx, target)
+	})
+	result, err := c.httpClient.Get(withTaskRequest(pageCtx, request), task.URL, fp.userAgent)
+	if request == headRequest && headUnsupported(err) {
+		task.Attempts++
+		result, err = c.httpClient.Get(pageCtx, task.URL, fp.userAgent)
+	}
+	if result != nil {
+		c.recordDownload(len(result.HTML))
+		fetched := []attribute.KeyValue{attribute.Int("status", result.StatusCode), attribute.Int("bytes", len(result.HTML))}
+		fetchSpan.SetAttributes(fetched...)
+		span.SetAttributes(fetched...)
+	}
+	var crawlErr *CrawlError
+	if errors.As(err, &crawlErr) && crawlErr.StatusCode != 0 {
+		span.SetAttributes(attribute.Int("status", crawlErr.StatusCode))
+	}
+	endSpan(fetchSpan, err)
+	c.linkReport.Record(task.URL, result, err)
 	if err != nil {
 		log.Printf("Error fetching %s: %v", task.URL, err)
+		c.recordFailure(task.URL, task.Attempts, err)
+		span.SetStatus(codes.Error, "fetch failed")
+		return
+	}
+	if c.Config.HonorCrawlDelayHeaders && result.Header != nil {
+		c.hostPolicies.RecordHeaders(parsedURL.Host, result.Header)
+	}
+
+	if result.Location != "" {
+		c.handleRedirect(task, parsedURL, result)
+		return
+	}
+
+	c.stats.pagesFetched.Add(1)
+	c.hosts.RecordFetch(c.normalizer.HostKey(parsedURL.Hostname()))
+	doc, htmlString := result.Doc, result.HTML
+	pageURL, redirectChain := task.URL, ""
+	if canonical, err := c.normalizer.Canonicalize(result.FinalURL); err == nil {
+		result.FinalURL = canonical
+	}
+	if result.FinalURL != "" && result.FinalURL != task.URL {
+		finalURL, err := url.Parse(result.FinalURL)
+		if err != nil {
+			log.Printf("Error parsing final URL %s for %s: %v", result.FinalURL, task.URL, err)
+			return
+		}
+		log.Printf("Redirected: %s -> %s", task.URL, result.FinalURL)
+		// Checked links are only reported; an out-of-scope final URL of any
+		// other page is neither stored nor used as the base for its links.
+		if !task.CheckOnly && !c.admitRedirect(task.URL, parsedURL, result.FinalURL, task.Depth == 0) {
+			return
+		}
+		// The HTTP client checks every hop, but clients that don't report
+		// them only leave the final URL to check.
+		if !IsAllowedByRobots(ctx, c.fetcher, finalURL, c.robotsAgent) {
+			log.Printf("Skipping %s: redirect target %s is disallowed by robots.txt for agent %s", task.URL, result.FinalURL, c.robotsAgent)
+			return
+		}
+		if !c.recordRedirect(task.URL, result.FinalURL) {
+			log.Printf("Skipping %s: redirect target %s was already crawled", task.URL, result.FinalURL)
+			return
+		}
+		chain := result.RedirectChain
+		if len(chain) == 0 {
+			chain = []string{task.URL} // e.g. from a client that doesn't report hops
+		}
+		redirectChain = c.recordRedirectChain(chain, result.FinalURL)
+		pageURL, parsedURL = result.FinalURL, finalURL
+	}
+
+	if c.linkReport != nil {
+		if doc != nil && !task.CheckOnly && c.followsLinks() && task.Depth < c.Config.MaxDepth {
+			c.extractAndQueueLinks(ctx, doc, parsedURL, task.SeedURL(), task.Depth+1, 0)
+		}
 		return
 	}
 
-	mainContent := ExtractMainContent(doc, c.Config.ContentTags)
+	if reason := c.contentLengthSkipReason(result.Header); reason != "" {
+		log.Printf("Skipping %s: %s", pageURL, reason)
+		return
+	}
+	extractor := c.extractorFor(result)
+	if extractor == "" {
+		log.Printf("Skipping %s: no extractor registered for content type '%s'", pageURL, result.Header.Get("Content-Type"))
+		return
+	}
+
+	bodyHash := BodyHash(htmlString)
+	if c.bodies != nil {
+		if first, found := c.bodies.FindOrAdd(bodyHash, pageURL); found {
+			log.Printf("Skipping %s: response body is identical to %s", pageURL, first)
+			return
+		}
+	}
+
+	// With parse_feeds, feeds are recognized by their content whatever
+	// their type, e.g. when served as text/html.
+	if (extractor == ExtractorFeed || c.Config.ParseFeeds && extractor != ExtractorPDF) && IsFeed(result.Header.Get("Content-Type"), htmlString) {
+		c.handleFeed(ctx, task, pageURL, parsedURL, result.Header, htmlString)
+		return
+	}
+
+	switch extractor {
+	case ExtractorFeed:
+		log.Printf("Skipping %s: not an RSS, Atom or JSON feed", pageURL)
+		return
+	case ExtractorJSON:
+		if rule := c.rules.For(parsedURL.Hostname()); rule != nil && rule.JSON != nil {
+			c.handleJSON(ctx, task, pageURL, parsedURL, result.Header, htmlString, rule.JSON)
+		} else {
+			log.Printf("Skipping JSON response %s: no json mapping in its extraction rule", pageURL)
+		}
+		return
+	case ExtractorPDF:
+		c.handlePDF(ctx, task, pageURL, parsedURL, result.Header, htmlString, bodyHash, redirectChain)
+		return
+	}
+
+	if c.Config.FollowMetaRefresh && c.followMetaRefresh(task, pageURL, parsedURL, doc) && c.Config.MetaRefreshSkipStore {
+		log.Printf("Skipping storage of meta refresh page %s", pageURL)
+		return
+	}
+
+	_, extractSpan := tracer.Start(ctx, "crawler.extract")
+	c.limitDocument(doc, pageURL)
+	rule := c.rules.For(parsedURL.Hostname())
+	contentDoc := doc
+	if c.boilerplate != nil {
+		contentDoc = c.boilerplate.Strip(parsedURL.Hostname(), doc)
+	}
+	var mainContent string
+	if rule != nil && rule.ContentSelector != "" {
+		mainContent = c.extractMainContent(contentDoc, parsedURL, []string{rule.ContentSelector})
+	}
 	if mainContent == "" {
-		log.Printf("Could not extract main content from %s", task.URL)
+		mainContent = c.extractMainContent(contentDoc, parsedURL, c.Config.ContentTags)
+	}
+	if mainContent == "" && contentDoc != doc {
+		// Everything was boilerplate, e.g. on a near-copy of the sample pages.
+		mainContent = c.extractMainContent(doc, parsedURL, c.Config.ContentTags)
+	}
+	if mainContent == "" && c.Config.SanitizeFallback {
+		if rule != nil && rule.ContentSelector != "" {
+			mainContent = c.extractSanitized(htmlString, parsedURL, []string{rule.ContentSelector})
+		}
+		if mainContent == "" {
+			mainContent = c.extractSanitized(htmlString, parsedURL, c.Config.ContentTags)
+		}
+		if mainContent != "" {
+			log.Printf("Recovered main content of %s from sanitized HTML", pageURL)
+		}
+	}
+	mainContent = c.textNormalizer.Normalize(mainContent)
+	if mainContent == "" {
+		log.Printf("Could not extract main content from %s", pageURL)
 	}
 
-	contentHash := GenerateContentHash(mainContent)
+	contentHash := GenerateContentHash(mainContent, c.Config.ExtractionVersion)
+	hashID := c.documentHashID(contentHash, pageURL, redirectChain)
 
-	title := strings.TrimSpace(doc.Find("title").First().Text())
-	metaDescription, _ := doc.Find("meta[name='description']").Attr("content")
-	metaDescription = strings.TrimSpace(metaDescription)
+	duplicateOf := c.nearDuplicateOf(pageURL, parsedURL.Hostname(), mainContent, hashID)
+	if c.traps != nil {
+		c.traps.RecordPage(parsedURL.Hostname(), contentHash, mainContent == "", duplicateOf != "")
+	}
 
 	canonicalURL, _ := doc.Find("link[rel='canonical']").Attr("href")
 	canonicalURL = strings.TrimSpace(canonicalURL)
 	if canonicalURL != "" {
-		parsedCanonical, err := NormalizeURL(parsedURL, canonicalURL)
+		parsedCanonical, err := c.normalizer.Normalize(parsedURL, canonicalURL)
 		if err == nil {
 			canonicalURL = parsedCanonical
 		} else {
-			log.Printf("Could not normalize canonical URL '%s' for page %s: %v", canonicalURL, task.URL, err)
+			log.Printf("Could not normalize canonical URL '%s' for page %s: %v", canonicalURL, pageURL, err)
 			canonicalURL = ""
 		}
 	}
 
+	var title string
+	if rule != nil {
+		title = c.textNormalizer.Normalize(selectorValue(doc, rule.TitleSelector))
+	}
+	if title == "" {
+		var source string
+		title, source = c.resolveTitle(doc, parsedURL, canonicalURL)
+		if source != TitleSourceTitle && title != "" {
+			c.debugf("Title of %s taken from %s: %s", pageURL, source, title)
+		}
+	}
+	// A title duplicate is only marked, never skipped like a content one, so
+	// mirrors of a syndicated article keep their URL.
+	contentDuplicate := duplicateOf != ""
+	if c.titles != nil && !contentDuplicate && title != "" {
+		siteName, _ := doc.Find("meta[property='og:site_name']").Attr("content")
+		if representative, found := c.titles.FindOrAdd(NormalizeTitle(title, siteName), hashID); found {
+			duplicateOf = representative
+			log.Printf("Likely duplicate by title detected for %s (representative ID: %s)", pageURL, representative)
+		}
+	}
+	metaDescription, _ := doc.Find("meta[name='description']").Attr("content")
+	metaDescription = strings.TrimSpace(metaDescription)
+
 	language, _ := doc.Find("html").Attr("lang")
 	language = strings.TrimSpace(language)
 
 	var publicationTimestamp int64
-	pubDateStr, _ := doc.Find("meta[property='article:published_time']").Attr("content")
+	var pubDateStr string
+	if rule != nil {
+		pubDateStr = selectorValue(doc, rule.DateSelector)
+	}
+	if pubDateStr == "" {
+		pubDateStr, _ = doc.Find("meta[property='article:published_time']").Attr("content")
+	}
 	if pubDateStr == "" {
 		pubDateStr, _ = doc.Find("meta[name='pubdate']").Attr("content")
 	}
@@ -202,21 +1082,11 @@ func (c *Crawler) crawlPage(ctx context.Context, task CrawlTask) {
 		})
 	}
 	if pubDateStr != "" {
-		parsedTime, err := time.Parse(time.RFC3339, pubDateStr)
+		parsedTime, err := ParseDate(pubDateStr)
 		if err == nil {
 			publicationTimestamp = parsedTime.Unix()
 		} else {
-			parsedTime, err = time.Parse("2006-01-02T15:04:05Z", pubDateStr)
-			if err == nil {
-				publicationTimestamp = parsedTime.Unix()
-			} else {
-				parsedTime, err = time.Parse("2006-01-02", pubDateStr)
-				if err == nil {
-					publicationTimestamp = parsedTime.Unix()
-				} else {
-					log.Printf("Could not parse publication date string '%s' for %s: %v", pubDateStr, task.URL, err)
-				}
-			}
+			log.Printf("Could not parse publication date string '%s' for %s: %v", pubDateStr, pageURL, err)
 		}
 	}
 
@@ -226,12 +1096,70 @@ func (c *Crawler) crawlPage(ctx context.Context, task CrawlTask) {
 		headingsBuilder.WriteString(" | ")
 	})
 	headingsText := strings.TrimSuffix(headingsBuilder.String(), " | ")
+
+	var responseHeaders string
+	if c.Config.StoreResponseHeaders {
+		responseHeaders, err = SerializeHeaders(result.Header, c.Config.RedactedHeaders)
+		if err != nil {
+			log.Printf("Error serializing response headers for %s: %v", pageURL, err)
+		}
+	}
+
+	var tablesJSON string
+	if c.Config.ExtractTables {
+		tablesJSON, err = ExtractTables(doc)
+		if err != nil {
+			log.Printf("Error extracting tables from %s: %v", pageURL, err)
+		}
+	}
+	var hreflangJSON string
+	if c.Config.ExtractHreflang {
+		hreflangJSON, err = extractHreflang(doc, parsedURL, c.normalizer)
+		if err != nil {
+			log.Printf("Error extracting hreflang links from %s: %v", pageURL, err)
+		}
+	}
+	var outboundAnchorsJSON string
+	if c.Config.StoreOutboundAnchors {
+		inScope := func(linkURL *url.URL) bool { return c.linkInScope(linkURL, parsedURL) }
+		outboundAnchorsJSON, err = extractOutboundAnchors(doc, parsedURL, c.normalizer, c.Config.MaxOutboundAnchors, inScope)
+		if err != nil {
+			log.Printf("Error extracting outbound anchors from %s: %v", pageURL, err)
+		}
+	}
+	var metaJSON string
+	if c.Config.StoreMetaTags {
+		metaJSON, err = ExtractMetaTags(doc, c.maxMetaJSONBytes)
+		if err != nil {
+			log.Printf("Error extracting meta tags from %s: %v", pageURL, err)
+		}
+	}
+	var breadcrumbsJSON string
+	if c.Config.ExtractBreadcrumbs {
+		breadcrumbsJSON, err = extractBreadcrumbs(doc, parsedURL, c.normalizer)
+		if err != nil {
+			log.Printf("Error extracting breadcrumbs from %s: %v", pageURL, err)
+		}
+	}
+	var qualityScore float64
+	if c.Config.ComputeQualityScore {
+		qualityScore = QualityScore(doc, htmlString, mainContent, c.Config.QualityWeights)
+	}
 	var contentVector []float32
+	extractSpan.End()
+
+	storedHTML := htmlString
+	if c.htmlStorage == HTMLStorageOnFailure && utf8.RuneCountInString(mainContent) >= c.Config.HTMLMinContentChars {
+		storedHTML = "" // extraction worked; the HTML is only kept for pages worth reprocessing
+	}
+	storedHTML, htmlTruncation := c.capHTML(storedHTML, pageURL)
 
 	webDoc := &storage.WebDocument{
-		HashID:               contentHash,
-		URL:                  task.URL,
-		HTMLSource:           htmlString,
+		HashID:               hashID,
+		URL:                  pageURL,
+		HTMLSource:           storedHTML,
+		HTMLRetained:         storedHTML != "",
+		HTMLTruncation:       htmlTruncation,
 		MainContent:          mainContent,
 		Title:                title,
 		MetaDescription:      metaDescription,
@@ -241,71 +1169,216 @@ func (c *Crawler) crawlPage(ctx context.Context, task CrawlTask) {
 		HeadingsText:         headingsText,
 		CrawledAt:            time.Now().UTC(),
 		ContentVector:        contentVector,
+		DuplicateOf:          duplicateOf,
+		TablesJSON:           tablesJSON,
+		HreflangJSON:         hreflangJSON,
+		OutboundAnchorsJSON:  outboundAnchorsJSON,
+		BodyHash:             bodyHash,
+		CrawlDepth:           int64(task.Depth),
+		SeedURL:              task.SeedURL(),
+		ReferrerURL:          task.Referrer,
+		ContentFingerprint:   ContentFingerprint(mainContent),
+		MetaJSON:             metaJSON,
+		RedirectChain:        redirectChain,
+		BreadcrumbsJSON:      breadcrumbsJSON,
+		ResponseHeaders:      responseHeaders,
+		ExtractionVersion:    c.Config.ExtractionVersion,
+		QualityScore:         float32(qualityScore),
+		InboundLinks:         c.inboundCount(pageURL),
 	}
 
-	if err := c.Storer.StoreDocument(ctx, webDoc); err != nil {
-		log.Printf("Error storing document for %s (ID: %s): %v", task.URL, contentHash, err)
-	} else {
-		// Log success (already done in StoreDocument in this version)
+	if c.storable(webDoc, contentDuplicate, result.Header, doc, rule) {
+		if c.changes != nil && c.unchanged(ctx, webDoc) {
+			log.Printf("Content of %s is unchanged, updated crawled_at of the stored version only", pageURL)
+		} else {
+			c.storeDocument(ctx, webDoc, func(err error) {
+				if err != nil {
+					log.Printf("Error storing document for %s (ID: %s): %v", pageURL, hashID, err)
+					c.recordFailure(pageURL, task.Attempts, newCrawlError(ErrCategoryStorage, 0, err))
+					return
+				}
+				c.stats.pagesStored.Add(1)
+			})
+		}
 	}
 
-	if task.Depth < c.Config.MaxDepth {
-		c.extractAndQueueLinks(doc, parsedURL, task.Depth+1)
+	if c.followsLinks() && task.Depth < c.Config.MaxDepth {
+		var parentScore float64
+		if c.focus != nil && c.focus.source == FocusScoreParent {
+			parentScore = c.focus.Score(ctx, title+"\n"+mainContent)
+		}
+		c.extractAndQueueLinks(ctx, doc, parsedURL, task.SeedURL(), task.Depth+1, parentScore)
 	}
 }
 
-func (c *Crawler) extractAndQueueLinks(doc *goquery.Document, baseURL *url.URL, nextDepth int) {
-	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
-			return
-		}
+// extractMainContent extracts the main content of doc in the configured
+// content_format.
+func (c *Crawler) extractMainContent(doc *goquery.Document, pageURL *url.URL, contentTags []string) string {
+	if strings.EqualFold(c.Config.ContentFormat, ContentFormatMarkdown) {
+		return ExtractMainContentMarkdown(doc, contentTags, pageURL)
+	}
+	return ExtractMainContent(doc, contentTags)
+}
 
-		absURLString, err := NormalizeURL(baseURL, href)
-		if err != nil {
-			log.Printf("Error normalizing URL %s (base %s): %v", href, baseURL.String(), err)
-			return
+// extractAndQueueLinks queues the in-scope links of doc. parentScore is the
+// focus relevance of the page itself, used when scoring links by parent page.
+func (c *Crawler) extractAndQueueLinks(ctx context.Context, doc *goquery.Document, baseURL *url.URL, seed string, nextDepth int, parentScore float64) {
+	linked := make(map[string]bool) // in-scope targets of this page, for inbound link counts
+	defer func() {
+		if c.inbound != nil {
+			c.inbound.Add(linked)
 		}
+	}()
 
-		linkURL, err := url.Parse(absURLString)
-		if err != nil {
-			log.Printf("Error parsing absolute URL %s: %v", absURLString, err)
-			return
-		}
+	var anchors []*goquery.Selection
+	c.linkScope(doc, baseURL).Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		anchors = append(anchors, s)
+	})
+	if c.Config.FollowHreflang {
+		// Translations are queued like links, subject to the same scope rules.
+		doc.Find(hreflangSelector).Each(func(i int, s *goquery.Selection) {
+			anchors = append(anchors, s)
+		})
+	}
+	if c.rng != nil {
+		// Deterministic mode queues links in href order, independent of page layout changes.
+		sort.SliceStable(anchors, func(i, j int) bool {
+			return anchors[i].AttrOr("href", "") < anchors[j].AttrOr("href", "")
+		})
+	}
 
-		// Only crawl links within the same domain (or subdomains if configured)
-		if linkURL.Hostname() != baseURL.Hostname() {
-			// log.Printf("Skipping external link: %s", absURLString)
-			return
+	for _, s := range anchors {
+		c.queueLink(ctx, s.AttrOr("href", ""), s.Text, baseURL, seed, nextDepth, parentScore, linked)
+	}
+}
+
+// queueLink resolves href on the page at baseURL, found by crawling from seed,
+// and queues it if it is in scope and new. In-scope targets are added to
+// linked. anchorText is only called when focused crawling scores links by
+// their anchor text.
+func (c *Crawler) queueLink(ctx context.Context, href string, anchorText func() string, baseURL *url.URL, seed string, nextDepth int, parentScore float64, linked map[string]bool) {
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+
+	absURLString, err := c.normalizer.Normalize(baseURL, href)
+	if err != nil {
+		log.Printf("Error normalizing URL %s (base %s): %v", href, baseURL.String(), err)
+		return
+	}
+	absURLString = c.rules.dropQueryParams(absURLString)
+
+	linkURL, err := url.Parse(absURLString)
+	if err != nil {
+		log.Printf("Error parsing absolute URL %s: %v", absURLString, err)
+		return
+	}
+
+	// Only crawl links within the same domain (or subdomains if configured)
+	if c.normalizer.HostKey(linkURL.Hostname()) != c.normalizer.HostKey(baseURL.Hostname()) {
+		// log.Printf("Skipping external link: %s", absURLString)
+		if c.linkReport != nil && c.Config.VerifyExternalLinks {
+			c.queueLinkCheck(absURLString, linkURL, baseURL, nextDepth)
 		}
+		return
+	}
+
+	if !c.allowedLink(absURLString, linkURL) {
+		return
+	}
+
+	if absURLString != baseURL.String() {
+		linked[absURLString] = true
+		c.linkReport.AddReferrer(absURLString, baseURL.String())
+	}
 
-		if IsExcludedDomain(linkURL, c.Config.ExcludedDomains) {
-			log.Printf("Skipping excluded domain link: %s", absURLString)
+	if c.hasVisited(absURLString) {
+		return
+	}
+	if c.traps != nil {
+		if ok, reason := c.traps.Admit(linkURL); !ok {
+			log.Printf("Skipping suspected crawler trap %s: %s", absURLString, reason)
 			return
 		}
+	}
 
-		// Check for ad links using compiled regex
-		isAd := false
-		for _, pattern := range c.adPatterns {
-			if pattern.MatchString(absURLString) {
-				isAd = true
-				break
-			}
+	c.markVisited(absURLString)
+	log.Printf("Queueing new link: %s (Depth: %d)", absURLString, nextDepth)
+	task := CrawlTask{URL: absURLString, Depth: nextDepth, Seed: seed, Referrer: baseURL.String()}
+	if c.focus != nil {
+		score := parentScore
+		if c.focus.source == FocusScoreAnchor {
+			score = c.focus.Score(ctx, anchorText())
 		}
-		if isAd {
-			log.Printf("Skipping ad link: %s", absURLString)
+		task.Priority = c.focus.Priority(score)
+	}
+	c.enqueue(task)
+}
+
+// allowedLink reports whether an in-scope link or redirect target may be
+// crawled: it must not be on an excluded domain or look like an ad link.
+func (c *Crawler) allowedLink(absURL string, linkURL *url.URL) bool {
+	if IsExcludedDomain(linkURL, c.Config.ExcludedDomains) {
+		log.Printf("Skipping excluded domain link: %s", absURL)
+		return false
+	}
+
+	// Check for ad links using compiled regex
+	if IsAdLink(absURL, c.adPatterns) {
+		log.Printf("Skipping ad link: %s", absURL)
+		return false
+	}
+	return true
+}
+
+// queueLinkCheck queues an out-of-scope link of a verify_links crawl to be
+// checked once, without following its links or counting toward max_hosts.
+func (c *Crawler) queueLinkCheck(absURL string, linkURL, baseURL *url.URL, nextDepth int) {
+	if linkURL.Scheme != "http" && linkURL.Scheme != "https" {
+		return
+	}
+	c.linkReport.AddReferrer(absURL, baseURL.String())
+	if c.hasVisited(absURL) {
+		return
+	}
+	c.markVisited(absURL)
+	c.enqueue(CrawlTask{URL: absURL, Depth: nextDepth, CheckOnly: true})
+}
+
+// linkScope returns the part of doc links are taken from: the whole document,
+// or with links_from_main_content only the main content blocks. Pages where
+// no main content is found fall back to the whole document so the crawl
+// doesn't dead-end on index pages.
+func (c *Crawler) linkScope(doc *goquery.Document, pageURL *url.URL) *goquery.Selection {
+	if !c.Config.LinksFromMainContent {
+		return doc.Selection
+	}
+	blocks, _ := MainContentBlocks(doc, c.Config.ContentTags)
+	if len(blocks) == 0 {
+		log.Printf("No main content found on %s, taking links from the whole page", pageURL)
+		return doc.Selection
+	}
+	var nodes []*html.Node
+	for _, block := range blocks {
+		nodes = append(nodes, block.Nodes...)
+	}
+	return doc.FindNodes(nodes...)
+}
+
+// enqueue adds a task to the frontier, dropping it if the frontier is full.
+func (c *Crawler) enqueue(task CrawlTask) {
+	host := ""
+	if taskURL, err := url.Parse(task.URL); err == nil {
+		host = c.normalizer.HostKey(taskURL.Hostname())
+		if !c.hosts.ReservePage(host) {
+			log.Printf("Skipping %s: host reached max_pages_per_host", task.URL)
 			return
 		}
-
-		if !c.hasVisited(absURLString) {
-			c.markVisited(absURLString)
-			log.Printf("Queueing new link: %s (Depth: %d)", absURLString, nextDepth)
-			// Non-blocking send or check context
-			select {
-			case c.taskQueue <- CrawlTask{URL: absURLString, Depth: nextDepth}:
-			default:
-				log.Printf("Task queue full or blocked. Dropping link: %s", absURLString)
-			}
+	}
+	if !c.frontier.Push(task) {
+		log.Printf("Task queue full or closed. Dropping link: %s", task.URL)
+		if host != "" {
+			c.hosts.ReleasePage(host)
 		}
-	})
+	}
 }
@@ -0,0 +1,298 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// loadCrawlerConfig loads a crawler section through config.LoadConfig, so
+// tests run with the same defaults as a real crawl.
+func loadCrawlerConfig(t *testing.T, crawlerYAML string) *config.CrawlerConfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("crawler:\n"+crawlerYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return &cfg.Crawler
+}
+
+// runCrawl crawls from seeds with one worker and returns what was stored.
+func runCrawl(t *testing.T, cfg *config.CrawlerConfig) (*crawler.Crawler, *crawltest.MockStorer) {
+	t.Helper()
+	cfg.Deterministic = true
+	storer := crawltest.NewMockStorer()
+	c := crawler.NewCrawler(cfg, storer, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return c, storer
+}
+
+func article(body string) string {
+	return `<html><head><title>` + body + `</title></head><body><article><p>` + body +
+		` is a fixture page with enough words in its main content to be stored by the crawler.</p></article>`
+}
+
+func TestCrawlStoresLinkedPages(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `<a href="/">home</a><a href="/c">c</a></body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	c, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/", server.URL + "/a", server.URL + "/b"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+	if got := c.VisitedURLs(); !slices.Equal(got, want) {
+		t.Errorf("visited URLs = %v, want %v", got, want)
+	}
+}
+
+func TestCrawlRespectsRobots(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/public">p</a><a href="/private/page">x</a></body></html>`),
+		"/public":     crawltest.HTML(article("Public") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nDisallow: /private\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 2\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/", server.URL + "/public"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+	if slices.Contains(server.Requests(), "/private/page") {
+		t.Errorf("disallowed /private/page was fetched; requests: %v", server.Requests())
+	}
+}
+
+func TestCrawlWithMockHTTPClient(t *testing.T) {
+	// Nothing listens on port 1, so robots.txt fails fast and is treated as allowing all.
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n")
+	cfg.SeedURLs = []string{"http://127.0.0.1:1/"}
+	cfg.Deterministic = true
+	client := &crawltest.MockHTTPClient{Results: map[string]*crawler.FetchResult{
+		"http://127.0.0.1:1/":     mockPage("http://127.0.0.1:1/", article("Home")+`<a href="/next">next</a><a href="/missing">gone</a></body></html>`),
+		"http://127.0.0.1:1/next": mockPage("http://127.0.0.1:1/next", article("Next")+`</body></html>`),
+	}}
+	storer := crawltest.NewMockStorer()
+	c := crawler.NewCrawler(cfg, storer, nil)
+	c.SetHTTPClient(client)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	want := []string{"http://127.0.0.1:1/", "http://127.0.0.1:1/next"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+	wantRequests := []string{"http://127.0.0.1:1/", "http://127.0.0.1:1/missing", "http://127.0.0.1:1/next"}
+	if got := client.Requests(); !slices.Equal(got, wantRequests) {
+		t.Errorf("requests = %v, want %v", got, wantRequests)
+	}
+	for _, doc := range storer.Documents() {
+		if doc.Title == "" || doc.MainContent == "" || doc.HashID == "" {
+			t.Errorf("document %s stored without title, main content or hash ID: %+v", doc.URL, doc)
+		}
+	}
+}
+
+func TestCrawlStoreErrorsAreNotFatal(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":  crawltest.HTML(article("Home") + `<a href="/a">a</a></body></html>`),
+		"/a": crawltest.HTML(article("Page A") + `</body></html>`),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	cfg.Deterministic = true
+	storer := crawltest.NewMockStorer()
+	storer.Err = errors.New("storage unavailable")
+	c := crawler.NewCrawler(cfg, storer, nil)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if docs := storer.Documents(); len(docs) != 0 {
+		t.Errorf("stored %d documents despite storage errors", len(docs))
+	}
+	if !slices.Contains(server.Requests(), "/a") {
+		t.Errorf("links of a page that failed to store were not followed; requests: %v", server.Requests())
+	}
+}
+
+// mockPage returns a successful HTML fetch result for pageURL.
+func mockPage(pageURL, body string) *crawler.FetchResult {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	return &crawler.FetchResult{
+		Doc:        doc,
+		HTML:       body,
+		StatusCode: http.StatusOK,
+		FinalURL:   pageURL,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+	}
+}
+
+func TestFailedURLsFileIsPerCrawl(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/missing">gone</a></body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "failed_urls.tsv")
+	for range 2 {
+		cfg := loadCrawlerConfig(t, "  max_depth: 1\n  failed_urls_file: "+path+"\n")
+		cfg.SeedURLs = []string{server.URL + "/"}
+		runCrawl(t, cfg)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "# url") {
+		t.Fatalf("failed URLs file should hold a header and the second crawl's failure, got:\n%s", data)
+	}
+	fields := strings.Split(lines[1], "\t")
+	if len(fields) != 5 || fields[0] != server.URL+"/missing" || fields[3] != "1" {
+		t.Errorf("failure line = %q, want %s/missing with 1 attempt", lines[1], server.URL)
+	}
+}
+
+func TestGlobalRateLimitCoversRobots(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	// One request every 500ms with a burst of 1: robots.txt, then the seed.
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  global_rate_limit: 2\n  global_rate_burst: 1\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	cfg.Deterministic = true
+	c := crawler.NewCrawler(cfg, crawltest.NewMockStorer(), nil)
+	// A second crawler in the process has limits of its own.
+	crawler.NewCrawler(loadCrawlerConfig(t, ""), crawltest.NewMockStorer(), nil)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if got := server.Requests(); !slices.Contains(got, "/robots.txt") || !slices.Contains(got, "/") {
+		t.Fatalf("requests = %v, want robots.txt and the seed", got)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("crawl took %s; the robots.txt fetch should have used the only burst token", elapsed)
+	}
+}
+
+func TestCrawlDecodesBOMPages(t *testing.T) {
+	titles := map[string]string{"/utf8": "Grüße aus Köln", "/utf16le": "Größe in Zürich", "/utf16be": "Ärger in Düsseldorf"}
+	encodeUTF16 := func(order unicode.Endianness, path string) string {
+		body, err := unicode.UTF16(order, unicode.UseBOM).NewEncoder().String(article(titles[path]) + `</body></html>`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	// The BOM decides the encoding, even over a wrong charset parameter.
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/utf8">a</a><a href="/utf16le">b</a><a href="/utf16be">c</a></body></html>`),
+		"/utf8":       {ContentType: "text/html; charset=iso-8859-1", Body: "\uFEFF" + article(titles["/utf8"]) + `</body></html>`},
+		"/utf16le":    {ContentType: "text/html; charset=utf-8", Body: encodeUTF16(unicode.LittleEndian, "/utf16le")},
+		"/utf16be":    {ContentType: "text/html", Body: encodeUTF16(unicode.BigEndian, "/utf16be")},
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	docs := storer.Documents()
+	if len(docs) != 4 {
+		t.Fatalf("stored %d documents, want 4: %v", len(docs), storer.URLs())
+	}
+	for _, doc := range docs[1:] {
+		want := titles[strings.TrimPrefix(doc.URL, server.URL)]
+		if doc.Title != want {
+			t.Errorf("%s: title = %q, want %q", doc.URL, doc.Title, want)
+		}
+		if !strings.HasPrefix(doc.MainContent, want+" is a fixture page") {
+			t.Errorf("%s: content = %q, want it to start with %q", doc.URL, doc.MainContent, want)
+		}
+	}
+}
+
+func TestDeterministicCrawlOrder(t *testing.T) {
+	fixtures := map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/c">c</a><a href="/a">a</a><a href="/b">b</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `<a href="/a/2">2</a><a href="/a/1">1</a></body></html>`),
+		"/a/1":        crawltest.HTML(article("Page A1") + `</body></html>`),
+		"/a/2":        crawltest.HTML(article("Page A2") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `<a href="/a/1">1</a></body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	}
+	crawl := func() []string {
+		server := crawltest.NewFixtureServer(fixtures)
+		defer server.Close()
+		cfg := loadCrawlerConfig(t, `  max_depth: 2
+  max_concurrency: 8
+  deterministic_seed: 42
+  user_agents:
+    - "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0"
+    - "Mozilla/5.0 (Windows NT 10.0) Chrome/126.0"
+`)
+		cfg.SeedURLs = []string{server.URL + "/"}
+		runCrawl(t, cfg)
+		return server.Requests()
+	}
+
+	// Links are queued in href order, whatever their order on the page.
+	want := []string{"/robots.txt", "/", "/a", "/b", "/c", "/a/1", "/a/2"}
+	for run := 1; run <= 3; run++ {
+		if got := crawl(); !slices.Equal(got, want) {
+			t.Fatalf("run %d requested %v, want %v", run, got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package crawltest provides fixtures and test doubles for exercising the
+// crawler end to end without network access or a running Milvus:
+//
+//	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+//		"/":           crawltest.HTML(`<html><body><article>...</article><a href="/a">a</a></body></html>`),
+//		"/a":          crawltest.HTML(`<html><body><article>...</article></body></html>`),
+//		"/robots.txt": crawltest.Text("User-agent: *\nDisallow: /private"),
+//	})
+//	defer server.Close()
+//
+//	storer := crawltest.NewMockStorer()
+//	c := crawler.NewCrawler(&config.CrawlerConfig{
+//		SeedURLs:       []string{server.URL + "/"},
+//		MaxDepth:       2,
+//		Deterministic:  true, // one worker, seeded randomness, sorted links
+//	}, storer, nil)
+//	if err := c.Start(context.Background()); err != nil {
+//		t.Fatal(err)
+//	}
+//
+//	// Assert on c.VisitedURLs(), storer.URLs() and server.Requests().
+package crawltest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+
+	"crawlengine/crawler"
+	"crawlengine/storage"
+)
+
+// Fixture is a canned HTTP response served by a FixtureServer.
+type Fixture struct {
+	Status      int // defaults to 200
+	ContentType string
+	Header      http.Header
+	Body        string
+}
+
+// HTML returns a 200 text/html fixture.
+func HTML(body string) Fixture {
+	return Fixture{ContentType: "text/html; charset=utf-8", Body: body}
+}
+
+// Text returns a 200 text/plain fixture, e.g. for robots.txt.
+func Text(body string) Fixture {
+	return Fixture{ContentType: "text/plain; charset=utf-8", Body: body}
+}
+
+// XML returns a 200 application/xml fixture, e.g. for sitemaps.
+func XML(body string) Fixture {
+	return Fixture{ContentType: "application/xml", Body: body}
+}
+
+// Redirect returns a fixture redirecting to location with the given 3xx status.
+func Redirect(status int, location string) Fixture {
+	return Fixture{Status: status, Header: http.Header{"Location": {location}}}
+}
+
+// FixtureServer is an httptest.Server serving fixtures by request path.
+// Unknown paths return 404.
+type FixtureServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// NewFixtureServer starts a server serving fixtures keyed by URL path
+// (including the query string, if any).
+func NewFixtureServer(fixtures map[string]Fixture) *FixtureServer {
+	fs := &FixtureServer{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		fs.requests = append(fs.requests, r.URL.RequestURI())
+		fs.mu.Unlock()
+
+		fixture, ok := fixtures[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		for name, values := range fixture.Header {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		if fixture.ContentType != "" {
+			w.Header().Set("Content-Type", fixture.ContentType)
+		}
+		status := fixture.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, fixture.Body)
+	}))
+	return fs
+}
+
+// Requests returns the request URIs received so far, in arrival order.
+func (fs *FixtureServer) Requests() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]string(nil), fs.requests...)
+}
+
+// MockStorer is an in-memory storage.Storer that records stored documents.
+type MockStorer struct {
+	mu   sync.Mutex
+	docs []*storage.WebDocument
+	// Err, if set, is returned by StoreDocument instead of storing.
+	Err    error
+	closed bool
+}
+
+var _ storage.Storer = (*MockStorer)(nil)
+
+func NewMockStorer() *MockStorer {
+	return &MockStorer{}
+}
+
+func (m *MockStorer) StoreDocument(ctx context.Context, doc *storage.WebDocument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.docs = append(m.docs, doc)
+	return nil
+}
+
+func (m *MockStorer) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+}
+
+// Documents returns the stored documents in store order.
+func (m *MockStorer) Documents() []*storage.WebDocument {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*storage.WebDocument(nil), m.docs...)
+}
+
+// URLs returns the sorted URLs of the stored documents.
+func (m *MockStorer) URLs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	urls := make([]string, 0, len(m.docs))
+	for _, doc := range m.docs {
+		urls = append(urls, doc.URL)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// MockHTTPClient is a crawler.HTTPClient serving canned results by URL.
+// URLs without a result fail with a 404 error.
+type MockHTTPClient struct {
+	mu       sync.Mutex
+	Results  map[string]*crawler.FetchResult
+	requests []string
+}
+
+var _ crawler.HTTPClient = (*MockHTTPClient)(nil)
+
+func (m *MockHTTPClient) Get(ctx context.Context, targetURL string, userAgent string) (*crawler.FetchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, targetURL)
+	result, ok := m.Results[targetURL]
+	if !ok {
+		return nil, &crawler.CrawlError{Category: crawler.ErrCategoryHTTPStatus, StatusCode: http.StatusNotFound, Err: fmt.Errorf("no mock result for %s", targetURL)}
+	}
+	return result, nil
+}
+
+// Requests returns the URLs requested so far, in request order.
+func (m *MockHTTPClient) Requests() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.requests...)
+}
@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the date formats accepted for publication dates and
+// sitemap lastmod values (W3C Datetime profiles of ISO 8601).
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// ParseDate parses a date string in any of the supported layouts.
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	var err error
+	for _, layout := range dateLayouts {
+		var parsed time.Time
+		parsed, err = time.Parse(layout, value)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// ParseCutoff parses a content date cutoff. It accepts a relative age such
+// as "7d" or "36h" (measured back from now) or an absolute date.
+func ParseCutoff(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil {
+		return now.Add(-age), nil
+	}
+	if date, err := ParseDate(value); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid cutoff %q: expected a relative age like \"7d\" or a date", value)
+}
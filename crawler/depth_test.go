@@ -0,0 +1,33 @@
+package crawler_test
+
+import (
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestStoredDocumentsRecordCrawlDepth(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `<a href="/">home</a><a href="/b">b</a></body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `<a href="/c">c</a></body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 2\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	want := map[string]int64{server.URL + "/": 0, server.URL + "/a": 1, server.URL + "/b": 2}
+	docs := storer.Documents()
+	if len(docs) != len(want) {
+		t.Fatalf("stored %v, want %d pages", storer.URLs(), len(want))
+	}
+	for _, doc := range docs {
+		if doc.CrawlDepth != want[doc.URL] {
+			t.Errorf("%s: crawl_depth = %d, want %d", doc.URL, doc.CrawlDepth, want[doc.URL])
+		}
+	}
+}
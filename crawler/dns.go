@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cachingResolver resolves host names for the shared transport, optionally
+// through custom DNS servers, and caches the results.
+// Go's resolver does not expose record TTLs, so cached entries live for a
+// fixed, configurable TTL instead.
+type cachingResolver struct {
+	resolver *net.Resolver
+	ttl      time.Duration
+	maxSize  int // 0 disables caching
+
+	mu      sync.Mutex
+	entries map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newCachingResolver(servers []string, cacheSize int, ttl time.Duration) *cachingResolver {
+	resolver := net.DefaultResolver
+	if len(servers) > 0 {
+		var next atomic.Uint64
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				// Round-robin over the configured servers instead of /etc/resolv.conf.
+				server := servers[int(next.Add(1)-1)%len(servers)]
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+		log.Printf("Using custom DNS servers: %v", servers)
+	}
+	return &cachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		maxSize:  cacheSize,
+		entries:  make(map[string]dnsCacheEntry),
+	}
+}
+
+// LookupHost returns the addresses of host, from the cache when possible.
+func (r *cachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r.maxSize > 0 {
+		r.mu.Lock()
+		entry, ok := r.entries[host]
+		r.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.addrs, nil
+		}
+	}
+
+	addrs, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.maxSize > 0 {
+		r.mu.Lock()
+		if len(r.entries) >= r.maxSize {
+			r.evictLocked()
+		}
+		r.entries[host] = dnsCacheEntry{addrs: addrs, expires: time.Now().Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// evictLocked drops expired entries, or the entry closest to expiry if none have expired.
+func (r *cachingResolver) evictLocked() {
+	now := time.Now()
+	var oldestHost string
+	var oldest time.Time
+	for host, entry := range r.entries {
+		if now.After(entry.expires) {
+			delete(r.entries, host)
+			continue
+		}
+		if oldestHost == "" || entry.expires.Before(oldest) {
+			oldestHost, oldest = host, entry.expires
+		}
+	}
+	if len(r.entries) >= r.maxSize && oldestHost != "" {
+		delete(r.entries, oldestHost)
+	}
+}
+
+// DialContext resolves the host of address through the resolver and dials
+// the resulting IPs in order until one succeeds.
+func (r *cachingResolver) DialContext(dialer contextDialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses found for %s", host)
+		}
+		return nil, lastErr
+	}
+}
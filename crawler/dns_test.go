@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachingResolverDialsCachedAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// fixture.test does not resolve, so the dial only succeeds through the cache.
+	r := newCachingResolver(nil, 10, time.Minute)
+	r.entries["fixture.test"] = dnsCacheEntry{addrs: []string{"127.0.0.1"}, expires: time.Now().Add(time.Minute)}
+	conn, err := r.DialContext(&net.Dialer{})(context.Background(), "tcp", net.JoinHostPort("fixture.test", port))
+	if err != nil {
+		t.Fatalf("dial through the cached address: %v", err)
+	}
+	conn.Close()
+
+	addrs, err := r.LookupHost(context.Background(), "fixture.test")
+	if err != nil || len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+		t.Errorf("LookupHost = %v, %v, want the cached address", addrs, err)
+	}
+}
+
+func TestCachingResolverEviction(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		maxSize int
+		expires map[string]time.Duration // host -> expiry from now
+		want    []string                 // hosts kept
+	}{
+		{"expired first", 3, map[string]time.Duration{"a": -time.Second, "b": time.Minute, "c": 2 * time.Minute}, []string{"b", "c"}},
+		{"closest to expiry", 2, map[string]time.Duration{"a": time.Minute, "b": 2 * time.Minute}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newCachingResolver(nil, tt.maxSize, time.Minute)
+			for host, ttl := range tt.expires {
+				r.entries[host] = dnsCacheEntry{expires: now.Add(ttl)}
+			}
+			r.evictLocked()
+			if len(r.entries) != len(tt.want) {
+				t.Errorf("kept %d entries, want %v", len(r.entries), tt.want)
+			}
+			for _, host := range tt.want {
+				if _, ok := r.entries[host]; !ok {
+					t.Errorf("%s was evicted, want it kept", host)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package crawler_test
+
+import (
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestDocumentLimitsStorePartialContent(t *testing.T) {
+	body := `<html><head><title>Big</title></head><body><article><p>First paragraph is a fixture with enough words to be stored.</p>` +
+		strings.Repeat(`<p>Filler paragraph that pushes the page over its limits.</p>`, 50) +
+		`<p>Last paragraph.</p></article></body></html>`
+	tests := []struct {
+		name   string
+		limits string
+	}{
+		{"max_document_bytes", "  max_document_bytes: 300\n"},
+		{"max_dom_nodes", "  max_dom_nodes: 20\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+				"/":           crawltest.HTML(body),
+				"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+			})
+			defer server.Close()
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 0\n"+tt.limits)
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			docs := storer.Documents()
+			if len(docs) != 1 {
+				t.Fatalf("stored %v, want the page", storer.URLs())
+			}
+			content := docs[0].MainContent
+			if !strings.Contains(content, "First paragraph") || strings.Contains(content, "Last paragraph") {
+				t.Errorf("main content = %q, want only the start of the page", content)
+			}
+		})
+	}
+}
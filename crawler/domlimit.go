@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"log"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// limitDocument prunes doc to the configured node count and depth, so
+// extraction on a huge or deeply nested page is bounded. What remains is
+// extracted and stored as partial content.
+func (c *Crawler) limitDocument(doc *goquery.Document, pageURL string) {
+	if len(doc.Nodes) == 0 {
+		return
+	}
+	if nodes, pruned := limitDOM(doc.Nodes[0], c.Config.MaxDOMNodes, c.Config.MaxDOMDepth); pruned {
+		log.Printf("Extraction of %s cut short: document pruned to %d nodes (max_dom_nodes %d, max_dom_depth %d), storing partial content", pageURL, nodes, c.Config.MaxDOMNodes, c.Config.MaxDOMDepth)
+	}
+}
+
+// limitDOM removes every node after the first maxNodes in document order
+// and the children of nodes maxDepth levels below root. A limit <= 0 is not
+// applied. It returns the number of nodes kept and whether any were removed.
+func limitDOM(root *html.Node, maxNodes, maxDepth int) (int, bool) {
+	nodes, depth, pruned := 0, 0, false
+	n := root
+	for {
+		nodes++
+		if maxNodes > 0 && nodes > maxNodes {
+			removeFrom(root, n)
+			return maxNodes, true
+		}
+		if maxDepth > 0 && depth >= maxDepth && n.FirstChild != nil {
+			for n.FirstChild != nil {
+				n.RemoveChild(n.FirstChild)
+			}
+			pruned = true
+		}
+
+		if n.FirstChild != nil {
+			n = n.FirstChild
+			depth++
+			continue
+		}
+		for n != root && n.NextSibling == nil {
+			n = n.Parent
+			depth--
+		}
+		if n == root {
+			return nodes, pruned
+		}
+		n = n.NextSibling
+	}
+}
+
+// removeFrom removes n and every node after it in document order from the
+// tree under root.
+func removeFrom(root, n *html.Node) {
+	for p := n; p != root; p = p.Parent {
+		for s := p.NextSibling; s != nil; {
+			next := s.NextSibling
+			p.Parent.RemoveChild(s)
+			s = next
+		}
+	}
+	n.Parent.RemoveChild(n)
+}
@@ -0,0 +1,44 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestLimitDOM(t *testing.T) {
+	const page = `<html><head></head><body><div><p>one</p><p>two <b>bold</b></p></div><p>three</p></body></html>`
+	tests := []struct {
+		name       string
+		maxNodes   int
+		maxDepth   int
+		want       string
+		wantNodes  int
+		wantPruned bool
+	}{
+		{"unlimited", 0, 0, page, 13, false},
+		{"nodes", 9, 0, `<html><head></head><body><div><p>one</p><p>two </p></div></body></html>`, 9, true},
+		{"depth", 0, 4, `<html><head></head><body><div><p></p><p></p></div><p>three</p></body></html>`, 9, true},
+		{"both within limits", 13, 10, page, 13, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := html.Parse(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, pruned := limitDOM(root, tt.maxNodes, tt.maxDepth)
+			var b strings.Builder
+			if err := html.Render(&b, root); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("pruned document = %s\nwant %s", got, tt.want)
+			}
+			if nodes != tt.wantNodes || pruned != tt.wantPruned {
+				t.Errorf("limitDOM = %d, %t, want %d, %t", nodes, pruned, tt.wantNodes, tt.wantPruned)
+			}
+		})
+	}
+}
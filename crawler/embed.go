@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"crawlengine/embedder"
+	"crawlengine/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// embedDocuments sets the content vectors of docs and, with a title
+// embedder, their title vectors, with one batch embedder call per vector
+// field. A document whose embedding fails is logged and left without that
+// vector, so it is stored under the missing vector policy for the
+// re-embedding job; the rest of the batch is unaffected.
+func (c *Crawler) embedDocuments(ctx context.Context, docs []*storage.WebDocument) {
+	if c.embedder == nil {
+		return
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = embedder.ContentText(doc.Title, doc.MainContent, c.embedContentChars)
+	}
+	vectors, err := c.embed(ctx, c.embedder, storage.FieldContentVector, texts)
+	for i, doc := range docs {
+		if err := embedder.ErrorAt(err, i); err != nil {
+			log.Printf("Error embedding content of %s: %v", doc.URL, err)
+			c.checkDimension(err)
+		} else {
+			doc.ContentVector = vectors[i]
+		}
+	}
+	if c.titleEmbedder == nil {
+		return
+	}
+	for i, doc := range docs {
+		texts[i] = embedder.TitleText(doc.Title, doc.HeadingsText)
+	}
+	vectors, err = c.embed(ctx, c.titleEmbedder, storage.FieldTitleVector, texts)
+	for i, doc := range docs {
+		if err := embedder.ErrorAt(err, i); err != nil {
+			log.Printf("Error embedding title of %s: %v", doc.URL, err)
+			c.checkDimension(err)
+		} else {
+			doc.TitleVector = vectors[i]
+		}
+	}
+}
+
+// checkDimension stops the crawl the first time err reports vectors of
+// another dimension than configured: the model behind the embedder changed,
+// so every later document would fail the same way. Documents already
+// fetched are still stored under the missing vector policy.
+func (c *Crawler) checkDimension(err error) {
+	var dimErr *embedder.DimensionError
+	if !errors.As(err, &dimErr) || !c.dimMismatch.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("Embedder now produces %d-dimensional vectors instead of %d. Stopping the crawl; restart it to apply milvus.on_dimension_mismatch.", dimErr.Got, dimErr.Want)
+	c.frontier.Close()
+}
+
+func (c *Crawler) embed(ctx context.Context, textEmbedder embedder.TextEmbedder, field string, texts []string) ([][]float32, error) {
+	ctx, span := tracer.Start(ctx, "crawler.embed", trace.WithAttributes(attribute.String("field", field), attribute.Int("batch_size", len(texts))))
+	vectors, err := textEmbedder.EmbedBatch(ctx, texts)
+	endSpan(span, err)
+	return vectors, err
+}
+
+// SetEmbedContentChars truncates main content to maxChars characters before
+// it is embedded; 0, the default, embeds it whole. It must be called before
+// Start.
+func (c *Crawler) SetEmbedContentChars(maxChars int) {
+	c.embedContentChars = maxChars
+}
+
+// SetTitleEmbedder sets the embedder for title vectors, which are only
+// computed when it is set. It must be called before Start.
+func (c *Crawler) SetTitleEmbedder(titleEmbedder embedder.TextEmbedder) {
+	c.titleEmbedder = titleEmbedder
+}
@@ -0,0 +1,227 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+	"crawlengine/embedder"
+)
+
+// fakeEmbedder records the texts it embeds. Its vectors have the text
+// length in the first dimension, unless vector is set.
+type fakeEmbedder struct {
+	dimension int
+	vector    func(text string) []float32
+
+	mu      sync.Mutex
+	texts   []string
+	batches []int // sizes of the EmbedBatch calls
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.mu.Lock()
+	e.texts = append(e.texts, text)
+	e.mu.Unlock()
+	if e.vector != nil {
+		return e.vector(text), nil
+	}
+	vector := make([]float32, e.dimension)
+	vector[0] = float32(len(text))
+	return vector, nil
+}
+
+func (e *fakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.batches = append(e.batches, len(texts))
+	e.mu.Unlock()
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = e.Embed(ctx, text)
+	}
+	return vectors, nil
+}
+
+func (e *fakeEmbedder) Dimension() int {
+	return e.dimension
+}
+
+// Texts returns the embedded texts in embedding order.
+func (e *fakeEmbedder) Texts() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.texts...)
+}
+
+// embedCrawl crawls from cfg's seeds with content and, if titles is set,
+// title embedders.
+func embedCrawl(t *testing.T, cfg *config.CrawlerConfig, content, titles embedder.TextEmbedder) *crawltest.MockStorer {
+	t.Helper()
+	cfg.Deterministic = true
+	storer := crawltest.NewMockStorer()
+	c := crawler.NewCrawler(cfg, storer, content)
+	if titles != nil {
+		c.SetTitleEmbedder(titles)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return storer
+}
+
+func TestEmbedDocumentsWithTitleVectors(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(strings.Replace(article("Home"), "<article>", "<article><h2>Welcome</h2>", 1) + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  embed_documents: true\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	content, titles := &fakeEmbedder{dimension: 4}, &fakeEmbedder{dimension: 2}
+	storer := embedCrawl(t, cfg, content, titles)
+
+	docs := storer.Documents()
+	if len(docs) != 1 {
+		t.Fatalf("stored %v, want the seed", storer.URLs())
+	}
+	doc := docs[0]
+	if len(doc.ContentVector) != 4 || len(doc.TitleVector) != 2 {
+		t.Errorf("vector dimensions = %d and %d, want 4 and 2", len(doc.ContentVector), len(doc.TitleVector))
+	}
+	if got := titles.Texts(); len(got) != 1 || got[0] != "Home\nWelcome" {
+		t.Errorf("title embedder texts = %q, want the title and headings", got)
+	}
+	if got := content.Texts(); len(got) != 1 || !strings.HasPrefix(got[0], "Home\n") || !strings.Contains(got[0], "fixture page") {
+		t.Errorf("content embedder texts = %q, want the title and main content", got)
+	}
+}
+
+func TestEmbedContentChars(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  embed_documents: true\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	cfg.Deterministic = true
+	content := &fakeEmbedder{dimension: 4}
+	storer := crawltest.NewMockStorer()
+	c := crawler.NewCrawler(cfg, storer, content)
+	c.SetEmbedContentChars(20)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if got, want := content.Texts(), []string{"Home\nHome is a fixture"}; !slices.Equal(got, want) {
+		t.Errorf("embedded texts = %q, want %q", got, want)
+	}
+	if docs := storer.Documents(); len(docs) != 1 || !strings.HasSuffix(docs[0].MainContent, "stored by the crawler.") {
+		t.Errorf("stored documents = %d, want the seed with its whole content", len(docs))
+	}
+}
+
+// slowEmbedder delays each embedder call and records how many overlap.
+type slowEmbedder struct {
+	*fakeEmbedder
+	delay time.Duration
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (e *slowEmbedder) wait() func() {
+	e.mu.Lock()
+	e.active++
+	e.maxActive = max(e.maxActive, e.active)
+	e.mu.Unlock()
+	time.Sleep(e.delay)
+	return func() {
+		e.mu.Lock()
+		e.active--
+		e.mu.Unlock()
+	}
+}
+
+func (e *slowEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	defer e.wait()()
+	return e.fakeEmbedder.Embed(ctx, text)
+}
+
+func (e *slowEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	defer e.wait()()
+	return e.fakeEmbedder.EmbedBatch(ctx, texts)
+}
+
+// hubServer serves a home page linking to n article pages.
+func hubServer(n int) *crawltest.FixtureServer {
+	fixtures := map[string]crawltest.Fixture{
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	}
+	links := ""
+	for i := 1; i <= n; i++ {
+		path := fmt.Sprintf("/%d", i)
+		links += `<a href="` + path + `">` + path + `</a>`
+		fixtures[path] = crawltest.HTML(article("Page "+path) + `</body></html>`)
+	}
+	fixtures["/"] = crawltest.HTML(article("Home") + links + `</body></html>`)
+	return crawltest.NewFixtureServer(fixtures)
+}
+
+func TestEmbedConcurrency(t *testing.T) {
+	server := hubServer(5)
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  embed_documents: true\n  embed_concurrency: 2\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	content := &slowEmbedder{fakeEmbedder: &fakeEmbedder{dimension: 4}, delay: 50 * time.Millisecond}
+	storer := embedCrawl(t, cfg, content, nil)
+
+	docs := storer.Documents()
+	if len(docs) != 6 {
+		t.Fatalf("stored %v, want the seed and its 5 links", storer.URLs())
+	}
+	for _, doc := range docs {
+		if len(doc.ContentVector) != 4 {
+			t.Errorf("%s stored without its content vector", doc.URL)
+		}
+	}
+	if content.maxActive != 2 {
+		t.Errorf("%d embedder calls overlapped, want embed_concurrency 2", content.maxActive)
+	}
+}
+
+func TestEmbedBatchSize(t *testing.T) {
+	server := hubServer(6)
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  embed_documents: true\n  embed_concurrency: 1\n  embed_batch_size: 3\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	content := &slowEmbedder{fakeEmbedder: &fakeEmbedder{dimension: 4}, delay: 50 * time.Millisecond}
+	storer := embedCrawl(t, cfg, content, nil)
+
+	if docs := storer.Documents(); len(docs) != 7 {
+		t.Fatalf("stored %v, want the seed and its 6 links", storer.URLs())
+	}
+	batches := content.batches
+	total := 0
+	for _, size := range batches {
+		if size > 3 {
+			t.Errorf("embedded a batch of %d, want at most embed_batch_size 3", size)
+		}
+		total += size
+	}
+	if total != 7 || len(batches) >= 7 {
+		t.Errorf("embedded batches of %v, want 7 documents in fewer calls", batches)
+	}
+}
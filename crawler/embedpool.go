@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"crawlengine/storage"
+)
+
+// embedJob is a document waiting for the embedding workers, with the
+// callback that receives the result of storing it.
+type embedJob struct {
+	ctx  context.Context
+	doc  *storage.WebDocument
+	done func(error)
+}
+
+// defaultEmbedBatchSize is the most documents an embed worker embeds per
+// batch when embed_batch_size is not set.
+const defaultEmbedBatchSize = 16
+
+// startEmbedWorkers starts embed_concurrency workers that embed and store
+// the documents sent to c.embedQueue until it is closed. The returned
+// channel is closed once they have drained the queue.
+func (c *Crawler) startEmbedWorkers() <-chan struct{} {
+	size := c.Config.EmbedQueueSize
+	if size <= 0 {
+		size = c.Config.EmbedConcurrency * 10
+	}
+	c.embedQueue = make(chan embedJob, size)
+	batchSize := c.Config.EmbedBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbedBatchSize
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.Config.EmbedConcurrency; i++ {
+		wg.Add(1)
+		go c.embedWorker(&wg, i, batchSize)
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	return drained
+}
+
+// embedWorker takes each document from the queue together with whatever
+// else is already waiting, up to batchSize documents, so under load one
+// embedder call covers many documents while a quiet queue adds no delay.
+func (c *Crawler) embedWorker(wg *sync.WaitGroup, id, batchSize int) {
+	defer wg.Done()
+	log.Printf("Embed worker %d started", id)
+	batch := make([]embedJob, 0, batchSize)
+	for job := range c.embedQueue {
+		batch = append(batch[:0], job)
+	fill:
+		for len(batch) < batchSize {
+			select {
+			case job, ok := <-c.embedQueue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, job)
+			default:
+				break fill
+			}
+		}
+		c.embedAndStoreBatch(batch)
+	}
+	log.Printf("Embed worker %d: Queue drained, exiting.", id)
+}
+
+// embedAndStoreBatch embeds the documents of batch together and checks them
+// for semantic duplicates in one search, then stores each one and reports
+// the result to its job. The embed and search spans belong to the first
+// job's trace.
+func (c *Crawler) embedAndStoreBatch(batch []embedJob) {
+	docs := make([]*storage.WebDocument, len(batch))
+	for i, job := range batch {
+		docs[i] = job.doc
+	}
+	c.embedDocuments(batch[0].ctx, docs)
+	skip := c.findSemanticDuplicates(batch[0].ctx, docs)
+	for i, job := range batch {
+		if !skip[i] {
+			job.done(c.store(job.ctx, job.doc))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// xmlDeclRegex matches the encoding of an <?xml ...?> declaration at the
+// start of a body, as used by XHTML pages and feeds.
+var xmlDeclRegex = regexp.MustCompile(`^\s*<\?xml\s[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// decodeBody converts a response body to UTF-8 and strips any byte-order
+// mark, so it can't leak into the first extracted field. The encoding is
+// taken from, in order:
+//
+//  1. a UTF-8 or UTF-16 byte-order mark
+//  2. the charset parameter of contentType
+//  3. UTF-8, if the body is valid UTF-8, since a declaration disagreeing
+//     with valid UTF-8 is far more often wrong than the bytes are
+//  4. the encoding of an <?xml ...?> declaration
+//  5. an HTML <meta> charset, falling back to windows-1252 as browsers do
+//
+// When the body is converted from an XML-declared encoding, the declaration
+// is rewritten to UTF-8 so that XML parsers don't decode it a second time.
+func decodeBody(body []byte, contentType string) string {
+	enc, _, certain := charset.DetermineEncoding(body, contentType)
+	rewriteDecl := false
+	if !certain {
+		if utf8.Valid(body) {
+			return strings.TrimPrefix(string(body), "\uFEFF")
+		}
+		if declared, ok := xmlDeclaredEncoding(body); ok {
+			enc, rewriteDecl = declared, true
+		}
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		decoded = body // undecodable with the declared encoding; keep the raw bytes
+		rewriteDecl = false
+	}
+	decoded = bytes.TrimPrefix(decoded, []byte("\uFEFF"))
+	if rewriteDecl {
+		if loc := xmlDeclRegex.FindSubmatchIndex(decoded); loc != nil {
+			decoded = append(append(append([]byte{}, decoded[:loc[2]]...), "UTF-8"...), decoded[loc[3]:]...)
+		}
+	}
+	return string(decoded)
+}
+
+// xmlDeclaredEncoding returns the known encoding named by body's XML declaration.
+func xmlDeclaredEncoding(body []byte) (encoding.Encoding, bool) {
+	head := body
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	m := xmlDeclRegex.FindSubmatch(head)
+	if m == nil {
+		return nil, false
+	}
+	enc, _ := charset.Lookup(string(m[1]))
+	return enc, enc != nil
+}
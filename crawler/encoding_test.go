@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const bomPage = `<html><head><title>Café résumé</title></head><body><p>Grüße, 東京</p></body></html>`
+
+// encode converts s from UTF-8 with enc.
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("encoding fixture: %v", err)
+	}
+	return b
+}
+
+func TestDecodeBody(t *testing.T) {
+	utf16LE := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	utf16BE := unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	latin1Page := `<html><head><title>Café résumé</title></head><body><p>Grüße</p></body></html>`
+	sjisFeed := `<?xml version="1.0" encoding="Shift_JIS"?><rss><channel><title>東京</title></channel></rss>`
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"UTF-8 BOM", append([]byte("\xEF\xBB\xBF"), bomPage...), "text/html", bomPage},
+		{"UTF-8 BOM over a wrong charset", append([]byte("\xEF\xBB\xBF"), bomPage...), "text/html; charset=iso-8859-1", bomPage},
+		{"UTF-16 LE BOM", encode(t, utf16LE, bomPage), "text/html", bomPage},
+		{"UTF-16 BE BOM", encode(t, utf16BE, bomPage), "text/html", bomPage},
+		{"UTF-16 LE BOM over a wrong charset", encode(t, utf16LE, bomPage), "text/html; charset=utf-8", bomPage},
+		{"UTF-16 BE BOM without content type", encode(t, utf16BE, bomPage), "", bomPage},
+		{"no BOM, valid UTF-8", []byte(bomPage), "text/html", bomPage},
+		{"charset parameter", encode(t, charmap.ISO8859_1, latin1Page), "text/html; charset=iso-8859-1", latin1Page},
+		{"windows-1252 fallback", encode(t, charmap.Windows1252, latin1Page), "text/html", latin1Page},
+		{"XML declaration", encode(t, japanese.ShiftJIS, sjisFeed), "application/xml", strings.Replace(sjisFeed, "Shift_JIS", "UTF-8", 1)},
+		{"XML declaration in valid UTF-8", []byte(strings.Replace(sjisFeed, "Shift_JIS", "ISO-8859-1", 1)), "application/xml", strings.Replace(sjisFeed, "Shift_JIS", "ISO-8859-1", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeBody(tt.body, tt.contentType)
+			if got != tt.want {
+				t.Errorf("decodeBody = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyTitle(t *testing.T) {
+	bodies := map[string][]byte{
+		"UTF-8 BOM":     append([]byte("\xEF\xBB\xBF"), bomPage...),
+		"UTF-16 LE BOM": encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), bomPage),
+		"UTF-16 BE BOM": encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), bomPage),
+	}
+	for name, body := range bodies {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(decodeBody(body, "text/html")))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if title := doc.Find("title").Text(); title != "Café résumé" {
+			t.Errorf("%s: title = %q, want %q", name, title, "Café résumé")
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoSeeds is returned by Start when there is no valid, in-scope seed URL
+// to crawl.
+var ErrNoSeeds = errors.New("no valid seed URLs")
+
+// ErrorCategory classifies why crawling a URL failed.
+type ErrorCategory string
+
+const (
+	ErrCategoryInvalidURL ErrorCategory = "invalid_url"
+	ErrCategoryNetwork    ErrorCategory = "network"
+	ErrCategoryHTTPStatus ErrorCategory = "http_status"
+	ErrCategoryParse      ErrorCategory = "parse"
+	ErrCategoryStorage    ErrorCategory = "storage"
+	ErrCategoryUnknown    ErrorCategory = "unknown"
+)
+
+// CrawlError is a categorized error produced while crawling a URL.
+type CrawlError struct {
+	Category   ErrorCategory
+	StatusCode int // HTTP status code, if a response was received
+	Err        error
+}
+
+func (e *CrawlError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (status %d): %v", e.Category, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Category, e.Err)
+}
+
+func (e *CrawlError) Unwrap() error {
+	return e.Err
+}
+
+func newCrawlError(category ErrorCategory, statusCode int, err error) *CrawlError {
+	return &CrawlError{Category: category, StatusCode: statusCode, Err: err}
+}
+
+// CategorizeError returns the category and HTTP status code of err.
+// Errors that are not a *CrawlError are reported as ErrCategoryUnknown.
+func CategorizeError(err error) (ErrorCategory, int) {
+	var crawlErr *CrawlError
+	if errors.As(err, &crawlErr) {
+		return crawlErr.Category, crawlErr.StatusCode
+	}
+	return ErrCategoryUnknown, 0
+}
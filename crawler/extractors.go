@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"log"
+	"mime"
+	"strings"
+
+	"crawlengine/config"
+)
+
+// Content extractors that content_types can map a media type to.
+const (
+	ExtractorHTML = "html"
+	ExtractorFeed = "feed"
+	ExtractorJSON = "json" // needs a json mapping in the host's extraction rule
+	ExtractorPDF  = "pdf"
+	ExtractorNone = "none" // skips a type registered by default
+)
+
+// htmlTypes, feedTypes and jsonTypes are the media types registered by
+// default: HTML always, feeds with parse_feeds and JSON when an extraction
+// rule has a json mapping.
+var (
+	htmlTypes = []string{"text/html", "application/xhtml+xml"}
+	feedTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json", "application/xml", "text/xml"}
+	jsonTypes = []string{"application/json"}
+)
+
+// contentExtractors maps response media types to the extractor that handles
+// them. A nil *contentExtractors keeps the behavior from before there was a
+// registry: JSON goes to the json extractor and everything else is HTML.
+type contentExtractors struct {
+	byType map[string]string
+}
+
+// newContentExtractors registers the default media types, then applies
+// content_types on top of them. Entries naming an unknown extractor are
+// skipped with a warning.
+func newContentExtractors(cfg *config.CrawlerConfig, rules *extractionRules) *contentExtractors {
+	e := &contentExtractors{byType: make(map[string]string)}
+	register := func(types []string, extractor string) {
+		for _, mediaType := range types {
+			e.byType[mediaType] = extractor
+		}
+	}
+	register(htmlTypes, ExtractorHTML)
+	if cfg.ParseFeeds {
+		register(feedTypes, ExtractorFeed)
+	}
+	if rules.hasJSONMapping() {
+		register(jsonTypes, ExtractorJSON)
+	}
+	for mediaType, extractor := range cfg.ContentTypes {
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		extractor = strings.ToLower(strings.TrimSpace(extractor))
+		switch extractor {
+		case ExtractorHTML, ExtractorFeed, ExtractorJSON, ExtractorPDF, ExtractorNone:
+			e.byType[mediaType] = extractor
+		default:
+			log.Printf("Warning: Unsupported extractor '%s' for content type %s in content_types, ignoring it.", extractor, mediaType)
+		}
+	}
+	return e
+}
+
+// For returns the extractor registered for contentType, or "" if the type
+// should be skipped. Besides the exact media type, a structured syntax
+// suffix is looked up as its base type (application/ld+json as
+// application/json) and any type as its "type/*" wildcard.
+func (e *contentExtractors) For(contentType string) string {
+	if e == nil {
+		if IsJSON(contentType) {
+			return ExtractorJSON
+		}
+		return ExtractorHTML
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(strings.ToLower(contentType), ";")
+		mediaType = strings.TrimSpace(mediaType)
+	}
+	candidates := []string{mediaType}
+	if _, suffix, ok := strings.Cut(mediaType, "+"); ok {
+		candidates = append(candidates, "application/"+suffix)
+	}
+	if major, _, ok := strings.Cut(mediaType, "/"); ok {
+		candidates = append(candidates, major+"/*")
+	}
+	for _, candidate := range candidates {
+		if extractor, ok := e.byType[candidate]; ok {
+			if extractor == ExtractorNone {
+				return ""
+			}
+			return extractor
+		}
+	}
+	return ""
+}
+
+// extractorFor returns the extractor registered for result's content type.
+// A parsed result without a content type, as from HTTP clients that don't
+// set headers, is HTML.
+func (c *Crawler) extractorFor(result *FetchResult) string {
+	contentType := result.Header.Get("Content-Type")
+	if contentType == "" && result.Doc != nil {
+		return ExtractorHTML
+	}
+	return c.extractors.For(contentType)
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"crawlengine/config"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func parseHTML(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestContentExtractorsFor(t *testing.T) {
+	cfg := &config.CrawlerConfig{
+		ParseFeeds: true,
+		ContentTypes: map[string]string{
+			"application/pdf":      "pdf",
+			"text/*":               "html",
+			"application/xml":      "none",
+			" Application/X-Doc ":  " PDF ",
+			"application/x-broken": "docx",
+		},
+	}
+	rules := newExtractionRules(map[string]config.ExtractionRule{
+		"api.example.com": {JSON: &config.JSONMapping{Title: "title"}},
+	})
+	e := newContentExtractors(cfg, rules)
+
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		// Registered by default, and by parse_feeds and a json mapping.
+		{"text/html", ExtractorHTML},
+		{"text/html; charset=utf-8", ExtractorHTML},
+		{"TEXT/HTML", ExtractorHTML},
+		{"application/xhtml+xml", ExtractorHTML},
+		{"application/rss+xml", ExtractorFeed},
+		{"application/json", ExtractorJSON},
+		// Structured syntax suffixes fall back to their base type.
+		{"application/ld+json", ExtractorJSON},
+		// Registered by content_types, with keys and values trimmed and lowercased.
+		{"application/pdf", ExtractorPDF},
+		{"application/x-doc", ExtractorPDF},
+		// "none" disables a type registered by default.
+		{"application/xml", ""},
+		// Wildcards match any subtype without a registration of its own.
+		{"text/plain", ExtractorHTML},
+		{"text/csv; charset=utf-8", ExtractorHTML},
+		// Unregistered types, and types mapped to unknown extractors, are skipped.
+		{"image/png", ""},
+		{"application/octet-stream", ""},
+		{"application/x-broken", ""},
+		{"", ""},
+		{"not a media type", ""},
+	}
+	for _, tt := range tests {
+		if got := e.For(tt.contentType); got != tt.want {
+			t.Errorf("For(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestContentExtractorsDefaults(t *testing.T) {
+	// Without parse_feeds or a json mapping only HTML is registered.
+	e := newContentExtractors(&config.CrawlerConfig{}, nil)
+	for contentType, want := range map[string]string{
+		"text/html":           ExtractorHTML,
+		"application/rss+xml": "",
+		"application/json":    "",
+		"application/pdf":     "",
+	} {
+		if got := e.For(contentType); got != want {
+			t.Errorf("For(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+
+	// A nil registry treats JSON as JSON and everything else as HTML.
+	var none *contentExtractors
+	if got := none.For("application/json"); got != ExtractorJSON {
+		t.Errorf("nil registry: For(application/json) = %q", got)
+	}
+	if got := none.For("image/png"); got != ExtractorHTML {
+		t.Errorf("nil registry: For(image/png) = %q", got)
+	}
+}
+
+func TestExtractorForResult(t *testing.T) {
+	c := &Crawler{extractors: newContentExtractors(&config.CrawlerConfig{}, nil)}
+	header := func(contentType string) http.Header {
+		h := http.Header{}
+		if contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+		return h
+	}
+	// A parsed result without a Content-Type, as from a client that sets no
+	// headers, is HTML; an unparsed one has no extractor.
+	if got := c.extractorFor(&FetchResult{Header: header(""), Doc: parseHTML(t, "<p>x</p>")}); got != ExtractorHTML {
+		t.Errorf("parsed result without a type: extractor = %q", got)
+	}
+	if got := c.extractorFor(&FetchResult{Header: header("")}); got != "" {
+		t.Errorf("unparsed result without a type: extractor = %q", got)
+	}
+	if got := c.extractorFor(&FetchResult{Header: header("image/png"), Doc: parseHTML(t, "")}); got != "" {
+		t.Errorf("image/png: extractor = %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// failureLog writes one line per failed URL of a crawl to a file as failures
+// happen, so a crash doesn't lose the list. Lines are tab-separated:
+//
+//	url	category	status	attempts	reason
+//
+// The first column is the URL, so the file can be passed back as seed_file
+// for a retry run. attempts counts the requests made for the URL, e.g. 2
+// when a HEAD request was refused and retried with GET.
+type failureLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openFailureLog starts the failed URLs file of a crawl, replacing the
+// previous crawl's, or with resumed appends to it, since a crawl resumed
+// from a checkpoint or frontier file is the same crawl.
+func openFailureLog(path string, resumed bool) (*failureLog, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resumed {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open failed URLs file %s: %w", path, err)
+	}
+
+	info, err := file.Stat()
+	if err == nil && info.Size() == 0 {
+		fmt.Fprintln(file, "# url\tcategory\tstatus\tattempts\treason")
+	}
+	return &failureLog{file: file}, nil
+}
+
+// Record appends a failure entry for targetURL.
+func (f *failureLog) Record(targetURL string, err error, attempts int) {
+	category, status := CategorizeError(err)
+	reason := strings.Join(strings.Fields(err.Error()), " ") // keep the record on one line
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, writeErr := fmt.Fprintf(f.file, "%s\t%s\t%d\t%d\t%s\n", targetURL, category, status, attempts, reason); writeErr != nil {
+		log.Printf("Error writing failed URL record for %s: %v", targetURL, writeErr)
+	}
+}
+
+func (f *failureLog) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// ReadSeedFile reads seed URLs from path, one per line. Only the first
+// tab-separated column is used, and blank lines and lines starting with '#'
+// are ignored, so a failed URLs file can be used as-is.
+func ReadSeedFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open seed file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var seeds []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, strings.SplitN(line, "\t", 2)[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read seed file %s: %w", path, err)
+	}
+	return seeds, nil
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"crawlengine/storage"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// feedItemHashPrefix keeps feed item IDs apart from page content hashes.
+const feedItemHashPrefix = "feed-item\x00"
+
+// IsFeed reports whether a response is an RSS, Atom or JSON feed, judged by
+// its root element. JSON feeds are only recognized when served as
+// application/feed+json, so that other JSON responses aren't mistaken for them.
+func IsFeed(contentType, body string) bool {
+	switch gofeed.DetectFeedType(strings.NewReader(body)) {
+	case gofeed.FeedTypeRSS, gofeed.FeedTypeAtom:
+		return true
+	case gofeed.FeedTypeJSON:
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		return mediaType == "application/feed+json"
+	}
+	return false
+}
+
+// handleFeed stores every item of the feed at pageURL as a document and
+// queues the item links for a full crawl. The feed itself is not stored.
+func (c *Crawler) handleFeed(ctx context.Context, task CrawlTask, pageURL string, baseURL *url.URL, header http.Header, body string) {
+	feed, err := gofeed.NewParser().ParseString(body)
+	if err != nil {
+		log.Printf("Error parsing feed %s: %v", pageURL, err)
+		c.recordFailure(pageURL, task.Attempts, newCrawlError(ErrCategoryParse, 0, err))
+		return
+	}
+	log.Printf("Parsed feed %s (%q) with %d items", pageURL, feed.Title, len(feed.Items))
+
+	linked := make(map[string]bool)
+	queued := 0
+	for _, item := range feed.Items {
+		link := item.Link
+		if link == "" && len(item.Links) > 0 {
+			link = item.Links[0]
+		}
+		itemURL := link
+		if link != "" {
+			if normalized, err := c.normalizer.Normalize(baseURL, link); err == nil {
+				itemURL = normalized
+			}
+		}
+
+		var published int64
+		if item.PublishedParsed != nil {
+			published = item.PublishedParsed.Unix()
+		} else if item.UpdatedParsed != nil {
+			published = item.UpdatedParsed.Unix()
+		}
+		// Unlike other documents, old items are skipped before the storage
+		// gates, so their links aren't followed either.
+		if c.isOlderThanCutoff(published) {
+			c.filteredByDate.Add(1)
+			continue
+		}
+
+		id := item.GUID
+		if id == "" {
+			id = itemURL
+		}
+		if id == "" {
+			id = item.Title
+		}
+		summary := feedText(item.Description)
+		content := feedText(item.Content)
+		if content == "" {
+			content = summary
+		}
+		content = c.textNormalizer.Normalize(content)
+		hashID := GenerateContentHash(feedItemHashPrefix+id, c.Config.ExtractionVersion)
+		doc := &storage.WebDocument{
+			HashID:               hashID,
+			URL:                  itemURL,
+			MainContent:          content,
+			Title:                c.textNormalizer.Normalize(strings.TrimSpace(item.Title)),
+			MetaDescription:      summary,
+			CanonicalURL:         itemURL,
+			Language:             feed.Language,
+			PublicationTimestamp: published,
+			CrawledAt:            time.Now().UTC(),
+			ExtractionVersion:    c.Config.ExtractionVersion,
+			CrawlDepth:           int64(task.Depth + 1), // items are links from the feed
+			SeedURL:              task.SeedURL(),
+			ReferrerURL:          pageURL,
+			DuplicateOf:          c.nearDuplicateOf(itemURL, baseURL.Hostname(), content, hashID),
+			ContentFingerprint:   ContentFingerprint(content),
+		}
+		if c.storable(doc, doc.DuplicateOf != "", header, nil, nil) {
+			c.storeDocument(ctx, doc, func(err error) {
+				if err != nil {
+					log.Printf("Error storing feed item %s from %s: %v", id, pageURL, err)
+					c.recordFailure(pageURL, task.Attempts, newCrawlError(ErrCategoryStorage, 0, err))
+					return
+				}
+				c.stats.pagesStored.Add(1)
+			})
+			queued++
+		}
+
+		if link != "" && c.followsLinks() && task.Depth < c.Config.MaxDepth {
+			c.queueLink(ctx, link, func() string { return doc.Title }, baseURL, task.SeedURL(), task.Depth+1, 0, linked)
+		}
+	}
+	if c.inbound != nil {
+		c.inbound.Add(linked)
+	}
+	log.Printf("Queued %d items from feed %s for storage", queued, pageURL)
+}
+
+// feedText returns the text of a feed field that may contain HTML.
+func feedText(s string) string {
+	if !strings.Contains(s, "<") {
+		return strings.TrimSpace(s)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"crawlengine/config"
+)
+
+// fingerprint is a user agent and the headers sent along with it.
+type fingerprint struct {
+	userAgent string
+	headers   http.Header // nil to send only the default headers
+}
+
+// builtinFingerprints are the profiles fingerprint_profiles entries can
+// refer to by name. Each matches what the browser sends on a top-level
+// navigation; Accept-Encoding is left to the transport so responses are
+// still decompressed transparently.
+var builtinFingerprints = map[string]config.FingerprintProfile{
+	"chrome_windows": {
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		Headers: map[string]string{
+			"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8",
+			"Accept-Language":           "en-US,en;q=0.9",
+			"Sec-CH-UA":                 `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+			"Sec-CH-UA-Mobile":          "?0",
+			"Sec-CH-UA-Platform":        `"Windows"`,
+			"Upgrade-Insecure-Requests": "1",
+		},
+	},
+	"firefox_windows": {
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		Headers: map[string]string{
+			"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Accept-Language":           "en-US,en;q=0.5",
+			"Upgrade-Insecure-Requests": "1",
+		},
+	},
+	"safari_macos": {
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+		Headers: map[string]string{
+			"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Accept-Language": "en-US,en;q=0.9",
+		},
+	},
+}
+
+// newFingerprintPicker returns a picker over the profiles with a positive
+// weight, or nil if there are none. Unknown built-in names are skipped with
+// a warning.
+func newFingerprintPicker(profiles []config.FingerprintProfile) *fingerprintPicker {
+	p := &fingerprintPicker{}
+	for _, profile := range profiles {
+		if profile.Weight <= 0 {
+			if profile.Weight < 0 {
+				log.Printf("Warning: Ignoring fingerprint profile '%s' with negative weight %g.", profile.Name, profile.Weight)
+			}
+			continue
+		}
+		if profile.UserAgent == "" {
+			builtin, ok := builtinFingerprints[profile.Name]
+			if !ok {
+				log.Printf("Warning: Ignoring unknown fingerprint profile '%s'.", profile.Name)
+				continue
+			}
+			builtin.Weight = profile.Weight
+			profile = builtin
+		}
+		p.add(newFingerprint(profile), profile.Weight)
+	}
+	if len(p.fingerprints) == 0 {
+		return nil
+	}
+	log.Printf("Rotating %d fingerprint profiles", len(p.fingerprints))
+	return p
+}
+
+func newFingerprint(profile config.FingerprintProfile) *fingerprint {
+	fp := &fingerprint{userAgent: profile.UserAgent, headers: make(http.Header)}
+	for name, value := range profile.Headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "User-Agent", "Accept-Encoding":
+			log.Printf("Warning: Ignoring %s header in fingerprint profile '%s'.", name, profile.Name)
+		default:
+			fp.headers.Set(name, value)
+		}
+	}
+	return fp
+}
+
+type fingerprintKey struct{}
+
+// withFingerprint returns ctx carrying the headers of fp for fetches made
+// with it.
+func withFingerprint(ctx context.Context, fp *fingerprint) context.Context {
+	return context.WithValue(ctx, fingerprintKey{}, fp)
+}
+
+func fingerprintFromContext(ctx context.Context) *fingerprint {
+	fp, _ := ctx.Value(fingerprintKey{}).(*fingerprint)
+	return fp
+}
+
+// apply sets the fingerprint's headers on header, replacing the defaults.
+// An Accept header other than DefaultAccept was negotiated for the host, so
+// it is kept.
+func (fp *fingerprint) apply(header http.Header) {
+	if fp == nil {
+		return
+	}
+	for name, values := range fp.headers {
+		if name == "Accept" && header.Get("Accept") != DefaultAccept {
+			continue
+		}
+		header[name] = values
+	}
+}
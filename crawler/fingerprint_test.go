@@ -0,0 +1,47 @@
+package crawler_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprintProfilesSendCoherentHeaders(t *testing.T) {
+	server, headers := chainServer(t, 30)
+	cfg := loadCrawlerConfig(t, `  max_depth: 30
+  user_agents: [ignored-agent]
+  fingerprint_profiles:
+    - firefox_windows
+    - name: custom
+      user_agent: custom-agent
+      headers:
+        Accept-Language: de-DE
+        X-Profile: custom
+        User-Agent: not-this-one
+    - name: disabled
+      user_agent: disabled-agent
+      weight: 0
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	runCrawl(t, cfg)
+
+	seen := make(map[string]int)
+	for _, header := range headers() {
+		agent := header.Get("User-Agent")
+		seen[agent]++
+		switch {
+		case strings.Contains(agent, "Firefox/"):
+			if header.Get("Accept-Language") != "en-US,en;q=0.5" || header.Get("X-Profile") != "" {
+				t.Errorf("firefox_windows request sent Accept-Language %q and X-Profile %q", header.Get("Accept-Language"), header.Get("X-Profile"))
+			}
+		case agent == "custom-agent":
+			if header.Get("Accept-Language") != "de-DE" || header.Get("X-Profile") != "custom" {
+				t.Errorf("custom request sent Accept-Language %q and X-Profile %q", header.Get("Accept-Language"), header.Get("X-Profile"))
+			}
+		default:
+			t.Errorf("request sent with user agent %q, want one of the enabled profiles", agent)
+		}
+	}
+	if len(seen) != 2 {
+		t.Errorf("user agents = %v, want both enabled profiles used", seen)
+	}
+}
@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"crawlengine/embedder"
+)
+
+// Focus scoring sources for focus_score_source.
+const (
+	FocusScoreAnchor = "anchor" // score each link by its anchor text
+	FocusScoreParent = "parent" // score all links on a page by the page's content
+)
+
+// focusScorer ranks links by their similarity to a topic for focused crawling.
+// Links scoring at least minScore are dispatched by score; links below it are
+// still queued, but behind every relevant link.
+type focusScorer struct {
+	embedder    embedder.TextEmbedder
+	topicVector []float32
+	minScore    float64
+	source      string
+}
+
+func newFocusScorer(ctx context.Context, textEmbedder embedder.TextEmbedder, topic string, minScore float64, source string) (*focusScorer, error) {
+	topicVector, err := textEmbedder.Embed(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed focus topic: %w", err)
+	}
+	source = strings.ToLower(source)
+	if source != FocusScoreParent {
+		source = FocusScoreAnchor
+	}
+	return &focusScorer{embedder: textEmbedder, topicVector: topicVector, minScore: minScore, source: source}, nil
+}
+
+// Score returns the cosine similarity between text and the topic.
+func (f *focusScorer) Score(ctx context.Context, text string) float64 {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+	ctx, span := tracer.Start(ctx, "crawler.embed")
+	vector, err := f.embedder.Embed(ctx, text)
+	endSpan(span, err)
+	if err != nil {
+		log.Printf("Error embedding text for focus scoring: %v", err)
+		return 0
+	}
+	return cosineSimilarity(f.topicVector, vector)
+}
+
+// Priority maps a relevance score to a frontier priority. Scores lie in
+// [-1, 1], so shifting irrelevant links down by 2 places them behind all
+// relevant ones while keeping their relative order.
+func (f *focusScorer) Priority(score float64) float64 {
+	if score >= f.minScore {
+		return score
+	}
+	return score - 2
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
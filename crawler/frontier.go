@@ -0,0 +1,253 @@
+package crawler
+
+import (
+	"container/heap"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Frontier dispatch policies for frontier_policy.
+const (
+	// FrontierPriority dispatches the highest priority task first, FIFO among equals.
+	FrontierPriority = "priority"
+	// FrontierHostRoundRobin keeps a queue per host and takes one task from each
+	// host in turn, so every host gets early coverage before any is crawled deep.
+	FrontierHostRoundRobin = "host_round_robin"
+)
+
+// frontier is the queue of pending crawl tasks shared by the workers.
+// With the default priority policy, tasks are dispatched highest Priority
+// first and in FIFO order among equal priorities, so with all priorities left
+// at zero the crawl is breadth-first. With host round-robin, the same order
+// applies within each host and the hosts take turns.
+// The frontier also tracks tasks handed out to workers: once the queue is
+// empty and no task is in flight, no new work can appear and Pop reports
+// that the crawl is finished.
+type frontier struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	items    taskQueue
+	seq      uint64
+	inFlight int
+	capacity int
+	closed   bool
+
+	// Tasks in flight and tasks cut short by cancellation, kept so that
+	// Snapshot includes every task not known to be finished.
+	active      map[string]frontierItem
+	interrupted []frontierItem
+}
+
+// newFrontier creates a frontier dispatching tasks by policy. Under host
+// round-robin, hostKey gives the key tasks are grouped by host under, so
+// hosts the crawler tracks as one take one turn.
+func newFrontier(capacity int, policy string, hostKey func(host string) string) *frontier {
+	f := &frontier{capacity: capacity, items: &taskHeap{}, active: make(map[string]frontierItem)}
+	if policy == FrontierHostRoundRobin {
+		f.items = newHostQueue(hostKey)
+	}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Push adds a discovered task. It returns false if the frontier is full or closed.
+func (f *frontier) Push(task CrawlTask) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || (f.capacity > 0 && f.items.Len() >= f.capacity) {
+		return false
+	}
+	f.push(task)
+	return true
+}
+
+// Seed adds a seed task regardless of capacity.
+func (f *frontier) Seed(task CrawlTask) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.push(task)
+	}
+}
+
+func (f *frontier) push(task CrawlTask) {
+	f.seq++
+	f.items.PushItem(frontierItem{task: task, seq: f.seq})
+	f.cond.Signal()
+}
+
+// Pop blocks until a task is available and marks it in flight. It returns
+// false once the frontier is closed or the crawl has run out of work.
+// Every task returned by Pop must be followed by a call to Done.
+func (f *frontier) Pop() (CrawlTask, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.items.Len() == 0 && !f.closed {
+		f.cond.Wait()
+	}
+	if f.closed {
+		return CrawlTask{}, false
+	}
+	item := f.items.PopItem()
+	f.inFlight++
+	f.active[item.task.URL] = item
+	return item.task, true
+}
+
+// Done marks a task returned by Pop as finished, closing the frontier if
+// that was the last piece of outstanding work.
+func (f *frontier) Done(task CrawlTask) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.doneLocked(task)
+}
+
+// Interrupt is Done for a task that cancellation may have cut short. The task
+// stays in snapshots so a resumed crawl retries it.
+func (f *frontier) Interrupt(task CrawlTask) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if item, ok := f.active[task.URL]; ok {
+		f.interrupted = append(f.interrupted, item)
+	}
+	f.doneLocked(task)
+}
+
+func (f *frontier) doneLocked(task CrawlTask) {
+	delete(f.active, task.URL)
+	f.inFlight--
+	if f.inFlight == 0 && f.items.Len() == 0 {
+		f.closeLocked()
+	}
+}
+
+// Snapshot returns the queued, in-flight and interrupted tasks in the order
+// they were queued.
+func (f *frontier) Snapshot() []CrawlTask {
+	f.mu.Lock()
+	items := f.items.Items()
+	for _, item := range f.active {
+		items = append(items, item)
+	}
+	items = append(items, f.interrupted...)
+	f.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+	tasks := make([]CrawlTask, len(items))
+	for i, item := range items {
+		tasks[i] = item.task
+	}
+	return tasks
+}
+
+// Close wakes all waiting workers and makes Pop return false.
+func (f *frontier) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closeLocked()
+}
+
+func (f *frontier) closeLocked() {
+	f.closed = true
+	f.cond.Broadcast()
+}
+
+// Len returns the number of queued tasks.
+func (f *frontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.items.Len()
+}
+
+type frontierItem struct {
+	task CrawlTask
+	seq  uint64
+}
+
+// taskQueue is the ordering used by the frontier. Callers hold the frontier lock.
+type taskQueue interface {
+	Len() int
+	PushItem(item frontierItem)
+	PopItem() frontierItem
+	Items() []frontierItem // a copy of the queued items, in no particular order
+}
+
+type taskHeap []frontierItem
+
+func (h taskHeap) Len() int { return len(h) }
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].task.Priority != h[j].task.Priority {
+		return h[i].task.Priority > h[j].task.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x any)   { *h = append(*h, x.(frontierItem)) }
+func (h *taskHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+func (h *taskHeap) PushItem(item frontierItem) { heap.Push(h, item) }
+func (h *taskHeap) PopItem() frontierItem      { return heap.Pop(h).(frontierItem) }
+func (h *taskHeap) Items() []frontierItem      { return append([]frontierItem(nil), *h...) }
+
+// hostQueue keeps one taskHeap per host key and pops from the hosts in turn.
+// Hosts are visited in the order they first got a task; a host whose queue
+// runs empty leaves the rotation and rejoins at the end when it gets new work.
+type hostQueue struct {
+	hostKey func(host string) string
+	queues  map[string]*taskHeap
+	order   []string
+	next    int
+	size    int
+}
+
+func newHostQueue(hostKey func(host string) string) *hostQueue {
+	return &hostQueue{hostKey: hostKey, queues: make(map[string]*taskHeap)}
+}
+
+func (q *hostQueue) Len() int { return q.size }
+
+func (q *hostQueue) PushItem(item frontierItem) {
+	host := ""
+	if u, err := url.Parse(item.task.URL); err == nil {
+		host = q.hostKey(u.Hostname())
+	}
+	queue, ok := q.queues[host]
+	if !ok {
+		queue = &taskHeap{}
+		q.queues[host] = queue
+		q.order = append(q.order, host)
+	}
+	queue.PushItem(item)
+	q.size++
+}
+
+func (q *hostQueue) PopItem() frontierItem {
+	if q.next >= len(q.order) {
+		q.next = 0
+	}
+	host := q.order[q.next]
+	queue := q.queues[host]
+	item := queue.PopItem()
+	q.size--
+	if queue.Len() == 0 {
+		delete(q.queues, host)
+		q.order = append(q.order[:q.next], q.order[q.next+1:]...)
+	} else {
+		q.next++
+	}
+	return item
+}
+
+func (q *hostQueue) Items() []frontierItem {
+	items := make([]frontierItem, 0, q.size)
+	for _, queue := range q.queues {
+		items = append(items, *queue...)
+	}
+	return items
+}
@@ -0,0 +1,58 @@
+package crawler
+
+import (
+	"slices"
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestHostRoundRobinGroupsByHostKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		collapseWWW bool
+		want        []string
+	}{
+		// www.a.example and a.example share one turn, so b.example gets every
+		// other task.
+		{"treat_www_as_same", true, []string{
+			"https://www.a.example/1", "https://b.example/1",
+			"https://a.example/2", "https://b.example/2",
+			"https://www.a.example/3",
+		}},
+		{"separate hosts", false, []string{
+			"https://www.a.example/1", "https://a.example/2", "https://b.example/1",
+			"https://www.a.example/3", "https://b.example/2",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalizer := newURLNormalizer("", tt.collapseWWW, nil)
+			f := newFrontier(0, FrontierHostRoundRobin, normalizer.HostKey)
+			for _, u := range []string{
+				"https://www.a.example/1", "https://a.example/2", "https://www.a.example/3",
+				"https://b.example/1", "https://b.example/2",
+			} {
+				f.Seed(CrawlTask{URL: u})
+			}
+
+			var got []string
+			for f.Len() > 0 {
+				task, _ := f.Pop()
+				got = append(got, task.URL)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("dispatch order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnqueueReleasesPageOfDroppedTask(t *testing.T) {
+	c := NewCrawler(&config.CrawlerConfig{MaxConcurrency: 1, MaxPagesPerHost: 1}, nil, nil)
+	c.frontier.Close()
+	c.enqueue(CrawlTask{URL: "https://example.com/a"})
+	if !c.hosts.ReservePage("example.com") {
+		t.Error("the page of a task the frontier dropped still counts toward max_pages_per_host")
+	}
+}
@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// frontierSnapshot is the content of frontier_file: the tasks not yet
+// crawled, in the order they were queued, and the visited set. Tasks that
+// were in flight when the snapshot was taken are included, so after a crash
+// at most max_concurrency pages are crawled a second time.
+type frontierSnapshot struct {
+	SavedAt time.Time   `json:"saved_at"`
+	Tasks   []CrawlTask `json:"tasks"`
+	Visited []string    `json:"visited"` // visited keys
+}
+
+// snapshotFrontier captures the frontier and visited set. The visited set is
+// copied first: a link queued in between is then in the tasks but not the
+// visited set, which restoring repairs, rather than visited but lost.
+func (c *Crawler) snapshotFrontier() *frontierSnapshot {
+	c.visitedLock.Lock()
+	visited := make([]string, 0, len(c.visited))
+	for key := range c.visited {
+		visited = append(visited, key)
+	}
+	c.visitedLock.Unlock()
+	sort.Strings(visited)
+	return &frontierSnapshot{
+		SavedAt: time.Now().UTC(),
+		Tasks:   c.frontier.Snapshot(),
+		Visited: visited,
+	}
+}
+
+// runFrontierWriter saves the frontier to frontier_file every interval until
+// stop is closed.
+func (c *Crawler) runFrontierWriter(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.saveFrontier()
+		}
+	}
+}
+
+// saveFrontier replaces frontier_file with a snapshot of the frontier. Like
+// the state file it is written to a temporary file and renamed, so a crash
+// leaves either the previous snapshot or the new one.
+func (c *Crawler) saveFrontier() {
+	if err := writeFileAtomic(c.Config.FrontierFile, c.snapshotFrontier()); err != nil {
+		log.Printf("Error saving frontier: %v", err)
+	}
+}
+
+// resumeFrontier restores the frontier and visited set from frontier_file.
+// It reports false, to start from the seeds, if frontier_file is unset,
+// missing, or has no tasks left because the previous crawl finished.
+// Tasks are restored like newly queued ones: their hosts are admitted under
+// max_hosts and they count toward max_pages_per_host, with tasks over
+// either limit dropped. Pages crawled before the interruption don't count,
+// so max_pages_per_host applies afresh.
+func (c *Crawler) resumeFrontier() (bool, error) {
+	if c.Config.FrontierFile == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(c.Config.FrontierFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read frontier file: %w", err)
+	}
+	var snapshot frontierSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return false, fmt.Errorf("failed to parse frontier file %s (delete it to start a new crawl): %w", c.Config.FrontierFile, err)
+	}
+	if len(snapshot.Tasks) == 0 {
+		log.Printf("Frontier file %s has no pending tasks; starting a new crawl from the seeds", c.Config.FrontierFile)
+		return false, nil
+	}
+
+	c.visitedLock.Lock()
+	for _, key := range snapshot.Visited {
+		c.visited[key] = true
+	}
+	c.visitedLock.Unlock()
+	restored := 0
+	for _, task := range snapshot.Tasks {
+		if taskURL, err := url.Parse(task.URL); err == nil {
+			host := c.normalizer.HostKey(taskURL.Hostname())
+			// Checked links never count toward max_hosts.
+			if !task.CheckOnly && !c.admitHost(host, task.URL) {
+				continue
+			}
+			if !c.hosts.ReservePage(host) {
+				log.Printf("Skipping %s: host reached max_pages_per_host", task.URL)
+				continue
+			}
+		}
+		c.frontier.Seed(task)
+		c.markVisited(task.URL)
+		restored++
+	}
+	log.Printf("Resuming crawl from %s saved at %s: %d pending tasks, %d visited URLs",
+		c.Config.FrontierFile, snapshot.SavedAt.Format(time.RFC3339), restored, len(snapshot.Visited))
+	return true, nil
+}
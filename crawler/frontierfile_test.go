@@ -0,0 +1,106 @@
+package crawler_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+	"crawlengine/storage"
+)
+
+// cancellingStorer cancels the crawl once it has stored n documents.
+type cancellingStorer struct {
+	*crawltest.MockStorer
+	n      int
+	cancel context.CancelFunc
+}
+
+func (s *cancellingStorer) StoreDocument(ctx context.Context, doc *storage.WebDocument) error {
+	err := s.MockStorer.StoreDocument(ctx, doc)
+	if len(s.Documents()) == s.n {
+		s.cancel()
+	}
+	return err
+}
+
+// interruptedCrawl crawls from cfg's seeds until n documents are stored and
+// returns the stored URLs.
+func interruptedCrawl(t *testing.T, cfg *config.CrawlerConfig, n int) []string {
+	t.Helper()
+	cfg.Deterministic = true
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	storer := &cancellingStorer{MockStorer: crawltest.NewMockStorer(), n: n, cancel: cancel}
+	crawler.NewCrawler(cfg, storer, nil).Start(ctx)
+	return storer.URLs()
+}
+
+func TestFrontierFileResumesInterruptedCrawl(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	crawlerYAML := "  max_depth: 1\n  frontier_file: " + filepath.Join(t.TempDir(), "frontier.json") + "\n"
+
+	// The first run is cancelled while /a is being stored, after the seed
+	// has been crawled and its links queued.
+	cfg := loadCrawlerConfig(t, crawlerYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	if got, want := interruptedCrawl(t, cfg, 2), []string{server.URL + "/", server.URL + "/a"}; !slices.Equal(got, want) {
+		t.Fatalf("first run stored %v, want %v", got, want)
+	}
+
+	// The second run picks up the interrupted page and the queued links,
+	// without crawling the seed again.
+	cfg = loadCrawlerConfig(t, crawlerYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+	want := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("resumed run stored %v, want %v", got, want)
+	}
+
+	// A finished crawl leaves no pending tasks, so the next run starts over.
+	cfg = loadCrawlerConfig(t, crawlerYAML)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer = runCrawl(t, cfg)
+	if got := storer.URLs(); len(got) != 4 {
+		t.Errorf("run after a finished crawl stored %v, want every page", got)
+	}
+}
+
+func TestFrontierFileResumeAppliesHostLimits(t *testing.T) {
+	page := func(name string) crawltest.Fixture { return crawltest.HTML(article(name) + `</body></html>`) }
+	siteA := map[string]crawltest.Fixture{"/a": page("Page A"), "/b": page("Page B"), "/c": page("Page C")}
+	siteB := map[string]crawltest.Fixture{"/d": page("Page D")}
+	_, b, urlA, urlB := newSites(t, siteA, siteB)
+
+	path := filepath.Join(t.TempDir(), "frontier.json")
+	snapshot := `{"saved_at":"2026-01-01T00:00:00Z","tasks":[` +
+		`{"url":"` + urlA + `/a","depth":1},{"url":"` + urlB + `/d","depth":1},` +
+		`{"url":"` + urlA + `/b","depth":1},{"url":"` + urlA + `/c","depth":1}],"visited":[]}`
+	if err := os.WriteFile(path, []byte(snapshot), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  max_hosts: 1\n  max_pages_per_host: 2\n  frontier_file: "+path+"\n")
+	cfg.SeedURLs = []string{urlA + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	if got, want := storer.URLs(), []string{urlA + "/a", urlA + "/b"}; !slices.Equal(got, want) {
+		t.Errorf("resumed run stored %v, want %v", got, want)
+	}
+	if requests := b.Requests(); len(requests) > 0 {
+		t.Errorf("task on a host over max_hosts was fetched: %v", requests)
+	}
+}
@@ -0,0 +1,167 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"crawlengine/config"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// Policies for gated_policy, applied to pages classified as a paywall or
+// consent wall.
+const (
+	GatedSkip = "skip"
+	GatedMark = "mark"
+)
+
+// maxGatedTextBytes is how much of the page text gated patterns look at.
+const maxGatedTextBytes = 64 << 10
+
+// Built-in markers of paywall and consent interstitials. Unlike configured
+// ones, they also show up on pages with the full article, e.g. in a cookie
+// banner, so they only classify pages with little main content.
+var (
+	defaultGatedSelectors = []string{
+		"[id*='paywall']", "[class*='paywall']",
+		"[id*='consent-wall']", "[class*='consent-wall']",
+		"#onetrust-consent-sdk", "#CybotCookiebotDialog", ".fc-consent-root", ".qc-cmp2-container",
+		".tp-modal", ".piano-offer", "[class*='subscriber-only']", "[class*='regwall']",
+	}
+	defaultGatedPatterns = []string{
+		`(?i)\b(subscribe|sign in|log in|register) (now )?to (continue|keep) reading\b`,
+		`(?i)\balready a (subscriber|member)\b`,
+		`(?i)\bthis (article|content|story) is (only )?(available|reserved) (to|for) (subscribers|members)\b`,
+		`(?i)\b(accept|agree to) (all )?cookies\b`,
+		`(?i)\bwe value your privacy\b`,
+		`구독자 전용|로그인 후 (이용|열람)`,
+	}
+)
+
+// gatedMarkers are the selectors and text patterns that identify gated pages.
+type gatedMarkers struct {
+	selectors []string // validated CSS selectors
+	patterns  []*regexp.Regexp
+}
+
+func (m gatedMarkers) empty() bool {
+	return len(m.selectors) == 0 && len(m.patterns) == 0
+}
+
+// match returns a description of the first marker found in doc or its
+// text, or "" if none is.
+func (m gatedMarkers) match(doc *goquery.Document, text func() string) string {
+	for _, sel := range m.selectors {
+		if doc.Find(sel).Length() > 0 {
+			return "selector '" + sel + "'"
+		}
+	}
+	if len(m.patterns) == 0 {
+		return ""
+	}
+	body := text()
+	for _, re := range m.patterns {
+		if re.MatchString(body) {
+			return "pattern '" + re.String() + "'"
+		}
+	}
+	return ""
+}
+
+// gatedDetector classifies paywall and consent interstitials, so they aren't
+// stored as the article. A page is gated when it matches a configured marker
+// of gated_selectors, gated_patterns or its extraction rule, or a built-in
+// marker with a main content shorter than gated_max_content_chars (negative
+// disables the built-in markers). A nil *gatedDetector classifies nothing.
+type gatedDetector struct {
+	mark            bool // mark gated pages instead of skipping them
+	maxContentChars int
+	global          gatedMarkers
+	builtin         gatedMarkers
+	hosts           map[*config.ExtractionRule]gatedMarkers
+}
+
+func newGatedDetector(cfg *config.CrawlerConfig, rules *extractionRules) *gatedDetector {
+	d := &gatedDetector{maxContentChars: cfg.GatedMaxContentChars}
+	switch strings.ToLower(cfg.GatedPolicy) {
+	case "":
+		return nil
+	case GatedSkip:
+	case GatedMark:
+		d.mark = true
+	default:
+		log.Printf("Warning: Unknown gated_policy '%s', gated content detection disabled.", cfg.GatedPolicy)
+		return nil
+	}
+	d.global = compileGatedMarkers("gated", cfg.GatedSelectors, cfg.GatedPatterns)
+	if d.maxContentChars > 0 {
+		d.builtin = compileGatedMarkers("built-in gated", defaultGatedSelectors, defaultGatedPatterns)
+	}
+	if rules != nil {
+		d.hosts = make(map[*config.ExtractionRule]gatedMarkers)
+		for _, byHost := range []map[string]*config.ExtractionRule{rules.exact, rules.wildcard} {
+			for _, rule := range byHost {
+				if markers := compileGatedMarkers("gated", rule.GatedSelectors, rule.GatedPatterns); !markers.empty() {
+					d.hosts[rule] = markers
+				}
+			}
+		}
+	}
+	log.Printf("Gated content detection enabled: %s pages behind a paywall or consent wall (%d selectors, %d patterns, %d per-domain rules)",
+		strings.ToLower(cfg.GatedPolicy), len(d.global.selectors), len(d.global.patterns), len(d.hosts))
+	return d
+}
+
+// compileGatedMarkers compiles selectors and patterns, skipping invalid ones
+// with a warning.
+func compileGatedMarkers(key string, selectors, patterns []string) gatedMarkers {
+	var m gatedMarkers
+	for _, selector := range selectors {
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			log.Printf("Warning: Ignoring invalid %s selector '%s': %v", key, selector, err)
+			continue
+		}
+		m.selectors = append(m.selectors, selector)
+	}
+	m.patterns = compileContentPatterns(key+"_patterns", patterns)
+	return m
+}
+
+// Classify reports whether a page is gated, and why. rule is the page's
+// extraction rule, whose markers are checked along with the global ones.
+func (d *gatedDetector) Classify(doc *goquery.Document, mainContent string, rule *config.ExtractionRule) (bool, string) {
+	if d == nil {
+		return false, ""
+	}
+	var text string
+	pageText := func() string {
+		if text == "" {
+			text = doc.Find("body").Text()
+			if len(text) > maxGatedTextBytes {
+				text = text[:maxGatedTextBytes]
+			}
+		}
+		return text
+	}
+	if markers, ok := d.hosts[rule]; ok {
+		if found := markers.match(doc, pageText); found != "" {
+			return true, "matches per-domain " + found
+		}
+	}
+	if found := d.global.match(doc, pageText); found != "" {
+		return true, "matches " + found
+	}
+	chars := utf8.RuneCountInString(mainContent)
+	if chars >= d.maxContentChars {
+		return false, ""
+	}
+	if found := d.builtin.match(doc, pageText); found != "" {
+		return true, fmt.Sprintf("main content of %d characters and built-in %s", chars, found)
+	}
+	return false, ""
+}
@@ -0,0 +1,60 @@
+package crawler_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestGatedPolicy(t *testing.T) {
+	long := strings.Repeat("The full article goes on with many more words about the story. ", 12)
+	gatedPage := func(title, body string) crawltest.Fixture {
+		return crawltest.HTML(`<html><head><title>` + title + `</title></head><body>` + body + `</body></html>`)
+	}
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/": crawltest.HTML(article("Home") + `<a href="/paywall">paywall</a><a href="/banner">banner</a>` +
+			`<a href="/members">members</a></body></html>`),
+		"/paywall": gatedPage("Paywall", `<article><p>The first lines of the story.</p>`+
+			`<p>Subscribe to continue reading.</p></article>`),
+		"/banner": gatedPage("Banner", `<div class="cookies">We value your privacy. Accept all cookies?</div>`+
+			`<article><p>`+long+`</p></article>`),
+		"/members":    gatedPage("Members", `<article class="members-only"><p>`+long+`</p></article>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		policy     string
+		wantStored []string
+		wantGated  []string
+	}{
+		{"mark", []string{"/", "/banner", "/members", "/paywall"}, []string{"/members", "/paywall"}},
+		{"skip", []string{"/", "/banner"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n  gated_policy: "+tt.policy+"\n  gated_selectors: [\".members-only\"]\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			var stored, gated []string
+			for _, doc := range storer.Documents() {
+				path := strings.TrimPrefix(doc.URL, server.URL)
+				stored = append(stored, path)
+				if doc.Gated {
+					gated = append(gated, path)
+				}
+			}
+			slices.Sort(stored)
+			slices.Sort(gated)
+			if !slices.Equal(stored, tt.wantStored) {
+				t.Errorf("stored %v, want %v", stored, tt.wantStored)
+			}
+			if !slices.Equal(gated, tt.wantGated) {
+				t.Errorf("gated %v, want %v", gated, tt.wantGated)
+			}
+		})
+	}
+}
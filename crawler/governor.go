@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"crawlengine/config"
+)
+
+// governorResumeRatio is how far below every limit usage must fall before a
+// paused governor resumes, so it doesn't flap around a threshold.
+const governorResumeRatio = 0.9
+
+// connCounter counts the connections opened by the crawler's transport that
+// are still open.
+type connCounter struct {
+	open atomic.Int64
+}
+
+// Dial wraps dial so the connections it returns are counted until closed.
+func (cc *connCounter) Dial(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		cc.open.Add(1)
+		return &countedConn{Conn: conn, counter: cc}, nil
+	}
+}
+
+// Open returns the number of connections currently open.
+func (cc *connCounter) Open() int64 {
+	return cc.open.Load()
+}
+
+type countedConn struct {
+	net.Conn
+	counter *connCounter
+	once    sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.counter.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// resourceUsage is one sample of the resources the governor watches.
+type resourceUsage struct {
+	goroutines int
+	heapMB     int
+	openConns  int
+}
+
+// resourceGovernor pauses new fetches while goroutines, heap or open
+// connections exceed their limits. A nil *resourceGovernor never blocks.
+type resourceGovernor struct {
+	maxGoroutines int
+	maxHeapMB     int
+	maxOpenConns  int
+	conns         *connCounter
+
+	mu     sync.Mutex
+	resume chan struct{} // non-nil while paused, closed on resume
+}
+
+func newResourceGovernor(cfg *config.CrawlerConfig, conns *connCounter) *resourceGovernor {
+	if cfg.MaxGoroutines <= 0 && cfg.MaxHeapMB <= 0 && cfg.MaxOpenConns <= 0 {
+		return nil
+	}
+	return &resourceGovernor{
+		maxGoroutines: cfg.MaxGoroutines,
+		maxHeapMB:     cfg.MaxHeapMB,
+		maxOpenConns:  cfg.MaxOpenConns,
+		conns:         conns,
+	}
+}
+
+// Run samples resource usage every interval until stop is closed, pausing
+// and resuming fetches as usage crosses the limits.
+func (g *resourceGovernor) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			g.setPaused(false, resourceUsage{}) // don't leave workers blocked
+			return
+		case <-ticker.C:
+			usage := g.sample()
+			if g.paused() {
+				if !g.exceeds(usage, governorResumeRatio) {
+					g.setPaused(false, usage)
+				}
+			} else if g.exceeds(usage, 1) {
+				g.setPaused(true, usage)
+			}
+		}
+	}
+}
+
+// Wait blocks while fetches are paused, returning early if ctx is cancelled.
+func (g *resourceGovernor) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	resume := g.resume
+	g.mu.Unlock()
+	if resume == nil {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *resourceGovernor) sample() resourceUsage {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return resourceUsage{
+		goroutines: runtime.NumGoroutine(),
+		heapMB:     int(mem.HeapAlloc >> 20),
+		openConns:  int(g.conns.Open()),
+	}
+}
+
+// exceeds reports whether any limited resource is above ratio of its limit.
+func (g *resourceGovernor) exceeds(usage resourceUsage, ratio float64) bool {
+	over := func(value, limit int) bool {
+		return limit > 0 && float64(value) > float64(limit)*ratio
+	}
+	return over(usage.goroutines, g.maxGoroutines) ||
+		over(usage.heapMB, g.maxHeapMB) ||
+		over(usage.openConns, g.maxOpenConns)
+}
+
+func (g *resourceGovernor) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resume != nil
+}
+
+func (g *resourceGovernor) setPaused(paused bool, usage resourceUsage) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case paused && g.resume == nil:
+		g.resume = make(chan struct{})
+		log.Printf("Resource governor: pausing new fetches (%s)", g.describe(usage))
+	case !paused && g.resume != nil:
+		close(g.resume)
+		g.resume = nil
+		if usage != (resourceUsage{}) {
+			log.Printf("Resource governor: resuming fetches (%s)", g.describe(usage))
+		}
+	}
+}
+
+// describe formats the limited resources in usage against their limits.
+func (g *resourceGovernor) describe(usage resourceUsage) string {
+	var parts []string
+	if g.maxGoroutines > 0 {
+		parts = append(parts, fmt.Sprintf("goroutines %d/%d", usage.goroutines, g.maxGoroutines))
+	}
+	if g.maxHeapMB > 0 {
+		parts = append(parts, fmt.Sprintf("heap %d/%d MB", usage.heapMB, g.maxHeapMB))
+	}
+	if g.maxOpenConns > 0 {
+		parts = append(parts, fmt.Sprintf("open connections %d/%d", usage.openConns, g.maxOpenConns))
+	}
+	return strings.Join(parts, ", ")
+}
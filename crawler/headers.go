@@ -0,0 +1,35 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// redactedHeaderValue replaces the values of redacted headers so their
+// presence stays visible without storing their contents.
+const redactedHeaderValue = "[REDACTED]"
+
+// SerializeHeaders encodes response headers as a JSON object of header name
+// to values, replacing the values of any header listed in redact.
+func SerializeHeaders(header http.Header, redact []string) (string, error) {
+	redacted := make(map[string]bool, len(redact))
+	for _, name := range redact {
+		redacted[http.CanonicalHeaderKey(name)] = true
+	}
+
+	out := make(map[string][]string, len(header))
+	for name, values := range header {
+		if redacted[http.CanonicalHeaderKey(name)] {
+			out[name] = []string{redactedHeaderValue}
+			continue
+		}
+		out[name] = values
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize response headers: %w", err)
+	}
+	return string(data), nil
+}
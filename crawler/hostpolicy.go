@@ -0,0 +1,187 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHeaderCrawlDelay caps the delay a site can request through response
+// headers, so one host cannot stall a worker indefinitely.
+const maxHeaderCrawlDelay = 60 * time.Second
+
+// crawlDelayHeaders are the non-standard response headers some sites use to
+// ask crawlers to slow down, in seconds.
+var crawlDelayHeaders = []string{"X-Crawl-Delay", "Crawl-Delay"}
+
+// hostPolicies caches per-host crawl hints beyond robots.txt: security.txt
+// contacts and delays requested through response headers. Like robots data,
+// each host's security.txt is fetched once and absence fails open.
+type hostPolicies struct {
+	fetcher *Fetcher
+	agent   string
+
+	mu    sync.Mutex
+	hosts map[string]*hostPolicy
+}
+
+type hostPolicy struct {
+	securityOnce sync.Once
+	contacts     []string
+
+	delay time.Duration // requested by crawl delay headers; guarded by hostPolicies.mu
+	next  time.Time     // earliest time of the next fetch; guarded by hostPolicies.mu
+}
+
+func newHostPolicies(fetcher *Fetcher, agent string) *hostPolicies {
+	return &hostPolicies{fetcher: fetcher, agent: agent, hosts: make(map[string]*hostPolicy)}
+}
+
+func (p *hostPolicies) get(host string) *hostPolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hp, ok := p.hosts[host]
+	if !ok {
+		hp = &hostPolicy{}
+		p.hosts[host] = hp
+	}
+	return hp
+}
+
+// FetchSecurityTxt fetches and records the security.txt contacts of u's host
+// the first time the host is seen.
+func (p *hostPolicies) FetchSecurityTxt(u *url.URL) {
+	hp := p.get(u.Host)
+	hp.securityOnce.Do(func() {
+		contacts := fetchSecurityContacts(p.fetcher, u, p.agent)
+		p.mu.Lock()
+		hp.contacts = contacts
+		p.mu.Unlock()
+		if len(contacts) > 0 {
+			log.Printf("security.txt for %s lists contacts: %s", u.Host, strings.Join(contacts, ", "))
+		}
+	})
+}
+
+// Contacts returns the security.txt contacts found per host.
+func (p *hostPolicies) Contacts() map[string][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	contacts := make(map[string][]string)
+	for host, hp := range p.hosts {
+		if len(hp.contacts) > 0 {
+			contacts[host] = hp.contacts
+		}
+	}
+	return contacts
+}
+
+// RecordHeaders applies a crawl delay requested by header to later fetches
+// from host. A response without such a header leaves the delay unchanged.
+func (p *hostPolicies) RecordHeaders(host string, header http.Header) {
+	delay, ok := CrawlDelayFromHeader(header)
+	if !ok {
+		return
+	}
+	if delay > maxHeaderCrawlDelay {
+		log.Printf("Warning: %s requested a crawl delay of %s, capping at %s", host, delay, maxHeaderCrawlDelay)
+		delay = maxHeaderCrawlDelay
+	}
+	hp := p.get(host)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if hp.delay != delay {
+		log.Printf("Honoring crawl delay of %s requested by %s", delay, host)
+		hp.delay = delay
+	}
+}
+
+// Wait blocks until host may be fetched again under its requested crawl
+// delay, reserving the next slot for the caller.
+func (p *hostPolicies) Wait(ctx context.Context, host string) error {
+	hp := p.get(host)
+	p.mu.Lock()
+	if hp.delay == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	start := hp.next
+	if start.Before(now) {
+		start = now
+	}
+	hp.next = start.Add(hp.delay)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CrawlDelayFromHeader returns the delay requested by an X-Crawl-Delay or
+// Crawl-Delay response header, in (possibly fractional) seconds.
+func CrawlDelayFromHeader(header http.Header) (time.Duration, bool) {
+	for _, name := range crawlDelayHeaders {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// fetchSecurityContacts fetches /.well-known/security.txt (RFC 9116) for u's
+// host and returns its Contact values. Any failure, including an HTML page
+// served in its place, yields no contacts.
+func fetchSecurityContacts(fetcher *Fetcher, u *url.URL, userAgent string) []string {
+	securityURL := u.Scheme + "://" + u.Host + "/.well-known/security.txt"
+	resp, err := fetcher.Fetch(context.Background(), securityURL, userAgent)
+	if err != nil {
+		log.Printf("No security.txt for %s: %v", u.Host, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || mediaType != "text/plain" {
+		return nil
+	}
+	return ParseSecurityContacts(io.LimitReader(resp.Body, 32*1024))
+}
+
+// ParseSecurityContacts returns the Contact field values of a security.txt body.
+func ParseSecurityContacts(r io.Reader) []string {
+	var contacts []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "contact") {
+			if value = strings.TrimSpace(value); value != "" {
+				contacts = append(contacts, value)
+			}
+		}
+	}
+	return contacts
+}
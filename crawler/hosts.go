@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// hostSet tracks the hosts a crawl has touched and optionally caps how many
+// distinct hosts may be admitted. A limit of 0 means unlimited.
+// It also counts pages per host: pages queued, which pageLimit caps, and
+// pages actually fetched, which are reported in the crawl summary.
+type hostSet struct {
+	mu        sync.Mutex
+	limit     int
+	pageLimit int
+	hosts     map[string]bool
+	queued    map[string]int
+	fetched   map[string]int
+}
+
+func newHostSet(limit, pageLimit int) *hostSet {
+	return &hostSet{
+		limit:     limit,
+		pageLimit: pageLimit,
+		hosts:     make(map[string]bool),
+		queued:    make(map[string]int),
+		fetched:   make(map[string]int),
+	}
+}
+
+// Admit reports whether pages on host may be queued. Already-seen hosts are
+// always admitted; a new host is admitted and recorded only while the limit
+// has not been reached.
+func (h *hostSet) Admit(host string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hosts[host] {
+		return true
+	}
+	if h.limit > 0 && len(h.hosts) >= h.limit {
+		return false
+	}
+	h.hosts[host] = true
+	return true
+}
+
+// admitHost admits host, the host key of target, under max_hosts. New hosts
+// enter a crawl only as seeds and, from seeds, as redirect targets, since
+// links are kept on the host of their page.
+func (c *Crawler) admitHost(host, target string) bool {
+	if c.hosts.Admit(host) {
+		return true
+	}
+	log.Printf("Skipping %s: new host %s over the max_hosts limit (%d)", target, host, c.Config.MaxHosts)
+	return false
+}
+
+// Len returns the number of hosts admitted so far.
+func (h *hostSet) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.hosts)
+}
+
+// ReservePage counts a page about to be queued for host and reports whether
+// the host is still under max_pages_per_host. The cap is logged once, when
+// the host reaches it.
+func (h *hostSet) ReservePage(host string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pageLimit > 0 && h.queued[host] >= h.pageLimit {
+		return false
+	}
+	h.queued[host]++
+	if h.queued[host] == h.pageLimit {
+		log.Printf("Host %s reached max_pages_per_host (%d), skipping its further links", host, h.pageLimit)
+	}
+	return true
+}
+
+// ReleasePage uncounts a page ReservePage counted for host that wasn't
+// queued after all.
+func (h *hostSet) ReleasePage(host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.queued[host] > 0 {
+		h.queued[host]--
+	}
+}
+
+// RecordFetch counts a page fetched from host.
+func (h *hostSet) RecordFetch(host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fetched[host]++
+}
+
+// hostCounts is a hostSet's state as saved in a checkpoint.
+type hostCounts struct {
+	Hosts   []string       `json:"hosts"`
+	Queued  map[string]int `json:"queued"`
+	Fetched map[string]int `json:"fetched"`
+}
+
+// Counts returns the admitted hosts and their page counts.
+func (h *hostSet) Counts() hostCounts {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := hostCounts{
+		Hosts:   make([]string, 0, len(h.hosts)),
+		Queued:  make(map[string]int, len(h.queued)),
+		Fetched: make(map[string]int, len(h.fetched)),
+	}
+	for host := range h.hosts {
+		counts.Hosts = append(counts.Hosts, host)
+	}
+	sort.Strings(counts.Hosts)
+	for host, n := range h.queued {
+		counts.Queued[host] = n
+	}
+	for host, n := range h.fetched {
+		counts.Fetched[host] = n
+	}
+	return counts
+}
+
+// Restore replaces the admitted hosts and page counts with counts.
+func (h *hostSet) Restore(counts hostCounts) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hosts = make(map[string]bool, len(counts.Hosts))
+	for _, host := range counts.Hosts {
+		h.hosts[host] = true
+	}
+	h.queued = make(map[string]int, len(counts.Queued))
+	for host, n := range counts.Queued {
+		h.queued[host] = n
+	}
+	h.fetched = make(map[string]int, len(counts.Fetched))
+	for host, n := range counts.Fetched {
+		h.fetched[host] = n
+	}
+}
+
+// FetchedPages returns the number of pages fetched per host.
+func (h *hostSet) FetchedPages() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := make(map[string]int, len(h.fetched))
+	for host, n := range h.fetched {
+		counts[host] = n
+	}
+	return counts
+}
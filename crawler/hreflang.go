@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// hreflangSelector matches alternate-language links such as
+// <link rel="alternate" hreflang="de" href="/de/">.
+const hreflangSelector = `link[rel~="alternate"][hreflang][href]`
+
+// ExtractHreflang serializes the document's alternate-language links as a
+// JSON object mapping language (lowercased, e.g. "en-us" or "x-default") to
+// absolute URL. Relative hrefs are resolved against base. Returns "" when the
+// document declares no alternates.
+func ExtractHreflang(doc *goquery.Document, base *url.URL) (string, error) {
+	return extractHreflang(doc, base, defaultNormalizer)
+}
+
+// extractHreflang is ExtractHreflang with alternate URLs in the form
+// normalizer gives them, so they match the URLs the crawl stores.
+func extractHreflang(doc *goquery.Document, base *url.URL, normalizer *urlNormalizer) (string, error) {
+	alternates := make(map[string]string)
+	doc.Find(hreflangSelector).Each(func(i int, s *goquery.Selection) {
+		lang := strings.ToLower(strings.TrimSpace(s.AttrOr("hreflang", "")))
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if lang == "" || href == "" {
+			return
+		}
+		absURL, err := normalizer.Normalize(base, href)
+		if err != nil {
+			return
+		}
+		if _, seen := alternates[lang]; !seen {
+			alternates[lang] = absURL
+		}
+	})
+	if len(alternates) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(alternates)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize hreflang alternates: %w", err)
+	}
+	return string(data), nil
+}
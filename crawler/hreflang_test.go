@@ -0,0 +1,29 @@
+package crawler_test
+
+import (
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestHreflangUsesStoredURLs(t *testing.T) {
+	head := `<link rel="alternate" hreflang="en" href="/en/"><link rel="alternate" hreflang="DE" href="/de/"><link rel="alternate" hreflang="de" href="/other/">`
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/en":         crawltest.HTML(strings.Replace(article("Home"), "</head>", head+"</head>", 1) + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  trailing_slash: strip\n  extract_hreflang: true\n")
+	cfg.SeedURLs = []string{server.URL + "/en"}
+	_, storer := runCrawl(t, cfg)
+
+	docs := storer.Documents()
+	if len(docs) != 1 {
+		t.Fatalf("stored %v, want the seed", storer.URLs())
+	}
+	if want := `{"de":"` + server.URL + `/de","en":"` + server.URL + `/en"}`; docs[0].HreflangJSON != want {
+		t.Errorf("hreflang_json = %s, want %s", docs[0].HreflangJSON, want)
+	}
+}
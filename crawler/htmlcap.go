@@ -0,0 +1,216 @@
+package crawler
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Strategies for html_truncation, applied to HTML longer than the
+// html_source field, and the values of html_truncation recording which was
+// applied.
+const (
+	HTMLTruncateSmart = "smart"
+	HTMLTruncateNaive = "naive"
+	HTMLTruncateSkip  = "skip"
+
+	HTMLTruncatedDropped = "dropped" // html_truncation of a document whose HTML was skipped
+)
+
+// SetHTMLLimit caps html_source at maxBytes, the length of its field in the
+// collection, as html_truncation says; 0 leaves it uncapped. It must be
+// called before Start.
+func (c *Crawler) SetHTMLLimit(maxBytes int) {
+	c.maxHTMLBytes = maxBytes
+}
+
+// capHTML fits pageHTML into the html_source limit and returns it with the
+// html_truncation value to record, "" if it already fit. A smart
+// truncation that can't fit falls back to a naive one.
+func (c *Crawler) capHTML(pageHTML, pageURL string) (string, string) {
+	if c.maxHTMLBytes <= 0 || len(pageHTML) <= c.maxHTMLBytes {
+		return pageHTML, ""
+	}
+	strategy := c.htmlTruncation
+	capped := ""
+	switch strategy {
+	case HTMLTruncateSkip:
+		log.Printf("HTML of %s is %d bytes, over the %d byte limit; not storing it", pageURL, len(pageHTML), c.maxHTMLBytes)
+		return "", HTMLTruncatedDropped
+	case HTMLTruncateSmart:
+		if capped = SmartTruncateHTML(pageHTML, c.maxHTMLBytes); capped == "" {
+			strategy = HTMLTruncateNaive
+		}
+	}
+	if strategy == HTMLTruncateNaive {
+		capped = truncateUTF8(pageHTML, c.maxHTMLBytes)
+	}
+	log.Printf("HTML of %s is %d bytes, over the %d byte limit; stored %d bytes truncated %s", pageURL, len(pageHTML), c.maxHTMLBytes, len(capped), strategy)
+	return capped, strategy
+}
+
+// SmartTruncateHTML shrinks pageHTML to at most maxBytes while keeping it
+// well-formed and as much of the <body> as possible. It removes, until the
+// page fits, first scripts, styles, templates and comments, then everything
+// in <head> but the <title>, <base> and canonical link, and finally the end
+// of the body, cutting the last kept element or text partway. Returns "" if
+// even the empty document doesn't fit.
+func SmartTruncateHTML(pageHTML string, maxBytes int) string {
+	doc, err := html.Parse(strings.NewReader(pageHTML))
+	if err != nil {
+		return ""
+	}
+	removeNodes(doc, func(n *html.Node) bool {
+		return n.Type == html.CommentNode ||
+			n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style || n.DataAtom == atom.Noscript || n.DataAtom == atom.Template)
+	})
+	if rendered := renderHTML(doc); len(rendered) <= maxBytes {
+		return rendered
+	}
+
+	var head, body *html.Node
+	for n := range doc.Descendants() {
+		switch {
+		case n.DataAtom == atom.Head && head == nil:
+			head = n
+		case n.DataAtom == atom.Body && body == nil:
+			body = n
+		}
+	}
+	if head != nil {
+		removeNodes(head, func(n *html.Node) bool {
+			return n.Parent == head && !(n.DataAtom == atom.Title || n.DataAtom == atom.Base ||
+				n.DataAtom == atom.Link && strings.EqualFold(attrValue(n, "rel"), "canonical"))
+		})
+		if rendered := renderHTML(doc); len(rendered) <= maxBytes {
+			return rendered
+		}
+	}
+	if body == nil {
+		return ""
+	}
+
+	// Fit the body's content into what the rest of the document leaves.
+	content := detachChildren(body)
+	budget := maxBytes - len(renderHTML(doc))
+	if budget < 0 {
+		return ""
+	}
+	for _, child := range content {
+		body.AppendChild(child)
+	}
+	fitChildren(body, budget)
+	rendered := renderHTML(doc)
+	if len(rendered) > maxBytes {
+		return ""
+	}
+	return rendered
+}
+
+// fitChildren drops and cuts n's trailing children until they render to at
+// most budget bytes, and returns the bytes they take.
+func fitChildren(n *html.Node, budget int) int {
+	used := 0
+	child := n.FirstChild
+	for ; child != nil; child = child.NextSibling {
+		size := len(renderHTML(child))
+		if used+size > budget {
+			break
+		}
+		used += size
+	}
+	if child == nil {
+		return used
+	}
+
+	// child is the first that doesn't fit: drop what follows, then cut it.
+	for child.NextSibling != nil {
+		n.RemoveChild(child.NextSibling)
+	}
+	remaining := budget - used
+	switch child.Type {
+	case html.TextNode:
+		child.Data = truncateEscaped(child.Data, remaining)
+		return used + len(renderHTML(child))
+	case html.ElementNode:
+		content := detachChildren(child)
+		if shell := len(renderHTML(child)); shell <= remaining {
+			for _, grandchild := range content {
+				child.AppendChild(grandchild)
+			}
+			return used + shell + fitChildren(child, remaining-shell)
+		}
+	}
+	n.RemoveChild(child)
+	return used
+}
+
+// truncateEscaped returns the longest prefix of text whose escaped form is
+// at most maxBytes, cut at a rune boundary.
+func truncateEscaped(text string, maxBytes int) string {
+	size := 0
+	for i, r := range text {
+		size += len(html.EscapeString(string(r)))
+		if size > maxBytes {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// truncateUTF8 cuts s to at most maxBytes without splitting a rune.
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// removeNodes removes the descendants of root that match.
+func removeNodes(root *html.Node, match func(*html.Node) bool) {
+	var matched []*html.Node
+	for n := range root.Descendants() {
+		if match(n) {
+			matched = append(matched, n)
+		}
+	}
+	for _, n := range matched {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// detachChildren removes and returns n's children.
+func detachChildren(n *html.Node) []*html.Node {
+	var children []*html.Node
+	for n.FirstChild != nil {
+		child := n.FirstChild
+		n.RemoveChild(child)
+		children = append(children, child)
+	}
+	return children
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func renderHTML(n *html.Node) string {
+	var buf bytes.Buffer
+	html.Render(&buf, n)
+	return buf.String()
+}
@@ -0,0 +1,87 @@
+package crawler_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+func TestSmartTruncateHTML(t *testing.T) {
+	const page = `<html><head><title>Story</title><meta name="description" content="A long description of the story">` +
+		`<script>var tracking = "a long script that is dropped first";</script><style>body { color: black; }</style></head>` +
+		`<body><!-- comment --><p>First paragraph of the story.</p><p>Second paragraph of the story.</p></body></html>`
+	tests := []struct {
+		name     string
+		maxBytes int
+		want     string
+	}{
+		{"scripts and comments dropped", 200,
+			`<html><head><title>Story</title><meta name="description" content="A long description of the story"/></head>` +
+				`<body><p>First paragraph of the story.</p><p>Second paragraph of the story.</p></body></html>`},
+		{"head stripped", 140,
+			`<html><head><title>Story</title></head><body><p>First paragraph of the story.</p><p>Second paragraph of the story.</p></body></html>`},
+		{"body cut", 100, ""},
+		{"too small", 10, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := crawler.SmartTruncateHTML(page, tt.maxBytes)
+			if len(got) > tt.maxBytes {
+				t.Errorf("SmartTruncateHTML = %d bytes, over the %d byte limit", len(got), tt.maxBytes)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("SmartTruncateHTML =\n%s\nwant\n%s", got, tt.want)
+			}
+			if tt.name == "body cut" && (!strings.HasPrefix(got, "<html><head><title>Story</title></head><body><p>First paragraph") || !strings.HasSuffix(got, "</p></body></html>")) {
+				t.Errorf("SmartTruncateHTML = %s, want the start of the body, well-formed", got)
+			}
+		})
+	}
+}
+
+func TestHTMLTruncation(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<script>var padding = "` + strings.Repeat("x", 400) + `";</script></body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		strategy string
+		check    func(html string) bool
+	}{
+		{"smart", func(html string) bool {
+			return !strings.Contains(html, "<script>") && strings.HasSuffix(html, "</html>")
+		}},
+		{"naive", func(html string) bool { return len(html) == 300 && strings.HasPrefix(html, "<html>") }},
+		{"skip", func(html string) bool { return html == "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			cfg := loadCrawlerConfig(t, "  max_depth: 0\n  html_truncation: "+tt.strategy+"\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			cfg.Deterministic = true
+			storer := crawltest.NewMockStorer()
+			c := crawler.NewCrawler(cfg, storer, nil)
+			c.SetHTMLLimit(300)
+			if err := c.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			docs := storer.Documents()
+			if len(docs) != 1 {
+				t.Fatalf("stored %v, want the seed", storer.URLs())
+			}
+			want := tt.strategy
+			if want == "skip" {
+				want = crawler.HTMLTruncatedDropped
+			}
+			if docs[0].HTMLTruncation != want || len(docs[0].HTMLSource) > 300 || !tt.check(docs[0].HTMLSource) {
+				t.Errorf("html_truncation = %q with HTML %q, want %s truncation", docs[0].HTMLTruncation, docs[0].HTMLSource, tt.strategy)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package crawler_test
+
+import (
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestHTMLStorage(t *testing.T) {
+	long := article("Long") + `<p>` + strings.Repeat("More words in a long article. ", 20) + `</p></body></html>`
+	tests := []struct {
+		htmlStorage  string
+		wantRetained map[string]bool // by path
+	}{
+		{"always", map[string]bool{"/": true, "/long": true}},
+		{"on_failure", map[string]bool{"/": true, "/long": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.htmlStorage, func(t *testing.T) {
+			server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+				"/":           crawltest.HTML(article("Short") + `<a href="/long">long</a></body></html>`),
+				"/long":       crawltest.HTML(long),
+				"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+			})
+			defer server.Close()
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n  html_storage: "+tt.htmlStorage+"\n  html_min_content_chars: 300\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			docs := storer.Documents()
+			if len(docs) != 2 {
+				t.Fatalf("stored %v, want both pages", storer.URLs())
+			}
+			for _, doc := range docs {
+				want := tt.wantRetained[strings.TrimPrefix(doc.URL, server.URL)]
+				if doc.HTMLRetained != want || (doc.HTMLSource != "") != want {
+					t.Errorf("%s: html_retained = %t with %d bytes of HTML, want %t", doc.URL, doc.HTMLRetained, len(doc.HTMLSource), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"crawlengine/storage"
+)
+
+// inboundLinks counts, per URL, how many distinct crawled pages link to it.
+// Only in-scope links are counted, as seen by extractAndQueueLinks.
+//
+// Counts are eventually consistent: a document stores the count known when
+// it is stored, and pages crawled later may still add referrers. In a
+// breadth-first crawl most referrers of a page are at its own depth or
+// shallower and so are usually seen first, but the stored value is a lower
+// bound. With finalize_inbound_links the final counts are written back to
+// the stored documents of the linked URLs once the crawl ends.
+type inboundLinks struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newInboundLinks() *inboundLinks {
+	return &inboundLinks{counts: make(map[string]int64)}
+}
+
+// Add counts one referring page for each URL in targets.
+func (l *inboundLinks) Add(targets map[string]bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for target := range targets {
+		l.counts[target]++
+	}
+}
+
+// Count returns the number of referring pages seen so far for url.
+func (l *inboundLinks) Count(url string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[url]
+}
+
+// Snapshot returns a copy of all counts.
+func (l *inboundLinks) Snapshot() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[string]int64, len(l.counts))
+	for url, n := range l.counts {
+		counts[url] = n
+	}
+	return counts
+}
+
+// InboundLinkUpdater is implemented by storers that can rewrite the
+// inbound_links field of stored documents after the crawl.
+type InboundLinkUpdater interface {
+	UpdateInboundLinks(ctx context.Context, counts map[string]int64) (int, error)
+}
+
+func (c *Crawler) inboundCount(url string) int64 {
+	if c.inbound == nil {
+		return 0
+	}
+	return c.inbound.Count(url)
+}
+
+// finalizeInboundLinks writes the final inbound link counts to the storer,
+// if it supports updating them.
+func (c *Crawler) finalizeInboundLinks(ctx context.Context) {
+	var updater InboundLinkUpdater
+	if !storage.Find(c.Storer, func(s storage.Storer) bool {
+		var ok bool
+		updater, ok = s.(InboundLinkUpdater)
+		return ok
+	}) {
+		log.Printf("Warning: storer does not support updating inbound links; stored counts are those known at store time.")
+		return
+	}
+	updated, err := updater.UpdateInboundLinks(ctx, c.inbound.Snapshot())
+	if err != nil {
+		log.Printf("Error finalizing inbound link counts: %v", err)
+		return
+	}
+	log.Printf("Finalized inbound link counts for %d documents", updated)
+}
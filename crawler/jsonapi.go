@@ -0,0 +1,240 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/storage"
+)
+
+// jsonItemHashPrefix keeps JSON item IDs apart from page content hashes.
+const jsonItemHashPrefix = "json-item\x00"
+
+// IsJSON reports whether a response is JSON by its Content-Type:
+// application/json or any +json type.
+func IsJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// validateJSONMapping checks that m maps a title or content and that every
+// path is well formed.
+func validateJSONMapping(m *config.JSONMapping) error {
+	if m.Title == "" && m.Content == "" {
+		return errors.New("json mapping needs a title or content path")
+	}
+	paths := map[string]string{
+		"items": m.Items, "id": m.ID, "url": m.URL, "title": m.Title, "content": m.Content,
+		"description": m.Description, "published": m.Published, "language": m.Language,
+	}
+	for i, link := range m.Links {
+		paths[fmt.Sprintf("links[%d]", i)] = link
+	}
+	for name, path := range paths {
+		if path == "" {
+			continue
+		}
+		for _, segment := range strings.Split(path, ".") {
+			if segment == "" {
+				return fmt.Errorf("invalid json %s path '%s': empty segment", name, path)
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPath returns the value at a dot-separated path in v. Segments are
+// object keys, or indexes into arrays; an empty path returns v itself.
+func jsonPath(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[segment]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// jsonText returns the text of the value at path in v: strings (with any
+// HTML stripped) and numbers as is, arrays as their elements' text joined
+// by blank lines. Objects and missing values have no text.
+func jsonText(v any, path string) string {
+	if path == "" {
+		return ""
+	}
+	value, ok := jsonPath(v, path)
+	if !ok {
+		return ""
+	}
+	return jsonValueText(value)
+}
+
+func jsonValueText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return feedText(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []any:
+		var parts []string
+		for _, element := range value {
+			if text := jsonValueText(element); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// jsonTimestamp returns the Unix timestamp of the value at path in v: a
+// number of seconds (or milliseconds, if too large for seconds) or a date
+// string. Returns 0 if it is missing or unparseable.
+func jsonTimestamp(v any, path string) int64 {
+	if path == "" {
+		return 0
+	}
+	value, ok := jsonPath(v, path)
+	if !ok {
+		return 0
+	}
+	switch value := value.(type) {
+	case float64:
+		if value > 1e12 {
+			return int64(value / 1000)
+		}
+		return int64(value)
+	case string:
+		if parsed, err := ParseDate(value); err == nil {
+			return parsed.Unix()
+		}
+	}
+	return 0
+}
+
+// handleJSON stores the documents that the rule's json mapping extracts
+// from the JSON response at pageURL, one per element if the items path is
+// an array, and queues the mapped links. The response itself is not stored.
+func (c *Crawler) handleJSON(ctx context.Context, task CrawlTask, pageURL string, baseURL *url.URL, header http.Header, body string, mapping *config.JSONMapping) {
+	var root any
+	if err := json.Unmarshal([]byte(body), &root); err != nil {
+		log.Printf("Error parsing JSON response %s: %v", pageURL, err)
+		c.recordFailure(pageURL, task.Attempts, newCrawlError(ErrCategoryParse, 0, err))
+		return
+	}
+
+	items, ok := jsonPath(root, mapping.Items)
+	if !ok {
+		log.Printf("JSON response %s has no items at '%s'", pageURL, mapping.Items)
+		items = []any{}
+	}
+	list, isList := items.([]any)
+	if !isList {
+		list = []any{items}
+	}
+
+	queued := 0
+	for i, item := range list {
+		itemURL := pageURL
+		if raw := jsonText(item, mapping.URL); raw != "" {
+			if normalized, err := c.normalizer.Normalize(baseURL, raw); err == nil {
+				itemURL = normalized
+			}
+		}
+		content := c.textNormalizer.Normalize(jsonText(item, mapping.Content))
+		title := c.textNormalizer.Normalize(jsonText(item, mapping.Title))
+		if content == "" && title == "" {
+			continue
+		}
+		id := jsonText(item, mapping.ID)
+		if id == "" && itemURL != pageURL {
+			id = itemURL
+		}
+		if id == "" {
+			id = pageURL + "#" + strconv.Itoa(i)
+		}
+		referrer := task.Referrer
+		if itemURL != pageURL {
+			referrer = pageURL // the item was linked from the JSON response
+		}
+		hashID := GenerateContentHash(jsonItemHashPrefix+id, c.Config.ExtractionVersion)
+		doc := &storage.WebDocument{
+			HashID:               hashID,
+			URL:                  itemURL,
+			MainContent:          content,
+			Title:                title,
+			MetaDescription:      jsonText(item, mapping.Description),
+			CanonicalURL:         itemURL,
+			Language:             jsonText(item, mapping.Language),
+			PublicationTimestamp: jsonTimestamp(item, mapping.Published),
+			CrawledAt:            time.Now().UTC(),
+			ExtractionVersion:    c.Config.ExtractionVersion,
+			CrawlDepth:           int64(task.Depth),
+			SeedURL:              task.SeedURL(),
+			ReferrerURL:          referrer,
+			DuplicateOf:          c.nearDuplicateOf(itemURL, baseURL.Hostname(), content, hashID),
+			ContentFingerprint:   ContentFingerprint(content),
+		}
+		if !c.storable(doc, doc.DuplicateOf != "", header, nil, nil) {
+			continue
+		}
+		c.storeDocument(ctx, doc, func(err error) {
+			if err != nil {
+				log.Printf("Error storing JSON item %s from %s: %v", id, pageURL, err)
+				c.recordFailure(pageURL, task.Attempts, newCrawlError(ErrCategoryStorage, 0, err))
+				return
+			}
+			c.stats.pagesStored.Add(1)
+		})
+		queued++
+	}
+
+	if c.followsLinks() && task.Depth < c.Config.MaxDepth {
+		linked := make(map[string]bool)
+		for _, path := range mapping.Links {
+			value, ok := jsonPath(root, path)
+			if !ok {
+				continue
+			}
+			links, isList := value.([]any)
+			if !isList {
+				links = []any{value}
+			}
+			for _, link := range links {
+				if href, ok := link.(string); ok {
+					c.queueLink(ctx, href, func() string { return "" }, baseURL, task.SeedURL(), task.Depth+1, 0, linked)
+				}
+			}
+		}
+		if c.inbound != nil {
+			c.inbound.Add(linked)
+		}
+	}
+	log.Printf("Queued %d of %d items from JSON response %s for storage", queued, len(list), pageURL)
+}
@@ -0,0 +1,73 @@
+package crawler_test
+
+import (
+	"slices"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestJSONAPIResponsesMapToDocuments(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/api/page1": {ContentType: "application/json", Body: `{"data": {"items": [
+			{"id": 1, "title": "First item", "url": "/items/1", "body": "<p>The first item has a URL of its own.</p>", "published": 1700000000},
+			{"id": 2, "title": "Second item", "body": "The second item has none.", "published": "2024-01-02T03:04:05Z"}]},
+			"next": "/api/page2"}`},
+		"/api/page2": {ContentType: "application/vnd.api+json", Body: `{"data": {"items": [
+			{"id": 3, "title": "Third item", "url": "/items/3", "body": "The third item is on the next page.", "published": 1700000000000}]}}`},
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 1
+  extraction_rules:
+    "127.0.0.1":
+      json:
+        items: data.items
+        id: id
+        url: url
+        title: title
+        content: body
+        published: published
+        links: [next]
+`)
+	cfg.SeedURLs = []string{server.URL + "/api/page1"}
+	_, storer := runCrawl(t, cfg)
+
+	type item struct {
+		url, title, content string
+		published           int64
+	}
+	want := []item{
+		{server.URL + "/items/1", "First item", "The first item has a URL of its own.", 1700000000},
+		{server.URL + "/api/page1", "Second item", "The second item has none.", 1704164645},
+		{server.URL + "/items/3", "Third item", "The third item is on the next page.", 1700000000},
+	}
+	var got []item
+	hashes := make(map[string]bool)
+	for _, doc := range storer.Documents() {
+		got = append(got, item{doc.URL, doc.Title, doc.MainContent, doc.PublicationTimestamp})
+		hashes[doc.HashID] = true
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("stored items = %+v\nwant %+v", got, want)
+	}
+	if len(hashes) != len(got) {
+		t.Errorf("%d items share %d hash IDs, want one each", len(got), len(hashes))
+	}
+}
+
+func TestJSONWithoutMappingIsNotStored(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/api":        {ContentType: "application/json", Body: `{"title": "Unmapped"}`},
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n")
+	cfg.SeedURLs = []string{server.URL + "/api"}
+	_, storer := runCrawl(t, cfg)
+	if got := storer.URLs(); len(got) != 0 {
+		t.Errorf("stored %v, want nothing", got)
+	}
+}
@@ -0,0 +1,196 @@
+package crawler
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Policies for unknown_language_policy, applied to pages whose language is
+// neither declared nor detectable.
+const (
+	UnknownLanguageStore = "store"
+	UnknownLanguageSkip  = "skip"
+)
+
+// maxDetectRunes is how much of the main content DetectLanguage looks at.
+const maxDetectRunes = 4096
+
+// languageFilter decides from a page's language whether to store it. Pages it
+// rejects are still crawled for links. A nil *languageFilter stores
+// everything.
+type languageFilter struct {
+	allowed     []string // normalized tags, e.g. "en" or "pt-br"
+	skipUnknown bool
+}
+
+func newLanguageFilter(allowed []string, unknownPolicy string) *languageFilter {
+	f := &languageFilter{}
+	for _, tag := range allowed {
+		if tag = normalizeLanguageTag(tag); tag != "" && !slices.Contains(f.allowed, tag) {
+			f.allowed = append(f.allowed, tag)
+		}
+	}
+	if len(f.allowed) == 0 {
+		return nil
+	}
+	switch strings.ToLower(unknownPolicy) {
+	case "", UnknownLanguageStore:
+	case UnknownLanguageSkip:
+		f.skipUnknown = true
+	default:
+		log.Printf("Warning: Unknown unknown_language_policy '%s', storing pages of unknown language.", unknownPolicy)
+	}
+	log.Printf("Language filter enabled: storing only %s", strings.Join(f.allowed, ", "))
+	return f
+}
+
+// Allow reports whether a page should be stored, and why. Its language is
+// the lang attribute declared, else the Content-Language response header,
+// else the language detected from content.
+func (f *languageFilter) Allow(declared string, header http.Header, content string) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	language, source := normalizeLanguageTag(declared), "lang attribute"
+	if language == "" && header != nil {
+		language, source = normalizeLanguageTag(header.Get("Content-Language")), "Content-Language header"
+	}
+	if language == "" {
+		language, source = DetectLanguage(content), "detected"
+	}
+	if language == "" {
+		if f.skipUnknown {
+			return false, "language unknown, unknown_language_policy is skip"
+		}
+		return true, "language unknown"
+	}
+	for _, allowed := range f.allowed {
+		if language == allowed || strings.HasPrefix(language, allowed+"-") {
+			return true, "language '" + language + "' (" + source + ") is allowed"
+		}
+	}
+	return false, "language '" + language + "' (" + source + ") is not in allowed_languages"
+}
+
+// normalizeLanguageTag lowercases a language tag such as "en_US" to "en-us".
+// Of a list like a Content-Language header, only the first tag is kept.
+func normalizeLanguageTag(tag string) string {
+	tag, _, _ = strings.Cut(tag, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-")
+}
+
+// stopwords are frequent words of the Latin-script languages DetectLanguage
+// tells apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "was", "on", "are", "this", "be", "as", "by", "not", "you", "have"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "des", "auf", "für", "im", "dem", "auch", "es"},
+	"fr": {"le", "la", "les", "et", "des", "est", "un", "une", "du", "que", "pour", "dans", "qui", "pas", "sur", "au", "avec", "ce", "il", "sont"},
+	"es": {"el", "la", "los", "las", "y", "que", "de", "en", "un", "una", "es", "por", "con", "para", "del", "se", "no", "su", "al", "lo"},
+	"it": {"il", "di", "che", "e", "la", "per", "un", "una", "non", "sono", "del", "della", "con", "è", "gli", "le", "da", "si", "nel", "anche"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "é", "por", "se", "dos", "mais"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met", "voor", "die", "ook", "er", "aan", "om", "maar", "als"},
+}
+
+// DetectLanguage guesses the language of text from its script and, for Latin
+// text, its most frequent words. It returns a tag such as "ko" or "en", or ""
+// if the text is too short or ambiguous to tell.
+func DetectLanguage(text string) string {
+	var letters, hangul, kana, han, latin int
+	scripts := map[string]int{}
+	var latinText strings.Builder
+	n := 0
+	for _, r := range text {
+		if n++; n > maxDetectRunes {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			latinText.WriteRune(' ')
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			latinText.WriteRune(unicode.ToLower(r))
+			continue
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		}
+		latinText.WriteRune(' ')
+	}
+	if letters < 20 {
+		return ""
+	}
+	// Korean and Japanese text mix in Han characters, so their own scripts
+	// decide at lower shares.
+	switch {
+	case hangul*5 > letters:
+		return "ko"
+	case kana*10 > letters:
+		return "ja"
+	case han*2 > letters:
+		return "zh"
+	}
+	for language, count := range scripts {
+		if count*2 > letters {
+			return language
+		}
+	}
+	if latin*2 <= letters {
+		return ""
+	}
+	return detectLatinLanguage(strings.Fields(latinText.String()))
+}
+
+// detectLatinLanguage returns the language whose stopwords make up the
+// largest share of words, if that share is high enough and clearly ahead of
+// the runner-up.
+func detectLatinLanguage(words []string) string {
+	if len(words) < 10 {
+		return ""
+	}
+	hits := make(map[string]int, len(stopwords))
+	for _, word := range words {
+		for language, list := range stopwords {
+			if slices.Contains(list, word) {
+				hits[language]++
+			}
+		}
+	}
+	ranked := make([]string, 0, len(hits))
+	for language := range hits {
+		ranked = append(ranked, language)
+	}
+	if len(ranked) == 0 {
+		return ""
+	}
+	slices.SortFunc(ranked, func(a, b string) int {
+		if hits[a] != hits[b] {
+			return hits[b] - hits[a]
+		}
+		return strings.Compare(a, b)
+	})
+	best, secondHits := ranked[0], 0
+	if len(ranked) > 1 {
+		secondHits = hits[ranked[1]]
+	}
+	// At least a tenth of the words, and a quarter more than the runner-up.
+	if hits[best]*10 < len(words) || hits[best]*4 < secondHits*5 {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,71 @@
+package crawler_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+// page is an article page declaring lang on its html element.
+func page(lang, body string) crawltest.Fixture {
+	return crawltest.HTML(strings.Replace(article(body), "<html>", `<html lang="`+lang+`">`, 1) + `</body></html>`)
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"The crawler stores the pages that it finds and it is one of the tools for the team.", "en"},
+		{"Der Crawler speichert die Seiten, die er findet, und das ist nicht mit dem Index zu verwechseln.", "de"},
+		{"크롤러는 찾은 페이지를 저장하고 링크를 따라가며 새로운 문서를 수집합니다.", "ko"},
+		{"Too short to tell.", ""},
+		{"1234 5678 9012 3456 7890 1234 5678 9012 3456 7890", ""},
+	}
+	for _, tt := range tests {
+		if got := crawler.DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedLanguagesFilterPages(t *testing.T) {
+	german := crawltest.HTML(article("Ein deutscher Artikel") + `</body></html>`)
+	german.Header = http.Header{"Content-Language": {"de-DE"}}
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/": crawltest.HTML(article("The home page of the site and the index of all of its articles") +
+			`<a href="/en-gb">en-gb</a><a href="/fr">fr</a><a href="/de">de</a><a href="/unknown">unknown</a></body></html>`),
+		"/en-gb":      page("en_GB", "A British page"),
+		"/fr":         page("fr", "Une page française"),
+		"/de":         german,
+		"/unknown":    crawltest.HTML(`<html><head><title>Numbers</title></head><body><p>1 2 3</p></body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{"store", []string{"/", "/en-gb", "/unknown"}},
+		{"skip", []string{"/", "/en-gb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n  allowed_languages: [EN]\n  unknown_language_policy: "+tt.policy+"\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			var got []string
+			for _, url := range storer.URLs() {
+				got = append(got, strings.TrimPrefix(url, server.URL))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("stored %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxLinkReferrers bounds the referring pages remembered per link target;
+// site-wide navigation links are referred to by every page.
+const maxLinkReferrers = 20
+
+// headRequest fetches the status of a verify_links target without its body.
+var headRequest = &TaskRequest{Method: http.MethodHead}
+
+// linkResult is the outcome of checking one link target that is reported:
+// broken (status 4xx or 5xx, or 0 if no response) or redirected.
+type linkResult struct {
+	status int
+	chain  []string // requested URL, redirect hops and final URL; nil unless redirected
+	err    string
+}
+
+// linkReport collects the broken and redirected links of a verify_links
+// crawl with the pages linking to them, and writes them as CSV when the
+// crawl ends. A nil *linkReport records nothing.
+type linkReport struct {
+	path string
+
+	mu        sync.Mutex
+	referrers map[string][]string // target URL -> referring pages, up to maxLinkReferrers
+	results   map[string]linkResult
+}
+
+func newLinkReport(mode, path string) *linkReport {
+	if mode != ModeVerifyLinks {
+		return nil
+	}
+	if path == "" {
+		path = "link_report.csv"
+	}
+	log.Printf("Verify links mode: pages are checked, not stored; broken and redirected links are reported to %s", path)
+	return &linkReport{
+		path:      path,
+		referrers: make(map[string][]string),
+		results:   make(map[string]linkResult),
+	}
+}
+
+// AddReferrer records that page links to target.
+func (r *linkReport) AddReferrer(target, page string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refs := r.referrers[target]
+	if len(refs) < maxLinkReferrers && !slices.Contains(refs, page) {
+		r.referrers[target] = append(refs, page)
+	}
+}
+
+// Record records the outcome of fetching target, keeping it only if the
+// link is broken or redirected. result is nil when err is set.
+func (r *linkReport) Record(target string, result *FetchResult, err error) {
+	if r == nil {
+		return
+	}
+	var entry linkResult
+	switch {
+	case err != nil:
+		var crawlErr *CrawlError
+		if errors.As(err, &crawlErr) {
+			entry.status = crawlErr.StatusCode
+		}
+		if entry.status != 0 && entry.status < 400 {
+			return // e.g. 204 No Content, which the crawler doesn't store but isn't broken
+		}
+		entry.err = strings.Join(strings.Fields(err.Error()), " ")
+	case result.Location != "":
+		entry.status = result.StatusCode
+		entry.chain = []string{target, result.Location}
+	case len(result.RedirectChain) > 0:
+		entry.status = result.StatusCode
+		entry.chain = append(append([]string(nil), result.RedirectChain...), result.FinalURL)
+	default:
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[target] = entry
+}
+
+// Write writes the report, one row per referring page of each broken or
+// redirected link, sorted by target:
+//
+//	source_url,target_url,status,redirect_chain,error
+//
+// source_url is empty for seeds, and redirect_chain joins its URLs with
+// " -> ".
+func (r *linkReport) Write() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to create link report %s: %w", r.path, err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	w.Write([]string{"source_url", "target_url", "status", "redirect_chain", "error"})
+
+	targets := make([]string, 0, len(r.results))
+	for target := range r.results {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	broken := 0
+	for _, target := range targets {
+		result := r.results[target]
+		if result.chain == nil {
+			broken++
+		}
+		sources := r.referrers[target]
+		if len(sources) == 0 {
+			sources = []string{""}
+		}
+		for _, source := range sources {
+			w.Write([]string{source, target, strconv.Itoa(result.status), strings.Join(result.chain, " -> "), result.err})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write link report %s: %w", r.path, err)
+	}
+	log.Printf("Link report: %d broken and %d redirected links written to %s", broken, len(targets)-broken, r.path)
+	return nil
+}
+
+// headSuffices reports whether task can be checked with a HEAD request:
+// its links won't be followed, so its body isn't needed.
+func (c *Crawler) headSuffices(task CrawlTask) bool {
+	return c.linkReport != nil && task.Request == nil &&
+		(task.CheckOnly || task.Depth >= c.Config.MaxDepth || !c.followsLinks())
+}
+
+// headUnsupported reports whether err is a server's refusal of HEAD, in
+// which case the link is checked again with GET.
+func headUnsupported(err error) bool {
+	var crawlErr *CrawlError
+	return errors.As(err, &crawlErr) &&
+		(crawlErr.StatusCode == http.StatusMethodNotAllowed || crawlErr.StatusCode == http.StatusNotImplemented)
+}
@@ -0,0 +1,54 @@
+package crawler_test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestVerifyLinksReport(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/ok">ok</a><a href="/moved">moved</a><a href="/missing">missing</a></body></html>`),
+		"/ok":         crawltest.HTML(article("OK") + `<a href="/missing">missing</a></body></html>`),
+		"/moved":      crawltest.Redirect(http.StatusMovedPermanently, "/ok"),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	report := filepath.Join(t.TempDir(), "links.csv")
+	cfg := loadCrawlerConfig(t, "  max_depth: 2\n  mode: verify_links\n  link_report_file: "+report+"\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	if urls := storer.URLs(); len(urls) != 0 {
+		t.Errorf("stored %v, want nothing in verify_links mode", urls)
+	}
+	file, err := os.Open(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range rows {
+		got = append(got, strings.ReplaceAll(strings.Join(row[:4], ","), server.URL, ""))
+	}
+	want := []string{
+		"source_url,target_url,status,redirect_chain",
+		"/,/missing,404,",
+		"/ok,/missing,404,",
+		"/,/moved,200,/moved -> /ok",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("report rows =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
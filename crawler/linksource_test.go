@@ -0,0 +1,45 @@
+package crawler_test
+
+import (
+	"slices"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestLinkSource(t *testing.T) {
+	tests := []struct {
+		linkSource string
+		want       []string
+	}{
+		{"html", []string{"/", "/linked"}},
+		{"sitemap", []string{"/", "/listed"}},
+		{"both", []string{"/", "/linked", "/listed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.linkSource, func(t *testing.T) {
+			fixtures := map[string]crawltest.Fixture{
+				"/":           crawltest.HTML(article("Home") + `<a href="/linked">linked</a></body></html>`),
+				"/linked":     crawltest.HTML(article("Linked") + `</body></html>`),
+				"/listed":     crawltest.HTML(article("Listed") + `</body></html>`),
+				"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+			}
+			server := crawltest.NewFixtureServer(fixtures)
+			defer server.Close()
+			fixtures["/sitemap.xml"] = crawltest.XML(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>` + server.URL + `/listed</loc></url></urlset>`)
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n  link_source: "+tt.linkSource+"\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			var want []string
+			for _, path := range tt.want {
+				want = append(want, server.URL+path)
+			}
+			if got := storer.URLs(); !slices.Equal(got, want) {
+				t.Errorf("stored URLs = %v, want %v", got, want)
+			}
+		})
+	}
+}
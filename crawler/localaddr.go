@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"crawlengine/config"
+)
+
+// Selections for local_address_selection.
+const (
+	LocalAddressRoundRobin = "round_robin"
+	LocalAddressRandom     = "random"
+)
+
+// contextDialer dials connections, like *net.Dialer.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// localAddrDialer binds each connection it dials to one of several local
+// source addresses, taking turns or picking at random, so traffic leaves
+// through all of a multi-homed host's egress IPs. If dialing from one
+// address fails, the others are tried before giving up; addresses the host
+// doesn't have are dropped from the rotation.
+type localAddrDialer struct {
+	dialer      net.Dialer
+	addrs       []net.IP
+	unavailable []atomic.Bool // by index into addrs
+	random      bool
+	next        atomic.Uint64
+}
+
+// newLocalAddrDialer returns a dialer using the local_addresses of cfg with
+// the settings of base, or base itself if none are valid.
+func newLocalAddrDialer(cfg *config.CrawlerConfig, base *net.Dialer) contextDialer {
+	var addrs []net.IP
+	var names []string
+	for _, raw := range cfg.LocalAddresses {
+		ip := net.ParseIP(strings.TrimSpace(raw))
+		if ip == nil {
+			log.Printf("Warning: Ignoring invalid local address '%s'.", raw)
+			continue
+		}
+		addrs = append(addrs, ip)
+		names = append(names, ip.String())
+	}
+	if len(addrs) == 0 {
+		return base
+	}
+
+	d := &localAddrDialer{dialer: *base, addrs: addrs, unavailable: make([]atomic.Bool, len(addrs))}
+	switch selection := strings.ToLower(cfg.LocalAddressSelection); selection {
+	case "", LocalAddressRoundRobin:
+	case LocalAddressRandom:
+		d.random = true
+	default:
+		log.Printf("Warning: Unsupported local_address_selection '%s', defaulting to %s.", cfg.LocalAddressSelection, LocalAddressRoundRobin)
+	}
+	log.Printf("Dialing from %d local addresses: %s", len(addrs), strings.Join(names, ", "))
+	return d
+}
+
+// DialContext dials address from the next local address of the remote
+// address's IP family, falling back to the others in turn on failure.
+func (d *localAddrDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	remote := net.ParseIP(host)
+
+	// An IPv4 source can't reach an IPv6 destination, or vice versa.
+	var candidates []int
+	for i, local := range d.addrs {
+		if !d.unavailable[i].Load() && (remote == nil || (remote.To4() == nil) == (local.To4() == nil)) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no available local address of the same IP family as %s", host)
+	}
+
+	var start int
+	if d.random {
+		start = rand.Intn(len(candidates))
+	} else {
+		start = int(d.next.Add(1)-1) % len(candidates)
+	}
+	var lastErr error
+	for i := range candidates {
+		index := candidates[(start+i)%len(candidates)]
+		dialer := d.dialer
+		dialer.LocalAddr = &net.TCPAddr{IP: d.addrs[index]}
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err == nil {
+			return conn, nil
+		}
+		if errors.Is(err, syscall.EADDRNOTAVAIL) && d.unavailable[index].CompareAndSwap(false, true) {
+			log.Printf("Warning: Local address %s is not available on this host, no longer dialing from it: %v", d.addrs[index], err)
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
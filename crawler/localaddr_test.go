@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestLocalAddrDialerRotatesSources(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	sources := make(chan string)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			conn.Close()
+			sources <- host
+		}
+	}()
+
+	cfg := &config.CrawlerConfig{LocalAddresses: []string{"127.0.0.1", "not an address", "192.0.2.1", "::1", "127.0.0.2"}}
+	d, ok := newLocalAddrDialer(cfg, &net.Dialer{}).(*localAddrDialer)
+	if !ok {
+		t.Fatal("newLocalAddrDialer with local addresses returned the base dialer")
+	}
+	var got []string
+	for range 4 {
+		conn, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("DialContext: %v", err)
+		}
+		conn.Close()
+		got = append(got, <-sources)
+	}
+	// 192.0.2.1 isn't an address of this host and ::1 is of the wrong family.
+	if want := []string{"127.0.0.1", "127.0.0.2", "127.0.0.1", "127.0.0.2"}; !slices.Equal(got, want) {
+		t.Errorf("dialed from %v, want %v", got, want)
+	}
+	if !d.unavailable[1].Load() {
+		t.Error("unavailable local address 192.0.2.1 is still in the rotation")
+	}
+
+	if _, ok := newLocalAddrDialer(&config.CrawlerConfig{}, &net.Dialer{}).(*net.Dialer); !ok {
+		t.Error("newLocalAddrDialer without local addresses did not return the base dialer")
+	}
+}
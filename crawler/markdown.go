@@ -0,0 +1,210 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Content formats for content_format.
+const (
+	ContentFormatPlaintext = "plaintext"
+	ContentFormatMarkdown  = "markdown"
+)
+
+var inlineSpaceRegex = regexp.MustCompile(`[ \t\r\n]+`)
+
+// RenderMarkdown renders the selected HTML as Markdown: headings as "#",
+// list items as "-" or "1.", links as [text](url), bold/italic emphasis,
+// code blocks and block quotes. Scripts, styles and navigation are dropped.
+func RenderMarkdown(sel *goquery.Selection, base *url.URL) string {
+	r := &markdownRenderer{base: base}
+	for _, node := range sel.Nodes {
+		r.render(node)
+	}
+	return strings.TrimSpace(r.String())
+}
+
+type markdownRenderer struct {
+	strings.Builder
+	base      *url.URL
+	listStack []listState
+	inPre     bool
+}
+
+type listState struct {
+	ordered bool
+	index   int
+}
+
+func (r *markdownRenderer) blockBreak() {
+	s := r.String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(s, "\n") {
+		r.WriteString("\n")
+	} else {
+		r.WriteString("\n\n")
+	}
+}
+
+func (r *markdownRenderer) lineBreak() {
+	if s := r.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		r.WriteString("\n")
+	}
+}
+
+func (r *markdownRenderer) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		r.render(child)
+	}
+}
+
+func (r *markdownRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if r.inPre {
+			r.WriteString(n.Data)
+			return
+		}
+		text := inlineSpaceRegex.ReplaceAllString(n.Data, " ")
+		if strings.HasSuffix(r.String(), "\n") || r.Len() == 0 {
+			text = strings.TrimLeft(text, " ")
+		}
+		r.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript", "nav", "footer", "aside", "template", "iframe":
+		return
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.blockBreak()
+		r.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		r.children(n)
+		r.blockBreak()
+	case "p", "div", "section", "article", "main", "header", "figure", "table":
+		r.blockBreak()
+		r.children(n)
+		r.blockBreak()
+	case "tr":
+		r.lineBreak()
+		r.children(n)
+		r.lineBreak()
+	case "td", "th":
+		r.children(n)
+		r.WriteString(" ")
+	case "br":
+		r.WriteString("\n")
+	case "hr":
+		r.blockBreak()
+		r.WriteString("---")
+		r.blockBreak()
+	case "ul", "ol":
+		r.lineBreak()
+		if len(r.listStack) == 0 {
+			r.blockBreak()
+		}
+		r.listStack = append(r.listStack, listState{ordered: n.Data == "ol"})
+		r.children(n)
+		r.listStack = r.listStack[:len(r.listStack)-1]
+		r.lineBreak()
+		if len(r.listStack) == 0 {
+			r.blockBreak()
+		}
+	case "li":
+		r.lineBreak()
+		depth := len(r.listStack)
+		if depth == 0 {
+			r.WriteString("- ")
+		} else {
+			state := &r.listStack[depth-1]
+			r.WriteString(strings.Repeat("  ", depth-1))
+			if state.ordered {
+				state.index++
+				r.WriteString(fmt.Sprintf("%d. ", state.index))
+			} else {
+				r.WriteString("- ")
+			}
+		}
+		r.children(n)
+		r.lineBreak()
+	case "a":
+		href := attr(n, "href")
+		text := strings.TrimSpace(inlineSpaceRegex.ReplaceAllString(nodeText(n), " "))
+		if href == "" || text == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			r.children(n)
+			return
+		}
+		if r.base != nil {
+			if resolved, err := NormalizeURL(r.base, href); err == nil {
+				href = resolved
+			}
+		}
+		r.WriteString("[" + text + "](" + href + ")")
+	case "strong", "b":
+		r.WriteString("**")
+		r.children(n)
+		r.WriteString("**")
+	case "em", "i":
+		r.WriteString("*")
+		r.children(n)
+		r.WriteString("*")
+	case "code":
+		if r.inPre {
+			r.children(n)
+			return
+		}
+		r.WriteString("`")
+		r.children(n)
+		r.WriteString("`")
+	case "pre":
+		r.blockBreak()
+		r.WriteString("```\n")
+		r.inPre = true
+		r.children(n)
+		r.inPre = false
+		r.lineBreak()
+		r.WriteString("```")
+		r.blockBreak()
+	case "blockquote":
+		r.blockBreak()
+		inner := &markdownRenderer{base: r.base}
+		inner.children(n)
+		for _, line := range strings.Split(strings.TrimSpace(inner.String()), "\n") {
+			r.WriteString("> " + line + "\n")
+		}
+		r.blockBreak()
+	default:
+		r.children(n)
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxMetaValueRunes caps the length of a single stored meta tag value.
+const maxMetaValueRunes = 1024
+
+// ExtractMetaTags serializes the document's <meta name|property content>
+// tags as a JSON object keyed by the lowercased name or property, e.g.
+// "keywords", "robots", "og:image" or "twitter:card". Values have their
+// whitespace collapsed; a key repeated with the same value is kept once, and
+// with different values maps to an array of them in document order. With
+// maxBytes > 0, keys that would take the JSON past maxBytes are dropped,
+// later ones first. Returns "" when no meta tag qualifies.
+func ExtractMetaTags(doc *goquery.Document, maxBytes int) (string, error) {
+	var keys []string // in document order
+	values := make(map[string][]string)
+	doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		value := strings.Join(strings.Fields(s.AttrOr("content", "")), " ")
+		if value == "" {
+			return
+		}
+		if runes := []rune(value); len(runes) > maxMetaValueRunes {
+			value = strings.TrimSpace(string(runes[:maxMetaValueRunes]))
+		}
+		var tagKeys []string
+		for _, attr := range []string{"name", "property"} {
+			key := strings.ToLower(strings.TrimSpace(s.AttrOr(attr, "")))
+			if key != "" && (len(tagKeys) == 0 || tagKeys[0] != key) {
+				tagKeys = append(tagKeys, key)
+			}
+		}
+		for _, key := range tagKeys {
+			if _, seen := values[key]; !seen {
+				keys = append(keys, key)
+			}
+			if !slices.Contains(values[key], value) {
+				values[key] = append(values[key], value)
+			}
+		}
+	})
+
+	meta := make(map[string]any, len(keys))
+	size := len("{}")
+	for _, key := range keys {
+		var value any = values[key]
+		if len(values[key]) == 1 {
+			value = values[key][0]
+		}
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize meta tag name: %w", err)
+		}
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize meta tag %s: %w", key, err)
+		}
+		entrySize := len(keyJSON) + len(":") + len(valueJSON)
+		if len(meta) > 0 {
+			entrySize += len(",")
+		}
+		if maxBytes > 0 && size+entrySize > maxBytes {
+			continue
+		}
+		meta[key] = value
+		size += entrySize
+	}
+	if len(meta) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize meta tags: %w", err)
+	}
+	return string(data), nil
+}
+
+// SetMetaJSONLimit caps meta_json at maxBytes, the length of its field in
+// the collection, by dropping meta tags that don't fit; 0 leaves it
+// uncapped. It must be called before Start.
+func (c *Crawler) SetMetaJSONLimit(maxBytes int) {
+	c.maxMetaJSONBytes = maxBytes
+}
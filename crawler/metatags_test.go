@@ -0,0 +1,59 @@
+package crawler_test
+
+import (
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractMetaTags(t *testing.T) {
+	const page = `<html><head>
+<meta charset="utf-8">
+<meta name="Keywords" content="  go,
+  crawler ">
+<meta property="og:image" content="https://example.com/a.png">
+<meta property="og:image" content="https://example.com/b.png">
+<meta name="robots" content="index">
+<meta name="robots" content="index">
+<meta name="twitter:card" property="og:type" content="summary">
+<meta name="empty" content="">
+</head><body></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		want     string
+	}{
+		{"all", 0, `{"keywords":"go, crawler","og:image":["https://example.com/a.png","https://example.com/b.png"],` +
+			`"og:type":"summary","robots":"index","twitter:card":"summary"}`},
+		{"capped", 45, `{"keywords":"go, crawler","robots":"index"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := crawler.ExtractMetaTags(doc, tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractMetaTags = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMetaTagsWithoutTags(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><meta charset="utf-8"></head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := crawler.ExtractMetaTags(doc, 0); got != "" || err != nil {
+		t.Errorf("ExtractMetaTags = %q, %v, want no meta tags", got, err)
+	}
+}
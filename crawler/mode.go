@@ -0,0 +1,52 @@
+package crawler
+
+// Crawl modes for mode.
+//
+// ModeFetchList is not the same as max_depth: 0. A depth-0 crawl never
+// extracts links from fetched pages either, but it still expands seeds through
+// their sitemaps when use_sitemaps is set. A fetch list fetches exactly the
+// listed URLs, plus the targets of their redirects and meta refreshes, which
+// stand in for the listed page rather than being new links.
+const (
+	// ModeCrawl fetches the seeds and follows in-scope links up to max_depth.
+	ModeCrawl = "crawl"
+	// ModeFetchList fetches, extracts and stores seed_urls and seed_file only,
+	// never following links, feed item links, hreflang alternates or sitemaps.
+	ModeFetchList = "fetch_list"
+	// ModeVerifyLinks crawls like ModeCrawl but neither extracts nor stores
+	// pages, reporting broken and redirected links to link_report_file.
+	ModeVerifyLinks = "verify_links"
+)
+
+// Link sources for link_source.
+const (
+	// LinkSourceHTML follows links found in fetched pages; sitemaps are only
+	// used when use_sitemaps is set.
+	LinkSourceHTML = "html"
+	// LinkSourceSitemap crawls the seeds and the URLs in their sitemaps, at
+	// depth 0, without following any link found in a page.
+	LinkSourceSitemap = "sitemap"
+	// LinkSourceBoth seeds from sitemaps and follows page links.
+	LinkSourceBoth = "both"
+)
+
+// HTML storage policies for html_storage.
+const (
+	// HTMLStorageAlways stores the HTML of every page.
+	HTMLStorageAlways = "always"
+	// HTMLStorageOnFailure stores the HTML only of pages whose main content
+	// is shorter than html_min_content_chars, so hard cases can be debugged
+	// and reprocessed later.
+	HTMLStorageOnFailure = "on_failure"
+)
+
+// followsLinks reports whether links found in fetched pages, including feed
+// item links and hreflang alternates, may be added to the frontier.
+func (c *Crawler) followsLinks() bool {
+	return c.mode != ModeFetchList && c.linkSource != LinkSourceSitemap
+}
+
+// usesSitemaps reports whether seeds are expanded through their sitemaps.
+func (c *Crawler) usesSitemaps() bool {
+	return c.mode != ModeFetchList && (c.Config.UseSitemaps || c.linkSource != LinkSourceHTML)
+}
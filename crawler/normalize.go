@@ -0,0 +1,182 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Trailing-slash policies for trailing_slash.
+const (
+	TrailingSlashKeep  = "keep"  // leave non-root paths as they are
+	TrailingSlashStrip = "strip" // remove the trailing slash from non-root paths
+)
+
+// urlNormalizer produces the canonical form of a URL used both for
+// visited-tracking and as the stored document URL. With collapseWWW, hosts
+// differing only in a leading "www." share one key for visited-tracking and
+// host scoping, but URLs keep the host they were found with, since that is
+// the one the site serves. Likewise, paths on caseInsensitiveHosts are
+// lowercased only in visited keys.
+type urlNormalizer struct {
+	trailingSlash        string
+	collapseWWW          bool
+	caseInsensitiveHosts map[string]bool // host keys
+}
+
+var defaultNormalizer = &urlNormalizer{trailingSlash: TrailingSlashKeep}
+
+func newURLNormalizer(trailingSlash string, collapseWWW bool, caseInsensitiveHosts []string) *urlNormalizer {
+	if !strings.EqualFold(trailingSlash, TrailingSlashStrip) {
+		trailingSlash = TrailingSlashKeep
+	}
+	n := &urlNormalizer{trailingSlash: strings.ToLower(trailingSlash), collapseWWW: collapseWWW}
+	if len(caseInsensitiveHosts) > 0 {
+		n.caseInsensitiveHosts = make(map[string]bool, len(caseInsensitiveHosts))
+		for _, host := range caseInsensitiveHosts {
+			n.caseInsensitiveHosts[n.HostKey(strings.ToLower(strings.TrimSpace(host)))] = true
+		}
+	}
+	return n
+}
+
+// HostKey returns the key host is tracked under.
+func (n *urlNormalizer) HostKey(host string) string {
+	if n.collapseWWW {
+		return stripWWW(host)
+	}
+	return host
+}
+
+// VisitKey returns the key a canonical URL is tracked under in the visited set.
+// On case-insensitive hosts the path is lowercased, so /Page and /page are
+// crawled once; the query is left alone.
+func (n *urlNormalizer) VisitKey(canonicalURL string) string {
+	if !n.collapseWWW && n.caseInsensitiveHosts == nil {
+		return canonicalURL
+	}
+	u, err := url.Parse(canonicalURL)
+	if err != nil || u.Host == "" {
+		return canonicalURL
+	}
+	u.Host = n.HostKey(u.Host)
+	if n.caseInsensitiveHosts[u.Hostname()] {
+		u.Path = strings.ToLower(u.Path)
+		u.RawPath = strings.ToLower(u.RawPath)
+	}
+	return u.String()
+}
+
+// stripWWW removes a leading "www." from host, unless that would leave a
+// single-label host such as "www.localhost" -> "localhost".
+func stripWWW(host string) string {
+	if rest, ok := strings.CutPrefix(host, "www."); ok && strings.Contains(rest, ".") {
+		return rest
+	}
+	return host
+}
+
+// Normalize resolves relativePath against base and returns its canonical form.
+func (n *urlNormalizer) Normalize(base *url.URL, relativePath string) (string, error) {
+	relURL, err := url.Parse(relativePath)
+	if err != nil {
+		return "", err
+	}
+	absURL := base.ResolveReference(relURL)
+	n.canonicalize(absURL)
+	return absURL.String(), nil
+}
+
+// Canonicalize returns the canonical form of an absolute URL string.
+func (n *urlNormalizer) Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	n.canonicalize(u)
+	return u.String(), nil
+}
+
+// canonicalize rewrites u in place: lowercase scheme and host, no default
+// port, no fragment, "/" for an empty path, the configured trailing-slash
+// policy, and normalized percent-encoding in the path.
+func (n *urlNormalizer) canonicalize(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") { // IPv6 literal
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Host == "" {
+		return // not a hierarchical URL (mailto:, etc.)
+	}
+
+	escapedPath := normalizePercentEncoding(u.EscapedPath())
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+	if n.trailingSlash == TrailingSlashStrip && len(escapedPath) > 1 {
+		escapedPath = strings.TrimRight(escapedPath, "/")
+		if escapedPath == "" {
+			escapedPath = "/"
+		}
+	}
+	if path, err := url.PathUnescape(escapedPath); err == nil {
+		u.Path = path
+		u.RawPath = escapedPath
+	}
+}
+
+// normalizePercentEncoding decodes percent-escapes of unreserved characters
+// (RFC 3986 section 2.3) and uppercases the hex digits of the remaining ones.
+func normalizePercentEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
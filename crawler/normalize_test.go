@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		trailingSlash string
+		in            string
+		want          string
+	}{
+		{"lowercase scheme and host", TrailingSlashKeep, "HTTP://Example.COM/Path", "http://example.com/Path"},
+		{"default http port", TrailingSlashKeep, "http://example.com:80/a", "http://example.com/a"},
+		{"default https port", TrailingSlashKeep, "https://example.com:443/a", "https://example.com/a"},
+		{"non-default port kept", TrailingSlashKeep, "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"http port on https kept", TrailingSlashKeep, "https://example.com:80/a", "https://example.com:80/a"},
+		{"IPv6 default port", TrailingSlashKeep, "http://[::1]:80/a", "http://[::1]/a"},
+		{"IPv6 other port", TrailingSlashKeep, "http://[::1]:8080/a", "http://[::1]:8080/a"},
+		{"fragment dropped", TrailingSlashKeep, "http://example.com/a#top", "http://example.com/a"},
+		{"empty path", TrailingSlashKeep, "http://example.com", "http://example.com/"},
+		{"empty path with query", TrailingSlashKeep, "http://example.com?q=1", "http://example.com/?q=1"},
+		{"keep trailing slash", TrailingSlashKeep, "http://example.com/dir/", "http://example.com/dir/"},
+		{"strip trailing slash", TrailingSlashStrip, "http://example.com/dir/", "http://example.com/dir"},
+		{"strip repeated slashes", TrailingSlashStrip, "http://example.com/dir//", "http://example.com/dir"},
+		{"strip keeps root", TrailingSlashStrip, "http://example.com/", "http://example.com/"},
+		{"strip keeps query", TrailingSlashStrip, "http://example.com/dir/?a=1", "http://example.com/dir?a=1"},
+		{"unreserved escapes decoded", TrailingSlashKeep, "http://example.com/%7Euser/%41b", "http://example.com/~user/Ab"},
+		{"reserved escapes uppercased", TrailingSlashKeep, "http://example.com/a%2fb%3a", "http://example.com/a%2Fb%3A"},
+		{"space stays escaped", TrailingSlashKeep, "http://example.com/a%20b", "http://example.com/a%20b"},
+		{"non-ASCII escaped", TrailingSlashKeep, "http://example.com/caf%c3%a9", "http://example.com/caf%C3%A9"},
+		{"escaped percent kept", TrailingSlashKeep, "http://example.com/100%25", "http://example.com/100%25"},
+		{"query left alone", TrailingSlashKeep, "http://example.com/?q=%7e", "http://example.com/?q=%7e"},
+		{"mailto untouched", TrailingSlashKeep, "mailto:someone@example.com", "mailto:someone@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newURLNormalizer(tt.trailingSlash, false, nil)
+			got, err := n.Canonicalize(tt.in)
+			if err != nil {
+				t.Fatalf("Canonicalize(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+	for _, in := range []string{"http://example.com/%zz", "http://[::1/", "://missing-scheme"} {
+		if got, err := defaultNormalizer.Canonicalize(in); err == nil {
+			t.Errorf("Canonicalize(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	base, err := url.Parse("https://Example.com:443/docs/guide/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"intro", "https://example.com/docs/guide/intro"},
+		{"../api/", "https://example.com/docs/api/"},
+		{"/", "https://example.com/"},
+		{"?page=2", "https://example.com/docs/guide/?page=2"},
+		{"#section", "https://example.com/docs/guide/"},
+		{"//CDN.example.com:443/x", "https://cdn.example.com/x"},
+		{"http://other.example:80/%7ea", "http://other.example/~a"},
+		{"www.example.com/a", "https://example.com/docs/guide/www.example.com/a"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeURL(base, tt.ref)
+		if err != nil {
+			t.Errorf("NormalizeURL(%q): %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+	if _, err := NormalizeURL(base, "%zz"); err == nil {
+		t.Error("NormalizeURL(\"%zz\") should fail")
+	}
+}
+
+func TestHostAndVisitKeys(t *testing.T) {
+	tests := []struct {
+		name                 string
+		collapseWWW          bool
+		caseInsensitiveHosts []string
+		url                  string
+		wantHost             string
+		wantVisit            string
+	}{
+		{"www kept", false, nil, "https://www.example.com/A", "www.example.com", "https://www.example.com/A"},
+		{"www collapsed", true, nil, "https://www.example.com/A", "example.com", "https://example.com/A"},
+		{"single-label www kept", true, nil, "http://www.localhost/A", "www.localhost", "http://www.localhost/A"},
+		{"case-insensitive path", false, []string{"Example.com"}, "https://example.com/A/B?Q=1", "example.com", "https://example.com/a/b?Q=1"},
+		{"case-insensitive through www", true, []string{"www.example.com"}, "https://www.example.com/A", "example.com", "https://example.com/a"},
+		{"other hosts keep case", false, []string{"example.com"}, "https://example.org/A", "example.org", "https://example.org/A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newURLNormalizer(TrailingSlashKeep, tt.collapseWWW, tt.caseInsensitiveHosts)
+			canonical, err := n.Canonicalize(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canonical != tt.url {
+				t.Errorf("Canonicalize(%q) = %q; the URL itself should be unchanged", tt.url, canonical)
+			}
+			u, _ := url.Parse(canonical)
+			if got := n.HostKey(u.Hostname()); got != tt.wantHost {
+				t.Errorf("HostKey(%q) = %q, want %q", u.Hostname(), got, tt.wantHost)
+			}
+			if got := n.VisitKey(canonical); got != tt.wantVisit {
+				t.Errorf("VisitKey(%q) = %q, want %q", canonical, got, tt.wantVisit)
+			}
+		})
+	}
+}
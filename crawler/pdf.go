@@ -0,0 +1,413 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"crawlengine/storage"
+)
+
+// maxPDFStreamBytes caps the decompressed size of a single PDF stream.
+const maxPDFStreamBytes = 16 << 20
+
+// handlePDF stores the text of the PDF at pageURL as a document. PDFs have
+// no links to follow.
+func (c *Crawler) handlePDF(ctx context.Context, task CrawlTask, pageURL string, parsedURL *url.URL, header http.Header, body, bodyHash, redirectChain string) {
+	content := c.textNormalizer.Normalize(ExtractPDFText([]byte(body)))
+	if content == "" {
+		log.Printf("Could not extract text from PDF %s", pageURL)
+		return
+	}
+	hashID := c.documentHashID(GenerateContentHash(content, c.Config.ExtractionVersion), pageURL, redirectChain)
+	doc := &storage.WebDocument{
+		HashID:             hashID,
+		URL:                pageURL,
+		MainContent:        content,
+		Title:              c.textNormalizer.Normalize(PDFTitle([]byte(body))),
+		CanonicalURL:       pageURL,
+		CrawledAt:          time.Now().UTC(),
+		BodyHash:           bodyHash,
+		CrawlDepth:         int64(task.Depth),
+		SeedURL:            task.SeedURL(),
+		ReferrerURL:        task.Referrer,
+		DuplicateOf:        c.nearDuplicateOf(pageURL, parsedURL.Hostname(), content, hashID),
+		ContentFingerprint: ContentFingerprint(content),
+		RedirectChain:      redirectChain,
+		ExtractionVersion:  c.Config.ExtractionVersion,
+		InboundLinks:       c.inboundCount(pageURL),
+	}
+	if !c.storable(doc, doc.DuplicateOf != "", header, nil, nil) {
+		return
+	}
+	if c.changes != nil && c.unchanged(ctx, doc) {
+		log.Printf("Content of %s is unchanged, updated crawled_at of the stored version only", pageURL)
+		return
+	}
+	c.storeDocument(ctx, doc, func(err error) {
+		if err != nil {
+			log.Printf("Error storing PDF %s: %v", pageURL, err)
+			c.recordFailure(pageURL, task.Attempts, newCrawlError(ErrCategoryStorage, 0, err))
+			return
+		}
+		c.stats.pagesStored.Add(1)
+	})
+}
+
+// ExtractPDFText returns the text shown by the text operators of a PDF's
+// uncompressed and FlateDecode content streams. It is a best-effort
+// extractor without font support: text drawn with embedded subset fonts,
+// whose strings are glyph IDs rather than characters, is dropped, and
+// scanned PDFs have no text at all.
+func ExtractPDFText(data []byte) string {
+	var text strings.Builder
+	for _, content := range pdfStreams(data) {
+		writePDFText(&text, content)
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// PDFTitle returns the /Title of a PDF's document information dictionary,
+// or "" if it has none or the dictionary is in a compressed object stream.
+func PDFTitle(data []byte) string {
+	i := bytes.Index(data, []byte("/Title"))
+	if i < 0 {
+		return ""
+	}
+	lex := pdfLexer{data: data, pos: i + len("/Title")}
+	if tok, ok := lex.next(); ok && tok.kind == pdfString {
+		title, _ := decodePDFString(tok.value)
+		return strings.Join(strings.Fields(title), " ")
+	}
+	return ""
+}
+
+// pdfStreams returns the data of the PDF's streams that are uncompressed or
+// compressed with FlateDecode alone; streams with other filters, such as
+// images, are skipped.
+func pdfStreams(data []byte) [][]byte {
+	var streams [][]byte
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			return streams
+		}
+		keyword := pos + i
+		pos = keyword + len("stream")
+		if keyword >= 3 && string(data[keyword-3:keyword]) == "end" {
+			continue // endstream
+		}
+		switch {
+		case bytes.HasPrefix(data[pos:], []byte("\r\n")):
+			pos += 2
+		case bytes.HasPrefix(data[pos:], []byte("\n")), bytes.HasPrefix(data[pos:], []byte("\r")):
+			pos++
+		default:
+			continue // not the stream keyword
+		}
+		end := bytes.Index(data[pos:], []byte("endstream"))
+		if end < 0 {
+			end = len(data) - pos // cut short, e.g. by max_document_bytes
+		}
+		raw := data[pos : pos+end]
+		pos += end
+
+		dict := data[:keyword]
+		if obj := bytes.LastIndex(dict, []byte("obj")); obj >= 0 {
+			dict = dict[obj:]
+		}
+		filters := bytes.Count(dict, []byte("Decode")) - bytes.Count(dict, []byte("DecodeParms"))
+		switch {
+		case filters == 0 && !bytes.Contains(dict, []byte("/Filter")):
+			streams = append(streams, raw)
+		case filters == 1 && bytes.Contains(dict, []byte("/FlateDecode")):
+			r, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			// A read error still leaves the data decoded up to it.
+			decoded, _ := io.ReadAll(io.LimitReader(r, maxPDFStreamBytes))
+			streams = append(streams, decoded)
+		}
+	}
+}
+
+// writePDFText appends the text shown between BT and ET in a content
+// stream to out, breaking lines where the text position moves down.
+func writePDFText(out *strings.Builder, content []byte) {
+	lex := pdfLexer{data: content}
+	var operands []pdfToken
+	inText := false
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			return
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op := string(tok.value); op {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			pdfNewline(out)
+		case "ID":
+			lex.skipInlineImage()
+		case "Td", "TD":
+			if inText && len(operands) >= 2 {
+				if ty, err := strconv.ParseFloat(string(operands[len(operands)-1].value), 64); err == nil && ty != 0 {
+					pdfNewline(out)
+				} else {
+					pdfSpace(out)
+				}
+			}
+		case "T*", "Tm":
+			if inText {
+				pdfNewline(out)
+			}
+		case "Tj", "'", "\"":
+			if inText && len(operands) > 0 {
+				if op != "Tj" {
+					pdfNewline(out)
+				}
+				writePDFString(out, operands[len(operands)-1])
+			}
+		case "TJ":
+			if !inText {
+				break
+			}
+			for _, operand := range operands {
+				switch operand.kind {
+				case pdfString:
+					writePDFString(out, operand)
+				case pdfNumber:
+					// Large negative adjustments move right by about a space.
+					if n, err := strconv.ParseFloat(string(operand.value), 64); err == nil && n < -200 {
+						pdfSpace(out)
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func writePDFString(out *strings.Builder, tok pdfToken) {
+	if tok.kind != pdfString {
+		return
+	}
+	if text, ok := decodePDFString(tok.value); ok {
+		out.WriteString(text)
+	}
+}
+
+func pdfNewline(out *strings.Builder) {
+	if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		out.WriteByte('\n')
+	}
+}
+
+func pdfSpace(out *strings.Builder) {
+	if s := out.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		out.WriteByte(' ')
+	}
+}
+
+// decodePDFString decodes a PDF string as UTF-16BE if it starts with a byte
+// order mark and as Latin-1 otherwise. It reports false for strings with
+// control characters, which are glyph IDs of a font rather than text.
+func decodePDFString(b []byte) (string, bool) {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units)), true
+	}
+	runes := make([]rune, 0, len(b))
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return "", false
+		}
+		runes = append(runes, rune(c))
+	}
+	return string(runes), true
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfOther // names, arrays and dictionary delimiters
+)
+
+type pdfToken struct {
+	kind  pdfTokenKind
+	value []byte
+}
+
+// pdfLexer splits PDF syntax into tokens.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFWhitespace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, value: l.literalString()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<',
+			c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfToken{kind: pdfOther}, true
+		case c == '<':
+			return pdfToken{kind: pdfString, value: l.hexString()}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			l.regular()
+			return pdfToken{kind: pdfOther, value: l.data[start:l.pos]}, true
+		case isPDFDelimiter(c):
+			l.pos++
+			return pdfToken{kind: pdfOther, value: []byte{c}}, true
+		default:
+			start := l.pos
+			l.regular()
+			value := l.data[start:l.pos]
+			if _, err := strconv.ParseFloat(string(value), 64); err == nil {
+				return pdfToken{kind: pdfNumber, value: value}, true
+			}
+			return pdfToken{kind: pdfOperator, value: value}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// regular advances past a run of regular characters.
+func (l *pdfLexer) regular() {
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+}
+
+// literalString reads a (...) string, with balanced parentheses and
+// backslash escapes, starting at its opening parenthesis.
+func (l *pdfLexer) literalString() []byte {
+	var s []byte
+	depth := 0
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			if depth > 0 {
+				s = append(s, c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+			s = append(s, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return s
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++ // line continuation
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					s = append(s, byte(n))
+				} else {
+					s = append(s, e)
+				}
+			}
+		default:
+			s = append(s, c)
+		}
+	}
+	return s
+}
+
+// hexString reads a <...> string starting at its opening bracket.
+func (l *pdfLexer) hexString() []byte {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, len(digits)/2)
+	for i := range s {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		s[i] = byte(n)
+	}
+	return s
+}
+
+// skipInlineImage advances past the binary data of an inline image, which
+// follows the ID operator and ends with EI.
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if isPDFWhitespace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' &&
+			(l.pos+3 == len(l.data) || isPDFWhitespace(l.data[l.pos+3])) {
+			l.pos += 3
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
@@ -0,0 +1,187 @@
+package crawler_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+// pdfFile builds a minimal PDF with an info dictionary titled title and one
+// page whose content stream is content, compressed with FlateDecode if
+// compress is set. Its xref table is left out, as the extractor doesn't use
+// one.
+func pdfFile(title, content string, compress bool) string {
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	fmt.Fprintf(&b, "1 0 obj\n<< /Title %s /Producer (test) >>\nendobj\n", title)
+	b.WriteString("2 0 obj\n<< /Type /Catalog /Pages 3 0 R >>\nendobj\n")
+	b.WriteString("3 0 obj\n<< /Type /Pages /Kids [4 0 R] /Count 1 >>\nendobj\n")
+	b.WriteString("4 0 obj\n<< /Type /Page /Parent 3 0 R /Contents 5 0 R >>\nendobj\n")
+	stream, filter := content, ""
+	if compress {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write([]byte(content))
+		w.Close()
+		stream, filter = z.String(), " /Filter /FlateDecode"
+	}
+	fmt.Fprintf(&b, "5 0 obj\n<< /Length %d%s >>\nstream\n%s\nendstream\nendobj\n", len(stream), filter, stream)
+	b.WriteString("trailer\n<< /Root 2 0 R /Info 1 0 R >>\n%%EOF\n")
+	return b.String()
+}
+
+// pdfText is a content stream showing lines of text, one per line.
+func pdfText(lines ...string) string {
+	var b strings.Builder
+	b.WriteString("BT /F1 12 Tf 72 712 Td\n")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("0 -14 Td\n")
+		}
+		r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+		fmt.Fprintf(&b, "(%s) Tj\n", r.Replace(line))
+	}
+	b.WriteString("ET\n")
+	return b.String()
+}
+
+func TestExtractPDFText(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  string
+		want string
+	}{
+		{"uncompressed", pdfFile("(T)", pdfText("First line", "Second (line)"), false), "First line\nSecond (line)"},
+		{"FlateDecode", pdfFile("(T)", pdfText("Compressed text"), true), "Compressed text"},
+		{"TJ spacing", pdfFile("(T)", "BT [(Hel) -20 (lo) -300 (world)] TJ ET", false), "Hello world"},
+		{"quote operators", pdfFile("(T)", "BT (a) Tj (b) ' 1 2 (c) \" ET", false), "a\nb\nc"},
+		{"same-line Td", pdfFile("(T)", "BT (a) Tj 40 0 Td (b) Tj T* (c) Tj ET", false), "a b\nc"},
+		{"text outside BT/ET ignored", pdfFile("(T)", "(hidden) Tj BT (shown) Tj ET", false), "shown"},
+		{"escapes", pdfFile("(T)", `BT (a\(b\)c\\d\101\tE (nested) F\
+G) Tj ET`, false), "a(b)c\\dA\tE (nested) FG"},
+		{"hex strings", pdfFile("(T)", "BT <48656C6C6F> Tj <FEFF00E9007400E9> Tj <41 4> Tj ET", false), "HelloétéA@"},
+		{"glyph ID strings dropped", pdfFile("(T)", "BT <00030004> Tj (kept) Tj ET", false), "kept"},
+		{"comments skipped", pdfFile("(T)", "BT % (not text) Tj\n(text) Tj ET", false), "text"},
+		{"inline image skipped", pdfFile("(T)", "BT (before) Tj ET BI /W 2 /H 2 ID \x00\x01(x) Tj\x02 EI BT (after) Tj ET", false), "before\nafter"},
+		{"several streams", pdfFile("(T)", pdfText("one"), false) + pdfFile("(T)", pdfText("two"), true), "one\ntwo"},
+		{"no streams", "%PDF-1.4\n1 0 obj << /Title (T) >> endobj\n%%EOF", ""},
+		{"not a PDF", "<html><body>stream of text</body></html>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crawler.ExtractPDFText([]byte(tt.pdf)); got != tt.want {
+				t.Errorf("ExtractPDFText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFTextSkipsUnsupportedStreams(t *testing.T) {
+	text := pdfText("hidden")
+	tests := map[string]string{
+		"image filter":        fmt.Sprintf("1 0 obj\n<< /Filter /DCTDecode >>\nstream\n%s\nendstream\nendobj\n", text),
+		"filter chain":        fmt.Sprintf("1 0 obj\n<< /Filter [/ASCII85Decode /FlateDecode] >>\nstream\n%s\nendstream\nendobj\n", text),
+		"corrupt FlateDecode": fmt.Sprintf("1 0 obj\n<< /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n", text),
+	}
+	for name, pdf := range tests {
+		if got := crawler.ExtractPDFText([]byte("%PDF-1.4\n" + pdf)); got != "" {
+			t.Errorf("%s: ExtractPDFText = %q, want no text", name, got)
+		}
+	}
+}
+
+func TestExtractPDFTextMalformed(t *testing.T) {
+	full := pdfFile("(Report)", pdfText("Line one", "Line two"), false)
+
+	// A body cut short, e.g. by max_document_bytes, keeps the text before the cut.
+	cut := full[:strings.Index(full, "Line two")+len("Line two) Tj")]
+	if got := crawler.ExtractPDFText([]byte(cut)); got != "Line one\nLine two" {
+		t.Errorf("truncated body: ExtractPDFText = %q", got)
+	}
+
+	// So does a compressed stream cut short.
+	words := strings.Repeat("compressed words ", 200)
+	compressed := pdfFile("(Report)", pdfText(words), true)
+	start := strings.Index(compressed, "stream\n") + len("stream\n")
+	end := strings.Index(compressed, "\nendstream")
+	for _, n := range []int{2, (end - start) / 2, end - start - 1} {
+		if got := crawler.ExtractPDFText([]byte(compressed[:start+n])); !strings.HasPrefix(words, got) {
+			t.Errorf("compressed stream cut after %d bytes: ExtractPDFText = %q", n, got)
+		}
+	}
+
+	// A broken xref table and trailer don't matter, as neither is read.
+	broken := strings.Replace(full, "trailer", "xref\n0 9999\nnot an xref entry\ntrailer <<", 1)
+	if got := crawler.ExtractPDFText([]byte(broken)); got != "Line one\nLine two" {
+		t.Errorf("broken xref: ExtractPDFText = %q", got)
+	}
+
+	// No prefix of a PDF makes the lexer panic or loop.
+	for _, pdf := range []string{full, compressed, pdfFile("<FEFF0041>", "BT (a\\", false)} {
+		for i := range len(pdf) {
+			crawler.ExtractPDFText([]byte(pdf[:i]))
+			crawler.PDFTitle([]byte(pdf[:i]))
+		}
+	}
+}
+
+func TestPDFTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"literal", "(Annual   report 2024)", "Annual report 2024"},
+		{"escaped", `(Q\(1\) results)`, "Q(1) results"},
+		{"UTF-16 hex", "<FEFF00C9007400E9>", "Été"},
+		{"not a string", "/Name", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crawler.PDFTitle([]byte(pdfFile(tt.title, pdfText("x"), false))); got != tt.want {
+				t.Errorf("PDFTitle = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := crawler.PDFTitle([]byte("%PDF-1.4\n%%EOF")); got != "" {
+		t.Errorf("PDFTitle without an info dictionary = %q", got)
+	}
+}
+
+func TestCrawlStoresPDFs(t *testing.T) {
+	for _, registered := range []bool{true, false} {
+		server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+			"/":           crawltest.HTML(article("Home") + `<a href="/report.pdf">report</a></body></html>`),
+			"/report.pdf": {ContentType: "application/pdf", Body: pdfFile("(Annual report)", pdfText("Revenue grew in every quarter."), true)},
+			"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+		})
+		crawlerYAML := "  max_depth: 1\n"
+		if registered {
+			crawlerYAML += "  content_types:\n    application/pdf: pdf\n"
+		}
+		cfg := loadCrawlerConfig(t, crawlerYAML)
+		cfg.SeedURLs = []string{server.URL + "/"}
+		_, storer := runCrawl(t, cfg)
+		server.Close()
+
+		docs := storer.Documents()
+		if !registered {
+			if len(docs) != 1 {
+				t.Errorf("without a pdf registration, stored %v; want only the HTML page", storer.URLs())
+			}
+			continue
+		}
+		if len(docs) != 2 {
+			t.Fatalf("stored %v, want the page and the PDF", storer.URLs())
+		}
+		pdf := docs[1]
+		if pdf.URL != server.URL+"/report.pdf" || pdf.Title != "Annual report" || pdf.MainContent != "Revenue grew in every quarter." {
+			t.Errorf("stored PDF = %s %q %q", pdf.URL, pdf.Title, pdf.MainContent)
+		}
+	}
+}
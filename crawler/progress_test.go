@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+)
+
+// lockedBuffer is a log output safe to read while the reporter writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestProgressReporterLogsThroughput(t *testing.T) {
+	logs := &lockedBuffer{}
+	log.SetOutput(logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	c := NewCrawler(&config.CrawlerConfig{MaxConcurrency: 2}, nil, nil)
+	c.stats.pagesFetched.Add(4)
+	c.stats.pagesStored.Add(3)
+	c.stats.errors.Add(1)
+	c.stats.workerTasks[0].Add(3)
+	c.stats.workerTasks[1].Add(2)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		c.runProgressReporter(10*time.Millisecond, stop)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "Progress:") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	_, line, ok := strings.Cut(logs.String(), "Progress:")
+	if !ok {
+		t.Fatal("no progress line was logged")
+	}
+	line, _, _ = strings.Cut(line, "\n")
+	for _, want := range []string{"stored 3", "error rate 20.0%", " w0=", " w1="} {
+		if !strings.Contains(line, want) {
+			t.Errorf("progress line %q does not contain %q", line, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package crawler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+// rssFeed is an RSS feed whose items link to /posts/<item>.
+func rssFeed(items ...string) string {
+	feed := `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title><link>/</link>`
+	for _, item := range items {
+		feed += `<item><title>` + item + `</title><link>/posts/` + item + `</link><guid>` + item +
+			`</guid><description>` + item + ` is a feed item with enough words to be stored.</description></item>`
+	}
+	return feed + `</channel></rss>`
+}
+
+func TestStoredDocumentsRecordProvenance(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/hub">hub</a></body></html>`),
+		"/hub":        crawltest.HTML(article("Hub") + `<a href="/feed.xml">feed</a><a href="/report.pdf">pdf</a><a href="/api/items">api</a></body></html>`),
+		"/feed.xml":   {ContentType: "application/rss+xml", Body: rssFeed("first", "second")},
+		"/report.pdf": {ContentType: "application/pdf", Body: pdfFile("(Report)", pdfText("Quarterly report text."), false)},
+		"/api/items": {ContentType: "application/json", Body: `{"items": [
+			{"title": "Linked item", "url": "/items/1", "body": "An item with a URL of its own."},
+			{"title": "Inline item", "body": "An item without a URL of its own."}]}`},
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 2
+  parse_feeds: true
+  content_types:
+    application/pdf: pdf
+  extraction_rules:
+    "127.0.0.1":
+      json:
+        items: items
+        url: url
+        title: title
+        content: body
+`)
+	seed := server.URL + "/"
+	cfg.SeedURLs = []string{seed}
+	_, storer := runCrawl(t, cfg)
+
+	hub := server.URL + "/hub"
+	want := map[string][2]string{ // URL -> seed, referrer
+		seed:                         {seed, ""},
+		hub:                          {seed, seed},
+		server.URL + "/posts/first":  {seed, server.URL + "/feed.xml"},
+		server.URL + "/posts/second": {seed, server.URL + "/feed.xml"},
+		server.URL + "/report.pdf":   {seed, hub},
+		server.URL + "/items/1":      {seed, server.URL + "/api/items"},
+		server.URL + "/api/items":    {seed, hub},
+	}
+	got := make(map[string][2]string)
+	for _, doc := range storer.Documents() {
+		got[doc.URL] = [2]string{doc.SeedURL, doc.ReferrerURL}
+	}
+	for url, provenance := range want {
+		if got[url] != provenance {
+			t.Errorf("%s: seed, referrer = %q, want %q", url, got[url], provenance)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("stored %v, want %d documents", storer.URLs(), len(want))
+	}
+}
+
+func TestPagesRecordSeedAndReferrer(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/one":        crawltest.HTML(article("Seed one") + `<a href="/a">a</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `<a href="/b">b</a></body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/two":        crawltest.HTML(article("Seed two") + `<a href="/moved">moved</a></body></html>`),
+		"/moved":      crawltest.Redirect(http.StatusMovedPermanently, "/c"),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 2\n")
+	one, two := server.URL+"/one", server.URL+"/two"
+	cfg.SeedURLs = []string{one, two}
+	_, storer := runCrawl(t, cfg)
+
+	want := map[string][2]string{ // URL -> seed, referrer
+		one:               {one, ""},
+		server.URL + "/a": {one, one},
+		server.URL + "/b": {one, server.URL + "/a"},
+		two:               {two, ""},
+		server.URL + "/c": {two, two},
+	}
+	got := make(map[string][2]string)
+	for _, doc := range storer.Documents() {
+		got[doc.URL] = [2]string{doc.SeedURL, doc.ReferrerURL}
+	}
+	for url, provenance := range want {
+		if got[url] != provenance {
+			t.Errorf("%s: seed, referrer = %q, want %q", url, got[url], provenance)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("stored %v, want %d documents", storer.URLs(), len(want))
+	}
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"crawlengine/config"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// QualityScore rates how much a page looks like substantial, readable
+// content, from 0 (boilerplate) to 1. It is the weighted average of four
+// signals, each in [0, 1]:
+//
+//   - text ratio: main content length / HTML length, with 25% or more
+//     scoring 1 (pages that are mostly markup score low)
+//   - link density: 1 - (text inside links / visible body text)
+//   - article body: 1 if the page has an <article>, <main>, role="main" or
+//     itemprop="articleBody" element holding at least 200 characters, else 0
+//   - content length: main content characters / TargetContentLength, capped at 1
+//
+// Signals with a zero weight are left out. Returns 0 if all weights are zero.
+func QualityScore(doc *goquery.Document, html, mainContent string, weights config.QualityWeights) float64 {
+	signals := []struct {
+		weight float64
+		value  func() float64
+	}{
+		{weights.TextRatio, func() float64 { return textRatioSignal(html, mainContent) }},
+		{weights.LinkDensity, func() float64 { return 1 - linkDensity(doc) }},
+		{weights.ArticleBody, func() float64 { return articleBodySignal(doc) }},
+		{weights.ContentLength, func() float64 { return contentLengthSignal(mainContent, weights.TargetContentLength) }},
+	}
+
+	var total, weightSum float64
+	for _, s := range signals {
+		if s.weight <= 0 {
+			continue
+		}
+		total += s.weight * s.value()
+		weightSum += s.weight
+	}
+	if weightSum == 0 {
+		return 0
+	}
+	return total / weightSum
+}
+
+func textRatioSignal(html, mainContent string) float64 {
+	if len(html) == 0 {
+		return 0
+	}
+	return clamp01(float64(len(mainContent)) / float64(len(html)) * 4)
+}
+
+func contentLengthSignal(mainContent string, target int) float64 {
+	if target <= 0 {
+		return 0
+	}
+	return clamp01(float64(utf8.RuneCountInString(mainContent)) / float64(target))
+}
+
+// linkDensity returns the share of visible body text that sits inside links.
+func linkDensity(doc *goquery.Document) float64 {
+	body := doc.Find("body")
+	textLen := len(strings.Join(strings.Fields(body.Text()), " "))
+	if textLen == 0 {
+		return 1
+	}
+	linkLen := 0
+	body.Find("a").Each(func(i int, s *goquery.Selection) {
+		linkLen += len(strings.Join(strings.Fields(s.Text()), " "))
+	})
+	return clamp01(float64(linkLen) / float64(textLen))
+}
+
+func articleBodySignal(doc *goquery.Document) float64 {
+	found := false
+	doc.Find("article, main, [role='main'], [itemprop='articleBody']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		found = len(strings.TrimSpace(s.Text())) >= 200
+		return !found
+	})
+	if found {
+		return 1
+	}
+	return 0
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
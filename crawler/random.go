@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"crawlengine/config"
+)
+
+// lockedRand is a seeded random source safe for concurrent use, used in
+// deterministic mode instead of the global source.
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
+
+// fingerprintPicker selects fingerprints in proportion to their weights by
+// binary searching the cumulative weights.
+type fingerprintPicker struct {
+	fingerprints []*fingerprint
+	cumulative   []float64 // cumulative[i] is the total weight of fingerprints[:i+1]
+}
+
+// add appends fp with weight, which must be positive.
+func (p *fingerprintPicker) add(fp *fingerprint, weight float64) {
+	total := weight
+	if n := len(p.cumulative); n > 0 {
+		total += p.cumulative[n-1]
+	}
+	p.fingerprints = append(p.fingerprints, fp)
+	p.cumulative = append(p.cumulative, total)
+}
+
+// defaultUserAgent is sent when no user agents or fingerprint profiles are
+// configured.
+const defaultUserAgent = "GoCrawler/1.0 (+http://example.com/bot)"
+
+// newUserAgentPicker returns a picker over the agents with a positive weight,
+// each sent with the default headers, or over defaultUserAgent if there are
+// none.
+func newUserAgentPicker(userAgents []config.UserAgent) *fingerprintPicker {
+	p := &fingerprintPicker{}
+	for _, ua := range userAgents {
+		if ua.Agent == "" || ua.Weight <= 0 {
+			if ua.Weight < 0 {
+				log.Printf("Warning: Ignoring user agent '%s' with negative weight %g.", ua.Agent, ua.Weight)
+			}
+			continue
+		}
+		p.add(&fingerprint{userAgent: ua.Agent}, ua.Weight)
+	}
+	if len(p.fingerprints) == 0 {
+		p.add(&fingerprint{userAgent: defaultUserAgent}, 1)
+	}
+	return p
+}
+
+// Pick returns the fingerprint whose cumulative weight range contains
+// x*total, for x uniformly distributed in [0, 1).
+func (p *fingerprintPicker) Pick(x float64) *fingerprint {
+	target := x * p.cumulative[len(p.cumulative)-1]
+	i := sort.Search(len(p.cumulative), func(i int) bool { return p.cumulative[i] > target })
+	if i == len(p.fingerprints) { // only reachable through rounding
+		i--
+	}
+	return p.fingerprints[i]
+}
+
+// fingerprint picks the user agent and headers for a fetch by weight from
+// the configured profiles or user agents, using the seeded source in
+// deterministic mode.
+func (c *Crawler) fingerprint() *fingerprint {
+	if c.rng != nil {
+		return c.fingerprints.Pick(c.rng.Float64())
+	}
+	return c.fingerprints.Pick(rand.Float64())
+}
@@ -0,0 +1,253 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Redirect policies for redirect_policy.
+const (
+	// RedirectFollowAndStore follows redirects and stores the content under the final URL.
+	RedirectFollowAndStore = "follow_and_store"
+	// RedirectFollowOnly does not store the redirecting URL but queues its target as a new link.
+	RedirectFollowOnly = "follow_only"
+	// RedirectSkip drops redirecting URLs entirely.
+	RedirectSkip = "skip"
+)
+
+// recordRedirect records that originalURL resolved to finalURL and marks the
+// final URL visited. It returns false if the final URL had already been
+// visited under another URL, in which case the page is a duplicate.
+func (c *Crawler) recordRedirect(originalURL, finalURL string) bool {
+	c.visitedLock.Lock()
+	defer c.visitedLock.Unlock()
+	c.redirects[originalURL] = finalURL
+	key := c.normalizer.VisitKey(finalURL)
+	if key == c.normalizer.VisitKey(originalURL) {
+		// Only the www prefix changed, so this is the same page, unless the
+		// target itself redirected before, which would loop.
+		_, looped := c.redirects[finalURL]
+		return !looped
+	}
+	if c.visited[key] {
+		return false
+	}
+	c.visited[key] = true
+	return true
+}
+
+// redirectHashPrefix keeps the IDs of redirected pages apart from plain
+// content hashes.
+const redirectHashPrefix = "redirect\x00"
+
+// documentHashID returns the hash_id of a page stored under pageURL with
+// content hash contentHash. The ID of a page reached through redirects, with
+// a redirectChain, also covers its final URL, so content stored from a
+// redirect target is told apart from the same content at another URL.
+func (c *Crawler) documentHashID(contentHash, pageURL, redirectChain string) string {
+	if redirectChain == "" {
+		return contentHash
+	}
+	return GenerateContentHash(redirectHashPrefix+pageURL+"\x00"+contentHash, c.Config.ExtractionVersion)
+}
+
+// recordRedirectChain marks the URLs between the first one of chain and
+// finalURL visited, as redirecting to finalURL, so links to any hop of a
+// shortener or tracking chain aren't fetched again. It returns the chain,
+// canonicalized, as a JSON array for redirect_chain.
+func (c *Crawler) recordRedirectChain(chain []string, finalURL string) string {
+	canonical := make([]string, len(chain))
+	for i, hop := range chain {
+		canonical[i] = hop
+		if u, err := c.normalizer.Canonicalize(hop); err == nil {
+			canonical[i] = u
+		}
+	}
+	c.visitedLock.Lock()
+	for i, hop := range canonical {
+		if i == 0 {
+			continue // recorded by recordRedirect
+		}
+		c.redirects[hop] = finalURL
+		c.visited[c.normalizer.VisitKey(hop)] = true
+	}
+	c.visitedLock.Unlock()
+
+	chainJSON, err := json.Marshal(canonical)
+	if err != nil {
+		return ""
+	}
+	return string(chainJSON)
+}
+
+// admitRedirect reports whether the redirect from source, parsed as
+// sourceURL, to target may be followed, applying the scope rules of links.
+// Like links, redirects stay on the host of their source, except that seeds
+// may redirect to another host, e.g. from example.com to www.example.com,
+// which is then admitted under max_hosts.
+func (c *Crawler) admitRedirect(source string, sourceURL *url.URL, target string, fromSeed bool) bool {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		log.Printf("Error parsing redirect target %s of %s: %v", target, source, err)
+		return false
+	}
+	if host := c.normalizer.HostKey(targetURL.Hostname()); host != c.normalizer.HostKey(sourceURL.Hostname()) {
+		if !fromSeed {
+			log.Printf("Skipping redirect %s -> %s: target is on another host", source, target)
+			return false
+		}
+		if !c.admitHost(host, target) {
+			return false
+		}
+	}
+	return c.allowedLink(target, targetURL)
+}
+
+type redirectCheckKey struct{}
+
+// withRedirectCheck returns ctx carrying allow, which decides whether a
+// fetch made with it follows a redirect to a target. Redirects allow rejects
+// are returned to the caller unfollowed, like under follow_only.
+func withRedirectCheck(ctx context.Context, allow func(target *url.URL) bool) context.Context {
+	return context.WithValue(ctx, redirectCheckKey{}, allow)
+}
+
+// redirectAllowed reports whether the redirect to req may be followed under
+// the check of its context, if any.
+func redirectAllowed(req *http.Request) bool {
+	allow, _ := req.Context().Value(redirectCheckKey{}).(func(*url.URL) bool)
+	return allow == nil || allow(req.URL)
+}
+
+// allowedRedirectHop reports whether a followed redirect may continue to
+// target: robots.txt must allow it and it must be an allowed link. ctx must
+// not carry the check itself, since robots.txt is fetched with it.
+func (c *Crawler) allowedRedirectHop(ctx context.Context, target *url.URL) bool {
+	if !IsAllowedByRobots(ctx, c.fetcher, target, c.robotsAgent) {
+		log.Printf("Not following redirect to %s: disallowed by robots.txt for agent %s", target, c.robotsAgent)
+		return false
+	}
+	return c.allowedLink(target.String(), target)
+}
+
+// handleRedirect applies the redirect policy to an unfollowed 3xx response.
+// Its location is resolved against the last hop followed, if any.
+func (c *Crawler) handleRedirect(task CrawlTask, baseURL *url.URL, result *FetchResult) {
+	if result.FinalURL != "" && result.FinalURL != task.URL {
+		if hopURL, err := url.Parse(result.FinalURL); err == nil {
+			baseURL = hopURL
+		}
+	}
+	target, err := c.normalizer.Normalize(baseURL, result.Location)
+	if err != nil {
+		log.Printf("Error resolving redirect location '%s' for %s: %v", result.Location, task.URL, err)
+		return
+	}
+
+	if c.redirectPolicy != RedirectFollowOnly {
+		log.Printf("Skipping redirect %s -> %s (status %d, policy %s)", task.URL, target, result.StatusCode, c.redirectPolicy)
+		return
+	}
+
+	if !c.admitRedirect(task.URL, baseURL, target, task.Depth == 0) {
+		return
+	}
+	if !c.recordRedirect(task.URL, target) {
+		log.Printf("Redirect target %s of %s already visited", target, task.URL)
+		return
+	}
+	log.Printf("Following redirect %s -> %s (Depth: %d)", task.URL, target, task.Depth)
+	c.enqueue(CrawlTask{URL: target, Depth: task.Depth, Priority: task.Priority, Seed: task.SeedURL(), Referrer: task.Referrer})
+}
+
+// ParseMetaRefresh parses the content attribute of a
+// <meta http-equiv="refresh"> tag, e.g. `0;url=/next`, `5, URL='page.html'`
+// or `0; http://example.com/`. It returns the delay in seconds and the raw
+// target URL. ok is false if the value has no valid delay or no target, as
+// with a plain self-refresh like "30".
+func ParseMetaRefresh(content string) (delay float64, target string, ok bool) {
+	content = strings.TrimSpace(content)
+	end := 0
+	for end < len(content) && (content[end] >= '0' && content[end] <= '9' || content[end] == '.') {
+		end++
+	}
+	delay, err := strconv.ParseFloat(content[:end], 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	// The delay is separated from the URL by ';', ',' or just whitespace.
+	rest := strings.TrimLeft(content[end:], " \t\r\n")
+	if rest != "" && (rest[0] == ';' || rest[0] == ',') {
+		rest = strings.TrimSpace(rest[1:])
+	} else if len(rest) == len(content[end:]) {
+		return 0, "", false
+	}
+	if len(rest) >= 3 && strings.EqualFold(rest[:3], "url") {
+		if afterKey := strings.TrimLeft(rest[3:], " \t\r\n"); strings.HasPrefix(afterKey, "=") {
+			rest = strings.TrimSpace(afterKey[1:])
+		}
+	}
+	if rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		rest = rest[1:]
+		if i := strings.IndexByte(rest, quote); i >= 0 {
+			rest = rest[:i]
+		}
+	}
+	target = strings.TrimSpace(rest)
+	if target == "" {
+		return 0, "", false
+	}
+	return delay, target, true
+}
+
+// followMetaRefresh queues the target of a short meta refresh on doc, treating
+// it like an HTTP redirect. It returns true if an in-scope refresh target was
+// found; pages refreshing out of scope are handled as ordinary pages.
+func (c *Crawler) followMetaRefresh(task CrawlTask, pageURL string, baseURL *url.URL, doc *goquery.Document) bool {
+	var target string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		equiv, _ := s.Attr("http-equiv")
+		if !strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+			return true
+		}
+		content, _ := s.Attr("content")
+		delay, rawTarget, ok := ParseMetaRefresh(content)
+		if !ok {
+			return true
+		}
+		if delay > float64(c.Config.MetaRefreshMaxDelaySec) {
+			log.Printf("Ignoring meta refresh on %s: delay %gs exceeds %ds", pageURL, delay, c.Config.MetaRefreshMaxDelaySec)
+			return false
+		}
+		resolved, err := c.normalizer.Normalize(baseURL, rawTarget)
+		if err != nil {
+			log.Printf("Error resolving meta refresh target '%s' for %s: %v", rawTarget, pageURL, err)
+			return false
+		}
+		target = resolved
+		return false
+	})
+	if target == "" || target == pageURL {
+		return false
+	}
+
+	if !c.admitRedirect(pageURL, baseURL, target, task.Depth == 0) {
+		return false
+	}
+	if !c.recordRedirect(pageURL, target) {
+		log.Printf("Meta refresh target %s of %s already visited", target, pageURL)
+		return true
+	}
+	log.Printf("Following meta refresh %s -> %s (Depth: %d)", pageURL, target, task.Depth)
+	c.enqueue(CrawlTask{URL: target, Depth: task.Depth, Priority: task.Priority, Seed: task.SeedURL(), Referrer: task.Referrer})
+	return true
+}
@@ -0,0 +1,222 @@
+package crawler_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+// newSites starts two fixture servers on different hosts, 127.0.0.1 and
+// localhost, and returns them with their base URLs.
+func newSites(t *testing.T, siteA, siteB map[string]crawltest.Fixture) (a, b *crawltest.FixtureServer, urlA, urlB string) {
+	t.Helper()
+	a, b = crawltest.NewFixtureServer(siteA), crawltest.NewFixtureServer(siteB)
+	t.Cleanup(a.Close)
+	t.Cleanup(b.Close)
+	return a, b, a.URL, strings.Replace(b.URL, "127.0.0.1", "localhost", 1)
+}
+
+func TestRedirectsStayInScope(t *testing.T) {
+	for _, policy := range []string{"follow_and_store", "follow_only"} {
+		t.Run(policy, func(t *testing.T) {
+			siteA := map[string]crawltest.Fixture{
+				"/":      crawltest.HTML(article("Home") + `<a href="/away">away</a><a href="/moved">moved</a></body></html>`),
+				"/moved": crawltest.Redirect(http.StatusMovedPermanently, "/new"),
+				"/new":   crawltest.HTML(article("New home") + `</body></html>`),
+			}
+			siteB := map[string]crawltest.Fixture{
+				"/landing": crawltest.HTML(article("Landing") + `<a href="/deeper">deeper</a></body></html>`),
+				"/deeper":  crawltest.HTML(article("Deeper") + `</body></html>`),
+			}
+			_, b, urlA, urlB := newSites(t, siteA, siteB)
+			siteA["/away"] = crawltest.Redirect(http.StatusFound, urlB+"/landing")
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 3\n  redirect_policy: "+policy+"\n")
+			cfg.SeedURLs = []string{urlA + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			want := []string{urlA + "/", urlA + "/new"}
+			if got := storer.URLs(); !slices.Equal(got, want) {
+				t.Errorf("stored URLs = %v, want %v", got, want)
+			}
+			if requests := b.Requests(); slices.Contains(requests, "/deeper") {
+				t.Errorf("links of an out-of-scope redirect target were followed; requests to other host: %v", requests)
+			}
+		})
+	}
+}
+
+func TestSeedRedirectAdmitsHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxHosts string
+		want     []string // paths on the other host
+	}{
+		{"unlimited", "0", []string{"/", "/about"}},
+		{"limit reached", "1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			siteB := map[string]crawltest.Fixture{
+				"/":      crawltest.HTML(article("Home") + `<a href="/about">about</a></body></html>`),
+				"/about": crawltest.HTML(article("About") + `</body></html>`),
+			}
+			siteA := map[string]crawltest.Fixture{}
+			_, _, urlA, urlB := newSites(t, siteA, siteB)
+			siteA["/"] = crawltest.Redirect(http.StatusMovedPermanently, urlB+"/")
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n  max_hosts: "+tt.maxHosts+"\n")
+			cfg.SeedURLs = []string{urlA + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			var want []string
+			for _, path := range tt.want {
+				want = append(want, urlB+path)
+			}
+			if got := storer.URLs(); !slices.Equal(got, want) {
+				t.Errorf("stored URLs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMaxHostsSkipsSeeds(t *testing.T) {
+	page := map[string]crawltest.Fixture{"/": crawltest.HTML(article("Home") + `</body></html>`)}
+	_, b, urlA, urlB := newSites(t, page, page)
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  max_hosts: 1\n")
+	cfg.SeedURLs = []string{urlA + "/", urlB + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	if got, want := storer.URLs(), []string{urlA + "/"}; !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+	if requests := b.Requests(); len(requests) > 0 {
+		t.Errorf("seed over max_hosts was fetched: %v", requests)
+	}
+}
+
+func TestMetaRefreshStaysInScope(t *testing.T) {
+	siteA := map[string]crawltest.Fixture{
+		"/":     crawltest.HTML(article("Home") + `<a href="/here">here</a><a href="/there">there</a></body></html>`),
+		"/next": crawltest.HTML(article("Next") + `</body></html>`),
+		"/here": crawltest.HTML(`<html><head><meta http-equiv="refresh" content="0; url=/next"></head><body></body></html>`),
+	}
+	siteB := map[string]crawltest.Fixture{"/": crawltest.HTML(article("Elsewhere") + `</body></html>`)}
+	_, b, urlA, urlB := newSites(t, siteA, siteB)
+	siteA["/there"] = crawltest.HTML(`<html><head><meta http-equiv="refresh" content="0;URL='` + urlB + `/'"></head><body>` +
+		`<article><p>This page moved to another site, which the crawler does not follow links to at all.</p></article></body></html>`)
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 2\n  follow_meta_refresh: true\n  meta_refresh_skip_store: true\n")
+	cfg.SeedURLs = []string{urlA + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	// The in-scope refresh page is skipped for its target; the out-of-scope
+	// one is stored as an ordinary page.
+	want := []string{urlA + "/", urlA + "/next", urlA + "/there"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+	if requests := b.Requests(); len(requests) > 0 {
+		t.Errorf("out-of-scope meta refresh target was fetched: %v", requests)
+	}
+}
+
+func TestRedirectChainIsStored(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRedirects string
+		want         []string // stored paths
+	}{
+		{"within max_redirects", "2", []string{"/", "/new"}},
+		{"past max_redirects", "1", []string{"/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+				"/":      crawltest.HTML(article("Home") + `<a href="/a-old">old</a></body></html>`),
+				"/a-old": crawltest.Redirect(http.StatusMovedPermanently, "/b-mid"),
+				"/b-mid": crawltest.Redirect(http.StatusFound, "/new"),
+				"/new":   crawltest.HTML(article("New home") + `<a href="/b-mid">mid</a></body></html>`),
+			})
+			defer server.Close()
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 2\n  max_redirects: "+tt.maxRedirects+"\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			var got []string
+			for _, doc := range storer.Documents() {
+				got = append(got, strings.TrimPrefix(doc.URL, server.URL))
+				if doc.URL != server.URL+"/new" {
+					continue
+				}
+				if want := `["` + server.URL + `/a-old","` + server.URL + `/b-mid"]`; doc.RedirectChain != want {
+					t.Errorf("redirect_chain = %s, want %s", doc.RedirectChain, want)
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("stored %v, want %v", got, tt.want)
+			}
+			if requests := server.Requests(); strings.Count(strings.Join(requests, " "), "/b-mid") != 1 {
+				t.Errorf("requests = %v, want /b-mid fetched once, as a hop of /a-old", requests)
+			}
+		})
+	}
+}
+
+func TestRedirectHopsHonorRobots(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/robots.txt":   crawltest.Text("User-agent: *\nDisallow: /private\n"),
+		"/":             crawltest.HTML(article("Home") + `<a href="/old">old</a><a href="/ads-old">ads</a></body></html>`),
+		"/old":          crawltest.Redirect(http.StatusMovedPermanently, "/mid"),
+		"/mid":          crawltest.Redirect(http.StatusFound, "/private/page"),
+		"/private/page": crawltest.HTML(article("Private") + `</body></html>`),
+		"/ads-old":      crawltest.Redirect(http.StatusFound, "/ads/page"),
+		"/ads/page":     crawltest.HTML(article("Ad") + `</body></html>`),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 2\n  redirect_policy: follow_and_store\n  ad_link_patterns: [\"/ads/\"]\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	if got, want := storer.URLs(), []string{server.URL + "/"}; !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+	for _, path := range []string{"/private/page", "/ads/page"} {
+		if requests := server.Requests(); slices.Contains(requests, path) {
+			t.Errorf("disallowed redirect target %s was fetched; requests: %v", path, requests)
+		}
+	}
+}
+
+func TestRedirectTargetHashIDCoversFinalURL(t *testing.T) {
+	page := crawltest.HTML(article("Same page") + `</body></html>`)
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":      crawltest.HTML(article("Home") + `<a href="/moved">moved</a><a href="/copy">copy</a></body></html>`),
+		"/moved": crawltest.Redirect(http.StatusMovedPermanently, "/new"),
+		"/new":   page,
+		"/copy":  page,
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	ids := map[string]string{}
+	for _, doc := range storer.Documents() {
+		ids[strings.TrimPrefix(doc.URL, server.URL)] = doc.HashID
+	}
+	if ids["/new"] == "" || ids["/copy"] == "" {
+		t.Fatalf("stored %v, want /new and /copy", storer.URLs())
+	}
+	if ids["/new"] == ids["/copy"] {
+		t.Errorf("redirect target /new has the hash_id %s of the same content at /copy", ids["/new"])
+	}
+}
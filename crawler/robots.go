@@ -1,71 +1,368 @@
 package crawler
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
+
+	"crawlengine/config"
 
 	"github.com/temoto/robotstxt"
+	"golang.org/x/sync/singleflight"
 )
 
-var (
-	robotsCache = make(map[string]*robotstxt.RobotsData)
-	cacheMutex  = &sync.RWMutex{}
+// Policies for robots_failure_policy, applied when a host's robots.txt can't
+// be fetched (network error, 429 or 5xx response) or parsed.
+const (
+	RobotsFailureAllow = "allow"
+	RobotsFailureDeny  = "deny"
+	RobotsFailureRetry = "retry"
 )
 
-// GetRobotsData fetches and parses robots.txt for a given base URL.
-// It uses a simple in-memory cache.
-func GetRobotsData(baseURL *url.URL, userAgent string) (*robotstxt.RobotsData, error) {
-	cacheMutex.RLock()
-	data, found := robotsCache[baseURL.Host]
-	cacheMutex.RUnlock()
+// robotsFailureCooldown is how long a host allowed or denied by
+// robots_failure_policy stays so before its robots.txt is fetched again, so
+// its pages don't each wait for another failed fetch.
+const robotsFailureCooldown = time.Minute
+
+type robotsFailurePolicy struct {
+	policy     string
+	maxRetries int
+	backoff    time.Duration
+}
+
+// robotsState is the robots.txt cache, overrides and robots_failure_policy
+// of one Fetcher, so crawlers in the same process don't share them.
+type robotsState struct {
+	// collapseWWW shares robots.txt between www and non-www hosts. Set by
+	// SetRobotsCollapseWWW before the Fetcher is used.
+	collapseWWW bool
+
+	// overrides replaces robots.txt for explicitly configured hosts, keyed
+	// by host (with port, if any) or hostname. Set by SetRobotsOverrides
+	// before the Fetcher is used.
+	overrides map[string]*robotstxt.RobotsData
+
+	// failure is the robots_failure_policy. Set by SetRobotsFailurePolicy
+	// before the Fetcher is used.
+	failure robotsFailurePolicy
+
+	cacheMu sync.RWMutex
+	cache   map[string]*robotstxt.RobotsData // keyed by hostKey
+
+	// fallbacks holds hosts whose robots.txt couldn't be fetched, keyed like
+	// cache, with what robots_failure_policy decided for them until their
+	// robots.txt may be fetched again.
+	fallbacksMu sync.Mutex
+	fallbacks   map[string]robotsFallback
 
+	// fetches collapses concurrent fetches of a host's robots.txt, so
+	// workers reaching an uncached host together wait for one fetch (and one
+	// round of retries) instead of each making their own.
+	fetches singleflight.Group
+}
+
+// robotsFallback is the robots_failure_policy decision for a host: rules
+// allowing everything, or nil rules denying the host.
+type robotsFallback struct {
+	data  *robotstxt.RobotsData
+	until time.Time
+}
+
+func newRobotsState() *robotsState {
+	return &robotsState{
+		failure:   robotsFailurePolicy{policy: RobotsFailureAllow},
+		cache:     make(map[string]*robotstxt.RobotsData),
+		fallbacks: make(map[string]robotsFallback),
+	}
+}
+
+// robotsState returns the robots state of f, or of the default fetcher if f
+// is nil.
+func (f *Fetcher) robotsState() *robotsState {
+	if f == nil {
+		return defaultFetcher.robots
+	}
+	return f.robots
+}
+
+// SetRobotsFailurePolicy sets what happens when a host's robots.txt can't be
+// fetched: RobotsFailureAllow allows the whole host, RobotsFailureDeny
+// disallows it, and RobotsFailureRetry retries the fetch maxRetries times
+// with a backoff doubling from backoff before disallowing it. The allow
+// policy is used until this is called. It must be called before f is used.
+func (f *Fetcher) SetRobotsFailurePolicy(policy string, maxRetries int, backoff time.Duration) {
+	f.robots.failure = robotsFailurePolicy{policy: policy, maxRetries: maxRetries, backoff: backoff}
+}
+
+// SetRobotsCollapseWWW makes www and non-www hosts share one cached
+// robots.txt and override, fetched from whichever host is seen first. It must
+// be called before f is used and before SetRobotsOverrides.
+func (f *Fetcher) SetRobotsCollapseWWW(enabled bool) {
+	f.robots.collapseWWW = enabled
+}
+
+// hostKey returns the key host's robots.txt is cached and overridden under.
+func (s *robotsState) hostKey(host string) string {
+	host = strings.ToLower(host)
+	if s.collapseWWW {
+		return stripWWW(host)
+	}
+	return host
+}
+
+// SetRobotsOverrides installs per-host robots.txt overrides. A host with
+// ignore_robots is treated as allowing everything; a host with an inline
+// robots body uses that body instead of its robots.txt. Invalid bodies are
+// rejected with an error and no overrides are installed. It must be called
+// before f is used.
+func (f *Fetcher) SetRobotsOverrides(overrides map[string]config.RobotsOverride) error {
+	parsed := make(map[string]*robotstxt.RobotsData, len(overrides))
+	for host, override := range overrides {
+		body := override.Robots
+		if override.IgnoreRobots {
+			body = "User-agent: *\nAllow: /"
+		} else if strings.TrimSpace(body) == "" {
+			return fmt.Errorf("robots override for %s needs ignore_robots or a robots body", host)
+		}
+		data, err := parseRobots(body)
+		if err != nil {
+			return fmt.Errorf("failed to parse robots override for %s: %w", host, err)
+		}
+		parsed[f.robots.hostKey(host)] = data
+		log.Printf("Warning: robots.txt for %s is overridden by config (ignore_robots=%t)", host, override.IgnoreRobots)
+	}
+
+	f.robots.overrides = parsed
+	return nil
+}
+
+// override returns the configured robots rules for the host of u, or nil.
+func (s *robotsState) override(u *url.URL) *robotstxt.RobotsData {
+	if data, ok := s.overrides[s.hostKey(u.Host)]; ok {
+		return data
+	}
+	return s.overrides[s.hostKey(u.Hostname())]
+}
+
+// GetRobotsData returns the robots rules for a given base URL: the configured
+// override for its host if there is one, otherwise its fetched and parsed
+// robots.txt.
+func GetRobotsData(ctx context.Context, fetcher *Fetcher, baseURL *url.URL, userAgent string) (*robotstxt.RobotsData, error) {
+	if override := fetcher.robotsState().override(baseURL); override != nil {
+		return override, nil
+	}
+	return fetchRobotsData(ctx, fetcher, baseURL, userAgent)
+}
+
+// fetchRobotsData fetches and parses robots.txt for a given base URL.
+// It uses the fetcher's in-memory cache. A robots.txt that doesn't exist (a
+// 4xx response other than 429) allows everything; one that can't be fetched
+// is handled by the robots_failure_policy for robotsFailureCooldown, and an
+// error is returned if the policy disallows the host. Concurrent calls for
+// the same host share one fetch.
+func fetchRobotsData(ctx context.Context, fetcher *Fetcher, baseURL *url.URL, userAgent string) (*robotstxt.RobotsData, error) {
+	state := fetcher.robotsState()
+	key := state.hostKey(baseURL.Host)
+	if data, err := state.cached(baseURL, key); data != nil || err != nil {
+		return data, err
+	}
+	data, err, _ := state.fetches.Do(key, func() (any, error) {
+		// A fetch that finished since the check above has filled the cache.
+		if data, err := state.cached(baseURL, key); data != nil || err != nil {
+			return data, err
+		}
+		return state.fetch(ctx, fetcher, baseURL, key, userAgent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data.(*robotstxt.RobotsData), nil
+}
+
+// cached returns the cached robots rules of the host with key, including
+// the rules robots_failure_policy still allows it with, or an error if the
+// policy still denies the host. It returns neither if robots.txt needs to be
+// fetched.
+func (s *robotsState) cached(baseURL *url.URL, key string) (*robotstxt.RobotsData, error) {
+	s.cacheMu.RLock()
+	data, found := s.cache[key]
+	s.cacheMu.RUnlock()
 	if found {
 		return data, nil
 	}
 
+	s.fallbacksMu.Lock()
+	fallback, failed := s.fallbacks[key]
+	s.fallbacksMu.Unlock()
+	if !failed || !time.Now().Before(fallback.until) {
+		return nil, nil
+	}
+	if fallback.data != nil {
+		return fallback.data, nil
+	}
+	return nil, fmt.Errorf("robots.txt for %s unavailable, host denied by robots_failure_policy until %s", baseURL.Host, fallback.until.Format(time.TimeOnly))
+}
+
+// fetch fetches robots.txt for fetchRobotsData, caching the result, and
+// retries, allows or denies the host as the robots_failure_policy says. Waits between retries end early
+// when ctx is cancelled.
+func (s *robotsState) fetch(ctx context.Context, fetcher *Fetcher, baseURL *url.URL, key, userAgent string) (*robotstxt.RobotsData, error) {
 	robotsURL := baseURL.Scheme + "://" + baseURL.Host + "/robots.txt"
-	log.Printf("Fetching robots.txt from: %s for agent: %s", robotsURL, userAgent)
+	policy := s.failure
+	backoff := policy.backoff
+	for attempt := 0; ; attempt++ {
+		log.Printf("Fetching robots.txt from: %s for agent: %s", robotsURL, userAgent)
+		robotsData, retryable, err := fetchRobotsFile(ctx, fetcher, robotsURL, userAgent)
+		if err == nil {
+			if robotsData == nil {
+				if robotsData, err = allowAllRobots(); err != nil {
+					return nil, err
+				}
+			}
+			s.cacheMu.Lock()
+			s.cache[key] = robotsData
+			s.cacheMu.Unlock()
+			s.fallbacksMu.Lock()
+			delete(s.fallbacks, key)
+			s.fallbacksMu.Unlock()
+			return robotsData, nil
+		}
+
+		switch {
+		case policy.policy == RobotsFailureAllow:
+			log.Printf("Error getting robots.txt for %s: %v. Assuming allow all for %s (robots_failure_policy %s).", baseURL.Host, err, robotsFailureCooldown, policy.policy)
+			allowAll, err := allowAllRobots()
+			if err != nil {
+				return nil, err
+			}
+			s.setFallback(key, allowAll)
+			return allowAll, nil
+		case policy.policy == RobotsFailureRetry && retryable && attempt < policy.maxRetries:
+			log.Printf("Warning: Error getting robots.txt for %s (attempt %d/%d), retrying in %s: %v", baseURL.Host, attempt+1, policy.maxRetries+1, backoff, err)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("robots.txt for %s unavailable: %w", baseURL.Host, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			continue
+		}
+		log.Printf("Error getting robots.txt for %s after %d attempts: %v. Disallowing the host for %s (robots_failure_policy %s).", baseURL.Host, attempt+1, err, robotsFailureCooldown, policy.policy)
+		s.setFallback(key, nil)
+		return nil, fmt.Errorf("robots.txt for %s unavailable: %w", baseURL.Host, err)
+	}
+}
+
+// setFallback records the robots_failure_policy decision for the host with
+// key for robotsFailureCooldown: data allowing everything, or nil to deny.
+func (s *robotsState) setFallback(key string, data *robotstxt.RobotsData) {
+	s.fallbacksMu.Lock()
+	s.fallbacks[key] = robotsFallback{data: data, until: time.Now().Add(robotsFailureCooldown)}
+	s.fallbacksMu.Unlock()
+}
 
-	resp, err := FetchPage(robotsURL, userAgent) // Use our FetchPage to respect UA
+// fetchRobotsFile makes one attempt at fetching and parsing robots.txt. It
+// returns nil rules without an error when there is no robots.txt. Network
+// errors, 429 and 5xx responses are retryable; unparseable bodies are not.
+func fetchRobotsFile(ctx context.Context, fetcher *Fetcher, robotsURL, userAgent string) (data *robotstxt.RobotsData, retryable bool, err error) {
+	resp, err := fetcher.Fetch(ctx, robotsURL, userAgent)
 	if err != nil {
-		log.Printf("Error fetching robots.txt for %s: %v. Assuming allow all.", baseURL.Host, err)
-		return robotstxt.FromStatusAndBytes(http.StatusOK, []byte("User-agent: *\nAllow: /"))
+		return nil, true, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, true, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("robots.txt for %s returned status %d. Assuming allow all for this specific error.", baseURL.Host, resp.StatusCode)
-		return robotstxt.FromStatusAndBytes(http.StatusOK, []byte("User-agent: *\nAllow: /"))
+		log.Printf("robots.txt at %s returned status %d. Assuming allow all.", robotsURL, resp.StatusCode)
+		return nil, false, nil
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading robots.txt body for %s: %v. Assuming allow all.", baseURL.Host, err)
-		return robotstxt.FromStatusAndBytes(http.StatusOK, []byte("User-agent: *\nAllow: /"))
+		return nil, true, fmt.Errorf("failed to read body: %w", err)
 	}
 
-	robotsData, err := robotstxt.FromBytes(body)
+	data, err = parseRobots(string(body))
 	if err != nil {
-		log.Printf("Error parsing robots.txt for %s: %v. Assuming allow all.", baseURL.Host, err)
-		return robotstxt.FromStatusAndBytes(http.StatusOK, []byte("User-agent: *\nAllow: /"))
+		return nil, false, fmt.Errorf("failed to parse: %w", err)
 	}
+	return data, false, nil
+}
 
-	cacheMutex.Lock()
-	robotsCache[baseURL.Host] = robotsData
-	cacheMutex.Unlock()
+// parseRobots parses a robots.txt body. The robotstxt library applies the
+// longest matching rule of a group, but of an Allow and a Disallow rule of
+// the same length it applies whichever is listed first; RFC 9309 prefers the
+// Allow rule, so Allow lines are moved ahead of the other lines of their
+// group before parsing.
+func parseRobots(body string) (*robotstxt.RobotsData, error) {
+	lines := strings.Split(body, "\n")
+	ordered := make([]string, 0, len(lines))
+	var allows, others []string
+	flush := func() {
+		ordered = append(append(ordered, allows...), others...)
+		allows, others = nil, nil
+	}
+	for _, line := range lines {
+		field, _, _ := strings.Cut(strings.TrimPrefix(line, "\ufeff"), ":")
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "user-agent", "useragent":
+			flush()
+			ordered = append(ordered, line)
+		case "allow":
+			allows = append(allows, line)
+		default:
+			others = append(others, line)
+		}
+	}
+	flush()
+	return robotstxt.FromString(strings.Join(ordered, "\n"))
+}
 
-	return robotsData, nil
+func allowAllRobots() (*robotstxt.RobotsData, error) {
+	return robotstxt.FromStatusAndBytes(http.StatusOK, []byte("User-agent: *\nAllow: /"))
+}
+
+// DefaultRobotsAgent is the product token used for robots.txt decisions when
+// robots_user_agent is not configured.
+const DefaultRobotsAgent = "GoCrawler"
+
+// RobotsAgentToken reduces a user-agent string to the product token robots.txt
+// groups are matched against, e.g. "MyBot/2.1 (+https://...)" -> "MyBot".
+// The robotstxt library picks the group whose name is the longest prefix of
+// this token, so a group naming our bot takes precedence over "*".
+func RobotsAgentToken(userAgent string) string {
+	token := strings.TrimSpace(userAgent)
+	if i := strings.IndexAny(token, "/ "); i > 0 {
+		token = token[:i]
+	}
+	if token == "" {
+		return DefaultRobotsAgent
+	}
+	return token
 }
 
 // IsAllowedByRobots checks if crawling a path is allowed by robots.txt.
-func IsAllowedByRobots(targetURL *url.URL, userAgent string) bool {
-	robotsData, err := GetRobotsData(targetURL, userAgent)
+func IsAllowedByRobots(ctx context.Context, fetcher *Fetcher, targetURL *url.URL, userAgent string) bool {
+	robotsData, err := GetRobotsData(ctx, fetcher, targetURL, userAgent)
 	if err != nil {
 		log.Printf("Cannot determine robots.txt for %s, disallowing path %s: %v", targetURL.Host, targetURL.Path, err)
 		return false
 	}
-	return robotsData.TestAgent(targetURL.Path, userAgent)
+	allowed := robotsData.TestAgent(targetURL.Path, userAgent)
+
+	if allowed && fetcher.robotsState().override(targetURL) != nil {
+		// Check the real robots.txt so every bypassed rule is visible in the logs.
+		if real, err := fetchRobotsData(ctx, fetcher, targetURL, userAgent); err == nil && !real.TestAgent(targetURL.Path, userAgent) {
+			log.Printf("WARNING: robots override for %s allows %s, which its robots.txt disallows for agent %s", targetURL.Host, targetURL.String(), userAgent)
+		}
+	}
+	return allowed
 }
@@ -0,0 +1,371 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+func TestRobotsAgentToken(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"MyBot/2.1 (+https://example.com/bot)", "MyBot"},
+		{"MyBot", "MyBot"},
+		{"  MyBot/1.0", "MyBot"},
+		{"My Bot", "My"},
+		{"", crawler.DefaultRobotsAgent},
+		{"/1.0", "/1.0"},
+	}
+	for _, tt := range tests {
+		if got := crawler.RobotsAgentToken(tt.userAgent); got != tt.want {
+			t.Errorf("RobotsAgentToken(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+// robotsAllowed reports whether robotsTXT, served by a fixture server,
+// allows path for agent.
+func robotsAllowed(t *testing.T, server *crawltest.FixtureServer, agent, path string) bool {
+	t.Helper()
+	u, err := url.Parse(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crawler.IsAllowedByRobots(context.Background(), nil, u, crawler.RobotsAgentToken(agent))
+}
+
+func TestRobotsGroupPrecedence(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/robots.txt": crawltest.Text(`User-agent: *
+Disallow: /private
+Disallow: /drafts
+
+User-agent: MyBot
+Disallow: /drafts
+Disallow: /mybot-blocked
+
+User-agent: MyBotNews
+Disallow: /news
+`),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		// The MyBot group replaces the * group rather than adding to it.
+		{"MyBot/2.1 (+https://example.com/bot)", "/private/page", true},
+		{"MyBot/2.1 (+https://example.com/bot)", "/mybot-blocked", false},
+		{"MyBot/2.1 (+https://example.com/bot)", "/drafts/1", false},
+		{"mybot/2.1", "/mybot-blocked", false},
+		// The longest matching group name wins.
+		{"MyBotNews/1.0", "/news/today", false},
+		{"MyBotNews/1.0", "/mybot-blocked", true},
+		{"MyBot/2.1", "/news/today", true},
+		// Agents without a group of their own fall back to *.
+		{"OtherBot/1.0", "/private/page", false},
+		{"OtherBot/1.0", "/mybot-blocked", true},
+		{"", "/private/page", false},
+	}
+	for _, tt := range tests {
+		if got := robotsAllowed(t, server, tt.agent, tt.path); got != tt.want {
+			t.Errorf("agent %q, path %s: allowed = %t, want %t", tt.agent, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRulePrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		path   string
+		want   bool
+	}{
+		{"longer disallow wins", "User-agent: *\nAllow: /docs\nDisallow: /docs/private\n", "/docs/private/x", false},
+		{"shorter allow applies", "User-agent: *\nAllow: /docs\nDisallow: /docs/private\n", "/docs/public", true},
+		{"longer allow wins", "User-agent: *\nDisallow: /\nAllow: /public\n", "/public/x", true},
+		{"shorter disallow applies", "User-agent: *\nDisallow: /\nAllow: /public\n", "/other", false},
+		{"tie goes to allow listed first", "User-agent: *\nAllow: /page\nDisallow: /page\n", "/page", true},
+		{"tie goes to allow listed last", "User-agent: *\nDisallow: /page\nAllow: /page\n", "/page", true},
+		{"tie with comments and crawl-delay", "User-agent: *\nDisallow: /page # old\nCrawl-delay: 1\nallow:/page\n", "/page", true},
+		{"allow of another group ignored", "User-agent: OtherBot\nAllow: /page\n\nUser-agent: *\nDisallow: /page\n", "/page", false},
+		{"allow of a later group ignored", "User-agent: *\nDisallow: /page\n\nUser-agent: OtherBot\nAllow: /page\n", "/page", false},
+		{"tie in a BOM-prefixed file", "\ufeffUser-agent: *\r\nDisallow: /page\r\nAllow: /page\r\n", "/page", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{"/robots.txt": crawltest.Text(tt.robots)})
+			defer server.Close()
+			if got := robotsAllowed(t, server, "MyBot/1.0", tt.path); got != tt.want {
+				t.Errorf("allowed = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlUsesRobotsUserAgent(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nDisallow: /\n\nUser-agent: MyBot\nDisallow: /b\n"),
+	})
+	defer server.Close()
+
+	// Pages are fetched with rotating browser user agents, but every robots
+	// decision is made for MyBot.
+	cfg := loadCrawlerConfig(t, `  max_depth: 1
+  robots_user_agent: "MyBot/1.0 (+https://example.com/bot)"
+  user_agents:
+    - "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0"
+    - "Mozilla/5.0 (Windows NT 10.0) Chrome/126.0"
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/", server.URL + "/a", server.URL + "/c"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored URLs = %v, want %v", got, want)
+	}
+}
+
+// flakyRobotsServer serves pages at / and /a to /c, and a robots.txt that
+// fails with a 503 for its first failures requests and allows everything
+// after. It returns the server and the number of robots.txt requests made.
+func flakyRobotsServer(t *testing.T, failures int, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var robotsRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, article("Page "+r.URL.Path)+`</body></html>`)
+			return
+		}
+		n := robotsRequests.Add(1)
+		time.Sleep(delay)
+		if int(n) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "User-agent: *\nAllow: /\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &robotsRequests
+}
+
+func TestRobotsFailureDeny(t *testing.T) {
+	server, robotsRequests := flakyRobotsServer(t, 1, 0)
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  robots_failure_policy: deny\n  robots_max_retries: 3\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	if got := storer.URLs(); len(got) != 0 {
+		t.Errorf("stored %v, want nothing from a host whose robots.txt failed", got)
+	}
+	if got := robotsRequests.Load(); got != 1 {
+		t.Errorf("robots.txt requested %d times, want 1: deny doesn't retry", got)
+	}
+}
+
+func TestRobotsFailureRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantStored   bool
+		wantRequests int32
+	}{
+		{"recovers", 2, true, 3},
+		{"gives up", 5, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, robotsRequests := flakyRobotsServer(t, tt.failures, 0)
+
+			cfg := loadCrawlerConfig(t, `  max_depth: 0
+  robots_failure_policy: retry
+  robots_max_retries: 2
+  robots_retry_backoff_ms: 1
+`)
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			if stored := len(storer.URLs()) > 0; stored != tt.wantStored {
+				t.Errorf("stored %v, want stored = %t", storer.URLs(), tt.wantStored)
+			}
+			if got := robotsRequests.Load(); got != tt.wantRequests {
+				t.Errorf("robots.txt requested %d times, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRobotsFailureCooldown(t *testing.T) {
+	server, robotsRequests := flakyRobotsServer(t, 1, 0)
+
+	// /a to /c come after the first robots.txt fetch failed, within the
+	// cooldown, so they are denied without fetching it again.
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  robots_failure_policy: deny\n")
+	cfg.SeedURLs = []string{server.URL + "/", server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	_, storer := runCrawl(t, cfg)
+
+	if got := storer.URLs(); len(got) != 0 {
+		t.Errorf("stored %v, want nothing during the cooldown", got)
+	}
+	if got := robotsRequests.Load(); got != 1 {
+		t.Errorf("robots.txt requested %d times, want 1", got)
+	}
+}
+
+func TestRobotsFetchIsSharedByWorkers(t *testing.T) {
+	server, robotsRequests := flakyRobotsServer(t, 0, 100*time.Millisecond)
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n  max_concurrency: 4\n")
+	cfg.SeedURLs = []string{server.URL + "/", server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	storer := crawltest.NewMockStorer()
+	c := crawler.NewCrawler(cfg, storer, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if got := len(storer.URLs()); got != 4 {
+		t.Errorf("stored %d pages, want 4", got)
+	}
+	if got := robotsRequests.Load(); got != 1 {
+		t.Errorf("robots.txt requested %d times by 4 workers, want 1", got)
+	}
+}
+
+func TestRobotsRetryStopsOnCancel(t *testing.T) {
+	server, _ := flakyRobotsServer(t, 10, 0)
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 0
+  robots_failure_policy: retry
+  robots_max_retries: 5
+  robots_retry_backoff_ms: 3600000
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	cfg.Deterministic = true
+	c := crawler.NewCrawler(cfg, crawltest.NewMockStorer(), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	c.Start(ctx)
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Start returned %s after cancellation; the retry backoff should end with the context", elapsed)
+	}
+}
+
+func TestRobotsFailurePolicyIsPerCrawler(t *testing.T) {
+	server, _ := flakyRobotsServer(t, 100, 0)
+
+	// Creating the second crawler must not change the policy of the first.
+	deny := loadCrawlerConfig(t, "  max_depth: 0\n  robots_failure_policy: deny\n")
+	deny.SeedURLs = []string{server.URL + "/"}
+	deny.Deterministic = true
+	denyStorer := crawltest.NewMockStorer()
+	denyCrawler := crawler.NewCrawler(deny, denyStorer, nil)
+	allow := loadCrawlerConfig(t, "  max_depth: 0\n  robots_failure_policy: allow\n")
+	allow.SeedURLs = []string{server.URL + "/"}
+	allow.Deterministic = true
+	allowStorer := crawltest.NewMockStorer()
+	allowCrawler := crawler.NewCrawler(allow, allowStorer, nil)
+
+	for _, c := range []*crawler.Crawler{denyCrawler, allowCrawler} {
+		if err := c.Start(context.Background()); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	}
+	if got := denyStorer.URLs(); len(got) != 0 {
+		t.Errorf("deny crawler stored %v, want nothing", got)
+	}
+	if got := allowStorer.URLs(); len(got) != 1 {
+		t.Errorf("allow crawler stored %v, want the seed", got)
+	}
+}
+
+func TestRobotsFallbackIsCached(t *testing.T) {
+	missing := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":  crawltest.HTML(article("Home") + `</body></html>`),
+		"/a": crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b": crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c": crawltest.HTML(article("Page C") + `</body></html>`),
+	})
+	defer missing.Close()
+	failing, failingRequests := flakyRobotsServer(t, 100, 0)
+
+	tests := []struct {
+		name     string
+		url      string
+		requests func() int
+	}{
+		{"missing robots.txt", missing.URL, func() int {
+			return len(slices.DeleteFunc(missing.Requests(), func(path string) bool { return path != "/robots.txt" }))
+		}},
+		{"allow on error", failing.URL, func() int { return int(failingRequests.Load()) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadCrawlerConfig(t, "  max_depth: 0\n  robots_failure_policy: allow\n")
+			cfg.SeedURLs = []string{tt.url + "/", tt.url + "/a", tt.url + "/b", tt.url + "/c"}
+			_, storer := runCrawl(t, cfg)
+
+			if got := len(storer.URLs()); got != 4 {
+				t.Errorf("stored %d pages, want 4", got)
+			}
+			if got := tt.requests(); got != 1 {
+				t.Errorf("robots.txt requested %d times for 4 pages, want 1", got)
+			}
+		})
+	}
+}
+
+func TestRobotsOverridesArePerCrawler(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nDisallow: /\n"),
+	})
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	// Creating the second crawler must not drop the overrides of the first.
+	overridden := loadCrawlerConfig(t, "  max_depth: 0\n  robots_overrides:\n    \""+u.Host+"\":\n      ignore_robots: true\n")
+	overridden.SeedURLs = []string{server.URL + "/"}
+	overridden.Deterministic = true
+	overriddenStorer := crawltest.NewMockStorer()
+	overriddenCrawler := crawler.NewCrawler(overridden, overriddenStorer, nil)
+	plain := loadCrawlerConfig(t, "  max_depth: 0\n")
+	plain.SeedURLs = []string{server.URL + "/"}
+	plain.Deterministic = true
+	plainStorer := crawltest.NewMockStorer()
+	plainCrawler := crawler.NewCrawler(plain, plainStorer, nil)
+
+	for _, c := range []*crawler.Crawler{overriddenCrawler, plainCrawler} {
+		if err := c.Start(context.Background()); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	}
+	if got := overriddenStorer.URLs(); len(got) != 1 {
+		t.Errorf("crawler with ignore_robots stored %v, want the seed", got)
+	}
+	if got := plainStorer.URLs(); len(got) != 0 {
+		t.Errorf("crawler without overrides stored %v, want nothing", got)
+	}
+}
@@ -0,0 +1,30 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestRobotsCollapseWWWIsPerFetcher(t *testing.T) {
+	overrides := map[string]config.RobotsOverride{"www.example.com": {IgnoreRobots: true}}
+	collapsed := NewFetcher(http.DefaultClient, 0, 0)
+	collapsed.SetRobotsCollapseWWW(true)
+	if err := collapsed.SetRobotsOverrides(overrides); err != nil {
+		t.Fatal(err)
+	}
+	separate := NewFetcher(http.DefaultClient, 0, 0)
+	if err := separate.SetRobotsOverrides(overrides); err != nil {
+		t.Fatal(err)
+	}
+
+	bare, _ := url.Parse("https://example.com/page")
+	if collapsed.robotsState().override(bare) == nil {
+		t.Error("with treat_www_as_same, example.com does not use the override of www.example.com")
+	}
+	if separate.robotsState().override(bare) != nil {
+		t.Error("without treat_www_as_same, example.com uses the override of www.example.com")
+	}
+}
@@ -0,0 +1,191 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"crawlengine/config"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// extractionRules holds the per-domain selector overrides from
+// extraction_rules. A pattern is either an exact host ("news.example.com")
+// or a wildcard ("*.example.com") matching the domain and its subdomains.
+// Exact matches win; among wildcards the longest domain wins.
+type extractionRules struct {
+	exact    map[string]*config.ExtractionRule
+	suffixes []string // wildcard domains, longest first
+	wildcard map[string]*config.ExtractionRule
+}
+
+// newExtractionRules validates every selector in rules. Rules with an
+// invalid selector are skipped with a warning; the rest are used.
+func newExtractionRules(rules map[string]config.ExtractionRule) *extractionRules {
+	if len(rules) == 0 {
+		return nil
+	}
+	r := &extractionRules{
+		exact:    make(map[string]*config.ExtractionRule),
+		wildcard: make(map[string]*config.ExtractionRule),
+	}
+	for pattern, rule := range rules {
+		if err := validateExtractionRule(rule); err != nil {
+			log.Printf("Warning: extraction rule for %s skipped: %v", pattern, err)
+			continue
+		}
+		rule := rule
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			r.wildcard[domain] = &rule
+			r.suffixes = append(r.suffixes, domain)
+		} else {
+			r.exact[pattern] = &rule
+		}
+	}
+	sort.Slice(r.suffixes, func(i, j int) bool { return len(r.suffixes[i]) > len(r.suffixes[j]) })
+	log.Printf("Loaded %d per-domain extraction rules", len(r.exact)+len(r.wildcard))
+	return r
+}
+
+func validateExtractionRule(rule config.ExtractionRule) error {
+	selectors := map[string]string{
+		"title_selector":   rule.TitleSelector,
+		"content_selector": rule.ContentSelector,
+		"date_selector":    rule.DateSelector,
+	}
+	for name, selector := range selectors {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", name, selector, err)
+		}
+	}
+	if rule.JSON != nil {
+		if err := validateJSONMapping(rule.JSON); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// For returns the rule for host, or nil if no rule matches.
+func (r *extractionRules) For(host string) *config.ExtractionRule {
+	if r == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if rule, ok := r.exact[host]; ok {
+		return rule
+	}
+	for _, domain := range r.suffixes {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return r.wildcard[domain]
+		}
+	}
+	return nil
+}
+
+// hasJSONMapping reports whether any rule maps JSON responses to documents.
+func (r *extractionRules) hasJSONMapping() bool {
+	if r == nil {
+		return false
+	}
+	for _, rules := range []map[string]*config.ExtractionRule{r.exact, r.wildcard} {
+		for _, rule := range rules {
+			if rule.JSON != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type ruleHeadersKey struct{}
+
+// withRuleHeaders returns ctx carrying the request headers of host's rule
+// for fetches made with it.
+func (r *extractionRules) withRuleHeaders(ctx context.Context, host string) context.Context {
+	rule := r.For(host)
+	if rule == nil || len(rule.Headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ruleHeadersKey{}, rule.Headers)
+}
+
+// applyRuleHeaders sets the rule headers carried by ctx on header.
+func applyRuleHeaders(ctx context.Context, header http.Header) {
+	headers, _ := ctx.Value(ruleHeadersKey{}).(map[string]string)
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+}
+
+// dropQueryParams returns rawURL without the query parameters its domain's
+// rule drops, or rawURL unchanged if there is no such rule or parameter.
+func (r *extractionRules) dropQueryParams(rawURL string) string {
+	if r == nil {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	rule := r.For(u.Hostname())
+	if rule == nil || len(rule.DropQueryParams) == 0 {
+		return rawURL
+	}
+
+	var kept []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !matchesParam(name, rule.DropQueryParams) {
+			kept = append(kept, param)
+		}
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	return u.String()
+}
+
+func matchesParam(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorValue returns the value of the first element matching selector:
+// its content attribute (for <meta>), its datetime attribute (for <time>), or
+// its text. Returns "" if nothing matches or selector is empty.
+func selectorValue(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	s := doc.Find(selector).First()
+	if s.Length() == 0 {
+		return ""
+	}
+	if value, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(value)
+	}
+	if value, ok := s.Attr("datetime"); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestExtractionRulesFor(t *testing.T) {
+	rules := newExtractionRules(map[string]config.ExtractionRule{
+		"News.Example.com":   {TitleSelector: "h1.headline"},
+		"*.example.com":      {TitleSelector: "h1"},
+		"*.blog.example.com": {ContentSelector: "div.post"},
+		"broken.example.org": {ContentSelector: "div["},
+	})
+	tests := []struct {
+		host string
+		want *config.ExtractionRule
+	}{
+		{"news.example.com", &config.ExtractionRule{TitleSelector: "h1.headline"}},
+		{"www.example.com", &config.ExtractionRule{TitleSelector: "h1"}},
+		{"example.com", &config.ExtractionRule{TitleSelector: "h1"}},
+		{"alice.blog.example.com", &config.ExtractionRule{ContentSelector: "div.post"}},
+		{"broken.example.org", nil},
+		{"example.org", nil},
+	}
+	for _, tt := range tests {
+		got := rules.For(tt.host)
+		if (got == nil) != (tt.want == nil) || got != nil && (got.TitleSelector != tt.want.TitleSelector || got.ContentSelector != tt.want.ContentSelector) {
+			t.Errorf("For(%q) = %+v, want %+v", tt.host, got, tt.want)
+		}
+	}
+
+	if newExtractionRules(nil) != nil {
+		t.Error("newExtractionRules without rules is not nil")
+	}
+}
+
+func TestDropQueryParams(t *testing.T) {
+	rules := newExtractionRules(map[string]config.ExtractionRule{
+		"example.com":    {DropQueryParams: []string{"sessionid", "utm_*"}},
+		"*.example.org":  {DropQueryParams: []string{"*"}},
+		"nodrop.example": {TitleSelector: "h1"},
+	})
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/a?id=1&sessionid=abc&utm_source=x&utm_medium=y", "https://example.com/a?id=1"},
+		{"https://example.com/a?session%69d=abc&page=2", "https://example.com/a?page=2"},
+		{"https://example.com/a?utm_source=x", "https://example.com/a"},
+		{"https://example.com/a?utm=1", "https://example.com/a?utm=1"},
+		{"https://example.com/a", "https://example.com/a"},
+		{"https://www.example.org/a?q=1&page=2#top", "https://www.example.org/a#top"},
+		{"https://nodrop.example/a?sessionid=abc", "https://nodrop.example/a?sessionid=abc"},
+		{"https://other.example/a?sessionid=abc", "https://other.example/a?sessionid=abc"},
+	}
+	for _, tt := range tests {
+		if got := rules.dropQueryParams(tt.url); got != tt.want {
+			t.Errorf("dropQueryParams(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	var none *extractionRules
+	if got := none.dropQueryParams("https://example.com/?sessionid=abc"); got != "https://example.com/?sessionid=abc" {
+		t.Errorf("dropQueryParams without rules = %q, want the URL unchanged", got)
+	}
+}
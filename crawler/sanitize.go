@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// fallbackPolicy rewrites a page as a well-formed fragment of structural and
+// text elements. Disallowed elements are dropped but their text is kept, so
+// only scripts, styles and embedded frames and objects lose content; class
+// and id survive for content_tags selectors.
+var fallbackPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"article", "main", "section", "div", "header", "footer", "nav", "aside",
+		"p", "br", "span", "h1", "h2", "h3", "h4", "h5", "h6",
+		"ul", "ol", "li", "dl", "dt", "dd", "blockquote", "pre", "code",
+		"table", "caption", "thead", "tbody", "tfoot", "tr", "th", "td",
+		"figure", "figcaption", "em", "strong", "b", "i", "u", "a", "time",
+	)
+	p.AllowAttrs("class", "id", "role", "itemprop").Globally()
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("datetime").OnElements("time")
+	p.AllowStandardURLs()
+	return p
+}()
+
+// unwrappedRawText are the elements whose content the tokenizer reads as raw
+// text, except scripts and styles. Their tags are removed before sanitizing.
+var unwrappedRawText = map[string]bool{
+	"noscript": true, "noembed": true, "noframes": true, "textarea": true, "title": true, "xmp": true,
+}
+
+// extractSanitized retries main content extraction on a sanitized copy of
+// htmlString, for malformed pages where the parsed document yields nothing.
+// It returns "" if the sanitized page has no main content either.
+func (c *Crawler) extractSanitized(htmlString string, pageURL *url.URL, contentTags []string) string {
+	sanitized := fallbackPolicy.Sanitize(unwrapRawText(htmlString))
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(sanitized))
+	if err != nil {
+		return ""
+	}
+	c.limitDocument(doc, pageURL.String())
+	return c.extractMainContent(doc, pageURL, contentTags)
+}
+
+// unwrapRawText removes the tags of unwrappedRawText elements, so markup
+// wrongly enclosed in them, such as a page body inside <noscript> or after an
+// unclosed <title>, is parsed as markup rather than swallowed as text.
+func unwrapRawText(htmlString string) string {
+	z := html.NewTokenizer(strings.NewReader(htmlString))
+	var b strings.Builder
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken, html.EndTagToken:
+			raw := string(z.Raw()) // TagName lowercases the buffer in place
+			name, _ := z.TagName()
+			if unwrappedRawText[string(name)] {
+				if tt == html.StartTagToken {
+					z.NextIsNotRawText()
+				}
+				continue
+			}
+			b.WriteString(raw)
+			continue
+		}
+		b.Write(z.Raw())
+	}
+}
@@ -0,0 +1,37 @@
+package crawler_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestSanitizeFallback(t *testing.T) {
+	// The unclosed <title> swallows the rest of the page as its text.
+	const broken = `<html><head><title>Broken page</head><body><article><p>Broken page` +
+		` is a fixture page with enough words in its main content to be stored by the crawler.</p></article></body></html>`
+	for _, fallback := range []bool{false, true} {
+		t.Run("sanitize_fallback="+strconv.FormatBool(fallback), func(t *testing.T) {
+			server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+				"/":           crawltest.HTML(broken),
+				"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+			})
+			defer server.Close()
+
+			cfg := loadCrawlerConfig(t, "  max_depth: 0\n  sanitize_fallback: "+strconv.FormatBool(fallback)+"\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			docs := storer.Documents()
+			if len(docs) != 1 {
+				t.Fatalf("stored %v, want the seed", storer.URLs())
+			}
+			recovered := strings.Contains(docs[0].MainContent, "enough words in its main content")
+			if recovered != fallback {
+				t.Errorf("main content = %q, recovered %t, want %t", docs[0].MainContent, recovered, fallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"crawlengine/config"
+)
+
+func TestCrawlSchedule(t *testing.T) {
+	s := newCrawlSchedule(&config.CrawlerConfig{
+		ScheduleTimezone: "Asia/Seoul",
+		CrawlWindows: []config.CrawlWindow{
+			{Days: []string{"mon", "Tuesday"}, Start: "09:00", End: "17:00"},
+			{Days: []string{"fri"}, Start: "22:00", End: "02:00"},
+			{Start: "25:00", End: "02:00"},
+		},
+	})
+	if len(s.windows) != 2 {
+		t.Fatalf("parsed %d windows, want the 2 valid ones", len(s.windows))
+	}
+	seoul, _ := time.LoadLocation("Asia/Seoul")
+	at := func(day, hour, minute int) time.Time { // January 2024, the 1st is a Monday
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, seoul)
+	}
+
+	tests := []struct {
+		name     string
+		t        time.Time
+		open     bool
+		nextOpen time.Time
+	}{
+		{"before monday window", at(1, 8, 59), false, at(1, 9, 0)},
+		{"monday window", at(1, 9, 0), true, at(2, 9, 0)},
+		{"monday window end", at(1, 17, 0), false, at(2, 9, 0)},
+		{"wednesday", at(3, 12, 0), false, at(5, 22, 0)},
+		{"friday night", at(5, 23, 30), true, at(8, 9, 0)},
+		{"past midnight into saturday", at(6, 1, 59), true, at(8, 9, 0)},
+		{"saturday after the window", at(6, 2, 0), false, at(8, 9, 0)},
+		{"other time zone", at(1, 10, 0).UTC(), true, at(2, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Open(tt.t); got != tt.open {
+				t.Errorf("Open = %t, want %t", got, tt.open)
+			}
+			if got := s.nextOpen(tt.t); !got.Equal(tt.nextOpen) {
+				t.Errorf("nextOpen = %s, want %s", got, tt.nextOpen)
+			}
+		})
+	}
+
+	if newCrawlSchedule(&config.CrawlerConfig{}) != nil || (*crawlSchedule)(nil).Open(at(3, 12, 0)) != true {
+		t.Error("crawling without crawl_windows is restricted")
+	}
+}
@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"crawlengine/config"
+)
+
+// TaskRequest is the method and body a seed_requests task is fetched with.
+type TaskRequest struct {
+	Method      string `json:"method"`
+	Body        string `json:"body,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+const defaultSeedBodyType = "application/x-www-form-urlencoded"
+
+// seedMethods are the methods a seed request may use, mapped to whether a
+// request with that method may carry a body.
+var seedMethods = map[string]bool{
+	"GET":   false,
+	"POST":  true,
+	"PUT":   true,
+	"PATCH": true,
+}
+
+// seedRequestTasks turns seed_requests into depth-0 tasks, dropping with a
+// warning those with an invalid URL, an unsupported method or a body their
+// method doesn't allow. Plain GETs become ordinary seed tasks.
+func (c *Crawler) seedRequestTasks(requests []config.SeedRequest) []CrawlTask {
+	tasks := make([]CrawlTask, 0, len(requests))
+	for _, req := range requests {
+		method := strings.ToUpper(strings.TrimSpace(req.Method))
+		if method == "" {
+			method = "GET"
+		}
+		allowsBody, ok := seedMethods[method]
+		if !ok {
+			log.Printf("Warning: Skipping seed request for %s: unsupported method %s.", req.URL, method)
+			continue
+		}
+		if req.Body != "" && !allowsBody {
+			log.Printf("Warning: Skipping seed request for %s: %s requests can't have a body.", req.URL, method)
+			continue
+		}
+		valid := c.validSeeds([]string{req.URL})
+		if len(valid) == 0 {
+			continue
+		}
+		task := CrawlTask{URL: valid[0]}
+		if method != "GET" {
+			task.Request = &TaskRequest{Method: method, Body: req.Body}
+			if req.Body != "" {
+				task.Request.ContentType = req.ContentType
+				if task.Request.ContentType == "" {
+					task.Request.ContentType = defaultSeedBodyType
+				}
+			}
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+type taskRequestKey struct{}
+
+// withTaskRequest returns ctx carrying req for fetches made with it. A nil
+// req leaves fetches as plain GETs.
+func withTaskRequest(ctx context.Context, req *TaskRequest) context.Context {
+	if req == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, taskRequestKey{}, req)
+}
+
+func taskRequestFromContext(ctx context.Context) *TaskRequest {
+	req, _ := ctx.Value(taskRequestKey{}).(*TaskRequest)
+	return req
+}
@@ -0,0 +1,56 @@
+package crawler_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSeedRequestsUseMethodAndBody(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nAllow: /\n")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"), body))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page := article("Page " + r.URL.Path)
+		if r.URL.Path == "/search" {
+			page += `<a href="/result">result</a>`
+		}
+		fmt.Fprint(w, page+`</body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 1
+  seed_requests:
+    - url: `+server.URL+`/search
+      method: post
+      body: q=crawler
+      content_type: application/x-www-form-urlencoded
+    - url: `+server.URL+`/search
+      method: POST
+      body: q=other
+`)
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{
+		"POST /search application/x-www-form-urlencoded q=crawler",
+		"GET /result  ",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+	if got, want := storer.URLs(), []string{server.URL + "/result", server.URL + "/search"}; !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+}
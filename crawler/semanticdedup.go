@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"crawlengine/config"
+	"crawlengine/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SimilaritySearcher is implemented by storers that can search the content
+// vectors of stored documents, for semantic_dedup_mode.
+type SimilaritySearcher interface {
+	SearchBatch(ctx context.Context, vectorField string, vectors [][]float32, topK int, expr string) ([][]storage.SearchResult, error)
+	Similarity(score float32) float32
+}
+
+// semanticDedupTopK is how many stored documents are compared with each new
+// one. The nearest is often an earlier version of the same page, which
+// doesn't count.
+const semanticDedupTopK = 3
+
+// semanticDedup finds documents whose content vector is nearly the same as a
+// stored document's.
+type semanticDedup struct {
+	searcher  SimilaritySearcher
+	mark      bool // mark duplicates instead of skipping them
+	threshold float64
+}
+
+func newSemanticDedup(cfg *config.CrawlerConfig, storer storage.Storer) *semanticDedup {
+	d := &semanticDedup{threshold: cfg.SemanticDedupThreshold}
+	switch strings.ToLower(cfg.SemanticDedupMode) {
+	case "":
+		return nil
+	case "skip":
+	case "mark":
+		d.mark = true
+	default:
+		log.Printf("Warning: Unknown semantic_dedup_mode '%s', semantic dedup disabled.", cfg.SemanticDedupMode)
+		return nil
+	}
+	if !cfg.EmbedDocuments {
+		log.Printf("Warning: semantic_dedup_mode needs embed_documents; semantic dedup disabled.")
+		return nil
+	}
+	if !storage.Find(storer, func(s storage.Storer) bool {
+		var ok bool
+		d.searcher, ok = s.(SimilaritySearcher)
+		return ok
+	}) {
+		log.Printf("Warning: storer does not support vector search; semantic dedup disabled.")
+		return nil
+	}
+	log.Printf("Semantic dedup enabled: %s documents with a stored one of cosine similarity %.3f or more", strings.ToLower(cfg.SemanticDedupMode), d.threshold)
+	return d
+}
+
+// findSemanticDuplicates checks docs, just embedded, against the stored
+// documents with one search, and against each other. A duplicate is marked
+// duplicate_of its nearest match, or with semantic_dedup_mode skip, reported
+// in the returned slice so it isn't stored. Documents without a content
+// vector are never duplicates, and a failed search counts as no match.
+func (c *Crawler) findSemanticDuplicates(ctx context.Context, docs []*storage.WebDocument) []bool {
+	skip := make([]bool, len(docs))
+	if c.semantic == nil {
+		return skip
+	}
+	var queried []int
+	var vectors [][]float32
+	for i, doc := range docs {
+		if len(doc.ContentVector) > 0 {
+			queried = append(queried, i)
+			vectors = append(vectors, doc.ContentVector)
+		}
+	}
+	if len(vectors) == 0 {
+		return skip
+	}
+
+	ctx, span := tracer.Start(ctx, "crawler.semantic_dedup", trace.WithAttributes(attribute.Int("batch_size", len(vectors))))
+	found, err := c.semantic.searcher.SearchBatch(ctx, storage.FieldContentVector, vectors, semanticDedupTopK, "")
+	endSpan(span, err)
+	if err != nil {
+		log.Printf("Warning: semantic dedup search failed, storing %d documents unchecked: %v", len(vectors), err)
+		found = nil
+	}
+
+	var unique []int // queried documents that aren't duplicates, for the in-batch check
+	for n, i := range queried {
+		doc := docs[i]
+		var representative string
+		var similarity float64
+		if n < len(found) {
+			for _, match := range found[n] {
+				if match.Document.URL == doc.URL || match.Document.HashID == doc.HashID {
+					continue // an earlier version of this page
+				}
+				// Matches are nearest first, so only the first one counts.
+				if s := float64(c.semantic.searcher.Similarity(match.Score)); s >= c.semantic.threshold {
+					representative, similarity = match.Document.HashID, s
+					if match.Document.DuplicateOf != "" {
+						representative = match.Document.DuplicateOf // stored under mark
+					}
+				}
+				break
+			}
+		}
+		if representative == "" {
+			// Documents of the same batch aren't searchable yet.
+			for _, j := range unique {
+				if s := cosineSimilarity(doc.ContentVector, docs[j].ContentVector); s >= c.semantic.threshold {
+					representative, similarity = docs[j].HashID, s
+					break
+				}
+			}
+		}
+		if representative == "" {
+			unique = append(unique, i)
+			continue
+		}
+
+		c.semanticDups.Add(1)
+		if c.semantic.mark {
+			log.Printf("Semantic duplicate detected for %s (similarity %.3f, representative ID: %s)", doc.URL, similarity, representative)
+			if doc.DuplicateOf == "" {
+				doc.DuplicateOf = representative
+			}
+		} else {
+			log.Printf("Skipping storage of semantic duplicate %s (similarity %.3f, representative ID: %s)", doc.URL, similarity, representative)
+			skip[i] = true
+		}
+	}
+	return skip
+}
@@ -0,0 +1,109 @@
+package crawler_test
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+	"crawlengine/storage"
+)
+
+// vectorSearchStorer searches the content vectors of the documents it
+// stored by cosine similarity, like Milvus with the IP metric.
+type vectorSearchStorer struct {
+	*crawltest.MockStorer
+}
+
+func (s *vectorSearchStorer) SearchBatch(ctx context.Context, vectorField string, vectors [][]float32, topK int, expr string) ([][]storage.SearchResult, error) {
+	docs := s.Documents()
+	results := make([][]storage.SearchResult, len(vectors))
+	for i, vector := range vectors {
+		for _, doc := range docs {
+			results[i] = append(results[i], storage.SearchResult{Document: doc, Score: cosine(vector, doc.ContentVector)})
+		}
+		sort.SliceStable(results[i], func(a, b int) bool { return results[i][a].Score > results[i][b].Score })
+		results[i] = results[i][:min(topK, len(results[i]))]
+	}
+	return results, nil
+}
+
+func (s *vectorSearchStorer) Similarity(score float32) float32 {
+	return score
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
+
+func TestSemanticDedup(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></body></html>`),
+		"/a":          crawltest.HTML(article("The election results") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Results of the election, reworded") + `</body></html>`),
+		"/c":          crawltest.HTML(article("The weather") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	// Both election pages embed to the same vector.
+	vector := func(text string) []float32 {
+		switch {
+		case strings.Contains(text, "election"):
+			return []float32{1, 0, 0}
+		case strings.Contains(text, "weather"):
+			return []float32{0, 1, 0}
+		}
+		return []float32{0, 0, 1}
+	}
+
+	tests := []struct {
+		mode       string
+		wantStored []string
+	}{
+		{"skip", []string{"/", "/a", "/c"}},
+		{"mark", []string{"/", "/a", "/b", "/c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n  embed_documents: true\n  semantic_dedup_mode: "+tt.mode+"\n")
+			cfg.SeedURLs = []string{server.URL + "/"}
+			cfg.Deterministic = true
+			storer := &vectorSearchStorer{crawltest.NewMockStorer()}
+			c := crawler.NewCrawler(cfg, storer, &fakeEmbedder{dimension: 3, vector: vector})
+			if err := c.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			var stored []string
+			hashes, duplicates := make(map[string]string), make(map[string]string)
+			for _, doc := range storer.Documents() {
+				path := strings.TrimPrefix(doc.URL, server.URL)
+				stored = append(stored, path)
+				hashes[path], duplicates[path] = doc.HashID, doc.DuplicateOf
+			}
+			slices.Sort(stored)
+			if !slices.Equal(stored, tt.wantStored) {
+				t.Errorf("stored %v, want %v", stored, tt.wantStored)
+			}
+			for path, duplicateOf := range duplicates {
+				want := ""
+				if path == "/b" {
+					want = hashes["/a"]
+				}
+				if duplicateOf != want {
+					t.Errorf("%s duplicate_of = %q, want %q", path, duplicateOf, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+)
+
+// SimHash computes a 64-bit SimHash fingerprint of the given text.
+// Texts that differ only in a small region produce fingerprints with a small
+// Hamming distance, which makes it suitable for near-duplicate detection.
+func SimHash(text string) uint64 {
+	var weights [64]int
+	for _, token := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+// HammingDistance returns the number of differing bits between two fingerprints.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+type simHashEntry struct {
+	fingerprint uint64
+	hashID      string
+}
+
+// simHashIndex keeps the SimHashes seen per host and answers "is there a
+// fingerprint within maxDistance bits" queries.
+// The 64 bits are split into maxDistance+1 bands; by the pigeonhole principle
+// two fingerprints within maxDistance bits agree exactly on at least one band,
+// so only entries sharing a band value need to be compared.
+type simHashIndex struct {
+	mu          sync.Mutex
+	maxDistance int
+	bandWidth   uint
+	bands       int
+	hosts       map[string][]map[uint64][]simHashEntry
+}
+
+func newSimHashIndex(maxDistance int) *simHashIndex {
+	bands := maxDistance + 1
+	if bands > 64 {
+		bands = 64
+	}
+	return &simHashIndex{
+		maxDistance: maxDistance,
+		bandWidth:   uint((64 + bands - 1) / bands),
+		bands:       bands,
+		hosts:       make(map[string][]map[uint64][]simHashEntry),
+	}
+}
+
+func (idx *simHashIndex) bandValue(fingerprint uint64, band int) uint64 {
+	shift := uint(band) * idx.bandWidth
+	if shift >= 64 {
+		return 0
+	}
+	mask := uint64(1)<<idx.bandWidth - 1
+	if idx.bandWidth >= 64 {
+		mask = ^uint64(0)
+	}
+	return (fingerprint >> shift) & mask
+}
+
+// FindOrAdd looks for a near-duplicate of fingerprint among the pages already
+// seen on host. If one is found its hash ID is returned as the cluster
+// representative; otherwise the fingerprint is added as a new representative.
+func (idx *simHashIndex) FindOrAdd(host string, fingerprint uint64, hashID string) (string, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	tables, ok := idx.hosts[host]
+	if !ok {
+		tables = make([]map[uint64][]simHashEntry, idx.bands)
+		for i := range tables {
+			tables[i] = make(map[uint64][]simHashEntry)
+		}
+		idx.hosts[host] = tables
+	}
+
+	for band := 0; band < idx.bands; band++ {
+		for _, entry := range tables[band][idx.bandValue(fingerprint, band)] {
+			if HammingDistance(entry.fingerprint, fingerprint) <= idx.maxDistance {
+				return entry.hashID, true
+			}
+		}
+	}
+
+	entry := simHashEntry{fingerprint: fingerprint, hashID: hashID}
+	for band := 0; band < idx.bands; band++ {
+		key := idx.bandValue(fingerprint, band)
+		tables[band][key] = append(tables[band][key], entry)
+	}
+	return "", false
+}
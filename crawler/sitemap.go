@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxSitemapDepth limits how many levels of nested sitemap indexes are followed.
+const maxSitemapDepth = 3
+
+// SitemapEntry is a page URL listed in a sitemap.
+type SitemapEntry struct {
+	Loc     string
+	LastMod time.Time // zero if the sitemap doesn't provide it
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// SitemapURLsForHost returns the sitemap locations for the host of baseURL:
+// those declared in robots.txt, or /sitemap.xml if there are none.
+func SitemapURLsForHost(ctx context.Context, fetcher *Fetcher, baseURL *url.URL, userAgent string) []string {
+	if robotsData, err := GetRobotsData(ctx, fetcher, baseURL, userAgent); err == nil && len(robotsData.Sitemaps) > 0 {
+		return robotsData.Sitemaps
+	}
+	return []string{baseURL.Scheme + "://" + baseURL.Host + "/sitemap.xml"}
+}
+
+// FetchSitemap fetches and parses the sitemap at sitemapURL, following
+// nested sitemap indexes, and returns the page entries it lists.
+func FetchSitemap(ctx context.Context, fetcher *Fetcher, sitemapURL string, userAgent string) ([]SitemapEntry, error) {
+	return fetchSitemap(ctx, fetcher, sitemapURL, userAgent, 0)
+}
+
+func fetchSitemap(ctx context.Context, fetcher *Fetcher, sitemapURL string, userAgent string, depth int) ([]SitemapEntry, error) {
+	resp, err := fetcher.Fetch(ctx, sitemapURL, userAgent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: %w", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sitemap %s returned status %d", sitemapURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sitemap %s: %w", sitemapURL, err)
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap %s: %w", sitemapURL, err)
+	}
+
+	var entries []SitemapEntry
+	for _, u := range doc.URLs {
+		entry := SitemapEntry{Loc: u.Loc}
+		if u.LastMod != "" {
+			if lastMod, err := ParseDate(u.LastMod); err == nil {
+				entry.LastMod = lastMod
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, nested := range doc.Sitemaps {
+		if depth+1 > maxSitemapDepth {
+			log.Printf("Sitemap index nesting too deep, skipping %s", nested.Loc)
+			continue
+		}
+		nestedEntries, err := fetchSitemap(ctx, fetcher, nested.Loc, userAgent, depth+1)
+		if err != nil {
+			log.Printf("Error reading nested sitemap: %v", err)
+			continue
+		}
+		entries = append(entries, nestedEntries...)
+	}
+	return entries, nil
+}
+
+// seedFromSitemaps queues the sitemap URLs of every seed host at depth 0,
+// skipping entries whose lastmod is before the content cutoff.
+func (c *Crawler) seedFromSitemaps(ctx context.Context, seedURLs []string) {
+	seenHosts := make(map[string]bool)
+	for _, seedURL := range seedURLs {
+		baseURL, err := url.Parse(seedURL)
+		if err != nil || seenHosts[baseURL.Host] {
+			continue
+		}
+		seenHosts[baseURL.Host] = true
+
+		fp := c.fingerprint()
+		queued := 0
+		for _, sitemapURL := range SitemapURLsForHost(ctx, c.fetcher, baseURL, c.robotsAgent) {
+			entries, err := FetchSitemap(withFingerprint(ctx, fp), c.fetcher, sitemapURL, fp.userAgent)
+			if err != nil {
+				log.Printf("Error reading sitemap for %s: %v", baseURL.Host, err)
+				continue
+			}
+			for _, entry := range entries {
+				if !entry.LastMod.IsZero() && c.isOlderThanCutoff(entry.LastMod.Unix()) {
+					c.filteredByDate.Add(1)
+					continue
+				}
+				loc, err := c.normalizer.Canonicalize(entry.Loc)
+				if err == nil {
+					loc = c.rules.dropQueryParams(loc)
+				}
+				if err != nil || c.hasVisited(loc) {
+					continue
+				}
+				if locURL, err := url.Parse(loc); err != nil || c.normalizer.HostKey(locURL.Hostname()) != c.normalizer.HostKey(baseURL.Hostname()) {
+					continue // sitemaps may only list URLs on their own host
+				}
+				if !c.hosts.ReservePage(c.normalizer.HostKey(baseURL.Hostname())) {
+					break
+				}
+				c.markVisited(loc)
+				c.frontier.Seed(CrawlTask{URL: loc, Depth: 0})
+				queued++
+			}
+		}
+		log.Printf("Queued %d URLs from sitemaps of %s", queued, baseURL.Host)
+	}
+}
+
+// isOlderThanCutoff reports whether a Unix timestamp predates the content
+// cutoff. Unknown (zero) timestamps are never filtered.
+func (c *Crawler) isOlderThanCutoff(timestamp int64) bool {
+	return !c.contentCutoff.IsZero() && timestamp != 0 && time.Unix(timestamp, 0).Before(c.contentCutoff)
+}
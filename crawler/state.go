@@ -0,0 +1,147 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Crawl statuses reported in the state file and webhook.
+const (
+	StateRunning   = "running"
+	StateFinished  = "finished"
+	StateCancelled = "cancelled"
+	StateFailed    = "failed" // the crawl could not start
+)
+
+// CrawlState is the crawl progress written to state_file, for dashboards
+// that read it instead of talking to the process.
+type CrawlState struct {
+	Status       string        `json:"status"`
+	StartedAt    time.Time     `json:"started_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	LastActivity time.Time     `json:"last_activity"` // when a worker last finished a task
+	QueueDepth   int           `json:"queue_depth"`
+	Visited      int           `json:"visited"`
+	PagesFetched int64         `json:"pages_fetched"`
+	PagesStored  int64         `json:"pages_stored"`
+	Errors       int64         `json:"errors"`
+	Hosts        int           `json:"hosts"`
+	Summary      *CrawlSummary `json:"summary,omitempty"` // only in the final write
+	Error        string        `json:"error,omitempty"`   // why a failed crawl failed
+}
+
+// CrawlSummary is the end-of-crawl summary included in the final state write.
+type CrawlSummary struct {
+	FinishedAt       time.Time           `json:"finished_at"`
+	DurationSec      float64             `json:"duration_sec"`
+	FilteredByDate   int64               `json:"filtered_by_date"`
+	PagesUnchanged   int64               `json:"pages_unchanged"`
+	SemanticDups     int64               `json:"semantic_duplicates"`
+	BytesDownloaded  int64               `json:"bytes_downloaded"`
+	BytesExceeded    bool                `json:"max_total_bytes_exceeded,omitempty"` // the crawl stopped early
+	PagesPerHost     map[string]int      `json:"pages_per_host"`
+	SecurityContacts map[string][]string `json:"security_contacts,omitempty"`
+}
+
+// crawlState snapshots the crawl's progress.
+func (c *Crawler) crawlState(status string) *CrawlState {
+	return &CrawlState{
+		Status:       status,
+		StartedAt:    c.startedAt,
+		UpdatedAt:    time.Now().UTC(),
+		LastActivity: time.Unix(0, c.lastProgress.Load()).UTC(),
+		QueueDepth:   c.frontier.Len(),
+		Visited:      c.visitedCount(),
+		PagesFetched: c.stats.pagesFetched.Load(),
+		PagesStored:  c.stats.pagesStored.Load(),
+		Errors:       c.stats.errors.Load(),
+		Hosts:        c.hosts.Len(),
+	}
+}
+
+// runStateWriter writes the crawl state to state_file every interval until
+// stop is closed.
+func (c *Crawler) runStateWriter(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.writeState(c.crawlState(StateRunning))
+		}
+	}
+}
+
+// finalState snapshots the crawl's progress with the crawl summary.
+func (c *Crawler) finalState(status string) *CrawlState {
+	state := c.crawlState(status)
+	state.Summary = &CrawlSummary{
+		FinishedAt:       state.UpdatedAt,
+		DurationSec:      state.UpdatedAt.Sub(c.startedAt).Seconds(),
+		FilteredByDate:   c.filteredByDate.Load(),
+		PagesUnchanged:   c.pagesUnchanged.Load(),
+		SemanticDups:     c.semanticDups.Load(),
+		BytesDownloaded:  c.stats.bytes.Load(),
+		BytesExceeded:    c.bytesExceeded.Load(),
+		PagesPerHost:     c.hosts.FetchedPages(),
+		SecurityContacts: c.hostPolicies.Contacts(),
+	}
+	return state
+}
+
+// reportFinal writes the final state to state_file and sends it to
+// webhook_url, whichever are configured.
+func (c *Crawler) reportFinal(ctx context.Context, state *CrawlState) {
+	if c.Config.StateFile != "" {
+		c.writeState(state)
+	}
+	if c.Config.WebhookURL != "" {
+		c.notifyWebhook(ctx, state)
+	}
+}
+
+// startFailed reports a crawl that failed to start and returns err.
+func (c *Crawler) startFailed(ctx context.Context, err error) error {
+	state := c.finalState(StateFailed)
+	state.Error = err.Error()
+	c.reportFinal(ctx, state)
+	return err
+}
+
+// writeState replaces state_file with state. It writes a temporary file in
+// the same directory and renames it, so readers never see a partial file.
+func (c *Crawler) writeState(state *CrawlState) {
+	if err := writeFileAtomic(c.Config.StateFile, state); err != nil {
+		log.Printf("Error writing crawl state: %v", err)
+	}
+}
+
+func writeFileAtomic(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package crawler_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+// readState reads the crawl state written to path.
+func readState(t *testing.T, path string) *crawler.CrawlState {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state crawler.CrawlState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("state file: %v", err)
+	}
+	return &state
+}
+
+func TestStateFileRecordsFinishedCrawl(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  state_file: "+path+"\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	runCrawl(t, cfg)
+
+	state := readState(t, path)
+	if state.Status != crawler.StateFinished {
+		t.Errorf("status = %q, want %q", state.Status, crawler.StateFinished)
+	}
+	if state.Visited != 3 || state.PagesFetched != 3 || state.PagesStored != 3 || state.QueueDepth != 0 {
+		t.Errorf("visited, fetched, stored, queued = %d, %d, %d, %d, want 3, 3, 3, 0",
+			state.Visited, state.PagesFetched, state.PagesStored, state.QueueDepth)
+	}
+	if state.Summary == nil {
+		t.Fatal("final state has no summary")
+	}
+	pages := 0
+	for _, n := range state.Summary.PagesPerHost {
+		pages += n
+	}
+	if pages != 3 {
+		t.Errorf("summary pages per host = %v, want 3 pages", state.Summary.PagesPerHost)
+	}
+}
@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// crawlStats holds counters updated from the crawl path.
+type crawlStats struct {
+	pagesFetched atomic.Int64
+	pagesStored  atomic.Int64
+	errors       atomic.Int64
+	bytes        atomic.Int64   // response body bytes downloaded
+	workerTasks  []atomic.Int64 // tasks processed per worker
+}
+
+func newCrawlStats(workers int) *crawlStats {
+	return &crawlStats{workerTasks: make([]atomic.Int64, workers)}
+}
+
+// runProgressReporter logs throughput every interval until stop is closed.
+func (c *Crawler) runProgressReporter(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastFetched, lastErrors := int64(0), int64(0)
+	lastWorker := make([]int64, len(c.stats.workerTasks))
+	lastTime := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			elapsed := now.Sub(lastTime).Seconds()
+			fetched := c.stats.pagesFetched.Load()
+			errors := c.stats.errors.Load()
+			deltaFetched, deltaErrors := fetched-lastFetched, errors-lastErrors
+
+			errorRate := 0.0
+			if attempts := deltaFetched + deltaErrors; attempts > 0 {
+				errorRate = float64(deltaErrors) / float64(attempts) * 100
+			}
+
+			perWorker := make([]string, len(c.stats.workerTasks))
+			for i := range c.stats.workerTasks {
+				tasks := c.stats.workerTasks[i].Load()
+				perWorker[i] = fmt.Sprintf("w%d=%.2f/s", i, float64(tasks-lastWorker[i])/elapsed)
+				lastWorker[i] = tasks
+			}
+
+			log.Printf("Progress: %.2f pages/s, queue depth %d, visited %d, stored %d, error rate %.1f%% | %s",
+				float64(deltaFetched)/elapsed, c.frontier.Len(), c.visitedCount(), c.stats.pagesStored.Load(), errorRate, strings.Join(perWorker, " "))
+
+			lastFetched, lastErrors, lastTime = fetched, errors, now
+		}
+	}
+}
+
+// logSummary logs the crawl totals and the pages fetched per host, busiest first.
+func (c *Crawler) logSummary() {
+	log.Printf("Crawl summary: %d pages fetched, %d stored, %d errors, %d hosts, %s downloaded",
+		c.stats.pagesFetched.Load(), c.stats.pagesStored.Load(), c.stats.errors.Load(), c.hosts.Len(), formatBytes(c.stats.bytes.Load()))
+	if c.bytesExceeded.Load() {
+		log.Printf("Crawl stopped early: max_total_bytes (%s) exceeded", formatBytes(c.Config.MaxTotalBytes))
+	}
+
+	counts := c.hosts.FetchedPages()
+	hosts := make([]string, 0, len(counts))
+	for host := range counts {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if counts[hosts[i]] != counts[hosts[j]] {
+			return counts[hosts[i]] > counts[hosts[j]]
+		}
+		return hosts[i] < hosts[j]
+	})
+	for _, host := range hosts {
+		capped := ""
+		if c.Config.MaxPagesPerHost > 0 && counts[host] >= c.Config.MaxPagesPerHost {
+			capped = " (capped)"
+		}
+		log.Printf("  %s: %d pages%s", host, counts[host], capped)
+	}
+
+	contacts := c.hostPolicies.Contacts()
+	if len(contacts) > 0 {
+		hosts = hosts[:0]
+		for host := range contacts {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		log.Printf("Security contacts (from security.txt):")
+		for _, host := range hosts {
+			log.Printf("  %s: %s", host, strings.Join(contacts[host], ", "))
+		}
+	}
+}
+
+// recordDownload adds n body bytes to the download total and, once the total
+// exceeds max_total_bytes, stops the crawl by closing the frontier so workers
+// exit after their current page.
+func (c *Crawler) recordDownload(n int) {
+	total := c.stats.bytes.Add(int64(n))
+	if c.Config.MaxTotalBytes <= 0 || total <= c.Config.MaxTotalBytes {
+		return
+	}
+	if c.bytesExceeded.CompareAndSwap(false, true) {
+		log.Printf("Download budget exceeded: %s downloaded, max_total_bytes is %s. Stopping the crawl after in-flight pages finish.",
+			formatBytes(total), formatBytes(c.Config.MaxTotalBytes))
+		c.frontier.Close()
+	}
+}
+
+// formatBytes formats n bytes with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
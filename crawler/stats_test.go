@@ -0,0 +1,99 @@
+package crawler_test
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestMaxTotalBytesStopsCrawl(t *testing.T) {
+	home := article("Home") + `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></body></html>`
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(home),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	// The budget runs out with /a, which is still stored; /b and /c are
+	// never fetched.
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  max_total_bytes: "+strconv.Itoa(len(home)+1)+"\n  state_file: "+statePath+"\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	want := []string{server.URL + "/", server.URL + "/a"}
+	if got := storer.URLs(); !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+	if requests := server.Requests(); slices.Contains(requests, "/b") || slices.Contains(requests, "/c") {
+		t.Errorf("requests = %v, want none after the budget ran out", requests)
+	}
+	if summary := readState(t, statePath).Summary; summary == nil || !summary.BytesExceeded {
+		t.Errorf("summary = %+v, want max_total_bytes_exceeded", summary)
+	}
+}
+
+// syncBuffer is a log output safe to read while workers write to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestMaxPagesPerHostCapsCrawl(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a><a href="/d">d</a></body></html>`),
+		"/a":          crawltest.HTML(article("Page A") + `</body></html>`),
+		"/b":          crawltest.HTML(article("Page B") + `</body></html>`),
+		"/c":          crawltest.HTML(article("Page C") + `</body></html>`),
+		"/d":          crawltest.HTML(article("Page D") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	logs := &syncBuffer{}
+	log.SetOutput(logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  max_pages_per_host: 3\n  state_file: "+statePath+"\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	if got := storer.URLs(); len(got) != 3 {
+		t.Errorf("stored %v, want 3 pages", got)
+	}
+	if want := "reached max_pages_per_host (3)"; strings.Count(logs.String(), want) != 1 {
+		t.Errorf("logs do not contain %q once:\n%s", want, logs)
+	}
+	summary := readState(t, statePath).Summary
+	if summary == nil || len(summary.PagesPerHost) != 1 {
+		t.Fatalf("summary = %+v, want pages of one host", summary)
+	}
+	for host, n := range summary.PagesPerHost {
+		if n != 3 {
+			t.Errorf("summary has %d pages for %s, want 3", n, host)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/storage"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// nearDuplicateOf returns the hash ID of the representative of the
+// near-duplicate cluster that content of the document at docURL falls into
+// on host, or "" if it starts a cluster of its own or near-duplicate
+// detection is off.
+func (c *Crawler) nearDuplicateOf(docURL, host, content, hashID string) string {
+	if c.nearDuplicates == nil || content == "" {
+		return ""
+	}
+	representative, found := c.nearDuplicates.FindOrAdd(host, SimHash(content), hashID)
+	if !found {
+		return ""
+	}
+	log.Printf("Near-duplicate content detected for %s (representative ID: %s)", docURL, representative)
+	return representative
+}
+
+// storable reports whether doc passes the storage gates that pages, feed
+// items, JSON items and PDFs all go through: near_duplicate_mode skip, the
+// content filter, allowed_languages, gated_policy and the publication date
+// cutoff. nearDuplicate is whether doc's content was found to be a near
+// duplicate, and header holds the response headers doc was extracted from.
+// page is the parsed HTML of a page, and nil for other documents, which
+// can't be classified as gated. Gated pages stored under gated_policy mark
+// have doc.Gated set.
+//
+// Callers still follow the links of documents that aren't storable, so
+// matching pages can be reached through non-matching ones.
+func (c *Crawler) storable(doc *storage.WebDocument, nearDuplicate bool, header http.Header, page *goquery.Document, rule *config.ExtractionRule) bool {
+	if nearDuplicate && strings.EqualFold(c.Config.NearDuplicateMode, "skip") {
+		log.Printf("Skipping storage of near-duplicate %s", doc.URL)
+		return false
+	}
+	allowed, reason := c.contentFilter.Allow(doc.MainContent)
+	if !allowed {
+		c.debugf("Skipping storage of %s: content %s", doc.URL, reason)
+		return false
+	}
+	if c.contentFilter != nil {
+		c.debugf("Content filter allows storing %s: content %s", doc.URL, reason)
+	}
+	if allowed, reason := c.languages.Allow(doc.Language, header, doc.MainContent); !allowed {
+		c.debugf("Skipping storage of %s: %s", doc.URL, reason)
+		return false
+	}
+	if page != nil {
+		if gated, reason := c.gated.Classify(page, doc.MainContent, rule); gated {
+			log.Printf("Classified %s as gated: %s", doc.URL, reason)
+			if !c.gated.mark {
+				log.Printf("Skipping storage of gated page %s", doc.URL)
+				return false
+			}
+			doc.Gated = true
+		}
+	}
+	if c.isOlderThanCutoff(doc.PublicationTimestamp) {
+		c.filteredByDate.Add(1)
+		log.Printf("Skipping storage of %s: published %s, before cutoff %s", doc.URL, time.Unix(doc.PublicationTimestamp, 0).UTC().Format(time.RFC3339), c.contentCutoff.Format(time.RFC3339))
+		return false
+	}
+	return true
+}
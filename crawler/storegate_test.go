@@ -0,0 +1,65 @@
+package crawler_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestLanguageGateAppliesToEveryDocumentType(t *testing.T) {
+	withLanguage := func(lang, feed string) string {
+		return strings.Replace(feed, "<channel>", "<channel><language>"+lang+"</language>", 1)
+	}
+	pdf := func(lang, text string) crawltest.Fixture {
+		return crawltest.Fixture{
+			ContentType: "application/pdf",
+			Header:      http.Header{"Content-Language": {lang}},
+			Body:        pdfFile("", pdfText(text), false),
+		}
+	}
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/": crawltest.HTML(article("Home") + `<a href="/en">en</a><a href="/fr">fr</a>` +
+			`<a href="/en.xml">en feed</a><a href="/fr.xml">fr feed</a><a href="/api/items">api</a>` +
+			`<a href="/en.pdf">en pdf</a><a href="/fr.pdf">fr pdf</a></body></html>`),
+		"/en":     page("en", "English page"),
+		"/fr":     page("fr", "Page française"),
+		"/en.xml": {ContentType: "application/rss+xml", Body: withLanguage("en", rssFeed("english"))},
+		"/fr.xml": {ContentType: "application/rss+xml", Body: withLanguage("fr", rssFeed("francais"))},
+		"/en.pdf": pdf("en", "An English report."),
+		"/fr.pdf": pdf("fr", "Un rapport en français."),
+		"/api/items": {ContentType: "application/json", Body: `{"items": [
+			{"url": "/items/en", "lang": "en", "body": "An English item."},
+			{"url": "/items/de", "lang": "de", "body": "Ein deutscher Eintrag."}]}`},
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 1
+  parse_feeds: true
+  allowed_languages: ["en"]
+  content_types:
+    application/pdf: pdf
+  extraction_rules:
+    "127.0.0.1":
+      json:
+        items: items
+        url: url
+        content: body
+        language: lang
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	var got []string
+	for _, url := range storer.URLs() {
+		got = append(got, strings.TrimPrefix(url, server.URL))
+	}
+	slices.Sort(got)
+	want := []string{"/", "/en", "/en.pdf", "/items/en", "/posts/english"}
+	if !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxTableSpan caps colspan/rowspan values so a malformed attribute can't
+// blow up the table grid.
+const maxTableSpan = 100
+
+// ExtractTables serializes every <table> in the document as JSON.
+// The result is an array of tables, each an array of row objects mapping the
+// header text to the cell text. Cells spanning several columns or rows are
+// repeated in every slot they cover. Tables without a header row use
+// "col1", "col2", ... as keys. Returns "" when the document has no tables.
+func ExtractTables(doc *goquery.Document) (string, error) {
+	var tables [][]map[string]string
+	doc.Find("table").Each(func(i int, table *goquery.Selection) {
+		// Skip tables nested in another table's cell; their text is already part of the outer cell.
+		if table.ParentsFiltered("table").Length() > 0 {
+			return
+		}
+		if rows := tableRows(table); len(rows) > 0 {
+			tables = append(tables, rows)
+		}
+	})
+	if len(tables) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(tables)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize tables: %w", err)
+	}
+	return string(data), nil
+}
+
+// tableGrid expands a table into a rectangular grid of cell texts,
+// resolving colspan and rowspan. It also reports whether the first row
+// consists only of header cells.
+func tableGrid(table *goquery.Selection) ([][]string, bool) {
+	var grid [][]string
+	pending := map[int]map[int]string{} // row -> column -> text carried down by rowspan
+	firstRowIsHeader := false
+
+	rows := table.Find("tr").FilterFunction(func(i int, tr *goquery.Selection) bool {
+		return tr.ParentsFiltered("table").First().IsSelection(table)
+	})
+	rows.Each(func(rowIdx int, tr *goquery.Selection) {
+		var row []string
+		carried := pending[rowIdx]
+		col := 0
+		fill := func() {
+			for {
+				text, ok := carried[col]
+				if !ok {
+					return
+				}
+				row = append(row, text)
+				col++
+			}
+		}
+
+		cells := tr.ChildrenFiltered("th, td")
+		if rowIdx == 0 && cells.Length() > 0 && cells.Length() == tr.ChildrenFiltered("th").Length() {
+			firstRowIsHeader = true
+		}
+		cells.Each(func(i int, cell *goquery.Selection) {
+			fill()
+			text := strings.Join(strings.Fields(cell.Text()), " ")
+			colspan := spanAttr(cell, "colspan")
+			rowspan := spanAttr(cell, "rowspan")
+			for c := 0; c < colspan; c++ {
+				row = append(row, text)
+				for r := 1; r < rowspan; r++ {
+					if pending[rowIdx+r] == nil {
+						pending[rowIdx+r] = map[int]string{}
+					}
+					pending[rowIdx+r][col] = text
+				}
+				col++
+			}
+		})
+		fill()
+		delete(pending, rowIdx)
+		grid = append(grid, row)
+	})
+	return grid, firstRowIsHeader
+}
+
+func tableRows(table *goquery.Selection) []map[string]string {
+	grid, hasHeader := tableGrid(table)
+	if len(grid) == 0 {
+		return nil
+	}
+
+	width := 0
+	for _, row := range grid {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	headers := make([]string, width)
+	body := grid
+	if hasHeader {
+		copy(headers, grid[0])
+		body = grid[1:]
+	}
+	seen := map[string]int{}
+	for i := range headers {
+		if headers[i] == "" {
+			headers[i] = "col" + strconv.Itoa(i+1)
+		}
+		// Keep keys unique when headers repeat (e.g. a colspan header).
+		seen[headers[i]]++
+		if n := seen[headers[i]]; n > 1 {
+			headers[i] = headers[i] + "_" + strconv.Itoa(n)
+		}
+	}
+
+	var rows []map[string]string
+	for _, cells := range body {
+		record := make(map[string]string, len(cells))
+		for i, text := range cells {
+			record[headers[i]] = text
+		}
+		rows = append(rows, record)
+	}
+	return rows
+}
+
+func spanAttr(cell *goquery.Selection, name string) int {
+	value, ok := cell.Attr(name)
+	if !ok {
+		return 1
+	}
+	span, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || span < 1 {
+		return 1
+	}
+	if span > maxTableSpan {
+		return maxTableSpan
+	}
+	return span
+}
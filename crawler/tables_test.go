@@ -0,0 +1,135 @@
+package crawler_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"crawlengine/crawler"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type tableRow = map[string]string
+
+func TestExtractTables(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want [][]tableRow
+	}{
+		{
+			name: "header row",
+			html: `<table><tr><th>Name</th><th>Age</th></tr><tr><td>Ann</td><td>31</td></tr><tr><td> Bob
+				Smith </td><td>42</td></tr></table>`,
+			want: [][]tableRow{{{"Name": "Ann", "Age": "31"}, {"Name": "Bob Smith", "Age": "42"}}},
+		},
+		{
+			name: "thead and tbody",
+			html: `<table><thead><tr><th>Name</th><th>Age</th></tr></thead><tbody><tr><td>Ann</td><td>31</td></tr></tbody></table>`,
+			want: [][]tableRow{{{"Name": "Ann", "Age": "31"}}},
+		},
+		{
+			name: "no header row",
+			html: `<table><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></table>`,
+			want: [][]tableRow{{{"col1": "a", "col2": "b"}, {"col1": "c"}}},
+		},
+		{
+			name: "mixed first row is not a header",
+			html: `<table><tr><th>Total</th><td>9</td></tr></table>`,
+			want: [][]tableRow{{{"col1": "Total", "col2": "9"}}},
+		},
+		{
+			name: "colspan header",
+			html: `<table><tr><th>Name</th><th colspan="2">Score</th></tr><tr><td>Ann</td><td>1</td><td>2</td></tr></table>`,
+			want: [][]tableRow{{{"Name": "Ann", "Score": "1", "Score_2": "2"}}},
+		},
+		{
+			name: "colspan cell",
+			html: `<table><tr><th>A</th><th>B</th><th>C</th></tr><tr><td colspan="2">ab</td><td>c</td></tr></table>`,
+			want: [][]tableRow{{{"A": "ab", "B": "ab", "C": "c"}}},
+		},
+		{
+			name: "rowspan cell",
+			html: `<table><tr><th>Year</th><th>Quarter</th></tr><tr><td rowspan="2">2024</td><td>Q1</td></tr><tr><td>Q2</td></tr><tr><td>2025</td><td>Q1</td></tr></table>`,
+			want: [][]tableRow{{{"Year": "2024", "Quarter": "Q1"}, {"Year": "2024", "Quarter": "Q2"}, {"Year": "2025", "Quarter": "Q1"}}},
+		},
+		{
+			name: "rowspan in a middle column",
+			html: `<table><tr><th>A</th><th>B</th><th>C</th></tr><tr><td>1</td><td rowspan="2">x</td><td>2</td></tr><tr><td>3</td><td>4</td></tr></table>`,
+			want: [][]tableRow{{{"A": "1", "B": "x", "C": "2"}, {"A": "3", "B": "x", "C": "4"}}},
+		},
+		{
+			name: "rowspan past the last row",
+			html: `<table><tr><th>A</th></tr><tr><td rowspan="5">x</td></tr></table>`,
+			want: [][]tableRow{{{"A": "x"}}},
+		},
+		{
+			name: "invalid spans count as one",
+			html: `<table><tr><th>A</th><th>B</th></tr><tr><td colspan="0">1</td><td rowspan="-2" colspan="two">2</td></tr></table>`,
+			want: [][]tableRow{{{"A": "1", "B": "2"}}},
+		},
+		{
+			name: "nested table stays in its cell",
+			html: `<table><tr><th>Outer</th></tr><tr><td>before <table><tr><td>inner</td></tr></table></td></tr></table>`,
+			want: [][]tableRow{{{"Outer": "before inner"}}},
+		},
+		{
+			name: "several tables",
+			html: `<table><tr><td>1</td></tr></table><p>text</p><table><tr><td>2</td></tr></table><table></table>`,
+			want: [][]tableRow{{{"col1": "1"}}, {{"col1": "2"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.html + "</body></html>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := crawler.ExtractTables(doc)
+			if err != nil {
+				t.Fatalf("ExtractTables: %v", err)
+			}
+			var got [][]tableRow
+			if err := json.Unmarshal([]byte(data), &got); err != nil {
+				t.Fatalf("unmarshal %q: %v", data, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractTables = %s, want %v", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTablesWithoutTables(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body><p>No tables here.</p></body></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := crawler.ExtractTables(doc); data != "" || err != nil {
+		t.Errorf("ExtractTables = %q, %v; want no tables", data, err)
+	}
+}
+
+func TestExtractTablesCapsSpans(t *testing.T) {
+	html := `<table><tr><td colspan="1000000" rowspan="1000000">x</td></tr><tr><td>y</td></tr></table>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := crawler.ExtractTables(doc)
+	if err != nil {
+		t.Fatalf("ExtractTables: %v", err)
+	}
+	var got [][]tableRow
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0]) != 2 || len(got[0][0]) != 100 {
+		t.Fatalf("want one table of two rows, the first 100 cells wide; got %d tables", len(got))
+	}
+	if second := got[0][1]; len(second) != 101 || second["col100"] != "x" || second["col101"] != "y" {
+		t.Errorf("second tableRow = %v, want x carried down into 100 cells and y after them", second)
+	}
+}
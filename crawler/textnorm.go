@@ -0,0 +1,104 @@
+package crawler
+
+import (
+	"log"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Steps of the text_normalization pipeline, applied in the configured order
+// to main content and titles before hashing and storage.
+const (
+	// TextNormNFC composes characters canonically (NFC).
+	TextNormNFC = "nfc"
+	// TextNormNFKC also folds compatibility characters such as ligatures,
+	// full-width forms and superscripts (NFKC).
+	TextNormNFKC = "nfkc"
+	// TextNormLigatures expands typographic Latin ligatures like "ﬁ".
+	TextNormLigatures = "ligatures"
+	// TextNormQuotes flattens curly quotes and primes to ASCII quotes.
+	TextNormQuotes = "quotes"
+	// TextNormControlChars strips control characters other than tab and newline.
+	TextNormControlChars = "control_chars"
+	// TextNormWhitespace collapses runs of spaces within lines, keeping their
+	// indentation, and limits blank lines to one.
+	TextNormWhitespace = "whitespace"
+)
+
+var (
+	ligatureReplacer = strings.NewReplacer(
+		"ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬅ", "st", "ﬆ", "st",
+	)
+	quoteReplacer = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+		"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	)
+)
+
+var textNormSteps = map[string]func(string) string{
+	TextNormNFC:          norm.NFC.String,
+	TextNormNFKC:         norm.NFKC.String,
+	TextNormLigatures:    ligatureReplacer.Replace,
+	TextNormQuotes:       quoteReplacer.Replace,
+	TextNormControlChars: stripControlChars,
+	TextNormWhitespace:   collapseWhitespace,
+}
+
+// textNormalizer applies normalization steps in order. A nil textNormalizer
+// leaves text unchanged.
+type textNormalizer []func(string) string
+
+// newTextNormalizer builds the pipeline for steps, skipping unknown ones
+// with a warning.
+func newTextNormalizer(steps []string) textNormalizer {
+	var n textNormalizer
+	for _, step := range steps {
+		fn, ok := textNormSteps[strings.ToLower(strings.TrimSpace(step))]
+		if !ok {
+			log.Printf("Warning: Ignoring unsupported text_normalization step '%s'.", step)
+			continue
+		}
+		n = append(n, fn)
+	}
+	return n
+}
+
+// Normalize runs text through every step.
+func (n textNormalizer) Normalize(text string) string {
+	for _, step := range n {
+		text = step(text)
+	}
+	return text
+}
+
+func stripControlChars(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func collapseWhitespace(text string) string {
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	blank := false
+	for _, line := range lines {
+		rest := strings.TrimLeft(line, " \t")
+		indent := line[:len(line)-len(rest)]
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, indent+strings.Join(fields, " "))
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,29 @@
+package crawler
+
+import "testing"
+
+func TestTextNormalizer(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		text  string
+		want  string
+	}{
+		{"none", nil, "“Café”  ﬁne\x00", "“Café”  ﬁne\x00"},
+		{"nfc", []string{"nfc"}, "Cafe\u0301", "Caf\u00e9"},
+		{"nfkc", []string{"NFKC"}, "ﬁne ｆｕｌｌ x²", "fine full x2"},
+		{"ligatures", []string{"ligatures"}, "ﬁne ﬂow ﬃx", "fine flow ffix"},
+		{"quotes", []string{"quotes"}, "“It’s” ‘fine’ 5′", `"It's" 'fine' 5'`},
+		{"control chars", []string{"control_chars"}, "a\x00b\tc\nd\x1b", "ab\tc\nd"},
+		{"whitespace", []string{"whitespace"}, "  a   b \n\n\n\n  c  d\n\t\te  f\n\n", "a b\n\n  c d\n\t\te f"},
+		{"in order", []string{"control_chars", "whitespace"}, "a \x00 b", "a b"},
+		{"unknown steps skipped", []string{"lowercase", "quotes"}, "“A”", `"A"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTextNormalizer(tt.steps).Normalize(tt.text); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
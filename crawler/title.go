@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Title sources for title_sources, tried in the configured order.
+const (
+	TitleSourceTitle   = "title"    // the <title> element
+	TitleSourceOGTitle = "og_title" // <meta property="og:title">
+	TitleSourceH1      = "h1"       // the first <h1>
+	TitleSourceURLSlug = "url_slug" // the last path segment of the canonical URL, else the page URL
+)
+
+var defaultTitleSources = []string{TitleSourceTitle, TitleSourceOGTitle, TitleSourceH1, TitleSourceURLSlug}
+
+// genericTitles are normalized titles (see NormalizeTitle) that say nothing
+// about the page, so the next title source is tried instead.
+var genericTitles = map[string]bool{
+	"home": true, "homepage": true, "home page": true, "index": true, "untitled": true,
+	"untitled document": true, "welcome": true, "default": true, "document": true, "page": true,
+	"홈": true, "메인": true,
+}
+
+// parseTitleSources validates title_sources, skipping unknown entries with a
+// warning. Empty means defaultTitleSources.
+func parseTitleSources(sources []string) []string {
+	if len(sources) == 0 {
+		return defaultTitleSources
+	}
+	var valid []string
+	for _, source := range sources {
+		switch source = strings.ToLower(strings.TrimSpace(source)); source {
+		case TitleSourceTitle, TitleSourceOGTitle, TitleSourceH1, TitleSourceURLSlug:
+			valid = append(valid, source)
+		default:
+			log.Printf("Warning: Ignoring unknown title_sources entry '%s'", source)
+		}
+	}
+	if len(valid) == 0 {
+		log.Printf("Warning: No valid title_sources, using %s.", strings.Join(defaultTitleSources, ", "))
+		return defaultTitleSources
+	}
+	return valid
+}
+
+// resolveTitle returns the first meaningful title among c's title sources,
+// with its whitespace collapsed, and the source it came from. A title is
+// meaningful unless it has no letter or digit, or is generic ("Home") once
+// the site name is stripped. If no source has a meaningful title, the first
+// non-empty one is returned.
+func (c *Crawler) resolveTitle(doc *goquery.Document, pageURL *url.URL, canonicalURL string) (string, string) {
+	siteName, _ := doc.Find("meta[property='og:site_name']").Attr("content")
+	var fallback, fallbackSource string
+	for _, source := range c.titleSources {
+		var title string
+		switch source {
+		case TitleSourceTitle:
+			title = doc.Find("title").First().Text()
+		case TitleSourceOGTitle:
+			title, _ = doc.Find("meta[property='og:title']").Attr("content")
+		case TitleSourceH1:
+			title = doc.Find("h1").First().Text()
+		case TitleSourceURLSlug:
+			slugURL := pageURL
+			if parsed, err := url.Parse(canonicalURL); err == nil && canonicalURL != "" {
+				slugURL = parsed
+			}
+			title = urlSlugTitle(slugURL)
+		}
+		title = c.textNormalizer.Normalize(strings.Join(strings.Fields(title), " "))
+		if title == "" {
+			continue
+		}
+		if meaningfulTitle(title, siteName) {
+			return title, source
+		}
+		if fallback == "" {
+			fallback, fallbackSource = title, source
+		}
+	}
+	return fallback, fallbackSource
+}
+
+func meaningfulTitle(title, siteName string) bool {
+	if strings.IndexFunc(title, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return false
+	}
+	normalized := NormalizeTitle(title, siteName)
+	if genericTitles[normalized] {
+		return false
+	}
+	return siteName == "" || normalized != strings.ToLower(strings.Join(strings.Fields(siteName), " "))
+}
+
+// urlSlugTitle turns the last path segment of u into words, e.g.
+// "/news/how-we-crawl.html" -> "how we crawl". Segments without a letter,
+// such as numeric IDs, and index pages give "".
+func urlSlugTitle(u *url.URL) string {
+	segment := path.Base(strings.TrimRight(u.Path, "/"))
+	if segment == "." || segment == "/" {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		segment = unescaped
+	}
+	switch strings.ToLower(path.Ext(segment)) {
+	case ".html", ".htm", ".php", ".asp", ".aspx", ".jsp":
+		segment = strings.TrimSuffix(segment, path.Ext(segment))
+	}
+	if strings.EqualFold(segment, "index") || strings.IndexFunc(segment, unicode.IsLetter) < 0 {
+		return ""
+	}
+	return strings.Join(strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '+' }), " ")
+}
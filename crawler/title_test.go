@@ -0,0 +1,66 @@
+package crawler_test
+
+import (
+	"strings"
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+)
+
+func TestTitleSources(t *testing.T) {
+	titled := func(head, body string) crawltest.Fixture {
+		return crawltest.HTML(`<html><head>` + head + `</head><body>` + body +
+			`<article><p>This fixture page has enough words in its main content to be stored by the crawler.</p></article></body></html>`)
+	}
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/": titled(`<title>Start</title>`, `<a href="/og">og</a><a href="/generic">generic</a>`+
+			`<a href="/news/how-we-crawl.html">slug</a><a href="/site-name">site</a>`),
+		"/og":                     titled(`<title> </title><meta property="og:title" content="Open  Graph title">`, `<h1>Heading</h1>`),
+		"/generic":                titled(`<title>Home</title>`, `<h1>The real headline</h1>`),
+		"/news/how-we-crawl.html": titled(``, ``),
+		"/site-name":              titled(`<title>Example News</title><meta property="og:site_name" content="Example News">`, `<h1>Story headline</h1>`),
+		"/robots.txt":             crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		sources string
+		want    map[string]string // path -> stored title
+	}{
+		{"default", "", map[string]string{
+			"/":                       "Start",
+			"/og":                     "Open Graph title",
+			"/generic":                "The real headline",
+			"/news/how-we-crawl.html": "how we crawl",
+			"/site-name":              "Story headline",
+		}},
+		{"h1 first, no slug", "  title_sources: [h1, title]\n", map[string]string{
+			"/":                       "Start",
+			"/og":                     "Heading",
+			"/generic":                "The real headline",
+			"/news/how-we-crawl.html": "",
+			"/site-name":              "Story headline",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadCrawlerConfig(t, "  max_depth: 1\n"+tt.sources)
+			cfg.SeedURLs = []string{server.URL + "/"}
+			_, storer := runCrawl(t, cfg)
+
+			got := make(map[string]string)
+			for _, doc := range storer.Documents() {
+				got[strings.TrimPrefix(doc.URL, server.URL)] = doc.Title
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("stored %v, want %d pages", storer.URLs(), len(tt.want))
+			}
+			for path, want := range tt.want {
+				if got[path] != want {
+					t.Errorf("%s title = %q, want %q", path, got[path], want)
+				}
+			}
+		})
+	}
+}
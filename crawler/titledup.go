@@ -0,0 +1,168 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// titleSeparators split a page title from the site name appended to it, as
+// in "Article title | Site" or "Article title - Site".
+var titleSeparators = []string{" | ", " - ", " – ", " — ", " :: ", " · ", " » "}
+
+// maxSiteNameWords is the most words a trailing title segment may have to be
+// taken for a site name when the page doesn't declare one.
+const maxSiteNameWords = 4
+
+// NormalizeTitle lowercases title, collapses its whitespace and strips the
+// site name: siteName (e.g. og:site_name) if the title starts or ends with
+// it next to a separator, otherwise a short trailing segment after the last
+// separator, such as " | Example News".
+func NormalizeTitle(title, siteName string) string {
+	title = strings.ToLower(strings.Join(strings.Fields(title), " "))
+	siteName = strings.ToLower(strings.Join(strings.Fields(siteName), " "))
+	if siteName != "" && siteName != title {
+		for _, sep := range titleSeparators {
+			if rest, ok := strings.CutSuffix(title, sep+siteName); ok {
+				return strings.TrimSpace(rest)
+			}
+			if rest, ok := strings.CutPrefix(title, siteName+sep); ok {
+				return strings.TrimSpace(rest)
+			}
+		}
+	}
+	cut := -1
+	for _, sep := range titleSeparators {
+		if i := strings.LastIndex(title, sep); i > cut {
+			cut = i
+		}
+	}
+	if cut > 0 {
+		head, tail := title[:cut], title[cut:]
+		for _, sep := range titleSeparators {
+			tail = strings.TrimPrefix(tail, sep)
+		}
+		if words := len(strings.Fields(tail)); words <= maxSiteNameWords && words < len(strings.Fields(head)) {
+			title = strings.TrimSpace(head)
+		}
+	}
+	return title
+}
+
+// titleWords returns the distinct words of a normalized title, ordered by a
+// fixed hash so every title is ordered the same way for prefix filtering.
+func titleWords(normalized string) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range strings.FieldsFunc(normalized, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	sort.Slice(words, func(i, j int) bool {
+		hi, hj := wordOrder(words[i]), wordOrder(words[j])
+		if hi != hj {
+			return hi < hj
+		}
+		return words[i] < words[j]
+	})
+	return words
+}
+
+func wordOrder(word string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	return h.Sum64()
+}
+
+// defaultTitleMinSimilarity is used for an out of range title_dedup_min_similarity.
+const defaultTitleMinSimilarity = 0.9
+
+type titleEntry struct {
+	words  []string
+	hashID string
+}
+
+// titleIndex finds pages whose normalized titles share at least
+// minSimilarity of their words (Jaccard similarity) with an earlier page,
+// across all hosts, since syndicated articles keep their title from site to
+// site. Titles with fewer than minWords words, like "Home", are ignored.
+// Each title is indexed under a prefix of its ordered words just long enough
+// that two titles above the threshold always share a prefix word, so only
+// those need comparing.
+type titleIndex struct {
+	mu            sync.Mutex
+	minSimilarity float64
+	minWords      int
+	entries       []titleEntry
+	byPrefix      map[string][]int
+}
+
+func newTitleIndex(minSimilarity float64, minWords int) *titleIndex {
+	if minSimilarity <= 0 || minSimilarity > 1 {
+		log.Printf("Warning: title_dedup_min_similarity %g is not in (0, 1], using %g.", minSimilarity, defaultTitleMinSimilarity)
+		minSimilarity = defaultTitleMinSimilarity
+	}
+	return &titleIndex{
+		minSimilarity: minSimilarity,
+		minWords:      minWords,
+		byPrefix:      make(map[string][]int),
+	}
+}
+
+// prefixLen is how many of a title's n ordered words are indexed.
+func (idx *titleIndex) prefixLen(n int) int {
+	return n - int(math.Ceil(idx.minSimilarity*float64(n))) + 1
+}
+
+// FindOrAdd looks for an earlier title similar to the normalized title. If
+// one is found its hash ID is returned; otherwise the title is added under
+// hashID.
+func (idx *titleIndex) FindOrAdd(normalized, hashID string) (string, bool) {
+	words := titleWords(normalized)
+	if len(words) == 0 || len(words) < idx.minWords {
+		return "", false
+	}
+	prefix := words[:idx.prefixLen(len(words))]
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	compared := make(map[int]bool)
+	for _, word := range prefix {
+		for _, i := range idx.byPrefix[word] {
+			if compared[i] {
+				continue
+			}
+			compared[i] = true
+			if jaccard(words, idx.entries[i].words) >= idx.minSimilarity {
+				return idx.entries[i].hashID, true
+			}
+		}
+	}
+
+	idx.entries = append(idx.entries, titleEntry{words: words, hashID: hashID})
+	for _, word := range prefix {
+		idx.byPrefix[word] = append(idx.byPrefix[word], len(idx.entries)-1)
+	}
+	return "", false
+}
+
+// jaccard returns the Jaccard similarity of two sets of distinct words.
+func jaccard(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, word := range a {
+		set[word] = true
+	}
+	shared := 0
+	for _, word := range b {
+		if set[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
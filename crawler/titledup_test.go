@@ -0,0 +1,63 @@
+package crawler_test
+
+import (
+	"testing"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title, siteName, want string
+	}{
+		{"Markets  Rally as Inflation Cools | Example News", "", "markets rally as inflation cools"},
+		{"Example News - Markets rally as inflation cools", "Example News", "markets rally as inflation cools"},
+		{"Markets rally as inflation cools – The Daily Example Times", "", "markets rally as inflation cools"},
+		{"Markets rally as inflation cools » a very long trailing segment here", "", "markets rally as inflation cools » a very long trailing segment here"},
+		{"Home | Example", "", "home | example"},
+		{"Example News", "Example News", "example news"},
+	}
+	for _, tt := range tests {
+		if got := crawler.NormalizeTitle(tt.title, tt.siteName); got != tt.want {
+			t.Errorf("NormalizeTitle(%q, %q) = %q, want %q", tt.title, tt.siteName, got, tt.want)
+		}
+	}
+}
+
+func TestTitleDedupMarksDuplicates(t *testing.T) {
+	page := func(title, content, links string) crawltest.Fixture {
+		return crawltest.HTML(`<html><head><title>` + title + `</title></head><body><article><p>` + content +
+			` is a fixture page with enough words in its main content to be stored by the crawler.</p></article>` + links + `</body></html>`)
+	}
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           page("Home", "Home", `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a>`),
+		"/a":          page("Markets rally as inflation cools | Example News", "The first report", ""),
+		"/b":          page("Markets rally as inflation cools - Other Wire", "The second report", ""),
+		"/c":          page("Markets fall as inflation rises | Example News", "The third report", ""),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  title_dedup: true\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	_, storer := runCrawl(t, cfg)
+
+	docs := make(map[string]string)
+	hashes := make(map[string]string)
+	for _, doc := range storer.Documents() {
+		docs[doc.URL] = doc.DuplicateOf
+		hashes[doc.URL] = doc.HashID
+	}
+	if len(docs) != 4 {
+		t.Fatalf("stored %v, want all 4 pages", storer.URLs())
+	}
+	if got, want := docs[server.URL+"/b"], hashes[server.URL+"/a"]; got != want {
+		t.Errorf("/b duplicate_of = %q, want /a's ID %q", got, want)
+	}
+	for _, path := range []string{"/", "/a", "/c"} {
+		if got := docs[server.URL+path]; got != "" {
+			t.Errorf("%s duplicate_of = %q, want none", path, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"context"
+
+	"crawlengine/storage"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the crawler's spans; it is a no-op unless tracing is configured.
+var tracer = otel.Tracer("crawlengine/crawler")
+
+// storeDocument stores doc and reports the result to done. With
+// embed_concurrency the document is queued for the embedding workers, which
+// call done once it is stored; otherwise done is called before returning.
+// done is not called for a document semantic_dedup_mode skips. The queued
+// document outlives ctx's cancellation so shutdown drains the queue rather
+// than dropping it.
+func (c *Crawler) storeDocument(ctx context.Context, doc *storage.WebDocument, done func(error)) {
+	if c.embedQueue != nil {
+		c.embedQueue <- embedJob{ctx: context.WithoutCancel(ctx), doc: doc, done: done}
+		return
+	}
+	if c.Config.EmbedDocuments {
+		docs := []*storage.WebDocument{doc}
+		c.embedDocuments(ctx, docs)
+		if c.findSemanticDuplicates(ctx, docs)[0] {
+			return
+		}
+	}
+	done(c.store(ctx, doc))
+}
+
+// store stores doc in a store span.
+func (c *Crawler) store(ctx context.Context, doc *storage.WebDocument) error {
+	ctx, span := tracer.Start(ctx, "crawler.store", trace.WithAttributes(attribute.String("hash_id", doc.HashID)))
+	err := c.Storer.StoreDocument(ctx, doc)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
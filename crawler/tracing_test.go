@@ -0,0 +1,59 @@
+package crawler_test
+
+import (
+	"testing"
+
+	"crawlengine/crawler/crawltest"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCrawlPageSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	cfg := loadCrawlerConfig(t, "  max_depth: 0\n")
+	cfg.SeedURLs = []string{server.URL + "/"}
+	runCrawl(t, cfg)
+
+	spans := recorder.Ended()
+	var page sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.Name() == "crawler.crawlPage" {
+			page = span
+		}
+	}
+	if page == nil {
+		t.Fatalf("no crawler.crawlPage span among %d spans", len(spans))
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range page.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["url"].AsString() != server.URL+"/" || attrs["status"].AsInt64() != 200 || attrs["bytes"].AsInt64() == 0 {
+		t.Errorf("crawlPage attributes = %v, want the url, status 200 and the body size", page.Attributes())
+	}
+
+	children := make(map[string]bool)
+	for _, span := range spans {
+		if span.Parent().SpanID() == page.SpanContext().SpanID() {
+			children[span.Name()] = true
+		}
+	}
+	for _, name := range []string{"crawler.fetch", "crawler.extract", "crawler.store"} {
+		if !children[name] {
+			t.Errorf("crawlPage has no %s child span; children: %v", name, children)
+		}
+	}
+}
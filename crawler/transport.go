@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"crawlengine/config"
+)
+
+// HTTP protocol settings for http_protocol.
+const (
+	// ProtocolAuto negotiates HTTP/2 over TLS when the server offers it, as Go does by default.
+	ProtocolAuto = "auto"
+	// ProtocolHTTP1 only speaks HTTP/1.1, working around broken HTTP/2 servers.
+	ProtocolHTTP1 = "http1"
+	// ProtocolHTTP2 requires HTTP/2 over TLS; servers that don't offer it fail.
+	ProtocolHTTP2 = "http2"
+)
+
+// newTransport builds the transport shared by page and robots.txt fetches.
+// Hosts listed in force_http1_hosts are sent through an HTTP/1.1-only copy.
+// Connections it opens are counted in conns.
+func newTransport(cfg *config.CrawlerConfig, conns *connCounter) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	resolver := newCachingResolver(cfg.DNSServers, cfg.DNSCacheSize, time.Duration(cfg.DNSCacheTTLSec)*time.Second)
+	transport.DialContext = conns.Dial(resolver.DialContext(newLocalAddrDialer(cfg, dialer)))
+	if cfg.MaxConnsPerHost > 0 {
+		// Keep as many idle as may be open, so busy hosts reuse connections
+		// instead of closing all but the default two after each burst.
+		transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+		transport.MaxIdleConnsPerHost = cfg.MaxConnsPerHost
+	}
+
+	switch protocol := strings.ToLower(cfg.HTTPProtocol); protocol {
+	case "", ProtocolAuto:
+	case ProtocolHTTP1, ProtocolHTTP2:
+		transport.Protocols = protocols(protocol)
+		log.Printf("Using HTTP protocol %s for all hosts", protocol)
+	default:
+		log.Printf("Warning: Unsupported http_protocol '%s', defaulting to %s.", cfg.HTTPProtocol, ProtocolAuto)
+	}
+
+	if len(cfg.ForceHTTP1Hosts) == 0 {
+		return transport
+	}
+	http1 := transport.Clone()
+	http1.Protocols = protocols(ProtocolHTTP1)
+	hosts := make(map[string]bool, len(cfg.ForceHTTP1Hosts))
+	for _, host := range cfg.ForceHTTP1Hosts {
+		hosts[strings.ToLower(host)] = true
+	}
+	log.Printf("Forcing HTTP/1.1 for hosts: %s", strings.Join(cfg.ForceHTTP1Hosts, ", "))
+	return &hostRoutingTransport{defaultTransport: transport, http1: http1, http1Hosts: hosts}
+}
+
+func protocols(protocol string) *http.Protocols {
+	p := new(http.Protocols)
+	if protocol == ProtocolHTTP2 {
+		p.SetHTTP2(true)
+	} else {
+		p.SetHTTP1(true)
+	}
+	return p
+}
+
+// hostRoutingTransport sends requests for http1Hosts through an
+// HTTP/1.1-only transport and everything else through defaultTransport.
+type hostRoutingTransport struct {
+	defaultTransport *http.Transport
+	http1            *http.Transport
+	http1Hosts       map[string]bool
+}
+
+func (t *hostRoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.http1Hosts[strings.ToLower(req.URL.Hostname())] {
+		return t.http1.RoundTrip(req)
+	}
+	return t.defaultTransport.RoundTrip(req)
+}
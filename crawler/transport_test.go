@@ -0,0 +1,51 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+)
+
+func TestTransportMaxConnsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	conns := &connCounter{}
+	client := &http.Client{Transport: newTransport(&config.CrawlerConfig{MaxConnsPerHost: 2}, conns)}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if maxActive != 2 {
+		t.Errorf("%d requests were served at once, want max_conns_per_host 2", maxActive)
+	}
+	if open := conns.open.Load(); open > 2 {
+		t.Errorf("%d connections open, want at most 2 kept idle", open)
+	}
+}
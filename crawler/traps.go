@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"crawlengine/config"
+)
+
+var digitRunRegex = regexp.MustCompile(`[0-9]+`)
+
+// trapDetector spots crawler traps such as calendars with endless next-month
+// links or faceted search with unbounded filter combinations. It uses three
+// heuristics, each disabled by a zero setting:
+//
+//   - URL patterns: URLs are reduced to host + path with digit runs replaced
+//     by "N" + sorted query parameter names, so /cal?y=2024&m=5 and
+//     /cal?y=2031&m=12 share a pattern. Once a pattern has been queued
+//     trap_max_urls_per_pattern times, further URLs matching it are skipped.
+//   - Query parameters: URLs with more than trap_max_query_params parameters
+//     are skipped.
+//   - Useful ratio: after trap_min_fetches pages from a host, if fewer than
+//     trap_min_useful_ratio of them had new content (non-empty, not an exact
+//     or near duplicate of an earlier page on the host), no more links to the
+//     host are queued.
+type trapDetector struct {
+	maxPerPattern  int
+	maxQueryParams int
+	minFetches     int
+	minUsefulRatio float64
+
+	mu       sync.Mutex
+	patterns map[string]int
+	hosts    map[string]*hostYield
+}
+
+type hostYield struct {
+	fetched, useful int
+	hashes          map[string]bool
+	trapped         bool
+}
+
+func newTrapDetector(cfg *config.CrawlerConfig) *trapDetector {
+	return &trapDetector{
+		maxPerPattern:  cfg.TrapMaxURLsPerPattern,
+		maxQueryParams: cfg.TrapMaxQueryParams,
+		minFetches:     cfg.TrapMinFetches,
+		minUsefulRatio: cfg.TrapMinUsefulRatio,
+		patterns:       make(map[string]int),
+		hosts:          make(map[string]*hostYield),
+	}
+}
+
+// URLPattern returns the trap pattern key for u.
+func URLPattern(u *url.URL) string {
+	names := make([]string, 0, len(u.Query()))
+	for name := range u.Query() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return u.Hostname() + digitRunRegex.ReplaceAllString(u.EscapedPath(), "N") + "?" + strings.Join(names, "&")
+}
+
+// Admit reports whether u may be queued. If not, the returned reason says
+// which heuristic flagged it. Admitted URLs count toward their pattern.
+func (t *trapDetector) Admit(u *url.URL) (bool, string) {
+	if t.maxQueryParams > 0 {
+		if n := len(u.Query()); n > t.maxQueryParams {
+			return false, fmt.Sprintf("%d query parameters exceed limit %d", n, t.maxQueryParams)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if yield := t.hosts[u.Hostname()]; yield != nil && yield.trapped {
+		return false, fmt.Sprintf("host %s yields too little new content (%d useful of %d fetched)", u.Hostname(), yield.useful, yield.fetched)
+	}
+	if t.maxPerPattern > 0 {
+		pattern := URLPattern(u)
+		count := t.patterns[pattern]
+		if count >= t.maxPerPattern {
+			return false, fmt.Sprintf("URL pattern %s already queued %d times", pattern, count)
+		}
+		t.patterns[pattern] = count + 1
+		if count+1 == t.maxPerPattern {
+			log.Printf("Suspected crawler trap: URL pattern %s reached %d URLs, skipping further matches", pattern, t.maxPerPattern)
+		}
+	}
+	return true, ""
+}
+
+// RecordPage records a fetched page on host. contentHash identifies its main
+// content; nearDuplicate is set when it was found to be a near duplicate.
+func (t *trapDetector) RecordPage(host, contentHash string, empty, nearDuplicate bool) {
+	if t.minFetches <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	yield := t.hosts[host]
+	if yield == nil {
+		yield = &hostYield{hashes: make(map[string]bool)}
+		t.hosts[host] = yield
+	}
+	yield.fetched++
+	if !empty && !nearDuplicate && !yield.hashes[contentHash] {
+		yield.useful++
+	}
+	yield.hashes[contentHash] = true
+
+	if !yield.trapped && yield.fetched >= t.minFetches && float64(yield.useful)/float64(yield.fetched) < t.minUsefulRatio {
+		yield.trapped = true
+		log.Printf("Suspected crawler trap: host %s produced new content on only %d of %d pages, no longer queueing its links", host, yield.useful, yield.fetched)
+	}
+}
@@ -0,0 +1,107 @@
+package crawler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// chainServer serves a chain of pages, each linking to the next, and
+// records the headers of the page requests in request order.
+func chainServer(t *testing.T, pages int) (*httptest.Server, func() []http.Header) {
+	t.Helper()
+	var mu sync.Mutex
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nAllow: /\n")
+			return
+		}
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		body := article("Page " + strconv.Itoa(n))
+		if n+1 < pages {
+			body += fmt.Sprintf(`<a href="/%d">next</a>`, n+1)
+		}
+		fmt.Fprint(w, body+`</body></html>`)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]http.Header(nil), headers...)
+	}
+}
+
+// userAgents returns the User-Agent of each of headers.
+func userAgents(headers []http.Header) []string {
+	agents := make([]string, len(headers))
+	for i, header := range headers {
+		agents[i] = header.Get("User-Agent")
+	}
+	return agents
+}
+
+func TestUserAgentsAreWeighted(t *testing.T) {
+	server, headers := chainServer(t, 100)
+	cfg := loadCrawlerConfig(t, `  max_depth: 100
+  user_agents:
+    - light-agent
+    - agent: heavy-agent
+      weight: 4
+    - agent: disabled-agent
+      weight: 0
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	runCrawl(t, cfg)
+
+	got := make(map[string]int)
+	for _, agent := range userAgents(headers()) {
+		got[agent]++
+	}
+	if got["light-agent"]+got["heavy-agent"] != 100 {
+		t.Fatalf("agents = %v, want every page fetched with a configured agent", got)
+	}
+	if got["heavy-agent"] < 2*got["light-agent"] {
+		t.Errorf("agents = %v, want heavy-agent picked about four times as often", got)
+	}
+}
+
+func TestUserAgentSequenceIsSeeded(t *testing.T) {
+	crawl := func() []string {
+		server, headers := chainServer(t, 20)
+		cfg := loadCrawlerConfig(t, `  max_depth: 20
+  deterministic_seed: 7
+  user_agents: [agent-a, agent-b, agent-c]
+`)
+		cfg.SeedURLs = []string{server.URL + "/"}
+		runCrawl(t, cfg)
+		return userAgents(headers())
+	}
+	first, second := crawl(), crawl()
+	if !slices.Equal(first, second) {
+		t.Errorf("agents = %v, then %v with the same seed", first, second)
+	}
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	server, headers := chainServer(t, 2)
+
+	// Agents with a zero weight are ignored, leaving only the default.
+	cfg := loadCrawlerConfig(t, "  max_depth: 1\n  user_agents:\n    - agent: \"Ignored/1.0\"\n      weight: 0\n")
+	cfg.SeedURLs = []string{server.URL + "/0"}
+	runCrawl(t, cfg)
+
+	got := userAgents(headers())
+	if len(got) != 2 || got[0] != got[1] || !strings.HasPrefix(got[0], "GoCrawler/") {
+		t.Errorf("user agents = %q, want the default user agent for both pages", got)
+	}
+}
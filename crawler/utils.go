@@ -1,9 +1,10 @@
 package crawler
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
-	"math/rand"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -13,27 +14,24 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-// GenerateContentHash creates a SHA256 hash for the given content.
-func GenerateContentHash(content string) string {
+// GenerateContentHash creates a SHA256 hash for the given content, salted with
+// the extraction version. Bumping the version gives every page a new ID, so
+// content stored by older extraction logic no longer counts as already seen.
+// An empty version yields the same hash as before versioning was added.
+func GenerateContentHash(content, extractionVersion string) string {
 	h := sha256.New()
+	if extractionVersion != "" {
+		h.Write([]byte(extractionVersion))
+		h.Write([]byte{0})
+	}
 	h.Write([]byte(content))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-// GetRandomUserAgent selects a random user agent from the provided list.
-func GetRandomUserAgent(userAgents []string) string {
-	if len(userAgents) == 0 {
-		return "GoCrawler/1.0 (+http://example.com/bot)" // Default user agent
-	}
-	rand.New(rand.NewSource(time.Now().UnixNano()))
-	return userAgents[rand.Intn(len(userAgents))]
-}
-
-// IsAdLink checks if a URL matches any of the ad link patterns.
-func IsAdLink(link string, adPatterns []string) bool {
+// IsAdLink checks if a URL matches any of the precompiled ad link patterns.
+func IsAdLink(link string, adPatterns []*regexp.Regexp) bool {
 	for _, pattern := range adPatterns {
-		match, _ := regexp.MatchString(pattern, link)
-		if match {
+		if pattern.MatchString(link) {
 			return true
 		}
 	}
@@ -50,67 +48,165 @@ func IsExcludedDomain(linkURL *url.URL, excludedDomains []string) bool {
 	return false
 }
 
+var (
+	multiSpaceRegex   = regexp.MustCompile(`\s{2,}`)
+	multiNewlineRegex = regexp.MustCompile(`\n{3,}`)
+)
+
+// MainContentBlocks returns the elements considered main content: matches of
+// contentTags, or, when none match, substantial blocks from common semantic
+// tags (with script, style and navigation elements removed). The second
+// result reports whether the fallback selection was used.
+func MainContentBlocks(doc *goquery.Document, contentTags []string) ([]*goquery.Selection, bool) {
+	var blocks []*goquery.Selection
+	for _, tagSelector := range contentTags {
+		doc.Find(tagSelector).Each(func(i int, s *goquery.Selection) {
+			blocks = append(blocks, s)
+		})
+	}
+	if len(blocks) > 0 {
+		return blocks, false
+	}
+
+	// Fallback or alternative: extract text from common semantic tags
+	doc.Find("article, main, section, p, h1, h2, h3").Each(func(i int, s *goquery.Selection) {
+		// Avoid script and style tags if they are nested within these
+		s.Find("script, style, nav, footer, aside, .adsbygoogle").Remove()
+		if len(strings.TrimSpace(s.Text())) > 50 { // Heuristic: only consider somewhat substantial text blocks
+			blocks = append(blocks, s)
+		}
+	})
+	return blocks, true
+}
+
 // ExtractMainContent attempts to extract the main textual content from HTML.
 // This is a simplistic approach; more sophisticated libraries like go-readability might be better.
 func ExtractMainContent(doc *goquery.Document, contentTags []string) string {
 	var contentBuilder strings.Builder
 
-	if len(contentTags) > 0 {
-		for _, tagSelector := range contentTags {
-			doc.Find(tagSelector).Each(func(i int, s *goquery.Selection) {
-				contentBuilder.WriteString(s.Text())
-				contentBuilder.WriteString("\n")
-			})
+	blocks, fallback := MainContentBlocks(doc, contentTags)
+	for _, s := range blocks {
+		if fallback {
+			contentBuilder.WriteString(strings.TrimSpace(s.Text()))
+			contentBuilder.WriteString("\n\n")
+		} else {
+			contentBuilder.WriteString(s.Text())
+			contentBuilder.WriteString("\n")
 		}
 	}
 
-	// Fallback or alternative: extract text from common semantic tags
-	if contentBuilder.Len() == 0 {
-		doc.Find("article, main, section, p, h1, h2, h3").Each(func(i int, s *goquery.Selection) {
-			// Avoid script and style tags if they are nested within these
-			s.Find("script, style, nav, footer, aside, .adsbygoogle").Remove()
-			text := strings.TrimSpace(s.Text())
-			if len(text) > 50 { // Heuristic: only consider somewhat substantial text blocks
-				contentBuilder.WriteString(text)
-				contentBuilder.WriteString("\n\n")
-			}
-		})
-	}
-
 	// Basic cleaning: remove excessive newlines and whitespace
-	cleanedContent := regexp.MustCompile(`\s{2,}`).ReplaceAllString(contentBuilder.String(), " ")
-	cleanedContent = regexp.MustCompile(`\n{3,}`).ReplaceAllString(cleanedContent, "\n\n")
+	cleanedContent := multiSpaceRegex.ReplaceAllString(contentBuilder.String(), " ")
+	cleanedContent = multiNewlineRegex.ReplaceAllString(cleanedContent, "\n\n")
 	return strings.TrimSpace(cleanedContent)
 }
 
-// FetchPage fetches the content of a URL.
-func FetchPage(targetURL string, userAgent string) (*http.Response, error) {
-	client := &http.Client{
-		Timeout: 15 * time.Second,
+// ExtractMainContentMarkdown extracts the same main content as
+// ExtractMainContent but renders it as Markdown, keeping headings, lists,
+// links and emphasis. Relative links are resolved against base.
+func ExtractMainContentMarkdown(doc *goquery.Document, contentTags []string, base *url.URL) string {
+	var contentBuilder strings.Builder
+	blocks, _ := MainContentBlocks(doc, contentTags)
+	for _, s := range blocks {
+		contentBuilder.WriteString(RenderMarkdown(s, base))
+		contentBuilder.WriteString("\n\n")
+	}
+	return strings.TrimSpace(multiNewlineRegex.ReplaceAllString(contentBuilder.String(), "\n\n"))
+}
+
+// maxRedirects is the number of redirects an HTTP client follows before
+// giving up, unless max_redirects sets another limit.
+const maxRedirects = 5
+
+// NewHTTPClient builds an http.Client for page fetches on top of transport.
+// When followRedirects is false, 3xx responses are returned to the caller unfollowed.
+func NewHTTPClient(followRedirects bool, transport http.RoundTripper) *http.Client {
+	return newHTTPClient(followRedirects, maxRedirects, transport)
+}
+
+// newHTTPClient is NewHTTPClient following at most limit redirects, or
+// maxRedirects if limit isn't positive. Redirects the check of the request
+// context rejects aren't followed either.
+func newHTTPClient(followRedirects bool, limit int, transport http.RoundTripper) *http.Client {
+	if limit <= 0 {
+		limit = maxRedirects
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   15 * time.Second,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 5 { // Limit redirects
+			if !followRedirects || len(via) > limit || !redirectAllowed(req) { // via holds one request per redirect, this one included
 				return http.ErrUseLastResponse
 			}
 			return nil
 		},
 	}
-	req, err := http.NewRequest("GET", targetURL, nil)
+}
+
+// Fetcher fetches the robots.txt files, sitemaps and security.txt files of
+// a crawler with its client, under the global_rate_limit it shares with the
+// crawler's page fetches, and holds the crawler's robots.txt cache and
+// robots_failure_policy. A nil *Fetcher uses a default client without a
+// rate limit.
+type Fetcher struct {
+	client  *http.Client
+	limiter *rateLimiter
+	robots  *robotsState
+}
+
+// defaultFetcher is used in place of a nil *Fetcher.
+var defaultFetcher = &Fetcher{client: NewHTTPClient(true, http.DefaultTransport), robots: newRobotsState()}
+
+// NewFetcher creates a Fetcher that sends requests with client, at most
+// requestsPerSecond of them in bursts of up to burst. A requestsPerSecond of
+// 0 is unlimited.
+func NewFetcher(client *http.Client, requestsPerSecond float64, burst int) *Fetcher {
+	return &Fetcher{client: client, limiter: newRateLimiter(requestsPerSecond, burst), robots: newRobotsState()}
+}
+
+// Fetch fetches the content of a URL.
+func (f *Fetcher) Fetch(ctx context.Context, targetURL string, userAgent string) (*http.Response, error) {
+	if f == nil {
+		f = defaultFetcher
+	}
+	return fetchWithClient(ctx, f.client, f.limiter, targetURL, userAgent)
+}
+
+// fetchWithClient fetches targetURL with client once limiter, the global
+// rate limit, allows it.
+func fetchWithClient(ctx context.Context, client *http.Client, limiter *rateLimiter, targetURL string, userAgent string) (*http.Response, error) {
+	if err := limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("global rate limiter wait aborted: %w", err)
+	}
+	method, body := "GET", io.Reader(nil)
+	taskRequest := taskRequestFromContext(ctx)
+	if taskRequest != nil {
+		method = taskRequest.Method
+		if taskRequest.Body != "" {
+			body = strings.NewReader(taskRequest.Body)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
 	if err != nil {
 		return nil, err
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", taskRequest.ContentType)
+	}
 	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+	req.Header.Set("Accept", acceptFromContext(ctx))
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5") // You might want to make this configurable or detect
+	fingerprintFromContext(ctx).apply(req.Header)
+	applyRuleHeaders(ctx, req.Header)
 
 	return client.Do(req)
 }
 
-// NormalizeURL resolves a relative URL against a base URL.
+// NormalizeURL resolves a relative URL against a base URL and returns it in
+// canonical form (see urlNormalizer) with the default trailing-slash policy.
+// A "www." prefix is always kept: treat_www_as_same only affects the keys the
+// crawler tracks URLs and hosts under, not the URLs themselves, and the same
+// holds for case_insensitive_paths.
 func NormalizeURL(base *url.URL, relativePath string) (string, error) {
-	relURL, err := url.Parse(relativePath)
-	if err != nil {
-		return "", err
-	}
-	absURL := base.ResolveReference(relURL)
-	return absURL.String(), nil
+	return defaultNormalizer.Normalize(base, relativePath)
 }
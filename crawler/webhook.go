@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds each webhook delivery attempt.
+const webhookTimeout = 10 * time.Second
+
+// notifyWebhook POSTs state as JSON to webhook_url, retrying failed
+// deliveries with exponential backoff up to webhook_max_retries times.
+// Delivery ignores cancellation of ctx, since a cancelled crawl is reported
+// too.
+func (c *Crawler) notifyWebhook(ctx context.Context, state *CrawlState) {
+	body, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Error encoding crawl webhook payload: %v", err)
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	backoff := time.Duration(c.Config.WebhookRetryBackoffMs) * time.Millisecond
+	client := &http.Client{Timeout: webhookTimeout}
+
+	for attempt := 0; ; attempt++ {
+		retryable, err := c.postWebhook(ctx, client, body)
+		if err == nil {
+			log.Printf("Sent crawl %s notification to webhook %s", state.Status, c.Config.WebhookURL)
+			return
+		}
+		if !retryable || attempt >= c.Config.WebhookMaxRetries {
+			log.Printf("Error sending crawl webhook after %d attempts: %v", attempt+1, err)
+			return
+		}
+		log.Printf("Warning: Crawl webhook failed (attempt %d/%d), retrying in %s: %v", attempt+1, c.Config.WebhookMaxRetries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postWebhook makes one delivery attempt. Network errors, 429 and 5xx
+// responses are retryable; other non-2xx responses are not.
+func (c *Crawler) postWebhook(ctx context.Context, client *http.Client, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.Config.WebhookHeaders {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // lets the connection be reused
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("webhook %s responded with %s", c.Config.WebhookURL, resp.Status)
+}
@@ -0,0 +1,97 @@
+package crawler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"crawlengine/crawler"
+	"crawlengine/crawler/crawltest"
+)
+
+// webhookReceiver records the crawl states posted to it, failing the first
+// failures deliveries with a 503.
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	attempts int
+	states   []crawler.CrawlState
+	headers  []http.Header
+}
+
+func newWebhookReceiver(t *testing.T, failures int) *webhookReceiver {
+	t.Helper()
+	r := &webhookReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.attempts++
+		if r.attempts <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var state crawler.CrawlState
+		if err := json.NewDecoder(req.Body).Decode(&state); err != nil {
+			t.Errorf("webhook payload: %v", err)
+		}
+		r.states = append(r.states, state)
+		r.headers = append(r.headers, req.Header.Clone())
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestWebhookReceivesFinalState(t *testing.T) {
+	server := crawltest.NewFixtureServer(map[string]crawltest.Fixture{
+		"/":           crawltest.HTML(article("Home") + `</body></html>`),
+		"/robots.txt": crawltest.Text("User-agent: *\nAllow: /\n"),
+	})
+	defer server.Close()
+	hook := newWebhookReceiver(t, 1)
+
+	cfg := loadCrawlerConfig(t, `  max_depth: 0
+  webhook_url: `+hook.URL+`
+  webhook_headers:
+    Authorization: Bearer secret
+  webhook_retry_backoff_ms: 1
+`)
+	cfg.SeedURLs = []string{server.URL + "/"}
+	runCrawl(t, cfg)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.attempts != 2 || len(hook.states) != 1 {
+		t.Fatalf("%d attempts delivered %d states, want the failed delivery retried once", hook.attempts, len(hook.states))
+	}
+	state := hook.states[0]
+	if state.Status != crawler.StateFinished || state.PagesStored != 1 || state.Summary == nil {
+		t.Errorf("state = %+v, want a finished crawl with 1 page stored and a summary", state)
+	}
+	if got := hook.headers[0].Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+}
+
+func TestWebhookReportsFailedStart(t *testing.T) {
+	hook := newWebhookReceiver(t, 0)
+
+	cfg := loadCrawlerConfig(t, "  webhook_url: "+hook.URL+"\n")
+	cfg.SeedURLs = []string{"not a url"}
+	c := crawler.NewCrawler(cfg, crawltest.NewMockStorer(), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); err == nil {
+		t.Fatal("Start succeeded without valid seeds")
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.states) != 1 || hook.states[0].Status != crawler.StateFailed || hook.states[0].Error == "" {
+		t.Errorf("states = %+v, want one failed state with its error", hook.states)
+	}
+}
@@ -1,22 +1,91 @@
 package embedder
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"crawlengine/config"
 )
 
 type TextEmbedder interface {
 	Embed(ctx context.Context, text string) ([]float32, error)
+	// EmbedBatch embeds texts, returning their vectors in the same order.
+	// When only some texts fail it returns the other vectors along with a
+	// *BatchError; any other error means the whole batch failed.
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
 	Dimension() int
 }
 
+// BatchError reports the texts of a batch that failed to embed while the
+// rest succeeded. The vectors returned with it are nil at those indexes.
+type BatchError struct {
+	Errs map[int]error // keyed by index into the batch
+}
+
+func (e *BatchError) Error() string {
+	first := -1
+	for i := range e.Errs {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	return fmt.Sprintf("%d texts of the batch failed to embed, the first (index %d): %v", len(e.Errs), first, e.Errs[first])
+}
+
+// ErrorAt returns the error for text i of a batch that EmbedBatch returned
+// err for: its own error, nil if it succeeded, when err is a *BatchError, and
+// err itself when the whole batch failed.
+func ErrorAt(err error, i int) error {
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Errs[i]
+	}
+	return err
+}
+
+// DimensionError reports a vector whose dimension differs from the one the
+// embedder was configured with, e.g. after the model behind an API changed.
+type DimensionError struct {
+	Got, Want int
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("embedding response vector has dimension %d, want %d", e.Got, e.Want)
+}
+
+// EmbedEach embeds texts with one embed call each, for embedders without a
+// batch API. The texts that fail are reported in a *BatchError.
+func EmbedEach(ctx context.Context, texts []string, embed func(context.Context, string) ([]float32, error)) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	errs := make(map[int]error)
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vector, err := embed(ctx, text)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		vectors[i] = vector
+	}
+	if len(errs) > 0 {
+		return vectors, &BatchError{Errs: errs}
+	}
+	return vectors, nil
+}
+
 type DummyEmbedder struct {
 	dimension int
 }
@@ -43,6 +112,10 @@ func (de *DummyEmbedder) Embed(ctx context.Context, text string) ([]float32, err
 	return vec, nil
 }
 
+func (de *DummyEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return EmbedEach(ctx, texts, de.Embed)
+}
+
 func (de *DummyEmbedder) Dimension() int {
 	return de.dimension
 }
@@ -68,26 +141,230 @@ func NewAPIEmbedder(cfg config.EmbedderConfig, dimension int) (*APIEmbedder, err
 	}, nil
 }
 
+// embeddingRequest and embeddingResponse follow the OpenAI-compatible
+// embeddings API, which takes many inputs per request and returns their
+// vectors by index.
+type embeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// apiStatusError is a non-2xx response from the embedding API.
+type apiStatusError struct {
+	status int
+	body   string
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("embedding API returned status %d: %s", e.status, e.body)
+}
+
+// rejectsInput reports whether status means the API refused the request's
+// input, as one bad text is enough to do for a whole batch.
+func (e *apiStatusError) rejectsInput() bool {
+	return e.status == http.StatusBadRequest || e.status == http.StatusRequestEntityTooLarge || e.status == http.StatusUnprocessableEntity
+}
+
 func (ae *APIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
-	// requestBody, err := json.Marshal(map[string]string{"text": text, "model": ae.modelName})
-	// ... http.NewRequestWithContext, set headers (Authorization if apiKey exists), client.Do ...
-	// ... parse response, extract vector ...
-	log.Printf("APIEmbedder: Embedding text (length %d) via %s", len(text), ae.apiEndpoint)
+	vectors, err := ae.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, ErrorAt(err, 0)
+	}
+	return vectors[0], nil
+}
 
-	if text == "" {
-		return make([]float32, ae.dimension), nil
+// EmbedBatch embeds texts in a single API request. If the API rejects the
+// request's input, the texts are retried one request each so only the ones
+// it refuses fail. Empty texts get a zero vector without being sent.
+func (ae *APIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	var inputs []string
+	var positions []int // index in texts of each input
+	for i, text := range texts {
+		if text == "" {
+			vectors[i] = make([]float32, ae.dimension)
+			continue
+		}
+		inputs = append(inputs, text)
+		positions = append(positions, i)
+	}
+	if len(inputs) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := ae.request(ctx, inputs)
+	var status *apiStatusError
+	if len(inputs) > 1 && errors.As(err, &status) && status.rejectsInput() {
+		log.Printf("APIEmbedder: batch of %d texts rejected (%v), embedding them one at a time", len(inputs), err)
+		embedded, err = EmbedEach(ctx, inputs, ae.Embed)
+	}
+	var batchErr *BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return nil, err
 	}
-	vec := make([]float32, ae.dimension)
-	for i := 0; i < ae.dimension; i++ {
-		vec[i] = float32(len(text)+i) * 0.01
+	for j, vector := range embedded {
+		vectors[positions[j]] = vector
 	}
-	return vec, fmt.Errorf("APIEmbedder.Embed not fully implemented")
+	if batchErr != nil {
+		errs := make(map[int]error, len(batchErr.Errs))
+		for j, err := range batchErr.Errs {
+			errs[positions[j]] = err
+		}
+		return vectors, &BatchError{Errs: errs}
+	}
+	return vectors, nil
+}
+
+// request sends inputs to the embeddings API in one request. Inputs missing
+// from the response or with a vector of the wrong dimension are reported in
+// a *BatchError.
+func (ae *APIEmbedder) request(ctx context.Context, inputs []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: ae.modelName, Input: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ae.apiEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if ae.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ae.apiKey)
+	}
+	resp, err := ae.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &apiStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(message))}
+	}
+	var parsed embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for _, item := range parsed.Data {
+		if item.Index >= 0 && item.Index < len(inputs) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	errs := make(map[int]error)
+	for i, vector := range vectors {
+		switch {
+		case vector == nil:
+			errs[i] = errors.New("embedding response has no vector for the text")
+		case ae.dimension > 0 && len(vector) != ae.dimension:
+			errs[i] = &DimensionError{Got: len(vector), Want: ae.dimension}
+			vectors[i] = nil
+		}
+	}
+	if len(errs) > 0 {
+		return vectors, &BatchError{Errs: errs}
+	}
+	return vectors, nil
 }
 
 func (ae *APIEmbedder) Dimension() int {
 	return ae.dimension
 }
 
+// ContentText is the text embedded as a document's content vector. With
+// maxChars > 0 the content is first cut to at most maxChars characters by
+// TruncateText.
+func ContentText(title, content string, maxChars int) string {
+	return title + "\n" + TruncateText(content, maxChars)
+}
+
+// TruncateText returns text cut to at most maxChars characters, at the last
+// sentence end in the second half of the window if there is one, otherwise
+// at the last whitespace, so words are not split. Text without whitespace,
+// such as CJK, is cut at any sentence end, and only a single word longer
+// than maxChars is cut mid-word. Text that fits, or any text when
+// maxChars <= 0, is returned unchanged.
+func TruncateText(text string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	cut, runes := len(text), 0
+	for i := range text {
+		if runes == maxChars {
+			cut = i
+			break
+		}
+		runes++
+	}
+	window := text[:cut]
+	if next, _ := utf8.DecodeRuneInString(text[cut:]); unicode.IsSpace(next) {
+		return strings.TrimSpace(window) // the window ends on a word boundary
+	}
+
+	end := lastSentenceEnd(window)
+	if end > len(window)/2 {
+		return window[:end]
+	}
+	if space := strings.LastIndexFunc(window, unicode.IsSpace); space > 0 {
+		return strings.TrimSpace(window[:space])
+	}
+	if end > 0 {
+		return window[:end] // e.g. CJK text without spaces
+	}
+	return window // a single word longer than maxChars
+}
+
+// lastSentenceEnd returns the byte offset just past the last sentence-ending
+// punctuation in text that is followed by whitespace, or 0 if there is none.
+func lastSentenceEnd(text string) int {
+	end := 0
+	for i, r := range text {
+		switch r {
+		case '.', '!', '?':
+			next := i + utf8.RuneLen(r)
+			if next < len(text) {
+				if after, _ := utf8.DecodeRuneInString(text[next:]); unicode.IsSpace(after) {
+					end = next
+				}
+			}
+		case '。', '！', '？':
+			end = i + utf8.RuneLen(r)
+		}
+	}
+	return end
+}
+
+// TitleText is the text embedded as a document's title vector: the title and
+// its headings.
+func TitleText(title, headings string) string {
+	return strings.TrimSpace(title + "\n" + headings)
+}
+
+// ProbeDimension embeds a short text with the configured embedder and
+// returns the dimension of the vectors it produces. It returns 0 for the
+// dummy embedder, which produces whatever dimension it is created with.
+func ProbeDimension(ctx context.Context, cfg *config.EmbedderConfig) (int, error) {
+	if strings.ToLower(cfg.Type) != "api" {
+		return 0, nil
+	}
+	probe, err := NewAPIEmbedder(*cfg, 0) // 0 accepts any dimension
+	if err != nil {
+		return 0, err
+	}
+	vector, err := probe.Embed(ctx, "dimension probe")
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe embedding dimension: %w", err)
+	}
+	return len(vector), nil
+}
+
 func NewTextEmbedder(cfg *config.EmbedderConfig, milvusDimension int) (TextEmbedder, error) {
 	log.Printf("Initializing embedder of type: '%s' with dimension: %d", cfg.Type, milvusDimension)
 	switch strings.ToLower(cfg.Type) {
@@ -0,0 +1,126 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     string
+	}{
+		{"fits", "Short text.", 20, "Short text."},
+		{"unlimited", "Some longer text.", 0, "Some longer text."},
+		{"sentence end", "First sentence here. Second one is cut", 30, "First sentence here."},
+		{"early sentence end", "Hi. Then a long sentence without an end", 30, "Hi. Then a long sentence"},
+		{"word boundary", "one two three four five", 13, "one two three"},
+		{"mid word", "one two three four five", 11, "one two"},
+		{"long word", "abcdefghijklmnop", 5, "abcde"},
+		{"multibyte", "héllo wörld and more", 11, "héllo wörld"},
+		{"cjk", "第一句。第二句很长没有结束", 8, "第一句。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TruncateText(tt.text, tt.maxChars); got != tt.want {
+				t.Errorf("TruncateText(%q, %d) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+			}
+		})
+	}
+}
+
+// embeddingServer serves an embeddings API with vectors of dim dimensions.
+// It rejects requests containing the input "bad" and records the input count
+// of each request.
+func embeddingServer(t *testing.T, dim int) (*httptest.Server, func() []int) {
+	t.Helper()
+	var mu sync.Mutex
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		sizes = append(sizes, len(req.Input))
+		mu.Unlock()
+		if slices.Contains(req.Input, "bad") {
+			http.Error(w, "input rejected", http.StatusBadRequest)
+			return
+		}
+		data := make([]map[string]any, len(req.Input))
+		for i := range data {
+			data[i] = map[string]any{"index": i, "embedding": make([]float32, dim)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), sizes...)
+	}
+}
+
+func TestProbeDimension(t *testing.T) {
+	server, _ := embeddingServer(t, 3)
+	cfg := &config.EmbedderConfig{Type: "api", APIEndpoint: server.URL}
+	if dim, err := ProbeDimension(context.Background(), cfg); dim != 3 || err != nil {
+		t.Errorf("ProbeDimension = %d, %v, want 3", dim, err)
+	}
+	if dim, err := ProbeDimension(context.Background(), &config.EmbedderConfig{Type: "dummy"}); dim != 0 || err != nil {
+		t.Errorf("ProbeDimension of the dummy embedder = %d, %v, want 0", dim, err)
+	}
+}
+
+func TestAPIEmbedderRejectsOtherDimension(t *testing.T) {
+	server, _ := embeddingServer(t, 3)
+	ae, err := NewAPIEmbedder(config.EmbedderConfig{APIEndpoint: server.URL}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ae.Embed(context.Background(), "text")
+	var dimErr *DimensionError
+	if !errors.As(err, &dimErr) || dimErr.Got != 3 || dimErr.Want != 4 {
+		t.Errorf("Embed error = %v, want a DimensionError of 3 for 4", err)
+	}
+}
+
+func TestAPIEmbedderBatch(t *testing.T) {
+	server, sizes := embeddingServer(t, 2)
+	ae, err := NewAPIEmbedder(config.EmbedderConfig{APIEndpoint: server.URL}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vectors, err := ae.EmbedBatch(context.Background(), []string{"a", "", "b"})
+	if err != nil || len(vectors) != 3 {
+		t.Fatalf("EmbedBatch = %d vectors, %v, want 3", len(vectors), err)
+	}
+	if got := sizes(); !slices.Equal(got, []int{2}) {
+		t.Errorf("request sizes = %v, want one request without the empty text", got)
+	}
+
+	// A rejected batch is retried one text at a time, so only the bad text fails.
+	vectors, err = ae.EmbedBatch(context.Background(), []string{"a", "bad", "b"})
+	if got := sizes()[1:]; !slices.Equal(got, []int{3, 1, 1, 1}) {
+		t.Errorf("request sizes = %v, want the batch, then each text", got)
+	}
+	if ErrorAt(err, 1) == nil || ErrorAt(err, 0) != nil || ErrorAt(err, 2) != nil {
+		t.Errorf("EmbedBatch error = %v, want only the bad text failed", err)
+	}
+	if vectors[0] == nil || vectors[1] != nil || vectors[2] == nil {
+		t.Errorf("vectors = %v, want all but the bad text's", vectors)
+	}
+}
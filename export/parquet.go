@@ -0,0 +1,271 @@
+// Package export writes stored documents to files for offline processing.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/storage"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetRow is the Parquet schema of an exported document: one column per
+// scalar field and the embedding as a LIST<FLOAT> column. Documents without a
+// real embedding have has_vector=false and an empty content_vector;
+// title_vector is empty unless the collection stores title vectors.
+type ParquetRow struct {
+	HashID               string    `parquet:"hash_id"`
+	URL                  string    `parquet:"url"`
+	HTMLSource           string    `parquet:"html_source"`
+	MainContent          string    `parquet:"main_content"`
+	Title                string    `parquet:"title"`
+	MetaDescription      string    `parquet:"meta_description"`
+	CanonicalURL         string    `parquet:"canonical_url"`
+	Language             string    `parquet:"language"`
+	PublicationTimestamp int64     `parquet:"publication_timestamp"` // Unix seconds, 0 if unknown
+	HeadingsText         string    `parquet:"headings_text"`
+	CrawledAt            time.Time `parquet:"crawled_at,timestamp(millisecond)"`
+	DuplicateOf          string    `parquet:"duplicate_of"`
+	TablesJSON           string    `parquet:"tables_json"`
+	ResponseHeaders      string    `parquet:"response_headers"`
+	ExtractionVersion    string    `parquet:"extraction_version"`
+	QualityScore         float32   `parquet:"quality_score"`
+	InboundLinks         int64     `parquet:"inbound_links"`
+	HreflangJSON         string    `parquet:"hreflang_json"`
+	OutboundAnchorsJSON  string    `parquet:"outbound_anchors_json"`
+	BodyHash             string    `parquet:"body_hash"`
+	HTMLRetained         bool      `parquet:"html_retained"`
+	CrawlDepth           int64     `parquet:"crawl_depth"`
+	ContentFingerprint   string    `parquet:"content_fingerprint"`
+	MetaJSON             string    `parquet:"meta_json"`
+	RedirectChain        string    `parquet:"redirect_chain"`
+	Gated                bool      `parquet:"gated"`
+	BreadcrumbsJSON      string    `parquet:"breadcrumbs_json"`
+	SeedURL              string    `parquet:"seed_url"`
+	ReferrerURL          string    `parquet:"referrer_url"`
+	HTMLTruncation       string    `parquet:"html_truncation"`
+	HasVector            bool      `parquet:"has_vector"`
+	ContentVector        []float32 `parquet:"content_vector,list"`
+	TitleVector          []float32 `parquet:"title_vector,list"`
+}
+
+// ParquetExporter writes documents from a MilvusStorer to numbered Parquet
+// files (part-00000.parquet, ...) in a directory, starting a new file once
+// the current one reaches max_rows_per_file rows or max_bytes_per_file bytes.
+type ParquetExporter struct {
+	cfg       *config.ExportConfig
+	storer    *storage.MilvusStorer
+	blobs     storage.BlobStore // resolves offloaded fields; may be nil
+	dimension int
+}
+
+func NewParquetExporter(cfg *config.ExportConfig, storer *storage.MilvusStorer, blobs storage.BlobStore, dimension int) *ParquetExporter {
+	return &ParquetExporter{cfg: cfg, storer: storer, blobs: blobs, dimension: dimension}
+}
+
+// Export writes every document matching the filter expression and returns
+// the number of documents and files written. Rows are flushed as one row
+// group per page read from Milvus, so the size limit is checked at page
+// granularity and a file may exceed it by up to one page.
+func (e *ParquetExporter) Export(ctx context.Context) (rows, files int, err error) {
+	if err := os.MkdirAll(e.cfg.Dir, 0o755); err != nil {
+		return 0, 0, fmt.Errorf("failed to create export directory %s: %w", e.cfg.Dir, err)
+	}
+
+	var part *parquetPart
+	defer func() {
+		if part != nil {
+			part.abort()
+		}
+	}()
+
+	page := make([]ParquetRow, 0, e.cfg.PageSize)
+	flush := func() error {
+		if len(page) == 0 {
+			return nil
+		}
+		if part == nil {
+			var err error
+			part, err = e.newPart(files)
+			if err != nil {
+				return err
+			}
+		}
+		if err := part.write(page); err != nil {
+			return err
+		}
+		rows += len(page)
+		page = page[:0]
+
+		if (e.cfg.MaxRowsPerFile > 0 && part.rows >= e.cfg.MaxRowsPerFile) ||
+			(e.cfg.MaxBytesPerFile > 0 && part.out.n >= e.cfg.MaxBytesPerFile) {
+			if err := part.close(); err != nil {
+				return err
+			}
+			part = nil
+			files++
+		}
+		return nil
+	}
+
+	err = e.storer.IterateDocumentsWhere(ctx, e.cfg.Filter, e.cfg.PageSize, func(doc *storage.WebDocument) error {
+		row, err := e.row(ctx, doc)
+		if err != nil {
+			return err
+		}
+		page = append(page, row)
+		if len(page) >= e.pageLimit(part) {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil && part != nil {
+		err = part.close()
+		part = nil
+		files++
+	}
+	if err != nil {
+		return rows, files, fmt.Errorf("failed to export documents to %s: %w", e.cfg.Dir, err)
+	}
+	return rows, files, nil
+}
+
+// pageLimit is the number of rows to buffer before writing a row group: the
+// page size, or fewer so that files split exactly at max_rows_per_file.
+func (e *ParquetExporter) pageLimit(part *parquetPart) int {
+	limit := e.cfg.PageSize
+	if e.cfg.MaxRowsPerFile > 0 {
+		remaining := e.cfg.MaxRowsPerFile
+		if part != nil {
+			remaining -= part.rows
+		}
+		limit = min(limit, remaining)
+	}
+	return limit
+}
+
+func (e *ParquetExporter) row(ctx context.Context, doc *storage.WebDocument) (ParquetRow, error) {
+	htmlSource, err := storage.ResolveBlobRef(ctx, e.blobs, doc.HTMLSource)
+	if err != nil {
+		return ParquetRow{}, err
+	}
+	mainContent, err := storage.ResolveBlobRef(ctx, e.blobs, doc.MainContent)
+	if err != nil {
+		return ParquetRow{}, err
+	}
+	return ParquetRow{
+		HashID:               doc.HashID,
+		URL:                  doc.URL,
+		HTMLSource:           htmlSource,
+		MainContent:          mainContent,
+		Title:                doc.Title,
+		MetaDescription:      doc.MetaDescription,
+		CanonicalURL:         doc.CanonicalURL,
+		Language:             doc.Language,
+		PublicationTimestamp: doc.PublicationTimestamp,
+		HeadingsText:         doc.HeadingsText,
+		CrawledAt:            doc.CrawledAt,
+		DuplicateOf:          doc.DuplicateOf,
+		TablesJSON:           doc.TablesJSON,
+		ResponseHeaders:      doc.ResponseHeaders,
+		ExtractionVersion:    doc.ExtractionVersion,
+		QualityScore:         doc.QualityScore,
+		InboundLinks:         doc.InboundLinks,
+		HreflangJSON:         doc.HreflangJSON,
+		OutboundAnchorsJSON:  doc.OutboundAnchorsJSON,
+		BodyHash:             doc.BodyHash,
+		HTMLRetained:         doc.HTMLRetained,
+		CrawlDepth:           doc.CrawlDepth,
+		ContentFingerprint:   doc.ContentFingerprint,
+		MetaJSON:             doc.MetaJSON,
+		RedirectChain:        doc.RedirectChain,
+		Gated:                doc.Gated,
+		BreadcrumbsJSON:      doc.BreadcrumbsJSON,
+		SeedURL:              doc.SeedURL,
+		ReferrerURL:          doc.ReferrerURL,
+		HTMLTruncation:       doc.HTMLTruncation,
+		HasVector:            len(doc.ContentVector) > 0,
+		ContentVector:        doc.ContentVector,
+		TitleVector:          doc.TitleVector,
+	}, nil
+}
+
+// parquetPart is one output file, written under a temporary name and renamed
+// into place when closed so readers never see a partial file.
+type parquetPart struct {
+	path   string
+	file   *os.File
+	out    *countingWriter
+	writer *parquet.GenericWriter[ParquetRow]
+	rows   int
+}
+
+func (e *ParquetExporter) newPart(index int) (*parquetPart, error) {
+	path := filepath.Join(e.cfg.Dir, fmt.Sprintf("part-%05d.parquet", index))
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	out := &countingWriter{w: file}
+	writer := parquet.NewGenericWriter[ParquetRow](out,
+		parquet.Compression(&parquet.Snappy),
+		parquet.KeyValueMetadata("embedding_dimension", strconv.Itoa(e.dimension)),
+	)
+	return &parquetPart{path: path, file: file, out: out, writer: writer}, nil
+}
+
+// write appends rows as one row group.
+func (p *parquetPart) write(rows []ParquetRow) error {
+	if _, err := p.writer.Write(rows); err != nil {
+		return fmt.Errorf("failed to write rows to %s: %w", p.path, err)
+	}
+	if err := p.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush row group to %s: %w", p.path, err)
+	}
+	p.rows += len(rows)
+	return nil
+}
+
+func (p *parquetPart) close() error {
+	if err := p.writer.Close(); err != nil {
+		p.abort()
+		return fmt.Errorf("failed to finish %s: %w", p.path, err)
+	}
+	if err := p.file.Close(); err != nil {
+		os.Remove(p.file.Name())
+		return fmt.Errorf("failed to close %s: %w", p.path, err)
+	}
+	if err := os.Rename(p.file.Name(), p.path); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", p.path, err)
+	}
+	log.Printf("Wrote %d rows (%d bytes) to %s", p.rows, p.out.n, p.path)
+	return nil
+}
+
+func (p *parquetPart) abort() {
+	p.file.Close()
+	os.Remove(p.file.Name())
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,60 @@
+// Package health serves liveness and readiness probes for the running
+// engine: /healthz answers as long as the process is up, while /readyz runs
+// the registered checks, e.g. that Milvus is reachable and the crawler is
+// making progress, and fails if any of them does.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// checkTimeout bounds each readiness check, so a hung dependency makes the
+// probe fail rather than time out.
+const checkTimeout = 2 * time.Second
+
+// Check is a named readiness check. Fn returns nil when the component is ready.
+type Check struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// NewHandler returns a handler serving /healthz and /readyz.
+func NewHandler(checks ...Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		var failures []string
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			err := check.Fn(ctx)
+			cancel()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", check.Name, err))
+			}
+		}
+		if len(failures) > 0 {
+			http.Error(w, strings.Join(failures, "\n"), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// Serve serves the probes on addr in the background until the process exits.
+func Serve(addr string, checks ...Check) {
+	server := &http.Server{Addr: addr, Handler: NewHandler(checks...), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		log.Printf("Serving health probes on http://%s/healthz and /readyz", addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Health server stopped: %v", err)
+		}
+	}()
+}
@@ -2,38 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	_ "net/http/pprof" // Registers profiling handlers, served only when debug.pprof_addr is set
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time" // For timeout context if needed
 
 	"crawlengine/config"
 	"crawlengine/crawler"
+	"crawlengine/embedder"
+	"crawlengine/export"
+	"crawlengine/health"
+	"crawlengine/reembed"
 	"crawlengine/storage"
+	"crawlengine/telemetry"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	log.SetOutput(os.Stderr) // stdout is reserved for -ndjson output
 
-	cfg, err := config.LoadConfig("config/config.yaml")
+	if err := run(); err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the engine. It returns errors rather than exiting, so that its
+// deferred cleanup, closing the storers and flushing traces, always runs.
+func run() error {
+	configFlag := flag.String("config", "", "path to the config file (default: $"+config.ConfigPathEnv+", ./config.yaml, ./config/config.yaml)")
+	exportFlag := flag.Bool("export-parquet", false, "export stored documents to Parquet as configured in the export section, then exit")
+	ndjsonFlag := flag.Bool("ndjson", false, "write each crawled document to stdout as a line of JSON instead of storing it in Milvus")
+	flag.Parse()
+	if *exportFlag && *ndjsonFlag {
+		return errors.New("-export-parquet and -ndjson cannot be used together")
+	}
+
+	configPath, err := config.ResolvePath(*configFlag)
+	if err != nil {
+		return fmt.Errorf("failed to locate configuration: %w", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		return fmt.Errorf("failed to load configuration from %s: %w", configPath, err)
 	}
+	log.Printf("Loaded configuration from %s", configPath)
 
 	log.Printf("Logger level set to: %s", cfg.Logger.Level)
 
+	if cfg.Debug.PprofAddr != "" {
+		go func() {
+			log.Printf("Serving pprof on http://%s/debug/pprof/", cfg.Debug.PprofAddr)
+			if err := http.ListenAndServe(cfg.Debug.PprofAddr, nil); err != nil {
+				log.Printf("pprof server stopped: %v", err)
+			}
+		}()
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), &cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
+
+	// verify_links only checks pages, so it needs no storage or embedder.
+	if strings.EqualFold(cfg.Crawler.Mode, crawler.ModeVerifyLinks) && !*exportFlag {
+		return verifyLinks(&cfg.Crawler, cfg.Logger.Level)
+	}
+	if *ndjsonFlag {
+		return crawlToNDJSON(cfg)
+	}
+
 	// Context for Milvus initialization (e.g., with a timeout)
 	initCtx, initCancel := context.WithTimeout(context.Background(), 30*time.Second) // 30-second timeout for Milvus setup
 	defer initCancel()
 
-	milvusStorer, err := storage.NewMilvusStorer(initCtx, &cfg.Milvus) // Pass context
+	milvusStorer, err := storage.NewMilvusStorer(initCtx, &cfg.Milvus, &cfg.Crawler) // Pass context
 	if err != nil {
-		log.Fatalf("Failed to initialize Milvus storer: %v", err)
+		return fmt.Errorf("failed to initialize Milvus storer: %w", err)
+	}
+	var docStorer storage.Storer = milvusStorer
+	defer func() { docStorer.Close() }() // Closes Milvus through any storers wrapping it
+	blobStore, err := storage.NewBlobStore(initCtx, &cfg.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to initialize blob store: %w", err)
+	}
+	if blobStore != nil {
+		log.Printf("Offloading HTML and content larger than %d bytes to %s blob store", cfg.Blob.Threshold, cfg.Blob.Type)
+		docStorer = storage.NewBlobOffloadStorer(milvusStorer, blobStore, cfg.Blob.Threshold)
 	}
-	defer milvusStorer.Close()
 
-	cr := crawler.NewCrawler(&cfg.Crawler, milvusStorer)
+	if *exportFlag {
+		exportCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		exporter := export.NewParquetExporter(&cfg.Export, milvusStorer, blobStore, cfg.Milvus.EmbeddingDimension)
+		rows, files, err := exporter.Export(exportCtx)
+		if err != nil {
+			return fmt.Errorf("export failed after %d documents: %w", rows, err)
+		}
+		log.Printf("Exported %d documents to %d Parquet files in %s", rows, files, cfg.Export.Dir)
+		return nil
+	}
+
+	// A changed embedding model is caught here rather than failing every
+	// document; with on_dimension_mismatch: new_collection this switches
+	// cfg.Milvus to a collection of the new dimension.
+	if cfg.Crawler.EmbedDocuments || cfg.Reembed.Enabled {
+		dim, err := embedder.ProbeDimension(initCtx, &cfg.Embedder)
+		titleDim := 0
+		if err == nil && cfg.Milvus.TitleVector {
+			titleDim, err = embedder.ProbeDimension(initCtx, &cfg.TitleEmbedder)
+		}
+		if err != nil {
+			log.Printf("Warning: %v. Skipping the embedding dimension check.", err)
+		} else if err := milvusStorer.EnsureEmbeddingDimension(initCtx, dim, titleDim); err != nil {
+			return fmt.Errorf("embedding dimension check failed: %w", err)
+		}
+	}
+
+	if cfg.Elastic.Endpoint != "" {
+		elasticStorer, err := storage.NewElasticStorer(initCtx, &cfg.Elastic, cfg.Milvus.EmbeddingDimension)
+		if err != nil {
+			return fmt.Errorf("failed to initialize Elasticsearch storer: %w", err)
+		}
+		docStorer = storage.NewMultiStorer(docStorer, elasticStorer) // Closing it flushes Elasticsearch documents still pending
+	}
+
+	textEmbedder, err := embedder.NewTextEmbedder(&cfg.Embedder, cfg.Milvus.EmbeddingDimension)
+	if err != nil {
+		return fmt.Errorf("failed to initialize embedder: %w", err)
+	}
+
+	var titleEmbedder embedder.TextEmbedder
+	if cfg.Milvus.TitleVector {
+		titleEmbedder, err = embedder.NewTextEmbedder(&cfg.TitleEmbedder, cfg.Milvus.TitleEmbeddingDimension)
+		if err != nil {
+			return fmt.Errorf("failed to initialize title embedder: %w", err)
+		}
+	}
+
+	cr := crawler.NewCrawler(&cfg.Crawler, docStorer, textEmbedder)
+	cr.SetEmbedContentChars(cfg.Embedder.EmbedContentChars)
+	cr.SetMetaJSONLimit(cfg.Milvus.MaxLengthMeta)
+	if blobStore == nil {
+		cr.SetHTMLLimit(cfg.Milvus.MaxLengthHTML) // offloaded HTML has no length limit
+	}
+	cr.SetLogLevel(cfg.Logger.Level)
+	if titleEmbedder != nil {
+		cr.SetTitleEmbedder(titleEmbedder)
+	}
+
+	if cfg.Health.Addr != "" {
+		stallTimeout := time.Duration(cfg.Health.StallTimeoutSec) * time.Second
+		health.Serve(cfg.Health.Addr,
+			health.Check{Name: "milvus", Fn: milvusStorer.Ready},
+			health.Check{Name: "crawler", Fn: func(context.Context) error { return cr.Ready(stallTimeout) }},
+		)
+	}
 
 	// Main context for the crawler itself
 	crawlerCtx, crawlerCancel := context.WithCancel(context.Background())
@@ -47,7 +185,69 @@ func main() {
 		crawlerCancel() // Signal crawler workers to stop
 	}()
 
-	cr.Start(crawlerCtx)
+	var reembedDone chan struct{}
+	reembedCtx, reembedCancel := context.WithCancel(crawlerCtx)
+	defer reembedCancel()
+	if cfg.Reembed.Enabled {
+		reembedDone = make(chan struct{})
+		job := reembed.NewJob(&cfg.Reembed, milvusStorer, textEmbedder, titleEmbedder, blobStore, cfg.Embedder.EmbedContentChars)
+		go func() {
+			defer close(reembedDone)
+			job.Run(reembedCtx)
+		}()
+	}
+
+	if err := cr.Start(crawlerCtx); err != nil {
+		return fmt.Errorf("crawler failed to start: %w", err)
+	}
+
+	if reembedDone != nil {
+		if cfg.Reembed.IntervalSec > 0 {
+			reembedCancel() // Periodic re-embedding stops with the crawl; flagged documents resume next run
+		}
+		<-reembedDone
+	}
 
 	log.Println("Crawling engine finished or was interrupted.")
+	return nil
+}
+
+// verifyLinks runs a verify_links crawl, which writes its link report when it
+// finishes or is interrupted.
+func verifyLinks(cfg *config.CrawlerConfig, logLevel string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cr := crawler.NewCrawler(cfg, nil, nil)
+	cr.SetLogLevel(logLevel)
+	if err := cr.Start(ctx); err != nil {
+		return fmt.Errorf("crawler failed to start: %w", err)
+	}
+	log.Println("Link verification finished or was interrupted.")
+	return nil
+}
+
+// crawlToNDJSON runs a crawl that writes documents to stdout as NDJSON
+// instead of storing them, e.g. to pipe into jq. Milvus, Elasticsearch and
+// re-embedding are not used; content vectors are included with
+// embed_documents.
+func crawlToNDJSON(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	textEmbedder, err := embedder.NewTextEmbedder(&cfg.Embedder, cfg.Milvus.EmbeddingDimension)
+	if err != nil {
+		return fmt.Errorf("failed to initialize embedder: %w", err)
+	}
+	storer := storage.NewNDJSONStorer(os.Stdout)
+	defer storer.Close()
+
+	cr := crawler.NewCrawler(&cfg.Crawler, storer, textEmbedder)
+	cr.SetEmbedContentChars(cfg.Embedder.EmbedContentChars)
+	cr.SetLogLevel(cfg.Logger.Level)
+	if err := cr.Start(ctx); err != nil {
+		return fmt.Errorf("crawler failed to start: %w", err)
+	}
+	log.Println("Crawling engine finished or was interrupted.")
+	return nil
 }
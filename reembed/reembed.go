@@ -0,0 +1,168 @@
+// Package reembed refreshes the vectors of stored documents flagged with
+// needs_embedding, e.g. after the embedder changed or documents were stored
+// without a vector. Each document's flag is cleared when its new vector is
+// written, so an interrupted run simply resumes with the documents still
+// flagged the next time it starts.
+package reembed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"crawlengine/config"
+	"crawlengine/embedder"
+	"crawlengine/storage"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the job's spans; it is a no-op unless tracing is configured.
+var tracer = otel.Tracer("crawlengine/reembed")
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Job re-embeds flagged documents in batches.
+type Job struct {
+	cfg      *config.ReembedConfig
+	storer   *storage.MilvusStorer
+	embedder embedder.TextEmbedder
+	title    embedder.TextEmbedder // nil unless title vectors are stored
+	blobs    storage.BlobStore     // resolves offloaded main content; may be nil
+
+	contentChars int // embed_content_chars
+}
+
+func NewJob(cfg *config.ReembedConfig, storer *storage.MilvusStorer, textEmbedder, titleEmbedder embedder.TextEmbedder, blobs storage.BlobStore, contentChars int) *Job {
+	return &Job{cfg: cfg, storer: storer, embedder: textEmbedder, title: titleEmbedder, blobs: blobs, contentChars: contentChars}
+}
+
+// Run marks documents matching mark_expr, if set, then re-embeds flagged
+// documents until none are left, repeating every interval_sec until ctx is
+// canceled. With an interval of 0 it makes a single pass.
+func (j *Job) Run(ctx context.Context) {
+	if j.cfg.MarkExpr != "" {
+		marked, err := j.storer.MarkNeedsEmbedding(ctx, j.cfg.MarkExpr, j.cfg.BatchSize)
+		if err != nil {
+			log.Printf("Error marking documents for re-embedding: %v", err)
+		} else {
+			log.Printf("Marked %d documents matching '%s' for re-embedding", marked, j.cfg.MarkExpr)
+		}
+	}
+
+	for {
+		embedded, failed, err := j.Pass(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error during re-embedding pass: %v", err)
+		} else if embedded > 0 || failed > 0 {
+			log.Printf("Re-embedding pass finished: %d documents embedded, %d failed", embedded, failed)
+		}
+		if j.cfg.IntervalSec <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(j.cfg.IntervalSec) * time.Second):
+		}
+	}
+}
+
+// Pass re-embeds every document currently flagged with needs_embedding.
+// Documents that fail to embed keep their flag for the next pass.
+func (j *Job) Pass(ctx context.Context) (embedded, failed int, err error) {
+	var batch []*storage.WebDocument
+	process := func() error {
+		ok, bad := j.embedBatch(ctx, batch)
+		failed += bad
+		upsertCtx, span := tracer.Start(ctx, "reembed.store", trace.WithAttributes(attribute.Int("documents", len(ok))))
+		err := j.storer.UpsertDocuments(upsertCtx, ok)
+		endSpan(span, err)
+		if err != nil {
+			failed += len(ok)
+			return err
+		}
+		embedded += len(ok)
+		batch = batch[:0]
+		return nil
+	}
+
+	err = j.storer.IterateDocumentsWhere(ctx, "needs_embedding == true", j.cfg.BatchSize, func(doc *storage.WebDocument) error {
+		batch = append(batch, doc)
+		if len(batch) >= j.cfg.BatchSize {
+			return process()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = process()
+	}
+	return embedded, failed, err
+}
+
+// embedBatch embeds docs with up to Concurrency embedder calls in flight. It
+// returns the documents that got a new vector and the number that failed.
+func (j *Job) embedBatch(ctx context.Context, docs []*storage.WebDocument) ([]*storage.WebDocument, int) {
+	results := make([]bool, len(docs))
+	sem := make(chan struct{}, j.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i, doc := range docs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, doc *storage.WebDocument) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := j.embedDocument(ctx, doc); err != nil {
+				log.Printf("Error re-embedding document ID %s (URL: %s): %v", doc.HashID, doc.URL, err)
+				return
+			}
+			results[i] = true
+		}(i, doc)
+	}
+	wg.Wait()
+
+	var ok []*storage.WebDocument
+	for i, doc := range docs {
+		if results[i] {
+			ok = append(ok, doc)
+		}
+	}
+	return ok, len(docs) - len(ok)
+}
+
+// embedDocument embeds doc's content and, with a title embedder, its title,
+// so re-embedding a document takes two embedder calls when title vectors are
+// stored.
+func (j *Job) embedDocument(ctx context.Context, doc *storage.WebDocument) (err error) {
+	content, err := storage.ResolveBlobRef(ctx, j.blobs, doc.MainContent)
+	if err != nil {
+		return fmt.Errorf("failed to load main content: %w", err)
+	}
+	ctx, span := tracer.Start(ctx, "reembed.embed", trace.WithAttributes(attribute.String("hash_id", doc.HashID)))
+	defer func() { endSpan(span, err) }()
+	vector, err := j.embedder.Embed(ctx, embedder.ContentText(doc.Title, content, j.contentChars))
+	if err != nil {
+		return err
+	}
+	var titleVector []float32
+	if j.title != nil {
+		if titleVector, err = j.title.Embed(ctx, embedder.TitleText(doc.Title, doc.HeadingsText)); err != nil {
+			return fmt.Errorf("failed to embed title: %w", err)
+		}
+	}
+	doc.ContentVector = vector
+	doc.TitleVector = titleVector
+	doc.NeedsEmbedding = false
+	return nil
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"crawlengine/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// BlobStore keeps large raw data outside the vector database. Put returns a
+// reference URI that is stored in place of the data; Get resolves it.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) (string, error)
+	Get(ctx context.Context, ref string) ([]byte, error)
+}
+
+// Blob store types for blob.type.
+const (
+	BlobStoreLocal = "local"
+	BlobStoreS3    = "s3"
+)
+
+const (
+	localBlobScheme = "file://"
+	s3BlobScheme    = "s3://"
+)
+
+// NewBlobStore creates the blob store selected by cfg.Type. It returns nil
+// when no blob store is configured.
+func NewBlobStore(ctx context.Context, cfg *config.BlobConfig) (BlobStore, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "":
+		return nil, nil
+	case BlobStoreLocal:
+		return NewLocalBlobStore(cfg.Dir)
+	case BlobStoreS3:
+		return NewS3BlobStore(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported blob store type '%s'", cfg.Type)
+	}
+}
+
+// IsBlobRef reports whether a stored field value is a blob reference rather
+// than the data itself.
+func IsBlobRef(value string) bool {
+	return strings.HasPrefix(value, localBlobScheme) || strings.HasPrefix(value, s3BlobScheme)
+}
+
+// LocalBlobStore stores blobs as files under a directory, mainly for testing
+// and single-machine setups.
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("local blob store requires a directory")
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve blob directory %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(absDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory %s: %w", absDir, err)
+	}
+	return &LocalBlobStore{dir: absDir}, nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, s.dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("blob key %s escapes the blob directory", key)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create blob directory for %s: %w", key, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+	return localBlobScheme + filepath.ToSlash(path), nil
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	if !strings.HasPrefix(ref, localBlobScheme) {
+		return nil, fmt.Errorf("not a local blob reference: %s", ref)
+	}
+	data, err := os.ReadFile(filepath.FromSlash(strings.TrimPrefix(ref, localBlobScheme)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", ref, err)
+	}
+	return data, nil
+}
+
+// S3BlobStore stores blobs in a bucket of an S3-compatible object store.
+// References have the form s3://bucket/key.
+type S3BlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3BlobStore(ctx context.Context, cfg *config.BlobConfig) (*S3BlobStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 blob store requires an endpoint and a bucket")
+	}
+	cli, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object store client for %s: %w", cfg.Endpoint, err)
+	}
+
+	exists, err := cli.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		log.Printf("Bucket '%s' does not exist. Creating...", cfg.Bucket)
+		if err := cli.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+	return &S3BlobStore{client: cli, bucket: cfg.Bucket}, nil
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload blob %s to bucket %s: %w", key, s.bucket, err)
+	}
+	return s3BlobScheme + s.bucket + "/" + key, nil
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(ref, s3BlobScheme), "/")
+	if !strings.HasPrefix(ref, s3BlobScheme) || !ok {
+		return nil, fmt.Errorf("not an s3 blob reference: %s", ref)
+	}
+	obj, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch blob %s: %w", ref, err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", ref, err)
+	}
+	return data, nil
+}
+
+// BlobOffloadStorer moves html_source and main_content values larger than a
+// threshold into a BlobStore and passes the document on to the next Storer
+// with those fields replaced by blob references.
+type BlobOffloadStorer struct {
+	next      Storer
+	blobs     BlobStore
+	threshold int
+}
+
+// NewBlobOffloadStorer wraps next. Fields longer than threshold bytes are
+// offloaded; a threshold of 0 offloads every non-empty HTML source and main
+// content.
+func NewBlobOffloadStorer(next Storer, blobs BlobStore, threshold int) *BlobOffloadStorer {
+	return &BlobOffloadStorer{next: next, blobs: blobs, threshold: threshold}
+}
+
+func (s *BlobOffloadStorer) StoreDocument(ctx context.Context, doc *WebDocument) error {
+	if doc == nil {
+		return fmt.Errorf("cannot store nil document")
+	}
+	offloaded := *doc
+	var err error
+	// hash_id covers the main content only, which pages with different
+	// HTML can share, so the HTML is keyed by its URL and the HTML itself.
+	htmlKey := fmt.Sprintf("html/%x.html", sha256.Sum256([]byte(doc.URL+"\x00"+doc.HTMLSource)))
+	if offloaded.HTMLSource, err = s.offload(ctx, htmlKey, doc.HTMLSource); err != nil {
+		return err
+	}
+	if offloaded.MainContent, err = s.offload(ctx, "content/"+doc.HashID+".txt", doc.MainContent); err != nil {
+		return err
+	}
+	return s.next.StoreDocument(ctx, &offloaded)
+}
+
+func (s *BlobOffloadStorer) offload(ctx context.Context, key, value string) (string, error) {
+	if value == "" || len(value) <= s.threshold {
+		return value, nil
+	}
+	ref, err := s.blobs.Put(ctx, key, []byte(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to offload %s: %w", key, err)
+	}
+	return ref, nil
+}
+
+// Close closes the wrapped storer.
+func (s *BlobOffloadStorer) Close() {
+	s.next.Close()
+}
+
+// Unwrap returns the storer documents are passed on to. Documents read back
+// through it still hold blob references.
+func (s *BlobOffloadStorer) Unwrap() []Storer {
+	return []Storer{s.next}
+}
+
+// ResolveBlobRef returns the data behind value if it is a blob reference, or
+// value itself otherwise.
+func ResolveBlobRef(ctx context.Context, blobs BlobStore, value string) (string, error) {
+	if blobs == nil || !IsBlobRef(value) {
+		return value, nil
+	}
+	data, err := blobs.Get(ctx, value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
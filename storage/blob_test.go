@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// recordingStorer keeps the documents stored through it.
+type recordingStorer struct {
+	fakeStorer
+	docs []*WebDocument
+}
+
+func (r *recordingStorer) StoreDocument(ctx context.Context, doc *WebDocument) error {
+	r.docs = append(r.docs, doc)
+	return nil
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	ref, err := store.Put(ctx, "html/page.html", []byte("<p>page</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBlobRef(ref) {
+		t.Errorf("Put returned %q, want a blob reference", ref)
+	}
+	if data, err := store.Get(ctx, ref); err != nil || string(data) != "<p>page</p>" {
+		t.Errorf("Get(%q) = %q, %v, want the stored data", ref, data, err)
+	}
+
+	for _, key := range []string{"../outside.html", "html/../../outside.html"} {
+		if _, err := store.Put(ctx, key, []byte("x")); err == nil || !strings.Contains(err.Error(), "escapes the blob directory") {
+			t.Errorf("Put(%q) = %v, want an error for a key escaping the directory", key, err)
+		}
+	}
+	if _, err := store.Get(ctx, "s3://bucket/key"); err == nil {
+		t.Error("Get of an s3 reference succeeded on a local blob store")
+	}
+}
+
+func TestBlobOffloadStorer(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &recordingStorer{}
+	offload := NewBlobOffloadStorer(next, store, 10)
+	ctx := context.Background()
+
+	// Both pages have the same main content, and so the same hash_id.
+	docs := []*WebDocument{
+		{HashID: "same", URL: "https://example.com/a", HTMLSource: "<html>page a</html>", MainContent: "short"},
+		{HashID: "same", URL: "https://example.com/b", HTMLSource: "<html>page b</html>", MainContent: "short"},
+	}
+	for _, doc := range docs {
+		if err := offload.StoreDocument(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(next.docs) != len(docs) {
+		t.Fatalf("%d documents passed on, want %d", len(next.docs), len(docs))
+	}
+	for i, stored := range next.docs {
+		if stored.MainContent != "short" {
+			t.Errorf("main content under the threshold was offloaded to %q", stored.MainContent)
+		}
+		if !IsBlobRef(stored.HTMLSource) {
+			t.Fatalf("HTML over the threshold was stored inline: %q", stored.HTMLSource)
+		}
+		html, err := ResolveBlobRef(ctx, store, stored.HTMLSource)
+		if err != nil || html != docs[i].HTMLSource {
+			t.Errorf("HTML of %s resolves to %q, %v, want %q", stored.URL, html, err, docs[i].HTMLSource)
+		}
+	}
+}
+
+func TestResolveBlobRef(t *testing.T) {
+	ctx := context.Background()
+	if got, err := ResolveBlobRef(ctx, nil, "file:///blobs/x.html"); got != "file:///blobs/x.html" || err != nil {
+		t.Errorf("ResolveBlobRef without a blob store = %q, %v, want the value unchanged", got, err)
+	}
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ResolveBlobRef(ctx, store, "<p>inline</p>"); got != "<p>inline</p>" || err != nil {
+		t.Errorf("ResolveBlobRef of inline data = %q, %v, want it unchanged", got, err)
+	}
+	if _, err := ResolveBlobRef(ctx, store, "file:///nonexistent/blob.html"); err == nil {
+		t.Error("ResolveBlobRef of a missing blob succeeded")
+	}
+}
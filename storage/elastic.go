@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"crawlengine/config"
+)
+
+// ElasticStorer indexes documents into an Elasticsearch or OpenSearch index
+// for full-text search. Documents are buffered and sent with the _bulk API
+// once BatchSize documents are pending, every FlushIntervalSec, and on Close.
+type ElasticStorer struct {
+	cfg        *config.ElasticConfig
+	dimension  int
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	pending []*WebDocument
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// elasticDocument is the indexed form of a WebDocument. The raw HTML is not
+// indexed.
+type elasticDocument struct {
+	URL                  string    `json:"url"`
+	Title                string    `json:"title"`
+	MetaDescription      string    `json:"meta_description,omitempty"`
+	MainContent          string    `json:"main_content"`
+	HeadingsText         string    `json:"headings_text,omitempty"`
+	CanonicalURL         string    `json:"canonical_url,omitempty"`
+	Language             string    `json:"language,omitempty"`
+	PublicationTimestamp int64     `json:"publication_timestamp,omitempty"`
+	CrawledAt            int64     `json:"crawled_at"`
+	ContentVector        []float32 `json:"content_vector,omitempty"`
+}
+
+// NewElasticStorer connects to the configured cluster and creates the index
+// with explicit mappings if it doesn't exist yet. dimension is the embedding
+// dimension, used when vectors are stored.
+func NewElasticStorer(ctx context.Context, cfg *config.ElasticConfig, dimension int) (*ElasticStorer, error) {
+	es := &ElasticStorer{
+		cfg:        cfg,
+		dimension:  dimension,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := es.ensureIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	go es.flushLoop()
+	return es, nil
+}
+
+func (es *ElasticStorer) ensureIndex(ctx context.Context) error {
+	resp, err := es.do(ctx, http.MethodHead, "/"+es.cfg.Index, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check for index %s: %w", es.cfg.Index, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		log.Printf("Index '%s' already exists.", es.cfg.Index)
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status %s checking for index %s", resp.Status, es.cfg.Index)
+	}
+
+	log.Printf("Index '%s' does not exist. Creating...", es.cfg.Index)
+	properties := map[string]any{
+		"url":                   map[string]any{"type": "keyword"},
+		"title":                 map[string]any{"type": "text"},
+		"meta_description":      map[string]any{"type": "text"},
+		"main_content":          map[string]any{"type": "text"},
+		"headings_text":         map[string]any{"type": "text"},
+		"canonical_url":         map[string]any{"type": "keyword"},
+		"language":              map[string]any{"type": "keyword"},
+		"publication_timestamp": map[string]any{"type": "date", "format": "epoch_second"},
+		"crawled_at":            map[string]any{"type": "date", "format": "epoch_second"},
+	}
+	body := map[string]any{"mappings": map[string]any{"properties": properties}}
+	if es.cfg.StoreVector {
+		// OpenSearch names its vector type knn_vector and needs k-NN enabled on the index.
+		if strings.EqualFold(es.cfg.VectorFieldType, "knn_vector") {
+			properties["content_vector"] = map[string]any{"type": "knn_vector", "dimension": es.dimension}
+			body["settings"] = map[string]any{"index": map[string]any{"knn": true}}
+		} else {
+			properties["content_vector"] = map[string]any{"type": "dense_vector", "dims": es.dimension}
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize mappings for index %s: %w", es.cfg.Index, err)
+	}
+	resp, err = es.do(ctx, http.MethodPut, "/"+es.cfg.Index, data)
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %w", es.cfg.Index, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to create index %s: status %s: %s", es.cfg.Index, resp.Status, msg)
+	}
+	log.Printf("Index '%s' created successfully.", es.cfg.Index)
+	return nil
+}
+
+// StoreDocument queues doc for indexing and sends the batch once it is full.
+func (es *ElasticStorer) StoreDocument(ctx context.Context, doc *WebDocument) error {
+	if doc == nil {
+		return fmt.Errorf("cannot store nil document")
+	}
+	es.mu.Lock()
+	es.pending = append(es.pending, doc)
+	full := len(es.pending) >= es.cfg.BatchSize
+	es.mu.Unlock()
+
+	if full {
+		return es.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush sends all pending documents in one bulk request. Documents the
+// request fails for, or that fail with a retryable status (429 or 5xx), are
+// put back to be sent by the next flush; other failed documents are dropped.
+// Each failed document is logged with its ID.
+func (es *ElasticStorer) Flush(ctx context.Context) error {
+	es.mu.Lock()
+	batch := es.pending
+	es.pending = nil
+	es.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	sent := make([]*WebDocument, 0, len(batch))
+	for _, doc := range batch {
+		action, err := json.Marshal(map[string]any{"index": map[string]any{"_index": es.cfg.Index, "_id": doc.HashID}})
+		if err == nil {
+			var source []byte
+			if source, err = json.Marshal(es.toElasticDocument(doc)); err == nil {
+				body.Write(action)
+				body.WriteByte('\n')
+				body.Write(source)
+				body.WriteByte('\n')
+				sent = append(sent, doc)
+				continue
+			}
+		}
+		log.Printf("Error serializing document ID %s for index %s, dropping it: %v", doc.HashID, es.cfg.Index, err)
+	}
+	if len(sent) == 0 {
+		return fmt.Errorf("failed to serialize any of %d documents for index %s", len(batch), es.cfg.Index)
+	}
+
+	resp, err := es.do(ctx, http.MethodPost, "/_bulk", body.Bytes())
+	if err != nil {
+		es.requeue(sent)
+		return fmt.Errorf("failed to bulk index %d documents into %s, keeping them for the next flush: %w", len(sent), es.cfg.Index, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		es.requeue(sent)
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to bulk index %d documents into %s, keeping them for the next flush: status %s: %s", len(sent), es.cfg.Index, resp.Status, msg)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		// Which documents were indexed is unknown; indexing them again under
+		// the same IDs is harmless.
+		es.requeue(sent)
+		return fmt.Errorf("failed to parse bulk response from %s, keeping %d documents for the next flush: %w", es.cfg.Index, len(sent), err)
+	}
+	if result.Errors {
+		var failed []string
+		var retry []*WebDocument
+		for i, item := range result.Items { // items are in the order of the request
+			for _, status := range item {
+				if len(status.Error) == 0 || string(status.Error) == "null" {
+					continue
+				}
+				failed = append(failed, status.ID)
+				retryable := status.Status == http.StatusTooManyRequests || status.Status >= 500
+				if retryable && i < len(sent) {
+					retry = append(retry, sent[i])
+				}
+				log.Printf("Document ID %s failed to index into %s (status %d, retry %t): %s", status.ID, es.cfg.Index, status.Status, retryable, status.Error)
+			}
+		}
+		es.requeue(retry)
+		return fmt.Errorf("%d of %d documents failed to index into %s, %d kept for the next flush: %s",
+			len(failed), len(sent), es.cfg.Index, len(retry), strings.Join(failed, ", "))
+	}
+	log.Printf("Bulk indexed %d documents into index '%s'", len(sent), es.cfg.Index)
+	return nil
+}
+
+// requeue puts docs back in front of the pending documents.
+func (es *ElasticStorer) requeue(docs []*WebDocument) {
+	if len(docs) == 0 {
+		return
+	}
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.pending = append(append([]*WebDocument(nil), docs...), es.pending...)
+}
+
+func (es *ElasticStorer) toElasticDocument(doc *WebDocument) elasticDocument {
+	edoc := elasticDocument{
+		URL:                  doc.URL,
+		Title:                doc.Title,
+		MetaDescription:      doc.MetaDescription,
+		MainContent:          doc.MainContent,
+		HeadingsText:         doc.HeadingsText,
+		CanonicalURL:         doc.CanonicalURL,
+		Language:             doc.Language,
+		PublicationTimestamp: doc.PublicationTimestamp,
+		CrawledAt:            doc.CrawledAt.Unix(),
+	}
+	if es.cfg.StoreVector && len(doc.ContentVector) == es.dimension {
+		edoc.ContentVector = doc.ContentVector
+	}
+	return edoc
+}
+
+func (es *ElasticStorer) flushLoop() {
+	defer close(es.done)
+	if es.cfg.FlushIntervalSec <= 0 {
+		<-es.stop
+		return
+	}
+	ticker := time.NewTicker(time.Duration(es.cfg.FlushIntervalSec) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := es.Flush(context.Background()); err != nil {
+				log.Printf("Error flushing documents to Elasticsearch: %v", err)
+			}
+		case <-es.stop:
+			return
+		}
+	}
+}
+
+func (es *ElasticStorer) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(es.cfg.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		contentType := "application/json"
+		if path == "/_bulk" {
+			contentType = "application/x-ndjson"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case es.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+es.cfg.APIKey)
+	case es.cfg.Username != "":
+		req.SetBasicAuth(es.cfg.Username, es.cfg.Password)
+	}
+	return es.httpClient.Do(req)
+}
+
+// Close stops the periodic flush and sends any pending documents.
+func (es *ElasticStorer) Close() {
+	close(es.stop)
+	<-es.done
+	if err := es.Flush(context.Background()); err != nil {
+		log.Printf("Error flushing documents to Elasticsearch on close: %v", err)
+		return
+	}
+	log.Println("Elasticsearch storer closed.")
+}
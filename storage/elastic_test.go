@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"crawlengine/config"
+)
+
+// fakeElastic serves an index that doesn't exist yet and the _bulk API,
+// answering each bulk item with the status listed for its ID, 201 if none.
+type fakeElastic struct {
+	statuses map[string]int
+
+	mu      sync.Mutex
+	mapping map[string]any
+	bulks   [][]string // IDs of each bulk request
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodHead && r.URL.Path == "/docs":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPut && r.URL.Path == "/docs":
+		json.NewDecoder(r.Body).Decode(&f.mapping)
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			http.Error(w, "bulk body must be NDJSON", http.StatusBadRequest)
+			return
+		}
+		var ids []string
+		var items []map[string]any
+		hasErrors := false
+		lines := bufio.NewScanner(r.Body)
+		for lines.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				} `json:"index"`
+			}
+			var source elasticDocument
+			if json.Unmarshal(lines.Bytes(), &action) != nil || action.Index.Index != "docs" ||
+				!lines.Scan() || json.Unmarshal(lines.Bytes(), &source) != nil || source.URL == "" {
+				http.Error(w, "malformed bulk body", http.StatusBadRequest)
+				return
+			}
+			ids = append(ids, action.Index.ID)
+			item := map[string]any{"_id": action.Index.ID, "status": http.StatusCreated}
+			if status, ok := f.statuses[action.Index.ID]; ok {
+				item["status"] = status
+				item["error"] = map[string]any{"type": "test_failure"}
+				hasErrors = true
+			}
+			items = append(items, map[string]any{"index": item})
+		}
+		f.bulks = append(f.bulks, ids)
+		json.NewEncoder(w).Encode(map[string]any{"errors": hasErrors, "items": items})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestElasticStorer(t *testing.T, fake http.Handler) *ElasticStorer {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cfg := &config.ElasticConfig{Endpoint: server.URL, Index: "docs", BatchSize: 10, StoreVector: true}
+	es, err := NewElasticStorer(context.Background(), cfg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(es.Close)
+	return es
+}
+
+func storeTestDocuments(t *testing.T, es *ElasticStorer, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		doc := &WebDocument{HashID: id, URL: "https://example.com/" + id, CrawledAt: time.Now(), ContentVector: []float32{1, 2}}
+		if err := es.StoreDocument(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestElasticStorerCreatesIndex(t *testing.T) {
+	fake := &fakeElastic{}
+	newTestElasticStorer(t, fake)
+
+	properties, _ := fake.mapping["mappings"].(map[string]any)["properties"].(map[string]any)
+	vector, _ := properties["content_vector"].(map[string]any)
+	if vector["type"] != "dense_vector" || vector["dims"] != float64(2) {
+		t.Errorf("content_vector mapping = %v, want a 2-dimensional dense_vector", vector)
+	}
+	if url, _ := properties["url"].(map[string]any); url["type"] != "keyword" {
+		t.Errorf("url mapping = %v, want a keyword", url)
+	}
+}
+
+func TestElasticStorerFlushRequeuesFailures(t *testing.T) {
+	fake := &fakeElastic{statuses: map[string]int{"busy": http.StatusTooManyRequests, "bad": http.StatusBadRequest}}
+	es := newTestElasticStorer(t, fake)
+	storeTestDocuments(t, es, "a", "busy", "bad", "b")
+
+	err := es.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "busy, bad") {
+		t.Fatalf("Flush = %v, want an error naming busy and bad", err)
+	}
+	delete(fake.statuses, "busy")
+	if err := es.Flush(context.Background()); err != nil {
+		t.Fatalf("second Flush: %v", err)
+	}
+
+	want := [][]string{{"a", "busy", "bad", "b"}, {"busy"}}
+	if !slices.EqualFunc(fake.bulks, want, slices.Equal) {
+		t.Errorf("bulk requests = %v, want %v", fake.bulks, want)
+	}
+}
+
+func TestElasticStorerFlushKeepsBatchOnRequestFailure(t *testing.T) {
+	fake := &fakeElastic{}
+	var down atomic.Bool
+	down.Store(true)
+	es := newTestElasticStorer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() && r.URL.Path == "/_bulk" {
+			io.Copy(io.Discard, r.Body)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	storeTestDocuments(t, es, "a", "b")
+
+	if err := es.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded against a failing cluster")
+	}
+	down.Store(false)
+	storeTestDocuments(t, es, "c")
+	if err := es.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush after recovery: %v", err)
+	}
+	if want := [][]string{{"a", "b", "c"}}; !slices.EqualFunc(fake.bulks, want, slices.Equal) {
+		t.Errorf("bulk requests = %v, want %v", fake.bulks, want)
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// flushCounter counts the rows and bytes inserted since the collection was
+// last flushed.
+type flushCounter struct {
+	mu    sync.Mutex
+	rows  int
+	bytes int64
+}
+
+// add counts an insert and reports whether the collection is due a flush:
+// maxRows rows or maxBytes bytes have been inserted since the last one, or
+// on every insert if neither threshold is set. The count restarts when a
+// flush is due.
+func (fc *flushCounter) add(rows int, bytes int64, maxRows int, maxBytes int64) (due bool, totalRows int, totalBytes int64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.rows += rows
+	fc.bytes += bytes
+	totalRows, totalBytes = fc.rows, fc.bytes
+	switch {
+	case maxRows <= 0 && maxBytes <= 0:
+	case maxRows > 0 && fc.rows >= maxRows, maxBytes > 0 && fc.bytes >= maxBytes:
+	default:
+		return false, totalRows, totalBytes
+	}
+	fc.rows, fc.bytes = 0, 0
+	return true, totalRows, totalBytes
+}
+
+// take returns the rows and bytes not flushed yet and restarts the count.
+func (fc *flushCounter) take() (int, int64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	rows, bytes := fc.rows, fc.bytes
+	fc.rows, fc.bytes = 0, 0
+	return rows, bytes
+}
+
+// flushThresholds reports whether flush_every_rows or flush_every_mb is set.
+func (ms *MilvusStorer) flushThresholds() bool {
+	return ms.cfg.FlushEveryRows > 0 || ms.cfg.FlushEveryMB > 0
+}
+
+// countInserted counts inserted columns toward the flush thresholds and
+// flushes the collection when one is reached.
+func (ms *MilvusStorer) countInserted(ctx context.Context, columns []entity.Column) {
+	rows, bytes := columnsSize(columns)
+	due, totalRows, totalBytes := ms.flushes.add(rows, bytes, ms.cfg.FlushEveryRows, int64(ms.cfg.FlushEveryMB)<<20)
+	if !due {
+		return
+	}
+	if ms.flushThresholds() {
+		log.Printf("Flushing collection %s after %d rows (%.1f MB) since the last flush", ms.cfg.CollectionName, totalRows, float64(totalBytes)/(1<<20))
+	}
+	ms.flush(ctx)
+}
+
+// flush seals the collection's growing segments. Failures are only logged:
+// the data is already inserted and Milvus seals segments on its own too.
+func (ms *MilvusStorer) flush(ctx context.Context) {
+	err := ms.withRetry(ctx, "Flush", func(ctx context.Context) error {
+		return ms.milvusClient.Flush(ctx, ms.cfg.CollectionName, false)
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to flush collection %s: %v", ms.cfg.CollectionName, err)
+	} else {
+		log.Printf("Collection %s flushed.", ms.cfg.CollectionName)
+	}
+}
+
+// columnsSize returns the rows in columns and their approximate size in
+// bytes: the length of strings, 4 bytes per vector dimension and 8 bytes
+// per other value.
+func columnsSize(columns []entity.Column) (int, int64) {
+	rows := 0
+	var bytes int64
+	for _, column := range columns {
+		rows = max(rows, column.Len())
+		switch c := column.(type) {
+		case *entity.ColumnVarChar:
+			for _, value := range c.Data() {
+				bytes += int64(len(value))
+			}
+		case *entity.ColumnFloatVector:
+			bytes += int64(c.Len()) * int64(c.Dim()) * 4
+		default:
+			bytes += int64(column.Len()) * 8
+		}
+	}
+	return rows, bytes
+}
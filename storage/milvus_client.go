@@ -9,6 +9,7 @@ import (
 
 	"crawlengine/config"
 
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
@@ -17,6 +18,7 @@ type WebDocument struct {
 	HashID               string    `json:"hash_id"`
 	URL                  string    `json:"url"`
 	HTMLSource           string    `json:"html_source"`
+	HTMLRetained         bool      `json:"html_retained"` // false when html_storage dropped the HTML of a well-extracted page
 	MainContent          string    `json:"main_content"`
 	Title                string    `json:"title"`
 	MetaDescription      string    `json:"meta_description"`
@@ -26,17 +28,129 @@ type WebDocument struct {
 	HeadingsText         string    `json:"headings_text"`
 	CrawledAt            time.Time `json:"crawled_at"`
 	ContentVector        []float32 `json:"content_vector"`
+	TitleVector          []float32 `json:"title_vector"` // embedding of the title and headings; only stored with title_vector
+	DuplicateOf          string    `json:"duplicate_of"`
+	TablesJSON           string    `json:"tables_json"`
+	ResponseHeaders      string    `json:"response_headers"`
+	ExtractionVersion    string    `json:"extraction_version"`
+	QualityScore         float32   `json:"quality_score"`
+	NeedsEmbedding       bool      `json:"needs_embedding"`
+	InboundLinks         int64     `json:"inbound_links"`
+	HreflangJSON         string    `json:"hreflang_json"`
+	OutboundAnchorsJSON  string    `json:"outbound_anchors_json"`
+	BodyHash             string    `json:"body_hash"` // SHA256 of the raw response body, unlike the content-derived hash_id
+
+	// CrawlDepth is the link depth the page was found at; only stored with extended_metadata.
+	CrawlDepth int64 `json:"crawl_depth"`
+
+	// SeedURL is the seed whose crawl branch reached the page, its own URL
+	// for seeds, and ReferrerURL the page that linked to it, empty for
+	// seeds; only stored with extended_metadata.
+	SeedURL     string `json:"seed_url"`
+	ReferrerURL string `json:"referrer_url"`
+
+	// HTMLTruncation is how HTMLSource was cut to fit max_length_html:
+	// "smart", "naive" or "dropped", empty if it fit; only stored with
+	// extended_metadata.
+	HTMLTruncation string `json:"html_truncation"`
+
+	// ContentFingerprint is the hash of the main content alone, unlike
+	// hash_id not salted with the extraction version, so it only changes
+	// with the content; only stored with extended_metadata.
+	ContentFingerprint string `json:"content_fingerprint"`
+
+	// MetaJSON maps the page's <meta> names and properties to their content;
+	// only stored with extended_metadata.
+	MetaJSON string `json:"meta_json"`
+
+	// RedirectChain is a JSON array of the URLs whose redirects were followed
+	// to reach URL, starting with the one the crawler requested; empty if the
+	// page wasn't redirected. Only stored with extended_metadata.
+	RedirectChain string `json:"redirect_chain"`
+
+	// Gated is set on pages classified as a paywall or consent interstitial
+	// under gated_policy mark; only stored with extended_metadata.
+	Gated bool `json:"gated"`
+
+	// BreadcrumbsJSON is the page's breadcrumb trail and primary navigation;
+	// only stored with extended_metadata.
+	BreadcrumbsJSON string `json:"breadcrumbs_json"`
 }
 
+// Policies for on_missing_vector, applied to documents without an embedding.
+const (
+	// MissingVectorZero inserts a zero vector placeholder.
+	MissingVectorZero = "zero"
+	// MissingVectorSkip inserts a zero vector placeholder with has_vector=false,
+	// so the row can be found and embedded later.
+	MissingVectorSkip = "skip"
+	// MissingVectorError rejects the document.
+	MissingVectorError = "error"
+)
+
+// Policies for on_dimension_mismatch, applied when the embedder produces
+// vectors of another dimension than embedding_dimension.
+const (
+	// DimensionMismatchFail refuses to start.
+	DimensionMismatchFail = "fail"
+	// DimensionMismatchNewCollection stores into a new collection, named
+	// after the configured one and the new dimension, created if needed.
+	DimensionMismatchNewCollection = "new_collection"
+)
+
+// extendedMetadataFields are only stored with extended_metadata, so
+// collections created before they were added keep working unchanged.
+var extendedMetadataFields = []string{"body_hash", "crawl_depth", "content_fingerprint", "meta_json", "redirect_chain", "gated", "breadcrumbs_json", "seed_url", "referrer_url", "html_truncation"}
+
+// maxShardNum is the default proxy.maxShardNum limit of Milvus.
+const maxShardNum = 16
+
+// maxLengthExtractionVersion is the schema length of the extraction_version field.
+const maxLengthExtractionVersion = 64
+
+// maxLengthHTMLTruncation is the schema length of the html_truncation field.
+const maxLengthHTMLTruncation = 16
+
 type MilvusStorer struct {
 	milvusClient client.Client
 	cfg          *config.MilvusConfig
+	fields       map[string]bool // fields stored in the collection
+	flushes      flushCounter    // inserts since the last flush
 }
 
-func NewMilvusStorer(ctx context.Context, cfg *config.MilvusConfig) (*MilvusStorer, error) {
+// NewMilvusStorer connects to Milvus and ensures the collection exists.
+// crawlerCfg decides which feature fields are stored.
+func NewMilvusStorer(ctx context.Context, cfg *config.MilvusConfig, crawlerCfg *config.CrawlerConfig) (*MilvusStorer, error) {
 	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
 	log.Printf("Connecting to Milvus at %s", addr)
 
+	fields, err := resolveStoredFields(cfg.StoredFields)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ShardNum < 0 || cfg.ShardNum > maxShardNum {
+		return nil, fmt.Errorf("shard_num must be between 1 and %d (0 for the Milvus default), got %d", maxShardNum, cfg.ShardNum)
+	}
+	// The title vector doubles the embedding cost, so title_vector rather than
+	// stored_fields decides whether it is stored.
+	if cfg.TitleVector {
+		fields[FieldTitleVector] = true
+	} else {
+		delete(fields, FieldTitleVector)
+	}
+	for _, name := range extendedMetadataFields {
+		if !cfg.ExtendedMetadata {
+			delete(fields, name)
+		} else if len(cfg.StoredFields) == 0 {
+			fields[name] = true
+		}
+	}
+	for name, enabled := range featureFields(crawlerCfg) {
+		if !enabled {
+			delete(fields, name)
+		}
+	}
+
 	cli, err := client.NewClient(ctx, client.Config{Address: addr})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Milvus: %w", err)
@@ -46,6 +160,25 @@ func NewMilvusStorer(ctx context.Context, cfg *config.MilvusConfig) (*MilvusStor
 	storer := &MilvusStorer{
 		milvusClient: cli,
 		cfg:          cfg,
+		fields:       fields,
+	}
+
+	switch strings.ToLower(cfg.OnDimensionMismatch) {
+	case "", DimensionMismatchFail, DimensionMismatchNewCollection:
+	default:
+		log.Printf("Warning: Unsupported on_dimension_mismatch '%s' in config, defaulting to '%s'.", cfg.OnDimensionMismatch, DimensionMismatchFail)
+	}
+
+	switch strings.ToLower(cfg.OnMissingVector) {
+	case MissingVectorSkip, MissingVectorError:
+	case "", MissingVectorZero:
+		log.Printf("Warning: on_missing_vector is '%s'; documents without embeddings are stored with zero vectors that show up in similarity searches. Use 'skip' to exclude them via has_vector.", MissingVectorZero)
+	default:
+		log.Printf("Warning: Unsupported on_missing_vector '%s' in config, defaulting to '%s'.", cfg.OnMissingVector, MissingVectorZero)
+	}
+
+	if metric := strings.ToUpper(cfg.MetricType); metric != "IP" && metric != "L2" {
+		log.Printf("Warning: Invalid MetricType '%s' in config, defaulting to L2.", cfg.MetricType)
 	}
 
 	// Ensure collection exists
@@ -58,54 +191,171 @@ func NewMilvusStorer(ctx context.Context, cfg *config.MilvusConfig) (*MilvusStor
 }
 
 func (ms *MilvusStorer) ensureCollection(ctx context.Context) error {
-	exists, err := ms.milvusClient.HasCollection(ctx, ms.cfg.CollectionName)
+	var exists bool
+	err := ms.withRetry(ctx, "HasCollection", func(ctx context.Context) error {
+		var err error
+		exists, err = ms.milvusClient.HasCollection(ctx, ms.cfg.CollectionName)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to check for collection %s: %w", ms.cfg.CollectionName, err)
 	}
 
 	if exists {
 		log.Printf("Collection '%s' already exists.", ms.cfg.CollectionName)
+		if err := ms.checkCollectionFields(ctx); err != nil {
+			return err
+		}
+		if ms.stores("crawled_at") {
+			if err := ms.ensureScalarIndex(ctx, "crawled_at"); err != nil {
+				log.Printf("Warning: %v. Time range queries will scan the collection.", err)
+			}
+		}
 		return nil
 	}
 
 	log.Printf("Collection '%s' does not exist. Creating...", ms.cfg.CollectionName)
 
+	allFields := []*entity.Field{
+		entity.NewField().WithName("hash_id").WithDataType(entity.FieldTypeVarChar).WithIsPrimaryKey(true).WithMaxLength(64),
+		entity.NewField().WithName("url").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthURL)),
+		entity.NewField().WithName("html_source").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthHTML)),
+		entity.NewField().WithName("main_content").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthContent)),
+		entity.NewField().WithName("title").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthTitle)),
+		entity.NewField().WithName("meta_description").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthMetaDesc)),
+		entity.NewField().WithName("canonical_url").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthCanonicalURL)),
+		entity.NewField().WithName("language").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthLanguage)),
+		entity.NewField().WithName("publication_timestamp").WithDataType(entity.FieldTypeInt64), // Stores Unix timestamp
+		entity.NewField().WithName("headings_text").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthHeadings)),
+		entity.NewField().WithName("crawled_at").WithDataType(entity.FieldTypeInt64), // Stores Unix timestamp
+		entity.NewField().WithName("duplicate_of").WithDataType(entity.FieldTypeVarChar).WithMaxLength(64),
+		entity.NewField().WithName("tables_json").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthTables)),
+		entity.NewField().WithName("response_headers").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthHeaders)),
+		entity.NewField().WithName("extraction_version").WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxLengthExtractionVersion),
+		entity.NewField().WithName("quality_score").WithDataType(entity.FieldTypeFloat),
+		entity.NewField().WithName("has_vector").WithDataType(entity.FieldTypeBool),
+		entity.NewField().WithName("needs_embedding").WithDataType(entity.FieldTypeBool),
+		entity.NewField().WithName("inbound_links").WithDataType(entity.FieldTypeInt64),
+		entity.NewField().WithName("hreflang_json").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthHreflang)),
+		entity.NewField().WithName("outbound_anchors_json").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthAnchors)),
+		entity.NewField().WithName("body_hash").WithDataType(entity.FieldTypeVarChar).WithMaxLength(64),
+		entity.NewField().WithName("html_retained").WithDataType(entity.FieldTypeBool),
+		entity.NewField().WithName("crawl_depth").WithDataType(entity.FieldTypeInt64),
+		entity.NewField().WithName("content_fingerprint").WithDataType(entity.FieldTypeVarChar).WithMaxLength(64),
+		entity.NewField().WithName("meta_json").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthMeta)),
+		entity.NewField().WithName("redirect_chain").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthRedirects)),
+		entity.NewField().WithName("gated").WithDataType(entity.FieldTypeBool),
+		entity.NewField().WithName("breadcrumbs_json").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthCrumbs)),
+		entity.NewField().WithName("seed_url").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthURL)),
+		entity.NewField().WithName("referrer_url").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthURL)),
+		entity.NewField().WithName("html_truncation").WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxLengthHTMLTruncation),
+		entity.NewField().WithName("content_vector").WithDataType(entity.FieldTypeFloatVector).WithDim(int64(ms.cfg.EmbeddingDimension)),
+		entity.NewField().WithName("title_vector").WithDataType(entity.FieldTypeFloatVector).WithDim(int64(ms.cfg.TitleEmbeddingDimension)),
+	}
 	schema := &entity.Schema{
 		CollectionName: ms.cfg.CollectionName,
 		Description:    "Web documents crawled for AI search engine",
 		AutoID:         false,
-		Fields: []*entity.Field{
-			entity.NewField().WithName("hash_id").WithDataType(entity.FieldTypeVarChar).WithIsPrimaryKey(true).WithMaxLength(64),
-			entity.NewField().WithName("url").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthURL)),
-			entity.NewField().WithName("html_source").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthHTML)),
-			entity.NewField().WithName("main_content").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthContent)),
-			entity.NewField().WithName("title").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthTitle)),
-			entity.NewField().WithName("meta_description").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthMetaDesc)),
-			entity.NewField().WithName("canonical_url").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthCanonicalURL)),
-			entity.NewField().WithName("language").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthLanguage)),
-			entity.NewField().WithName("publication_timestamp").WithDataType(entity.FieldTypeInt64), // Stores Unix timestamp
-			entity.NewField().WithName("headings_text").WithDataType(entity.FieldTypeVarChar).WithMaxLength(int64(ms.cfg.MaxLengthHeadings)),
-			entity.NewField().WithName("crawled_at").WithDataType(entity.FieldTypeInt64), // Stores Unix timestamp
-			entity.NewField().WithName("content_vector").WithDataType(entity.FieldTypeFloatVector).WithDim(int64(ms.cfg.EmbeddingDimension)),
-		},
-	}
-
-	err = ms.milvusClient.CreateCollection(ctx, schema, entity.DefaultShardNumber) // entity.DefaultShardNumber or specify
+	}
+	for _, field := range allFields {
+		if ms.stores(field.Name) {
+			schema.Fields = append(schema.Fields, field)
+		}
+	}
+	if len(schema.Fields) < len(allFields) {
+		log.Printf("Storing %d of %d document fields (stored_fields, extended_metadata and enabled features)", len(schema.Fields), len(allFields))
+	}
+
+	shardNum := entity.DefaultShardNumber
+	if ms.cfg.ShardNum > 0 {
+		shardNum = int32(ms.cfg.ShardNum)
+	}
+	err = ms.milvusClient.CreateCollection(ctx, schema, shardNum)
 	if err != nil {
 		return fmt.Errorf("failed to create collection %s: %w", ms.cfg.CollectionName, err)
 	}
-	log.Printf("Collection '%s' created successfully.", ms.cfg.CollectionName)
+	if shardNum != entity.DefaultShardNumber {
+		log.Printf("Collection '%s' created successfully with %d shards.", ms.cfg.CollectionName, shardNum)
+	} else {
+		log.Printf("Collection '%s' created successfully.", ms.cfg.CollectionName)
+	}
 
-	log.Printf("Creating index for field 'content_vector' in collection '%s'...", ms.cfg.CollectionName)
-	var idx entity.Index // Declare idx as the interface type entity.Index
+	for _, fieldName := range []string{FieldContentVector, FieldTitleVector} {
+		if !ms.stores(fieldName) {
+			continue
+		}
+		if err := ms.createVectorIndex(ctx, fieldName); err != nil {
+			return err
+		}
+	}
+
+	if ms.stores("crawled_at") {
+		if err := ms.ensureScalarIndex(ctx, "crawled_at"); err != nil {
+			return err
+		}
+	}
+
+	err = ms.milvusClient.LoadCollection(ctx, ms.cfg.CollectionName, false)
+	if err != nil {
+		return fmt.Errorf("failed to load collection %s: %w", ms.cfg.CollectionName, err)
+	}
+	log.Printf("Collection '%s' loaded.", ms.cfg.CollectionName)
+
+	return nil
+}
+
+// EnsureEmbeddingDimension checks that the collection's vector fields have
+// the dimensions the embedders were found to produce, dim for content_vector
+// and titleDim for title_vector, so a changed model is caught before the
+// first insert rather than failing every one. A dimension of 0 is unknown and
+// not checked. On a mismatch it returns an error, or with
+// on_dimension_mismatch set to new_collection switches to a collection of
+// the new dimensions, updating collection_name and the embedding dimensions
+// in the config it was created with.
+func (ms *MilvusStorer) EnsureEmbeddingDimension(ctx context.Context, dim, titleDim int) error {
+	if dim == 0 {
+		dim = ms.cfg.EmbeddingDimension
+	}
+	if titleDim == 0 || !ms.stores(FieldTitleVector) {
+		titleDim = ms.cfg.TitleEmbeddingDimension
+	}
+	if dim == ms.cfg.EmbeddingDimension && titleDim == ms.cfg.TitleEmbeddingDimension {
+		return nil
+	}
+	if strings.ToLower(ms.cfg.OnDimensionMismatch) != DimensionMismatchNewCollection {
+		return fmt.Errorf("embedders produce %d-dimensional content and %d-dimensional title vectors, but collection %s expects %d and %d (embedding_dimension, title_embedding_dimension); "+
+			"set them accordingly with a new collection_name, or set on_dimension_mismatch to %s",
+			dim, titleDim, ms.cfg.CollectionName, ms.cfg.EmbeddingDimension, ms.cfg.TitleEmbeddingDimension, DimensionMismatchNewCollection)
+	}
+
+	name := fmt.Sprintf("%s_dim%d", ms.cfg.CollectionName, dim)
+	if ms.stores(FieldTitleVector) && titleDim != dim {
+		name = fmt.Sprintf("%s_title%d", name, titleDim)
+	}
+	log.Printf("Warning: embedders produce %d-dimensional content and %d-dimensional title vectors, but collection '%s' expects %d and %d. Storing into collection '%s' instead (on_dimension_mismatch: %s).",
+		dim, titleDim, ms.cfg.CollectionName, ms.cfg.EmbeddingDimension, ms.cfg.TitleEmbeddingDimension, name, DimensionMismatchNewCollection)
+	ms.cfg.CollectionName = name
+	ms.cfg.EmbeddingDimension = dim
+	ms.cfg.TitleEmbeddingDimension = titleDim
+	return ms.ensureCollection(ctx)
+}
 
-	metricType := entity.L2
+// metricType returns the configured similarity metric, L2 unless it is IP.
+func (ms *MilvusStorer) metricType() entity.MetricType {
 	if strings.ToUpper(ms.cfg.MetricType) == "IP" {
-		metricType = entity.IP
-	} else if strings.ToUpper(ms.cfg.MetricType) != "L2" {
-		log.Printf("Warning: Invalid MetricType '%s' in config, defaulting to L2.", ms.cfg.MetricType)
+		return entity.IP
 	}
+	return entity.L2
+}
+
+// createVectorIndex creates the configured index on the vector field
+// fieldName, waiting for it to build if wait_for_index is set.
+func (ms *MilvusStorer) createVectorIndex(ctx context.Context, fieldName string) error {
+	log.Printf("Creating index for field '%s' in collection '%s'...", fieldName, ms.cfg.CollectionName)
+	var idx entity.Index // Declare idx as the interface type entity.Index
+	var err error
 
+	metricType := ms.metricType()
 	if strings.ToUpper(ms.cfg.IndexType) == "IVF_FLAT" {
 		idx, err = entity.NewIndexIvfFlat(metricType, ms.cfg.Nlist)
 		if err != nil {
@@ -123,99 +373,338 @@ func (ms *MilvusStorer) ensureCollection(ctx context.Context) error {
 		idx, _ = entity.NewIndexIvfFlat(entity.L2, ms.cfg.Nlist) // Defaulting
 	}
 
-	err = ms.milvusClient.CreateIndex(ctx, ms.cfg.CollectionName, "content_vector", idx, false) // sync=false (async)
+	err = ms.milvusClient.CreateIndex(ctx, ms.cfg.CollectionName, fieldName, idx, false) // sync=false (async)
 	if err != nil {
-		return fmt.Errorf("failed to create index for collection %s on field 'content_vector': %w", ms.cfg.CollectionName, err)
+		return fmt.Errorf("failed to create index for collection %s on field '%s': %w", ms.cfg.CollectionName, fieldName, err)
 	}
-	log.Printf("Index for 'content_vector' on collection '%s' creation request sent.", ms.cfg.CollectionName)
+	log.Printf("Index for '%s' on collection '%s' creation request sent.", fieldName, ms.cfg.CollectionName)
 
-	err = ms.milvusClient.LoadCollection(ctx, ms.cfg.CollectionName, false)
-	if err != nil {
-		return fmt.Errorf("failed to load collection %s: %w", ms.cfg.CollectionName, err)
+	if ms.cfg.WaitForIndex {
+		if err := ms.waitForIndex(ctx, fieldName); err != nil {
+			return err
+		}
 	}
-	log.Printf("Collection '%s' loaded.", ms.cfg.CollectionName)
-
 	return nil
 }
+
+// waitForIndex polls the index state of fieldName until the build finishes,
+// fails, or IndexWaitTimeoutSec elapses, logging build progress along the way.
+func (ms *MilvusStorer) waitForIndex(ctx context.Context, fieldName string) error {
+	timeout := time.Duration(ms.cfg.IndexWaitTimeoutSec) * time.Second
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("Waiting up to %s for index on '%s' to finish building...", timeout, fieldName)
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		state, err := ms.milvusClient.GetIndexState(waitCtx, ms.cfg.CollectionName, fieldName)
+		if err != nil {
+			return fmt.Errorf("failed to get index state for field '%s': %w", fieldName, err)
+		}
+		switch commonpb.IndexState(state) {
+		case commonpb.IndexState_Finished:
+			log.Printf("Index on '%s' in collection '%s' is built.", fieldName, ms.cfg.CollectionName)
+			return nil
+		case commonpb.IndexState_Failed:
+			return fmt.Errorf("index build failed for field '%s' in collection %s", fieldName, ms.cfg.CollectionName)
+		}
+
+		total, indexed, err := ms.milvusClient.GetIndexBuildProgress(waitCtx, ms.cfg.CollectionName, fieldName)
+		if err == nil {
+			log.Printf("Index build progress for '%s': %d/%d rows (state %s)", fieldName, indexed, total, commonpb.IndexState(state))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			return fmt.Errorf("timed out after %s waiting for index on field '%s': %w", timeout, fieldName, waitCtx.Err())
+		}
+	}
+}
+
 func (ms *MilvusStorer) StoreDocument(ctx context.Context, doc *WebDocument) error {
 	if doc == nil {
 		return fmt.Errorf("cannot store nil document")
 	}
 	log.Printf("Attempting to store document for URL: %s with ID: %s", doc.URL, doc.HashID)
 
-	if len(doc.ContentVector) != 0 && len(doc.ContentVector) != ms.cfg.EmbeddingDimension {
-		return fmt.Errorf("document ID %s has content vector with dimension %d, but collection expects %d",
-			doc.HashID, len(doc.ContentVector), ms.cfg.EmbeddingDimension)
-	}
-
-	currentContentVector := doc.ContentVector
-	if len(currentContentVector) == 0 {
-		log.Printf("Warning: Document ID %s has no content vector. Inserting a zero vector as placeholder.", doc.HashID)
-		currentContentVector = make([]float32, ms.cfg.EmbeddingDimension)
-	}
-
-	hashIDs := []string{doc.HashID}
-	urls := []string{doc.URL}
-	htmlSources := []string{doc.HTMLSource}
-	mainContents := []string{doc.MainContent}
-	titles := []string{doc.Title}
-	metaDescriptions := []string{doc.MetaDescription}
-	canonicalURLs := []string{doc.CanonicalURL}
-	languages := []string{doc.Language}
-	publicationTimestamps := []int64{doc.PublicationTimestamp}
-	headingsTexts := []string{doc.HeadingsText}
-	crawledAts := []int64{doc.CrawledAt.Unix()}
-	contentVectors := [][]float32{currentContentVector}
-
-	colHashID := entity.NewColumnVarChar("hash_id", hashIDs)
-	colURL := entity.NewColumnVarChar("url", urls)
-	colHTMLSource := entity.NewColumnVarChar("html_source", htmlSources)
-	colMainContent := entity.NewColumnVarChar("main_content", mainContents)
-	colTitle := entity.NewColumnVarChar("title", titles)
-	colMetaDescription := entity.NewColumnVarChar("meta_description", metaDescriptions)
-	colCanonicalURL := entity.NewColumnVarChar("canonical_url", canonicalURLs)
-	colLanguage := entity.NewColumnVarChar("language", languages)
-	colPublicationTimestamp := entity.NewColumnInt64("publication_timestamp", publicationTimestamps)
-	colHeadingsText := entity.NewColumnVarChar("headings_text", headingsTexts)
-	colCrawledAt := entity.NewColumnInt64("crawled_at", crawledAts)
-	colContentVector := entity.NewColumnFloatVector("content_vector", ms.cfg.EmbeddingDimension, contentVectors)
-
-	_, err := ms.milvusClient.Insert(
-		ctx,
-		ms.cfg.CollectionName,
-		"",
-		colHashID,
-		colURL,
-		colHTMLSource,
-		colMainContent,
-		colTitle,
-		colMetaDescription,
-		colCanonicalURL,
-		colLanguage,
-		colPublicationTimestamp,
-		colHeadingsText,
-		colCrawledAt,
-		colContentVector,
-	)
+	columns, err := ms.documentColumns([]*WebDocument{doc})
+	if err != nil {
+		return err
+	}
 
+	err = ms.withRetry(ctx, "Insert", func(ctx context.Context) error {
+		_, err := ms.milvusClient.Insert(ctx, ms.cfg.CollectionName, "", columns...)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to insert document into Milvus (URL: %s, ID: %s): %w", doc.URL, doc.HashID, err)
 	}
 
 	log.Printf("Successfully inserted document ID: %s for URL: %s into Milvus collection '%s'", doc.HashID, doc.URL, ms.cfg.CollectionName)
 
-	err = ms.milvusClient.Flush(ctx, ms.cfg.CollectionName, false)
+	ms.countInserted(ctx, columns)
+	return nil
+}
+
+// UpsertDocuments inserts docs, replacing stored documents with the same hash_id.
+func (ms *MilvusStorer) UpsertDocuments(ctx context.Context, docs []*WebDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	columns, err := ms.documentColumns(docs)
 	if err != nil {
-		log.Printf("Warning: Failed to flush collection %s: %v", ms.cfg.CollectionName, err)
-	} else {
-		log.Printf("Collection %s flushed.", ms.cfg.CollectionName)
+		return err
 	}
+	err = ms.withRetry(ctx, "Upsert", func(ctx context.Context) error {
+		_, err := ms.milvusClient.Upsert(ctx, ms.cfg.CollectionName, "", columns...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upsert %d documents into Milvus: %w", len(docs), err)
+	}
+	if ms.flushThresholds() {
+		ms.countInserted(ctx, columns) // without thresholds, upserts are left for Milvus to seal
+	}
+	return nil
+}
+
+// documentColumns converts docs into insert columns for the stored fields,
+// applying the missing vector policy and field length limits.
+func (ms *MilvusStorer) documentColumns(docs []*WebDocument) ([]entity.Column, error) {
+	var (
+		hashIDs               []string
+		urls                  []string
+		htmlSources           []string
+		mainContents          []string
+		titles                []string
+		metaDescriptions      []string
+		canonicalURLs         []string
+		languages             []string
+		publicationTimestamps []int64
+		headingsTexts         []string
+		crawledAts            []int64
+		contentVectors        [][]float32
+		titleVectors          [][]float32
+		duplicateOfs          []string
+		tablesJSONs           []string
+		responseHeadersList   []string
+		extractionVersions    []string
+		hasVectors            []bool
+		qualityScores         []float32
+		needsEmbeddings       []bool
+		inboundLinks          []int64
+		hreflangJSONs         []string
+		outboundAnchorsJSONs  []string
+		bodyHashes            []string
+		htmlRetained          []bool
+		crawlDepths           []int64
+		contentFingerprints   []string
+		metaJSONs             []string
+		redirectChains        []string
+		gatedFlags            []bool
+		breadcrumbsJSONs      []string
+		seedURLs              []string
+		referrerURLs          []string
+		htmlTruncations       []string
+	)
+
+	for _, doc := range docs {
+		if len(doc.ContentVector) != 0 && len(doc.ContentVector) != ms.cfg.EmbeddingDimension {
+			return nil, fmt.Errorf("document ID %s has content vector with dimension %d, but collection expects %d",
+				doc.HashID, len(doc.ContentVector), ms.cfg.EmbeddingDimension)
+		}
 
+		currentContentVector := doc.ContentVector
+		hasVector := len(currentContentVector) != 0
+		if !hasVector {
+			// The vector field is required by the schema, so every policy that stores
+			// the row still inserts a zero placeholder; has_vector tells them apart.
+			switch strings.ToLower(ms.cfg.OnMissingVector) {
+			case MissingVectorError:
+				return nil, fmt.Errorf("document ID %s (URL: %s) has no content vector", doc.HashID, doc.URL)
+			case MissingVectorSkip:
+				log.Printf("Document ID %s has no content vector. Storing it unembedded for a later pass.", doc.HashID)
+			default:
+				log.Printf("Warning: Document ID %s has no content vector. Inserting a zero vector as placeholder.", doc.HashID)
+			}
+			currentContentVector = make([]float32, ms.cfg.EmbeddingDimension)
+		}
+
+		// Title vectors are optional per document, so a missing one is always
+		// a zero placeholder; has_vector only tracks the content vector.
+		titleVector := doc.TitleVector
+		if ms.stores(FieldTitleVector) {
+			if len(titleVector) != 0 && len(titleVector) != ms.cfg.TitleEmbeddingDimension {
+				return nil, fmt.Errorf("document ID %s has title vector with dimension %d, but collection expects %d",
+					doc.HashID, len(titleVector), ms.cfg.TitleEmbeddingDimension)
+			}
+			if len(titleVector) == 0 {
+				titleVector = make([]float32, ms.cfg.TitleEmbeddingDimension)
+			}
+		}
+
+		tablesJSON := doc.TablesJSON
+		if len(tablesJSON) > ms.cfg.MaxLengthTables {
+			log.Printf("Warning: tables_json for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(tablesJSON), ms.cfg.MaxLengthTables)
+			tablesJSON = ""
+		}
+
+		responseHeaders := doc.ResponseHeaders
+		if len(responseHeaders) > ms.cfg.MaxLengthHeaders {
+			log.Printf("Warning: response_headers for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(responseHeaders), ms.cfg.MaxLengthHeaders)
+			responseHeaders = ""
+		}
+
+		hreflangJSON := doc.HreflangJSON
+		if len(hreflangJSON) > ms.cfg.MaxLengthHreflang {
+			log.Printf("Warning: hreflang_json for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(hreflangJSON), ms.cfg.MaxLengthHreflang)
+			hreflangJSON = ""
+		}
+
+		outboundAnchorsJSON := doc.OutboundAnchorsJSON
+		if len(outboundAnchorsJSON) > ms.cfg.MaxLengthAnchors {
+			log.Printf("Warning: outbound_anchors_json for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(outboundAnchorsJSON), ms.cfg.MaxLengthAnchors)
+			outboundAnchorsJSON = ""
+		}
+
+		metaJSON := doc.MetaJSON
+		if len(metaJSON) > ms.cfg.MaxLengthMeta {
+			log.Printf("Warning: meta_json for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(metaJSON), ms.cfg.MaxLengthMeta)
+			metaJSON = ""
+		}
+
+		redirectChain := doc.RedirectChain
+		if len(redirectChain) > ms.cfg.MaxLengthRedirects {
+			log.Printf("Warning: redirect_chain for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(redirectChain), ms.cfg.MaxLengthRedirects)
+			redirectChain = ""
+		}
+
+		breadcrumbsJSON := doc.BreadcrumbsJSON
+		if len(breadcrumbsJSON) > ms.cfg.MaxLengthCrumbs {
+			log.Printf("Warning: breadcrumbs_json for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(breadcrumbsJSON), ms.cfg.MaxLengthCrumbs)
+			breadcrumbsJSON = ""
+		}
+
+		seedURL, referrerURL := doc.SeedURL, doc.ReferrerURL
+		if len(seedURL) > ms.cfg.MaxLengthURL {
+			log.Printf("Warning: seed_url for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(seedURL), ms.cfg.MaxLengthURL)
+			seedURL = ""
+		}
+		if len(referrerURL) > ms.cfg.MaxLengthURL {
+			log.Printf("Warning: referrer_url for document ID %s is %d bytes, exceeding max length %d. Dropping it.", doc.HashID, len(referrerURL), ms.cfg.MaxLengthURL)
+			referrerURL = ""
+		}
+
+		if len(doc.ExtractionVersion) > maxLengthExtractionVersion {
+			return nil, fmt.Errorf("document ID %s has extraction version of %d bytes, exceeding max length %d",
+				doc.HashID, len(doc.ExtractionVersion), maxLengthExtractionVersion)
+		}
+
+		hashIDs = append(hashIDs, doc.HashID)
+		urls = append(urls, doc.URL)
+		htmlSources = append(htmlSources, doc.HTMLSource)
+		mainContents = append(mainContents, doc.MainContent)
+		titles = append(titles, doc.Title)
+		metaDescriptions = append(metaDescriptions, doc.MetaDescription)
+		canonicalURLs = append(canonicalURLs, doc.CanonicalURL)
+		languages = append(languages, doc.Language)
+		publicationTimestamps = append(publicationTimestamps, doc.PublicationTimestamp)
+		headingsTexts = append(headingsTexts, doc.HeadingsText)
+		crawledAts = append(crawledAts, doc.CrawledAt.Unix())
+		contentVectors = append(contentVectors, currentContentVector)
+		titleVectors = append(titleVectors, titleVector)
+		duplicateOfs = append(duplicateOfs, doc.DuplicateOf)
+		tablesJSONs = append(tablesJSONs, tablesJSON)
+		responseHeadersList = append(responseHeadersList, responseHeaders)
+		extractionVersions = append(extractionVersions, doc.ExtractionVersion)
+		hasVectors = append(hasVectors, hasVector)
+		qualityScores = append(qualityScores, doc.QualityScore)
+		// Unembedded rows always need embedding; embedded ones only when flagged.
+		needsEmbeddings = append(needsEmbeddings, doc.NeedsEmbedding || !hasVector)
+		inboundLinks = append(inboundLinks, doc.InboundLinks)
+		hreflangJSONs = append(hreflangJSONs, hreflangJSON)
+		outboundAnchorsJSONs = append(outboundAnchorsJSONs, outboundAnchorsJSON)
+		bodyHashes = append(bodyHashes, doc.BodyHash)
+		htmlRetained = append(htmlRetained, doc.HTMLRetained)
+		crawlDepths = append(crawlDepths, doc.CrawlDepth)
+		contentFingerprints = append(contentFingerprints, doc.ContentFingerprint)
+		metaJSONs = append(metaJSONs, metaJSON)
+		redirectChains = append(redirectChains, redirectChain)
+		gatedFlags = append(gatedFlags, doc.Gated)
+		breadcrumbsJSONs = append(breadcrumbsJSONs, breadcrumbsJSON)
+		seedURLs = append(seedURLs, seedURL)
+		referrerURLs = append(referrerURLs, referrerURL)
+		htmlTruncations = append(htmlTruncations, doc.HTMLTruncation)
+	}
+
+	columns := []entity.Column{
+		entity.NewColumnVarChar("hash_id", hashIDs),
+		entity.NewColumnVarChar("url", urls),
+		entity.NewColumnVarChar("html_source", htmlSources),
+		entity.NewColumnVarChar("main_content", mainContents),
+		entity.NewColumnVarChar("title", titles),
+		entity.NewColumnVarChar("meta_description", metaDescriptions),
+		entity.NewColumnVarChar("canonical_url", canonicalURLs),
+		entity.NewColumnVarChar("language", languages),
+		entity.NewColumnInt64("publication_timestamp", publicationTimestamps),
+		entity.NewColumnVarChar("headings_text", headingsTexts),
+		entity.NewColumnInt64("crawled_at", crawledAts),
+		entity.NewColumnFloatVector("content_vector", ms.cfg.EmbeddingDimension, contentVectors),
+		entity.NewColumnVarChar("duplicate_of", duplicateOfs),
+		entity.NewColumnVarChar("tables_json", tablesJSONs),
+		entity.NewColumnVarChar("response_headers", responseHeadersList),
+		entity.NewColumnVarChar("extraction_version", extractionVersions),
+		entity.NewColumnBool("has_vector", hasVectors),
+		entity.NewColumnFloat("quality_score", qualityScores),
+		entity.NewColumnBool("needs_embedding", needsEmbeddings),
+		entity.NewColumnInt64("inbound_links", inboundLinks),
+		entity.NewColumnVarChar("hreflang_json", hreflangJSONs),
+		entity.NewColumnVarChar("outbound_anchors_json", outboundAnchorsJSONs),
+		entity.NewColumnVarChar("body_hash", bodyHashes),
+		entity.NewColumnBool("html_retained", htmlRetained),
+		entity.NewColumnInt64("crawl_depth", crawlDepths),
+		entity.NewColumnVarChar("content_fingerprint", contentFingerprints),
+		entity.NewColumnVarChar("meta_json", metaJSONs),
+		entity.NewColumnVarChar("redirect_chain", redirectChains),
+		entity.NewColumnBool("gated", gatedFlags),
+		entity.NewColumnVarChar("breadcrumbs_json", breadcrumbsJSONs),
+		entity.NewColumnVarChar("seed_url", seedURLs),
+		entity.NewColumnVarChar("referrer_url", referrerURLs),
+		entity.NewColumnVarChar("html_truncation", htmlTruncations),
+	}
+	if ms.stores(FieldTitleVector) {
+		columns = append(columns, entity.NewColumnFloatVector(FieldTitleVector, ms.cfg.TitleEmbeddingDimension, titleVectors))
+	}
+	stored := columns[:0]
+	for _, col := range columns {
+		if ms.stores(col.Name()) {
+			stored = append(stored, col)
+		}
+	}
+	return stored, nil
+}
+
+// Ready reports whether Milvus is reachable and the collection is loaded.
+func (ms *MilvusStorer) Ready(ctx context.Context) error {
+	state, err := ms.milvusClient.GetLoadState(ctx, ms.cfg.CollectionName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get load state of collection %s: %w", ms.cfg.CollectionName, err)
+	}
+	if state != entity.LoadStateLoaded {
+		return fmt.Errorf("collection %s is not loaded (load state %d)", ms.cfg.CollectionName, state)
+	}
 	return nil
 }
 
 // Close closes the Milvus client connection.
 func (ms *MilvusStorer) Close() {
+	if rows, bytes := ms.flushes.take(); rows > 0 && ms.milvusClient != nil {
+		log.Printf("Flushing collection %s with %d rows (%.1f MB) inserted since the last flush", ms.cfg.CollectionName, rows, float64(bytes)/(1<<20))
+		ms.flush(context.Background())
+	}
 	if ms.milvusClient != nil {
 		err := ms.milvusClient.Close()
 		if err != nil {
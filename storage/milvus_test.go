@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"crawlengine/config"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// fakeMilvus records the calls MilvusStorer makes to create a collection
+// and insert into it. Other client methods panic.
+type fakeMilvus struct {
+	client.Client
+
+	mu        sync.Mutex
+	shardsNum int32
+	inserts   int
+	flushes   int
+}
+
+func (f *fakeMilvus) HasCollection(ctx context.Context, name string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeMilvus) CreateCollection(ctx context.Context, schema *entity.Schema, shardsNum int32, opts ...client.CreateCollectionOption) error {
+	f.shardsNum = shardsNum
+	return nil
+}
+
+func (f *fakeMilvus) CreateIndex(ctx context.Context, collName, fieldName string, idx entity.Index, async bool, opts ...client.IndexOption) error {
+	return nil
+}
+
+func (f *fakeMilvus) DescribeIndex(ctx context.Context, collName, fieldName string, opts ...client.IndexOption) ([]entity.Index, error) {
+	return nil, nil
+}
+
+func (f *fakeMilvus) LoadCollection(ctx context.Context, collName string, async bool, opts ...client.LoadCollectionOption) error {
+	return nil
+}
+
+func (f *fakeMilvus) Insert(ctx context.Context, collName, partitionName string, columns ...entity.Column) (entity.Column, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inserts++
+	return nil, nil
+}
+
+func (f *fakeMilvus) Flush(ctx context.Context, collName string, async bool, opts ...client.FlushOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushes++
+	return nil
+}
+
+// newFakeMilvusStorer returns a storer of hash_id, url and content_vector
+// backed by a fakeMilvus.
+func newFakeMilvusStorer(cfg *config.MilvusConfig) (*MilvusStorer, *fakeMilvus) {
+	cfg.CollectionName = "documents"
+	cfg.EmbeddingDimension = 4
+	cfg.MaxLengthURL = 256
+	fake := &fakeMilvus{}
+	return &MilvusStorer{
+		milvusClient: fake,
+		cfg:          cfg,
+		fields:       map[string]bool{"hash_id": true, "url": true, FieldContentVector: true},
+	}, fake
+}
+
+func TestEnsureCollectionShardNum(t *testing.T) {
+	tests := []struct {
+		shardNum int
+		want     int32
+	}{
+		{0, entity.DefaultShardNumber},
+		{4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.shardNum), func(t *testing.T) {
+			ms, fake := newFakeMilvusStorer(&config.MilvusConfig{ShardNum: tt.shardNum})
+			if err := ms.ensureCollection(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if fake.shardsNum != tt.want {
+				t.Errorf("collection created with %d shards, want %d", fake.shardsNum, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreDocumentFlushThresholds(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MilvusConfig
+		want int
+	}{
+		{"every insert", config.MilvusConfig{}, 7},
+		{"every 3 rows", config.MilvusConfig{FlushEveryRows: 3}, 2},
+		{"every MB", config.MilvusConfig{FlushEveryMB: 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, fake := newFakeMilvusStorer(&tt.cfg)
+			for i := range 7 {
+				doc := &WebDocument{HashID: fmt.Sprint(i), URL: fmt.Sprintf("https://example.com/%d", i)}
+				if err := ms.StoreDocument(context.Background(), doc); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if fake.inserts != 7 {
+				t.Errorf("%d inserts, want 7", fake.inserts)
+			}
+			if fake.flushes != tt.want {
+				t.Errorf("%d flushes, want %d", fake.flushes, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureEmbeddingDimension(t *testing.T) {
+	tests := []struct {
+		name          string
+		titles        bool
+		policy        string
+		dim, titleDim int
+		wantErr       bool
+		wantName      string
+		wantTitleDim  int
+	}{
+		{"match", true, "", 4, 2, false, "documents", 2},
+		{"unknown dimensions", true, "", 0, 0, false, "documents", 2},
+		{"title mismatch", true, "", 4, 3, true, "documents", 2},
+		{"title not stored", false, "", 4, 3, false, "documents", 2},
+		{"content mismatch", false, "", 5, 0, true, "documents", 2},
+		{"new collection for titles", true, DimensionMismatchNewCollection, 4, 3, false, "documents_dim4_title3", 3},
+		{"new collection for content", false, DimensionMismatchNewCollection, 5, 0, false, "documents_dim5", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, _ := newFakeMilvusStorer(&config.MilvusConfig{TitleEmbeddingDimension: 2, OnDimensionMismatch: tt.policy})
+			ms.fields[FieldTitleVector] = tt.titles
+			err := ms.EnsureEmbeddingDimension(context.Background(), tt.dim, tt.titleDim)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EnsureEmbeddingDimension(%d, %d) = %v, want error %t", tt.dim, tt.titleDim, err, tt.wantErr)
+			}
+			if ms.cfg.CollectionName != tt.wantName || ms.cfg.TitleEmbeddingDimension != tt.wantTitleDim {
+				t.Errorf("collection %s with title dimension %d, want %s with %d", ms.cfg.CollectionName, ms.cfg.TitleEmbeddingDimension, tt.wantName, tt.wantTitleDim)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// NDJSONStorer writes each document as one line of JSON, e.g. to stdout for
+// piping a crawl into jq. Lines are written whole and unbuffered, so a
+// reader sees every document as soon as it is stored, and documents stored
+// concurrently never interleave.
+type NDJSONStorer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	written int
+}
+
+func NewNDJSONStorer(w io.Writer) *NDJSONStorer {
+	return &NDJSONStorer{w: w}
+}
+
+func (s *NDJSONStorer) StoreDocument(ctx context.Context, doc *WebDocument) error {
+	if doc == nil {
+		return fmt.Errorf("cannot store nil document")
+	}
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode document %s as JSON: %w", doc.URL, err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write document %s: %w", doc.URL, err)
+	}
+	s.written++
+	return nil
+}
+
+func (s *NDJSONStorer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Wrote %d documents as NDJSON", s.written)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestNDJSONStorerWritesOneLinePerDocument(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewNDJSONStorer(&buf)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc := &WebDocument{HashID: fmt.Sprint(i), URL: fmt.Sprintf("https://example.com/%d", i), MainContent: "line one\nline two"}
+			if err := s.StoreDocument(context.Background(), doc); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := s.StoreDocument(context.Background(), nil); err == nil {
+		t.Error("storing a nil document succeeded")
+	}
+	s.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var doc WebDocument
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			t.Fatalf("line %q is not a document: %v", scanner.Text(), err)
+		}
+		if doc.MainContent != "line one\nline two" {
+			t.Errorf("document %s main_content = %q", doc.HashID, doc.MainContent)
+		}
+		seen[doc.HashID] = true
+	}
+	if len(seen) != 20 || s.written != 20 {
+		t.Errorf("read %d documents, wrote %d, want 20", len(seen), s.written)
+	}
+}
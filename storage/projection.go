@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crawlengine/config"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// requiredFields are stored regardless of stored_fields: the primary key, the
+// vector, and the flags the missing vector policy and re-embedding job query.
+var requiredFields = []string{"hash_id", "content_vector", "has_vector", "needs_embedding"}
+
+// featureFields are fields the crawler only fills with an optional feature
+// enabled, mapped to whether it is. Like extended metadata fields, they are
+// left out of the collection while their feature is off, so a crawl using
+// none of them keeps the schema of collections created before they existed.
+func featureFields(cfg *config.CrawlerConfig) map[string]bool {
+	return map[string]bool{
+		"duplicate_of":          cfg.NearDuplicateMode != "" || cfg.TitleDedup || cfg.SemanticDedupMode != "",
+		"tables_json":           cfg.ExtractTables,
+		"response_headers":      cfg.StoreResponseHeaders,
+		"extraction_version":    cfg.ExtractionVersion != "",
+		"quality_score":         cfg.ComputeQualityScore,
+		"inbound_links":         cfg.StoreInboundLinks,
+		"hreflang_json":         cfg.ExtractHreflang,
+		"outbound_anchors_json": cfg.StoreOutboundAnchors,
+		"html_retained":         strings.EqualFold(cfg.HTMLStorage, "on_failure"),
+	}
+}
+
+// resolveStoredFields returns the set of fields to store for the stored_fields
+// setting: every document field if it is empty, otherwise the selected fields
+// plus the required ones. Unknown field names are an error.
+func resolveStoredFields(selected []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(documentOutputFields))
+	for _, name := range documentOutputFields {
+		known[name] = true
+	}
+	if len(selected) == 0 {
+		return known, nil
+	}
+
+	fields := make(map[string]bool, len(selected)+len(requiredFields))
+	for _, name := range selected {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown field '%s' in stored_fields", name)
+		}
+		fields[name] = true
+	}
+	for _, name := range requiredFields {
+		if !fields[name] {
+			log.Printf("Field '%s' is required and always stored; adding it to stored_fields.", name)
+			fields[name] = true
+		}
+	}
+	return fields, nil
+}
+
+// stores reports whether the collection stores the field name.
+func (ms *MilvusStorer) stores(name string) bool {
+	return ms.fields[name]
+}
+
+// outputFields returns the stored document fields, for queries.
+func (ms *MilvusStorer) outputFields() []string {
+	fields := make([]string, 0, len(ms.fields))
+	for _, name := range documentOutputFields {
+		if ms.fields[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// checkCollectionFields reconciles stored_fields with the schema of an
+// existing collection. Fields listed in stored_fields that the collection
+// lacks are an error, since Milvus cannot add fields to an existing
+// collection; other fields it lacks, such as has_vector in collections
+// created before it was added, are not stored, with a warning. Fields the
+// collection has but stored_fields leaves out must still be inserted, so
+// they keep being stored, with a warning. A shard count differing from
+// shard_num is warned about too, since it cannot be changed either, and a
+// vector dimension differing from the config is an error.
+func (ms *MilvusStorer) checkCollectionFields(ctx context.Context) error {
+	coll, err := ms.milvusClient.DescribeCollection(ctx, ms.cfg.CollectionName)
+	if err != nil {
+		return fmt.Errorf("failed to describe collection %s: %w", ms.cfg.CollectionName, err)
+	}
+	if ms.cfg.ShardNum > 0 && coll.ShardNum != int32(ms.cfg.ShardNum) {
+		log.Printf("Warning: collection '%s' has %d shards, not shard_num %d; the shard count is fixed at creation. Use a new collection_name to change it.",
+			ms.cfg.CollectionName, coll.ShardNum, ms.cfg.ShardNum)
+	}
+	existing := make(map[string]bool, len(coll.Schema.Fields))
+	for _, field := range coll.Schema.Fields {
+		existing[field.Name] = true
+		var want int
+		switch field.Name {
+		case FieldContentVector:
+			want = ms.cfg.EmbeddingDimension
+		case FieldTitleVector:
+			want = ms.cfg.TitleEmbeddingDimension
+		}
+		if dim := field.TypeParams[entity.TypeParamDim]; want > 0 && dim != strconv.Itoa(want) {
+			return fmt.Errorf("collection %s has %s of dimension %s, but the config expects %d; use a new collection_name or match the dimension in the config",
+				ms.cfg.CollectionName, field.Name, dim, want)
+		}
+	}
+
+	selected := make(map[string]bool, len(ms.cfg.StoredFields))
+	for _, name := range ms.cfg.StoredFields {
+		selected[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	var missing, absent, extra []string
+	for name := range ms.fields {
+		if existing[name] {
+			continue
+		}
+		if selected[name] {
+			missing = append(missing, name)
+		} else {
+			absent = append(absent, name)
+			delete(ms.fields, name)
+		}
+	}
+	for name := range existing {
+		if !ms.fields[name] {
+			extra = append(extra, name)
+			ms.fields[name] = true
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(absent)
+	sort.Strings(extra)
+
+	if len(missing) > 0 {
+		return fmt.Errorf("collection %s has no fields %s selected by stored_fields; use a new collection_name or remove them from stored_fields",
+			ms.cfg.CollectionName, strings.Join(missing, ", "))
+	}
+	if len(absent) > 0 {
+		log.Printf("Warning: collection '%s' has no fields %s, so they are not stored. Use a new collection_name to store them.",
+			ms.cfg.CollectionName, strings.Join(absent, ", "))
+		if !ms.stores("has_vector") {
+			log.Printf("Warning: collection '%s' has no has_vector field; documents stored without an embedding are not excluded from searches.", ms.cfg.CollectionName)
+		}
+		if !ms.stores("needs_embedding") {
+			log.Printf("Warning: collection '%s' has no needs_embedding field; documents cannot be marked for re-embedding.", ms.cfg.CollectionName)
+		}
+	}
+	if len(extra) > 0 {
+		log.Printf("Warning: collection '%s' was created with fields %s not selected by stored_fields; they are still stored. Use a new collection to drop them.",
+			ms.cfg.CollectionName, strings.Join(extra, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestFeatureFields(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.CrawlerConfig
+		want []string // enabled feature fields
+	}{
+		{"none", config.CrawlerConfig{}, nil},
+		{"near duplicates", config.CrawlerConfig{NearDuplicateMode: "mark"}, []string{"duplicate_of"}},
+		{"title dedup", config.CrawlerConfig{TitleDedup: true}, []string{"duplicate_of"}},
+		{"tables", config.CrawlerConfig{ExtractTables: true}, []string{"tables_json"}},
+		{"response headers", config.CrawlerConfig{StoreResponseHeaders: true}, []string{"response_headers"}},
+		{"extraction version", config.CrawlerConfig{ExtractionVersion: "2"}, []string{"extraction_version"}},
+		{"html on failure", config.CrawlerConfig{HTMLStorage: "ON_FAILURE"}, []string{"html_retained"}},
+		{"html always", config.CrawlerConfig{HTMLStorage: "always"}, nil},
+		{"inbound and anchors", config.CrawlerConfig{StoreInboundLinks: true, StoreOutboundAnchors: true}, []string{"inbound_links", "outbound_anchors_json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := featureFields(&tt.cfg)
+			want := make(map[string]bool)
+			for _, name := range tt.want {
+				want[name] = true
+			}
+			for name, enabled := range fields {
+				if enabled != want[name] {
+					t.Errorf("%s enabled = %t, want %t", name, enabled, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestFeatureFieldsAreDocumentFields(t *testing.T) {
+	known, err := resolveStoredFields(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range featureFields(&config.CrawlerConfig{}) {
+		if !known[name] {
+			t.Errorf("feature field %s is not a document field", name)
+		}
+	}
+	for _, name := range extendedMetadataFields {
+		if !known[name] {
+			t.Errorf("extended metadata field %s is not a document field", name)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// documentOutputFields are all document fields; queries return the stored ones.
+var documentOutputFields = []string{
+	"hash_id", "url", "html_source", "main_content", "title", "meta_description",
+	"canonical_url", "language", "publication_timestamp", "headings_text", "crawled_at",
+	"duplicate_of", "tables_json", "response_headers", "extraction_version", "has_vector", "quality_score", "needs_embedding", "inbound_links", "hreflang_json", "outbound_anchors_json", "body_hash", "html_retained", "crawl_depth", "content_fingerprint", "meta_json", "redirect_chain", "gated", "breadcrumbs_json", "seed_url", "referrer_url", "html_truncation", "content_vector", "title_vector",
+}
+
+// QueryByTimeRange returns up to limit documents crawled in [start, end).
+func (ms *MilvusStorer) QueryByTimeRange(ctx context.Context, start, end time.Time, limit int) ([]*WebDocument, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive, got %d", limit)
+	}
+	expr := fmt.Sprintf("crawled_at >= %d && crawled_at < %d", start.Unix(), end.Unix())
+	var rs client.ResultSet
+	err := ms.withRetry(ctx, "Query", func(ctx context.Context) error {
+		var err error
+		rs, err = ms.milvusClient.Query(ctx, ms.cfg.CollectionName, nil, expr, ms.outputFields(), client.WithLimit(int64(limit)))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents crawled between %s and %s: %w", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+	}
+	return documentsFromResultSet(rs)
+}
+
+// maxVersionsPerURL bounds the documents DocumentByURL compares; a URL has
+// one per distinct content stored for it.
+const maxVersionsPerURL = 100
+
+// DocumentByURL returns the most recently crawled document stored for
+// rawURL, or nil if there is none.
+func (ms *MilvusStorer) DocumentByURL(ctx context.Context, rawURL string) (*WebDocument, error) {
+	expr := "url == " + strconv.Quote(rawURL)
+	var rs client.ResultSet
+	err := ms.withRetry(ctx, "Query", func(ctx context.Context) error {
+		var err error
+		rs, err = ms.milvusClient.Query(ctx, ms.cfg.CollectionName, nil, expr, ms.outputFields(), client.WithLimit(maxVersionsPerURL))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents for URL %s: %w", rawURL, err)
+	}
+	docs, err := documentsFromResultSet(rs)
+	if err != nil {
+		return nil, err
+	}
+	var latest *WebDocument
+	for _, doc := range docs {
+		if latest == nil || doc.CrawledAt.After(latest.CrawledAt) {
+			latest = doc
+		}
+	}
+	return latest, nil
+}
+
+// IterateDocuments calls fn for every stored document, fetching pageSize
+// documents at a time in hash_id order, so memory use is bounded by the page
+// size rather than the collection size. Iteration stops at the first error
+// returned by fn, which is returned as is, or when ctx is canceled.
+//
+// Each page is a separate query continuing after the last hash_id seen, so
+// the iteration is not a snapshot: documents inserted while it runs are
+// visited only if their hash_id sorts after the current position, and every
+// page is read with the collection's default consistency level.
+func (ms *MilvusStorer) IterateDocuments(ctx context.Context, pageSize int, fn func(*WebDocument) error) error {
+	return ms.IterateDocumentsWhere(ctx, "", pageSize, fn)
+}
+
+// IterateDocumentsWhere is IterateDocuments restricted to documents matching
+// the Milvus boolean expression expr. Documents upserted by fn keep their
+// hash_id, which is behind the iteration position, so fn may safely rewrite
+// the documents it is given.
+func (ms *MilvusStorer) IterateDocumentsWhere(ctx context.Context, expr string, pageSize int, fn func(*WebDocument) error) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+	opt := client.NewQueryIteratorOption(ms.cfg.CollectionName).
+		WithExpr(expr).
+		WithOutputFields(ms.outputFields()...).
+		WithBatchSize(pageSize)
+	iter, err := ms.milvusClient.QueryIterator(ctx, opt)
+	if err != nil {
+		return fmt.Errorf("failed to start iterating collection %s: %w", ms.cfg.CollectionName, err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rs, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch next page from collection %s: %w", ms.cfg.CollectionName, err)
+		}
+		docs, err := documentsFromResultSet(rs)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if err := fn(doc); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// MarkNeedsEmbedding sets needs_embedding on every document matching expr,
+// e.g. `extraction_version != "2"`, so the re-embedding job picks them up.
+// It returns the number of documents marked.
+func (ms *MilvusStorer) MarkNeedsEmbedding(ctx context.Context, expr string, batchSize int) (int, error) {
+	if !ms.stores("needs_embedding") {
+		return 0, fmt.Errorf("collection %s has no needs_embedding field; use a new collection_name to re-embed documents", ms.cfg.CollectionName)
+	}
+	filter := "needs_embedding == false"
+	if expr != "" {
+		filter = "(" + expr + ") && " + filter
+	}
+
+	var batch []*WebDocument
+	marked := 0
+	flush := func() error {
+		if err := ms.UpsertDocuments(ctx, batch); err != nil {
+			return err
+		}
+		marked += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	err := ms.IterateDocumentsWhere(ctx, filter, batchSize, func(doc *WebDocument) error {
+		doc.NeedsEmbedding = true
+		batch = append(batch, doc)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return marked, fmt.Errorf("failed to mark documents matching '%s' for re-embedding: %w", expr, err)
+	}
+	return marked, nil
+}
+
+// UpdateInboundLinks raises inbound_links to the count in counts on the
+// stored documents of each URL in counts, upserting only the documents that
+// change, in batches. Counts are merged by keeping the larger value, since a
+// partial crawl sees only some of a page's referrers; documents of URLs
+// not in counts are left alone. It returns the number of documents updated.
+func (ms *MilvusStorer) UpdateInboundLinks(ctx context.Context, counts map[string]int64) (int, error) {
+	const batchSize = 100
+	urls := make([]string, 0, len(counts))
+	for url, n := range counts {
+		if n > 0 {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+
+	updated := 0
+	for start := 0; start < len(urls); start += batchSize {
+		batch := urls[start:min(start+batchSize, len(urls))]
+		quoted := make([]string, len(batch))
+		for i, url := range batch {
+			quoted[i] = strconv.Quote(url)
+		}
+		expr := "url in [" + strings.Join(quoted, ", ") + "]"
+		var rs client.ResultSet
+		err := ms.withRetry(ctx, "Query", func(ctx context.Context) error {
+			var err error
+			rs, err = ms.milvusClient.Query(ctx, ms.cfg.CollectionName, nil, expr, ms.outputFields(), client.WithLimit(int64(len(batch)*maxVersionsPerURL)))
+			return err
+		})
+		if err != nil {
+			return updated, fmt.Errorf("failed to query documents for inbound link counts: %w", err)
+		}
+		docs, err := documentsFromResultSet(rs)
+		if err != nil {
+			return updated, err
+		}
+		var changed []*WebDocument
+		for _, doc := range docs {
+			if count := counts[doc.URL]; count > doc.InboundLinks {
+				doc.InboundLinks = count
+				changed = append(changed, doc)
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		if err := ms.UpsertDocuments(ctx, changed); err != nil {
+			return updated, fmt.Errorf("failed to update inbound link counts: %w", err)
+		}
+		updated += len(changed)
+	}
+	return updated, nil
+}
+
+// ensureScalarIndex creates an STL_SORT index on an Int64 field if it has
+// none, for efficient range queries.
+func (ms *MilvusStorer) ensureScalarIndex(ctx context.Context, fieldName string) error {
+	if indexes, err := ms.milvusClient.DescribeIndex(ctx, ms.cfg.CollectionName, fieldName); err == nil && len(indexes) > 0 {
+		return nil
+	}
+	log.Printf("Creating scalar index for field '%s' in collection '%s'...", fieldName, ms.cfg.CollectionName)
+	if err := ms.milvusClient.CreateIndex(ctx, ms.cfg.CollectionName, fieldName, entity.NewScalarIndexWithType(entity.Sorted), false); err != nil {
+		return fmt.Errorf("failed to create scalar index on field '%s': %w", fieldName, err)
+	}
+	return nil
+}
+
+// documentsFromResultSet converts query results into documents. Fields
+// missing from the result set are left at their zero values.
+func documentsFromResultSet(rs client.ResultSet) ([]*WebDocument, error) {
+	docs := make([]*WebDocument, rs.Len())
+	for i := range docs {
+		docs[i] = &WebDocument{}
+	}
+
+	stringField := func(name string, set func(doc *WebDocument, value string)) error {
+		col := rs.GetColumn(name)
+		if col == nil {
+			return nil
+		}
+		for i, doc := range docs {
+			value, err := col.GetAsString(i)
+			if err != nil {
+				return fmt.Errorf("failed to read field '%s': %w", name, err)
+			}
+			set(doc, value)
+		}
+		return nil
+	}
+	int64Field := func(name string, set func(doc *WebDocument, value int64)) error {
+		col := rs.GetColumn(name)
+		if col == nil {
+			return nil
+		}
+		for i, doc := range docs {
+			value, err := col.GetAsInt64(i)
+			if err != nil {
+				return fmt.Errorf("failed to read field '%s': %w", name, err)
+			}
+			set(doc, value)
+		}
+		return nil
+	}
+
+	float32Field := func(name string, set func(doc *WebDocument, value float32)) error {
+		col := rs.GetColumn(name)
+		if col == nil {
+			return nil
+		}
+		for i, doc := range docs {
+			value, err := col.GetAsDouble(i)
+			if err != nil {
+				return fmt.Errorf("failed to read field '%s': %w", name, err)
+			}
+			set(doc, float32(value))
+		}
+		return nil
+	}
+
+	boolField := func(name string, set func(doc *WebDocument, value bool)) error {
+		col := rs.GetColumn(name)
+		if col == nil {
+			return nil
+		}
+		for i, doc := range docs {
+			value, err := col.GetAsBool(i)
+			if err != nil {
+				return fmt.Errorf("failed to read field '%s': %w", name, err)
+			}
+			set(doc, value)
+		}
+		return nil
+	}
+
+	fields := []error{
+		stringField("hash_id", func(d *WebDocument, v string) { d.HashID = v }),
+		stringField("url", func(d *WebDocument, v string) { d.URL = v }),
+		stringField("html_source", func(d *WebDocument, v string) { d.HTMLSource = v }),
+		stringField("main_content", func(d *WebDocument, v string) { d.MainContent = v }),
+		stringField("title", func(d *WebDocument, v string) { d.Title = v }),
+		stringField("meta_description", func(d *WebDocument, v string) { d.MetaDescription = v }),
+		stringField("canonical_url", func(d *WebDocument, v string) { d.CanonicalURL = v }),
+		stringField("language", func(d *WebDocument, v string) { d.Language = v }),
+		int64Field("publication_timestamp", func(d *WebDocument, v int64) { d.PublicationTimestamp = v }),
+		stringField("headings_text", func(d *WebDocument, v string) { d.HeadingsText = v }),
+		int64Field("crawled_at", func(d *WebDocument, v int64) { d.CrawledAt = time.Unix(v, 0).UTC() }),
+		stringField("duplicate_of", func(d *WebDocument, v string) { d.DuplicateOf = v }),
+		stringField("tables_json", func(d *WebDocument, v string) { d.TablesJSON = v }),
+		stringField("response_headers", func(d *WebDocument, v string) { d.ResponseHeaders = v }),
+		stringField("extraction_version", func(d *WebDocument, v string) { d.ExtractionVersion = v }),
+		float32Field("quality_score", func(d *WebDocument, v float32) { d.QualityScore = v }),
+		boolField("needs_embedding", func(d *WebDocument, v bool) { d.NeedsEmbedding = v }),
+		int64Field("inbound_links", func(d *WebDocument, v int64) { d.InboundLinks = v }),
+		stringField("hreflang_json", func(d *WebDocument, v string) { d.HreflangJSON = v }),
+		stringField("outbound_anchors_json", func(d *WebDocument, v string) { d.OutboundAnchorsJSON = v }),
+		stringField("body_hash", func(d *WebDocument, v string) { d.BodyHash = v }),
+		boolField("html_retained", func(d *WebDocument, v bool) { d.HTMLRetained = v }),
+		int64Field("crawl_depth", func(d *WebDocument, v int64) { d.CrawlDepth = v }),
+		stringField("content_fingerprint", func(d *WebDocument, v string) { d.ContentFingerprint = v }),
+		stringField("meta_json", func(d *WebDocument, v string) { d.MetaJSON = v }),
+		stringField("redirect_chain", func(d *WebDocument, v string) { d.RedirectChain = v }),
+		boolField("gated", func(d *WebDocument, v bool) { d.Gated = v }),
+		stringField("breadcrumbs_json", func(d *WebDocument, v string) { d.BreadcrumbsJSON = v }),
+		stringField("seed_url", func(d *WebDocument, v string) { d.SeedURL = v }),
+		stringField("referrer_url", func(d *WebDocument, v string) { d.ReferrerURL = v }),
+		stringField("html_truncation", func(d *WebDocument, v string) { d.HTMLTruncation = v }),
+	}
+	for _, err := range fields {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if col, ok := rs.GetColumn("content_vector").(*entity.ColumnFloatVector); ok {
+		for i, doc := range docs {
+			doc.ContentVector = col.Data()[i]
+		}
+	}
+	if col, ok := rs.GetColumn("title_vector").(*entity.ColumnFloatVector); ok {
+		for i, doc := range docs {
+			doc.TitleVector = col.Data()[i]
+		}
+	}
+	// Zero vector placeholders are not real embeddings; leave them out.
+	if col, ok := rs.GetColumn("has_vector").(*entity.ColumnBool); ok {
+		for i, doc := range docs {
+			if !col.Data()[i] {
+				doc.ContentVector = nil
+			}
+		}
+	}
+	return docs, nil
+}
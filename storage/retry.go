@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// withRetry runs fn with a per-attempt timeout of operation_timeout_sec and
+// retries it with exponential backoff, up to max_retries times, while it
+// fails with a retryable error. op names the operation in logs and errors.
+//
+// Note that an Insert whose attempt timed out may still have been applied by
+// Milvus, so a retried insert can leave a duplicate row; upserts are safe to
+// retry.
+func (ms *MilvusStorer) withRetry(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	timeout := time.Duration(ms.cfg.OperationTimeoutSec) * time.Second
+	backoff := time.Duration(ms.cfg.RetryBackoffMs) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		opCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			opCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		err := fn(opCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= ms.cfg.MaxRetries || ctx.Err() != nil || !isRetryable(err) {
+			if attempt > 0 {
+				return fmt.Errorf("milvus %s failed after %d attempts: %w", op, attempt+1, err)
+			}
+			return err
+		}
+
+		log.Printf("Warning: Milvus %s failed (attempt %d/%d), retrying in %s: %v", op, attempt+1, ms.cfg.MaxRetries+1, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether err is a transient Milvus failure: a timed out
+// attempt or a gRPC Unavailable, DeadlineExceeded or ResourceExhausted
+// (rate limited) status. Everything else, such as a schema mismatch or an
+// invalid expression, fails immediately.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		switch grpcErr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+			return true
+		}
+	}
+	return false
+}
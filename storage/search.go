@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// Vector fields Search can query.
+const (
+	// FieldContentVector embeds the title and main content.
+	FieldContentVector = "content_vector"
+	// FieldTitleVector embeds the title and headings, which suits navigational
+	// queries. It is only stored with title_vector enabled.
+	FieldTitleVector = "title_vector"
+)
+
+// Search parameters for the vector indexes; higher values are more accurate
+// but slower.
+const (
+	searchNprobe = 16 // IVF_FLAT clusters probed, capped at nlist
+	searchMinEf  = 64 // HNSW candidate list size, raised to topK if smaller
+)
+
+// SearchResult is a document found by Search, with its score under the
+// collection's metric: a distance for L2 (lower is closer), a similarity for
+// IP (higher is closer), or the reranked score for SearchCombined. Vector
+// fields are not returned.
+type SearchResult struct {
+	Document *WebDocument
+	Score    float32
+}
+
+// Search returns the topK documents whose vectorField, FieldContentVector or
+// FieldTitleVector, is nearest to vector, optionally restricted to documents
+// matching the Milvus boolean expression expr. Documents without a real
+// embedding (has_vector=false) are never returned.
+func (ms *MilvusStorer) Search(ctx context.Context, vectorField string, vector []float32, topK int, expr string) ([]SearchResult, error) {
+	found, err := ms.SearchBatch(ctx, vectorField, [][]float32{vector}, topK, expr)
+	if err != nil {
+		return nil, err
+	}
+	return found[0], nil
+}
+
+// SearchBatch is Search for several query vectors in one request, which
+// costs about as much as a single search; found[i] are the matches of
+// vectors[i].
+func (ms *MilvusStorer) SearchBatch(ctx context.Context, vectorField string, vectors [][]float32, topK int, expr string) ([][]SearchResult, error) {
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	queries := make([]entity.Vector, len(vectors))
+	for i, vector := range vectors {
+		if err := ms.checkSearchVector(vectorField, vector, topK); err != nil {
+			return nil, err
+		}
+		queries[i] = entity.FloatVector(vector)
+	}
+	var results []client.SearchResult
+	err := ms.withRetry(ctx, "Search", func(ctx context.Context) error {
+		var err error
+		results, err = ms.milvusClient.Search(ctx, ms.cfg.CollectionName, nil, ms.searchExpr(expr), ms.searchOutputFields(),
+			queries, vectorField, ms.metricType(), topK, ms.searchParam(topK))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s of collection %s: %w", vectorField, ms.cfg.CollectionName, err)
+	}
+	found := make([][]SearchResult, len(vectors))
+	for i := range found {
+		if i >= len(results) {
+			break // no matches for the remaining vectors
+		}
+		if found[i], err = searchResult(results[i]); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// Similarity converts a score returned by Search into a cosine similarity,
+// assuming normalized embeddings as most embedding models produce: IP
+// scores already are one, and L2 scores are squared distances, 2 - 2cos.
+func (ms *MilvusStorer) Similarity(score float32) float32 {
+	if ms.metricType() == entity.IP {
+		return score
+	}
+	return 1 - score/2
+}
+
+// SearchCombined searches the title and content vectors together, merging
+// the two result lists with Milvus' weighted reranker. titleWeight in [0, 1]
+// is the weight of the title match; the content match gets the rest.
+func (ms *MilvusStorer) SearchCombined(ctx context.Context, titleVector, contentVector []float32, titleWeight float64, topK int, expr string) ([]SearchResult, error) {
+	if titleWeight < 0 || titleWeight > 1 {
+		return nil, fmt.Errorf("title weight must be between 0 and 1, got %g", titleWeight)
+	}
+	if err := ms.checkSearchVector(FieldTitleVector, titleVector, topK); err != nil {
+		return nil, err
+	}
+	if err := ms.checkSearchVector(FieldContentVector, contentVector, topK); err != nil {
+		return nil, err
+	}
+
+	expr = ms.searchExpr(expr)
+	requests := []*client.ANNSearchRequest{
+		client.NewANNSearchRequest(FieldTitleVector, ms.metricType(), expr, []entity.Vector{entity.FloatVector(titleVector)}, ms.searchParam(topK), topK),
+		client.NewANNSearchRequest(FieldContentVector, ms.metricType(), expr, []entity.Vector{entity.FloatVector(contentVector)}, ms.searchParam(topK), topK),
+	}
+	reranker := client.NewWeightedReranker([]float64{titleWeight, 1 - titleWeight})
+	var results []client.SearchResult
+	err := ms.withRetry(ctx, "HybridSearch", func(ctx context.Context) error {
+		var err error
+		results, err = ms.milvusClient.HybridSearch(ctx, ms.cfg.CollectionName, nil, topK, ms.searchOutputFields(), reranker, requests)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search title and content vectors of collection %s: %w", ms.cfg.CollectionName, err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return searchResult(results[0]) // one query vector
+}
+
+func (ms *MilvusStorer) checkSearchVector(vectorField string, vector []float32, topK int) error {
+	if topK <= 0 {
+		return fmt.Errorf("topK must be positive, got %d", topK)
+	}
+	var dimension int
+	switch vectorField {
+	case FieldContentVector:
+		dimension = ms.cfg.EmbeddingDimension
+	case FieldTitleVector:
+		dimension = ms.cfg.TitleEmbeddingDimension
+	default:
+		return fmt.Errorf("unknown vector field '%s'", vectorField)
+	}
+	if !ms.stores(vectorField) {
+		return fmt.Errorf("collection %s does not store %s; enable title_vector in a new collection to search it", ms.cfg.CollectionName, vectorField)
+	}
+	if len(vector) != dimension {
+		return fmt.Errorf("query vector for %s has dimension %d, but collection expects %d", vectorField, len(vector), dimension)
+	}
+	return nil
+}
+
+// searchExpr restricts expr to documents with a real embedding, if the
+// collection tells them apart.
+func (ms *MilvusStorer) searchExpr(expr string) string {
+	if !ms.stores("has_vector") {
+		return expr
+	}
+	if expr == "" {
+		return "has_vector == true"
+	}
+	return "(" + expr + ") && has_vector == true"
+}
+
+// searchParam returns the search parameters for the configured index type.
+func (ms *MilvusStorer) searchParam(topK int) entity.SearchParam {
+	if strings.ToUpper(ms.cfg.IndexType) == "HNSW" {
+		param, _ := entity.NewIndexHNSWSearchParam(max(topK, searchMinEf))
+		return param
+	}
+	param, _ := entity.NewIndexIvfFlatSearchParam(min(searchNprobe, max(ms.cfg.Nlist, 1)))
+	return param
+}
+
+// searchOutputFields are the stored fields returned with search results: all
+// but the vectors, which callers rarely need and which make responses large.
+func (ms *MilvusStorer) searchOutputFields() []string {
+	var fields []string
+	for _, name := range ms.outputFields() {
+		if name != FieldContentVector && name != FieldTitleVector {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func searchResult(result client.SearchResult) ([]SearchResult, error) {
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	docs, err := documentsFromResultSet(result.Fields)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]SearchResult, len(docs))
+	for i, doc := range docs {
+		found[i] = SearchResult{Document: doc, Score: result.Scores[i]}
+	}
+	return found, nil
+}
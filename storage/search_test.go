@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"crawlengine/config"
+)
+
+func TestCheckSearchVector(t *testing.T) {
+	contentOnly := &MilvusStorer{
+		cfg:    &config.MilvusConfig{CollectionName: "docs", EmbeddingDimension: 3, TitleEmbeddingDimension: 2},
+		fields: map[string]bool{FieldContentVector: true},
+	}
+	withTitles := &MilvusStorer{
+		cfg:    contentOnly.cfg,
+		fields: map[string]bool{FieldContentVector: true, FieldTitleVector: true},
+	}
+	tests := []struct {
+		name    string
+		storer  *MilvusStorer
+		field   string
+		vector  []float32
+		topK    int
+		wantErr string
+	}{
+		{"content", contentOnly, FieldContentVector, []float32{1, 2, 3}, 5, ""},
+		{"title", withTitles, FieldTitleVector, []float32{1, 2}, 5, ""},
+		{"title not stored", contentOnly, FieldTitleVector, []float32{1, 2}, 5, "does not store title_vector"},
+		{"wrong dimension", withTitles, FieldTitleVector, []float32{1, 2, 3}, 5, "has dimension 3, but collection expects 2"},
+		{"unknown field", withTitles, "body_vector", []float32{1}, 5, "unknown vector field"},
+		{"no results requested", contentOnly, FieldContentVector, []float32{1, 2, 3}, 0, "topK must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.storer.checkSearchVector(tt.field, tt.vector, tt.topK)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkSearchVector: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkSearchVector error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// Storer persists crawled documents. MilvusStorer is the primary
+// implementation; the interface lets the crawler run against other sinks
+// and test doubles.
+type Storer interface {
+	StoreDocument(ctx context.Context, doc *WebDocument) error
+	Close()
+}
+
+// Wrapper is implemented by storers that pass documents on to other
+// storers, so optional capabilities of the wrapped storers, such as looking
+// up stored documents, can be found through the wrapper.
+type Wrapper interface {
+	Unwrap() []Storer
+}
+
+// Find calls match with s and then with each storer it wraps, depth first,
+// until match returns true, and reports whether it did. match typically
+// type-asserts an optional interface and keeps the result.
+func Find(s Storer, match func(Storer) bool) bool {
+	if s == nil {
+		return false
+	}
+	if match(s) {
+		return true
+	}
+	if w, ok := s.(Wrapper); ok {
+		for _, inner := range w.Unwrap() {
+			if Find(inner, match) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MultiStorer stores every document in each of its storers, e.g. Milvus for
+// vector search and Elasticsearch for full-text search.
+type MultiStorer struct {
+	storers []Storer
+}
+
+func NewMultiStorer(storers ...Storer) *MultiStorer {
+	return &MultiStorer{storers: storers}
+}
+
+// StoreDocument stores doc in every storer, even if an earlier one fails,
+// and returns the errors joined.
+func (m *MultiStorer) StoreDocument(ctx context.Context, doc *WebDocument) error {
+	var errs []error
+	for _, s := range m.storers {
+		if err := s.StoreDocument(ctx, doc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every storer, so the wrapped storers must not be closed
+// separately.
+func (m *MultiStorer) Close() {
+	for _, s := range m.storers {
+		s.Close()
+	}
+}
+
+// Unwrap returns the storers documents are stored in. Capabilities found
+// through it act on the one storer that has them, e.g. change detection
+// updates crawled_at in Milvus only.
+func (m *MultiStorer) Unwrap() []Storer {
+	return m.storers
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+type fakeStorer struct {
+	name   string
+	closed int
+}
+
+func (f *fakeStorer) StoreDocument(ctx context.Context, doc *WebDocument) error { return nil }
+func (f *fakeStorer) Close()                                                    { f.closed++ }
+
+// searchingStorer has an optional capability, like MilvusStorer's searches.
+type searchingStorer struct{ fakeStorer }
+
+func (s *searchingStorer) Similarity(score float32) float32 { return score }
+
+type similarity interface{ Similarity(score float32) float32 }
+
+func TestFindThroughWrappers(t *testing.T) {
+	milvus := &searchingStorer{fakeStorer{name: "milvus"}}
+	elastic := &fakeStorer{name: "elastic"}
+	tests := []struct {
+		name   string
+		storer Storer
+		want   bool
+	}{
+		{"direct", milvus, true},
+		{"without capability", elastic, false},
+		{"blob offload", NewBlobOffloadStorer(milvus, nil, 0), true},
+		{"multi", NewMultiStorer(elastic, milvus), true},
+		{"multi of blob offload", NewMultiStorer(NewBlobOffloadStorer(milvus, nil, 0), elastic), true},
+		{"multi without capability", NewMultiStorer(elastic, NewBlobOffloadStorer(elastic, nil, 0)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var found similarity
+			ok := Find(tt.storer, func(s Storer) bool {
+				var ok bool
+				found, ok = s.(similarity)
+				return ok
+			})
+			if ok != tt.want {
+				t.Fatalf("Find = %t, want %t", ok, tt.want)
+			}
+			if ok && found != similarity(milvus) {
+				t.Errorf("Find matched %v, want the wrapped Milvus storer", found)
+			}
+		})
+	}
+}
+
+func TestMultiStorerClosesEachStorerOnce(t *testing.T) {
+	milvus, elastic := &fakeStorer{name: "milvus"}, &fakeStorer{name: "elastic"}
+	NewMultiStorer(NewBlobOffloadStorer(milvus, nil, 0), elastic).Close()
+	for _, s := range []*fakeStorer{milvus, elastic} {
+		if s.closed != 1 {
+			t.Errorf("%s closed %d times, want 1", s.name, s.closed)
+		}
+	}
+}